@@ -28,6 +28,7 @@ type Configuration struct {
 	JWT_SECRET                   string
 	REFRESH_JWT_SECRET           string
 	EXPORTER                     bool
+	EXPORTER_MAX_SERIES          int
 	METADATA_DB_USER             string
 	METADATA_DB_PASS             string
 	METADATA_DB_DBNAME           string
@@ -54,6 +55,39 @@ type Configuration struct {
 	FUNCTIONS_ADMIN_SERVICE_PORT string
 	INITIAL_CONFIG_FILE          string
 	WS_HOST                      string
+	OTEL_EXPORTER_OTLP_ENDPOINT  string
+	OTEL_EXPORTER_OTLP_HEADERS   string
+	OTEL_SERVICE_NAME            string
+	TIERED_STORAGE_FS_ROOTS      string
+	WEBHOOK_ALLOW_PRIVATE_HOSTS  bool
+	OIDC_ENABLED                 bool
+	OIDC_ISSUER_URL              string
+	OIDC_CLIENT_ID               string
+	OIDC_CLIENT_SECRET           string
+	OIDC_REDIRECT_URL            string
+	OIDC_SCOPES                  string
+	OIDC_AUDIENCE                string
+	OIDC_USERNAME_CLAIM          string
+	OIDC_GROUPS_CLAIM            string
+	OIDC_USER_TYPE_MAPPING       string
+	OIDC_ROLE_MAPPING            string
+	OIDC_DEFAULT_USER_TYPE       string
+	LDAP_ENABLED                 bool
+	LDAP_URL                     string
+	LDAP_START_TLS               bool
+	LDAP_INSECURE_SKIP_VERIFY    bool
+	LDAP_TLS_CA                  string
+	LDAP_BIND_DN                 string
+	LDAP_BIND_PASSWORD           string
+	LDAP_BASE_DN                 string
+	LDAP_USER_FILTER             string
+	LDAP_FULL_NAME_ATTRIBUTE     string
+	LDAP_GROUP_ATTRIBUTE         string
+	LDAP_USER_TYPE_MAPPING       string
+	LDAP_ROLE_MAPPING            string
+	LDAP_DEFAULT_USER_TYPE       string
+	LDAP_TENANT_MAPPING          string
+	LDAP_CACHE_TTL_SECONDS       int
 }
 
 func GetConfig() Configuration {
@@ -101,9 +135,36 @@ func GetConfig() Configuration {
 	if configuration.FUNCTIONS_ADMIN_SERVICE_PORT == "" {
 		configuration.FUNCTIONS_ADMIN_SERVICE_PORT = "8880"
 	}
+	if configuration.EXPORTER_MAX_SERIES == 0 {
+		configuration.EXPORTER_MAX_SERIES = 10000
+	}
 	if configuration.WS_HOST == "" {
 		configuration.WS_HOST = "localhost:7770"
 	}
+	if configuration.OTEL_SERVICE_NAME == "" {
+		configuration.OTEL_SERVICE_NAME = "memphis"
+	}
+	if configuration.OIDC_SCOPES == "" {
+		configuration.OIDC_SCOPES = "openid profile email"
+	}
+	if configuration.OIDC_USERNAME_CLAIM == "" {
+		configuration.OIDC_USERNAME_CLAIM = "preferred_username"
+	}
+	if configuration.OIDC_GROUPS_CLAIM == "" {
+		configuration.OIDC_GROUPS_CLAIM = "groups"
+	}
+	if configuration.LDAP_USER_FILTER == "" {
+		configuration.LDAP_USER_FILTER = "(uid={username})"
+	}
+	if configuration.LDAP_FULL_NAME_ATTRIBUTE == "" {
+		configuration.LDAP_FULL_NAME_ATTRIBUTE = "cn"
+	}
+	if configuration.LDAP_GROUP_ATTRIBUTE == "" {
+		configuration.LDAP_GROUP_ATTRIBUTE = "memberOf"
+	}
+	if configuration.LDAP_CACHE_TTL_SECONDS == 0 {
+		configuration.LDAP_CACHE_TTL_SECONDS = 300
+	}
 
 	gin.SetMode(gin.ReleaseMode)
 	return configuration
@@ -64,7 +64,7 @@ func CloseMetadataDb(db MetadataStorage, l logger) {
 func AddIndexToTable(indexName, tableName, field string, MetadataDbClient MetadataStorage) error {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
-	addIndexQuery := "CREATE INDEX" + pgx.Identifier{indexName}.Sanitize() + "ON" + pgx.Identifier{tableName}.Sanitize() + "(" + pgx.Identifier{field}.Sanitize() + ")"
+	addIndexQuery := "CREATE INDEX IF NOT EXISTS " + pgx.Identifier{indexName}.Sanitize() + " ON " + pgx.Identifier{tableName}.Sanitize() + "(" + pgx.Identifier{field}.Sanitize() + ")"
 	db := MetadataDbClient.Client
 	_, err := db.Exec(ctx, addIndexQuery)
 	if err != nil {
@@ -239,7 +239,8 @@ func createTables(MetadataDbClient MetadataStorage) error {
 		ALTER TABLE schemas DROP CONSTRAINT IF EXISTS name;
 		ALTER TABLE schemas DROP CONSTRAINT IF EXISTS schemas_name_tenant_name_key;
 		ALTER TABLE schemas ADD CONSTRAINT schemas_name_tenant_name_key UNIQUE(name, tenant_name);
-		ALTER TYPE enum_type ADD VALUE 'avro';
+		ALTER TABLE schemas ADD COLUMN IF NOT EXISTS compatibility VARCHAR NOT NULL DEFAULT 'NONE';
+		ALTER TYPE enum_type ADD VALUE IF NOT EXISTS 'avro';
 		END IF;
 	END $$;`
 
@@ -251,6 +252,7 @@ func createTables(MetadataDbClient MetadataStorage) error {
 		type enum_type NOT NULL DEFAULT 'protobuf',
 		created_by_username VARCHAR NOT NULL,
 		tenant_name VARCHAR NOT NULL DEFAULT '$memphis',
+		compatibility VARCHAR NOT NULL DEFAULT 'NONE',
 		PRIMARY KEY (id),
 		CONSTRAINT fk_tenant_name_schemas
 			FOREIGN KEY(tenant_name)
@@ -315,6 +317,8 @@ func createTables(MetadataDbClient MetadataStorage) error {
 			ALTER TABLE consumers ADD COLUMN IF NOT EXISTS sdk VARCHAR NOT NULL DEFAULT 'unknown';
 			ALTER TABLE consumers ADD COLUMN IF NOT EXISTS app_id VARCHAR NOT NULL DEFAULT 'unknown';
 			UPDATE consumers SET app_id = connection_id WHERE app_id = 'unknown';
+			ALTER TABLE consumers ADD COLUMN IF NOT EXISTS is_paused BOOL NOT NULL DEFAULT false;
+			ALTER TABLE consumers ADD COLUMN IF NOT EXISTS paused_until TIMESTAMPTZ;
 			IF EXISTS (
 				SELECT 1
 				FROM information_schema.columns
@@ -347,6 +351,8 @@ func createTables(MetadataDbClient MetadataStorage) error {
 		version INTEGER NOT NULL DEFAULT 2,
 		sdk VARCHAR NOT NULL DEFAULT 'unknown',
 		app_id VARCHAR NOT NULL,
+		is_paused BOOL NOT NULL DEFAULT false,
+		paused_until TIMESTAMPTZ,
 		PRIMARY KEY (id),
 		CONSTRAINT fk_station_id
 			FOREIGN KEY(station_id)
@@ -374,6 +380,9 @@ func createTables(MetadataDbClient MetadataStorage) error {
 		ALTER TABLE stations ADD COLUMN IF NOT EXISTS dls_station VARCHAR NOT NULL DEFAULT '';
 		ALTER TABLE stations ADD COLUMN IF NOT EXISTS functions_lock_held BOOL NOT NULL DEFAULT false;
 		ALTER TABLE stations ADD COLUMN IF NOT EXISTS functions_locked_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+		ALTER TABLE stations ADD COLUMN IF NOT EXISTS broker_schema_enforcement BOOL NOT NULL DEFAULT false;
+		ALTER TABLE stations ADD COLUMN IF NOT EXISTS dls_retry_policy JSON NOT NULL DEFAULT '{}';
+		ALTER TABLE stations ADD COLUMN IF NOT EXISTS tiered_storage_format VARCHAR NOT NULL DEFAULT 'json';
 		DROP INDEX IF EXISTS unique_station_name_deleted;
 		CREATE UNIQUE INDEX unique_station_name_deleted ON stations(name, is_deleted, tenant_name) WHERE is_deleted = false;
 		END IF;
@@ -408,6 +417,9 @@ func createTables(MetadataDbClient MetadataStorage) error {
 		dls_station VARCHAR NOT NULL DEFAULT '',
 		functions_lock_held BOOL NOT NULL DEFAULT false,
 		functions_locked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		broker_schema_enforcement BOOL NOT NULL DEFAULT false,
+		dls_retry_policy JSON NOT NULL DEFAULT '{}',
+		tiered_storage_format VARCHAR NOT NULL DEFAULT 'json',
 		PRIMARY KEY (id),
 		CONSTRAINT fk_tenant_name_stations
 			FOREIGN KEY(tenant_name)
@@ -447,6 +459,25 @@ func createTables(MetadataDbClient MetadataStorage) error {
 			REFERENCES tenants(name)
 		);`
 
+	schemaReferencesTable := `CREATE TABLE IF NOT EXISTS schema_references(
+		id SERIAL NOT NULL,
+		schema_version_id INTEGER NOT NULL,
+		name VARCHAR NOT NULL,
+		referenced_schema_id INTEGER NOT NULL,
+		referenced_version_number INTEGER NOT NULL,
+		tenant_name VARCHAR NOT NULL DEFAULT '$memphis',
+		PRIMARY KEY (id),
+		UNIQUE(schema_version_id, name),
+		CONSTRAINT fk_schema_version_id
+			FOREIGN KEY(schema_version_id)
+			REFERENCES schema_versions(id)
+			ON DELETE CASCADE,
+		CONSTRAINT fk_tenant_name_schema_references
+			FOREIGN KEY(tenant_name)
+			REFERENCES tenants(name)
+		);
+		CREATE INDEX IF NOT EXISTS schema_references_referenced_schema_id ON schema_references(referenced_schema_id);`
+
 	alterProducersTable := `
 	DO $$
 	BEGIN
@@ -525,6 +556,8 @@ func createTables(MetadataDbClient MetadataStorage) error {
 			ALTER TABLE dls_messages ADD COLUMN IF NOT EXISTS producer_name VARCHAR NOT NULL DEFAULT '';
 			ALTER TABLE dls_messages ADD COLUMN IF NOT EXISTS partition_number INTEGER NOT NULL DEFAULT -1;
 			ALTER TABLE dls_messages ADD COLUMN IF NOT EXISTS attached_function_id INT NOT NULL DEFAULT -1;
+			ALTER TABLE dls_messages ADD COLUMN IF NOT EXISTS retry_attempts INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE dls_messages ADD COLUMN IF NOT EXISTS next_retry_at TIMESTAMPTZ;
 			DROP INDEX IF EXISTS dls_producer_id;
 			IF EXISTS (
 				SELECT 1 FROM information_schema.columns WHERE table_name = 'dls_messages' AND column_name = 'producer_id'
@@ -558,6 +591,8 @@ func createTables(MetadataDbClient MetadataStorage) error {
 		producer_name VARCHAR NOT NULL,
 		partition_number INTEGER NOT NULL DEFAULT -1,
 		attached_function_id INT NOT NULL DEFAULT -1,
+		retry_attempts INTEGER NOT NULL DEFAULT 0,
+		next_retry_at TIMESTAMPTZ,
 		PRIMARY KEY (id),
 		CONSTRAINT fk_station_id
 			FOREIGN KEY(station_id)
@@ -567,7 +602,9 @@ func createTables(MetadataDbClient MetadataStorage) error {
 			REFERENCES tenants(name)
 	);
 	CREATE INDEX IF NOT EXISTS dls_station_id
-		ON dls_messages(station_id);`
+		ON dls_messages(station_id);
+	CREATE INDEX IF NOT EXISTS dls_next_retry_at
+		ON dls_messages(next_retry_at) WHERE next_retry_at IS NOT NULL;`
 
 	asyncTasksTable := `
         CREATE TABLE IF NOT EXISTS async_tasks(
@@ -624,6 +661,55 @@ func createTables(MetadataDbClient MetadataStorage) error {
 		END IF;
 	END $$;`
 
+	cgLagThresholdsTable := `
+	CREATE TABLE IF NOT EXISTS cg_lag_thresholds(
+		id SERIAL NOT NULL,
+		station_id INTEGER NOT NULL,
+		cg_name VARCHAR NOT NULL DEFAULT '',
+		max_pending_msgs BIGINT NOT NULL DEFAULT 0,
+		max_oldest_unacked_age_ms BIGINT NOT NULL DEFAULT 0,
+		sustained_for_sec INTEGER NOT NULL DEFAULT 60,
+		tenant_name VARCHAR NOT NULL DEFAULT '$memphis',
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (id),
+		UNIQUE(station_id, cg_name, tenant_name)
+		);`
+
+	tieredStorageCheckpointsTable := `
+	CREATE TABLE IF NOT EXISTS tiered_storage_checkpoints(
+		id SERIAL NOT NULL,
+		tenant_name VARCHAR NOT NULL DEFAULT '$memphis',
+		integration_type VARCHAR NOT NULL,
+		stream_name VARCHAR NOT NULL,
+		high_water_mark BIGINT NOT NULL DEFAULT 0,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (id),
+		UNIQUE(tenant_name, integration_type, stream_name)
+		);`
+
+	apiKeysTable := `
+	CREATE TABLE IF NOT EXISTS api_keys(
+		id SERIAL NOT NULL,
+		name VARCHAR NOT NULL,
+		key_prefix VARCHAR NOT NULL,
+		key_hash VARCHAR NOT NULL,
+		scope VARCHAR NOT NULL DEFAULT 'read_only',
+		role_ids INTEGER[] NOT NULL DEFAULT '{}',
+		user_id INTEGER NOT NULL,
+		created_by VARCHAR NOT NULL,
+		tenant_name VARCHAR NOT NULL DEFAULT '$memphis',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		expires_at TIMESTAMPTZ,
+		last_used_at TIMESTAMPTZ,
+		rotated_at TIMESTAMPTZ,
+		PRIMARY KEY (id),
+		UNIQUE(key_hash),
+		UNIQUE(name, tenant_name),
+	CONSTRAINT fk_tenant_name_api_keys
+		FOREIGN KEY(tenant_name)
+		REFERENCES tenants(name)
+		);`
+
 	rolesTable := `
 	CREATE TYPE roles_enum AS ENUM ('management', 'application');
 	CREATE TABLE IF NOT EXISTS roles(
@@ -660,7 +746,7 @@ func createTables(MetadataDbClient MetadataStorage) error {
 	db := MetadataDbClient.Client
 	ctx := MetadataDbClient.Ctx
 
-	tables := []string{alterTenantsTable, tenantsTable, alterUsersTable, usersTable, alterAuditLogsTable, auditLogsTable, alterConfigurationsTable, configurationsTable, alterIntegrationsTable, integrationsTable, alterSchemasTable, schemasTable, alterTagsTable, tagsTable, alterStationsTable, stationsTable, alterDlsMsgsTable, dlsMessagesTable, alterConsumersTable, consumersTable, alterSchemaVerseTable, schemaVersionsTable, alterProducersTable, producersTable, alterConnectionsTable, asyncTasksTable, alterAsyncTasks, testEventsTable, functionsTable, attachedFunctionsTable, sharedLocksTable, functionsEngineWorkersTable, scheduledFunctionWorkersTable, connectorsEngineWorkersTable, connectorsConnectionsTable, connectorsTable, alterConnectorsTable, alterConnectorsConnectionsTable, rolesTable, permissionsTable}
+	tables := []string{alterTenantsTable, tenantsTable, alterUsersTable, usersTable, alterAuditLogsTable, auditLogsTable, alterConfigurationsTable, configurationsTable, alterIntegrationsTable, integrationsTable, alterSchemasTable, schemasTable, alterTagsTable, tagsTable, alterStationsTable, stationsTable, alterDlsMsgsTable, dlsMessagesTable, alterConsumersTable, consumersTable, alterSchemaVerseTable, schemaVersionsTable, schemaReferencesTable, alterProducersTable, producersTable, alterConnectionsTable, asyncTasksTable, alterAsyncTasks, testEventsTable, functionsTable, attachedFunctionsTable, sharedLocksTable, functionsEngineWorkersTable, scheduledFunctionWorkersTable, connectorsEngineWorkersTable, connectorsConnectionsTable, connectorsTable, alterConnectorsTable, alterConnectorsConnectionsTable, rolesTable, permissionsTable, cgLagThresholdsTable, tieredStorageCheckpointsTable, apiKeysTable}
 
 	for _, table := range tables {
 		_, err := db.Exec(ctx, table)
@@ -672,6 +758,15 @@ func createTables(MetadataDbClient MetadataStorage) error {
 			}
 		}
 	}
+
+	// indexes backing the dls messages search filters
+	dlsMsgsIndexes := [][2]string{{"dls_updated_at", "updated_at"}, {"dls_message_type", "message_type"}, {"dls_producer_name", "producer_name"}, {"dls_partition_number", "partition_number"}}
+	for _, index := range dlsMsgsIndexes {
+		err := AddIndexToTable(index[0], "dls_messages", index[1], MetadataDbClient)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -1547,6 +1642,14 @@ func GetAllStationsDetailsPerTenant(tenantName string) ([]models.ExtendedStation
 			&stationRes.TieredStorageEnabled,
 			&stationRes.TenantName,
 			&stationRes.ResendDisabled,
+			&stationRes.PartitionsList,
+			&stationRes.Version,
+			&stationRes.DlsStation,
+			&stationRes.FunctionsLockHeld,
+			&stationRes.FunctionsLockedAt,
+			&stationRes.BrokerSchemaEnforcement,
+			&stationRes.DlsRetryPolicy,
+			&stationRes.TieredStorageFormat,
 			&producer.ID,
 			&producer.Name,
 			&producer.StationId,
@@ -1677,6 +1780,9 @@ func GetAllStationsDetailsLight(tenantName string) ([]models.ExtendedStationLigh
 			&stationRes.DlsStation,
 			&stationRes.FunctionsLockHeld,
 			&stationRes.FunctionsLockedAt,
+			&stationRes.BrokerSchemaEnforcement,
+			&stationRes.DlsRetryPolicy,
+			&stationRes.TieredStorageFormat,
 			&stationRes.Activity,
 		); err != nil {
 			return []models.ExtendedStationLight{}, err
@@ -1857,11 +1963,8 @@ func GetAllStationsDetails() ([]models.ExtendedStation, error) {
 	COALESCE(p.station_id, 0), 
 	COALESCE(p.type, 'application'), 
 	COALESCE(p.connection_id, ''), 
-	COALESCE(p.created_by, 0), 
-	COALESCE(p.created_by_username, ''), 
 	COALESCE(p.is_active, false), 
-	COALESCE(p.created_at, CURRENT_TIMESTAMP), 
-	COALESCE(p.is_deleted, false),
+	COALESCE(p.updated_at, CURRENT_TIMESTAMP), 
 	COALESCE(p.tenant_name, ''),  
 	COALESCE(c.id, 0),  
 	COALESCE(c.name, ''), 
@@ -1870,11 +1973,8 @@ func GetAllStationsDetails() ([]models.ExtendedStation, error) {
 	COALESCE(c.connection_id, ''),
 	COALESCE(c.consumers_group, ''),
 	COALESCE(c.max_ack_time_ms, 0), 
-	COALESCE(c.created_by, 0), 
-	COALESCE(c.created_by_username, ''), 
 	COALESCE(c.is_active, false), 
-	COALESCE(c.created_at, CURRENT_TIMESTAMP), 
-	COALESCE(c.is_deleted, false), 
+	COALESCE(c.updated_at, CURRENT_TIMESTAMP), 
 	COALESCE(c.max_msg_deliveries, 0), 
 	COALESCE(c.start_consume_from_seq, 0),
 	COALESCE(c.last_msgs, 0),
@@ -1924,8 +2024,15 @@ func GetAllStationsDetails() ([]models.ExtendedStation, error) {
 			&stationRes.DlsConfigurationSchemaverse,
 			&stationRes.TieredStorageEnabled,
 			&stationRes.TenantName,
+			&stationRes.ResendDisabled,
+			&stationRes.PartitionsList,
+			&stationRes.Version,
+			&stationRes.DlsStation,
 			&stationRes.FunctionsLockHeld,
 			&stationRes.FunctionsLockedAt,
+			&stationRes.BrokerSchemaEnforcement,
+			&stationRes.DlsRetryPolicy,
+			&stationRes.TieredStorageFormat,
 			&producer.ID,
 			&producer.Name,
 			&producer.StationId,
@@ -2179,6 +2286,156 @@ func UpdateStationDlsConfig(stationName string, poison bool, schemaverse bool, t
 	return nil
 }
 
+func UpdateStationBrokerSchemaEnforcement(stationName string, enforced bool, tenantName string) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	query := `UPDATE stations SET broker_schema_enforcement = $2
+	WHERE name = $1 AND is_deleted = false AND tenant_name=$3`
+	stmt, err := conn.Conn().Prepare(ctx, "update_station_broker_schema_enforcement", query)
+	if err != nil {
+		return err
+	}
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
+	}
+	_, err = conn.Conn().Query(ctx, stmt.Name, stationName, enforced, tenantName)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func UpdateStationDlsRetryPolicy(stationName string, policy models.DlsRetryPolicy, tenantName string) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	query := `UPDATE stations SET dls_retry_policy = $2
+	WHERE name = $1 AND is_deleted = false AND tenant_name=$3`
+	stmt, err := conn.Conn().Prepare(ctx, "update_station_dls_retry_policy", query)
+	if err != nil {
+		return err
+	}
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
+	}
+	_, err = conn.Conn().Exec(ctx, stmt.Name, stationName, policy, tenantName)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func UpdateStationTieredStorageFormat(stationName string, format string, tenantName string) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	query := `UPDATE stations SET tiered_storage_format = $2
+	WHERE name = $1 AND is_deleted = false AND tenant_name=$3`
+	stmt, err := conn.Conn().Prepare(ctx, "update_station_tiered_storage_format", query)
+	if err != nil {
+		return err
+	}
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
+	}
+	_, err = conn.Conn().Exec(ctx, stmt.Name, stationName, format, tenantName)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func UpdateStationPartitions(stationName string, partitionsList []int, version int, tenantName string) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	query := `UPDATE stations SET partitions = $2, version = $3, updated_at = NOW()
+	WHERE name = $1 AND is_deleted = false AND tenant_name=$4`
+	stmt, err := conn.Conn().Prepare(ctx, "update_station_partitions", query)
+	if err != nil {
+		return err
+	}
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
+	}
+	_, err = conn.Conn().Exec(ctx, stmt.Name, stationName, partitionsList, version, tenantName)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func GetBrokerSchemaEnforcedStations() ([]models.SchemaEnforcedStation, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return []models.SchemaEnforcedStation{}, err
+	}
+	defer conn.Release()
+	query := `SELECT s.name, s.tenant_name, s.dls_configuration_schemaverse, sc.type, v.id, v.version_number, v.schema_content, v.msg_struct_name
+	FROM stations AS s
+	INNER JOIN schemas AS sc ON sc.name = s.schema_name AND sc.tenant_name = s.tenant_name
+	INNER JOIN schema_versions AS v ON v.schema_id = sc.id AND v.active = true
+	WHERE s.broker_schema_enforcement = true AND s.is_deleted = false`
+	stmt, err := conn.Conn().Prepare(ctx, "get_broker_schema_enforced_stations", query)
+	if err != nil {
+		return []models.SchemaEnforcedStation{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name)
+	if err != nil {
+		return []models.SchemaEnforcedStation{}, err
+	}
+	defer rows.Close()
+	stations, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.SchemaEnforcedStation])
+	if err != nil {
+		return []models.SchemaEnforcedStation{}, err
+	}
+	return stations, nil
+}
+
+func GetPartitionedStations() ([]models.PartitionedStation, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return []models.PartitionedStation{}, err
+	}
+	defer conn.Release()
+	query := `SELECT name, tenant_name, partitions FROM stations WHERE version > 0 AND is_deleted = false AND cardinality(partitions) > 0`
+	stmt, err := conn.Conn().Prepare(ctx, "get_partitioned_stations", query)
+	if err != nil {
+		return []models.PartitionedStation{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name)
+	if err != nil {
+		return []models.PartitionedStation{}, err
+	}
+	defer rows.Close()
+	stations, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.PartitionedStation])
+	if err != nil {
+		return []models.PartitionedStation{}, err
+	}
+	return stations, nil
+}
+
 func UpdateStationsOfDeletedUser(userId int, tenantName string) error {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
@@ -3070,9 +3327,13 @@ func InsertNewConsumer(name string,
 		partitions,
 		version,
 		sdk,
-		app_id)
-    VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) 
-	RETURNING id`
+		app_id,
+		is_paused,
+		paused_until)
+    VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16,
+		COALESCE((SELECT true FROM consumers WHERE station_id = $2 AND consumers_group = $4 AND is_paused = true LIMIT 1), false),
+		(SELECT paused_until FROM consumers WHERE station_id = $2 AND consumers_group = $4 AND is_paused = true LIMIT 1))
+	RETURNING id, is_paused, paused_until`
 
 	stmt, err := conn.Conn().Prepare(ctx, "insert_new_consumer", query)
 	if err != nil {
@@ -3080,6 +3341,8 @@ func InsertNewConsumer(name string,
 	}
 
 	var consumerId int
+	var isPaused bool
+	var pausedUntil *time.Time
 	updatedAt := time.Now()
 	isActive := true
 
@@ -3090,7 +3353,7 @@ func InsertNewConsumer(name string,
 	}
 	defer rows.Close()
 	for rows.Next() {
-		err := rows.Scan(&consumerId)
+		err := rows.Scan(&consumerId, &isPaused, &pausedUntil)
 		if err != nil {
 			return models.Consumer{}, err
 		}
@@ -3128,6 +3391,8 @@ func InsertNewConsumer(name string,
 		LastMessages:        lastMessages,
 		TenantName:          tenantName,
 		PartitionsList:      partitionsList,
+		IsPaused:            isPaused,
+		PausedUntil:         pausedUntil,
 	}
 	return newConsumer, nil
 }
@@ -3169,7 +3434,7 @@ func GetAllConsumersByStation(stationId int) ([]models.ExtendedConsumer, error)
 	}
 	defer conn.Release()
 	query := `SELECT DISTINCT ON (c.name, c.consumers_group) c.id, c.name, c.updated_at, c.is_active, c.consumers_group, c.max_ack_time_ms, c.max_msg_deliveries, s.name, c.partitions,
-				COUNT (CASE WHEN c.is_active THEN 1 END) OVER (PARTITION BY c.name) AS count, c.version, c.sdk
+				COUNT (CASE WHEN c.is_active THEN 1 END) OVER (PARTITION BY c.name) AS count, c.version, c.sdk, c.is_paused, c.paused_until
 				FROM consumers AS c
 				LEFT JOIN stations AS s ON s.id = c.station_id
 				WHERE c.station_id = $1 AND c.type = 'application' ORDER BY c.name, c.consumers_group, c.updated_at DESC
@@ -3193,163 +3458,359 @@ func GetAllConsumersByStation(stationId int) ([]models.ExtendedConsumer, error)
 	return consumers, nil
 }
 
-func GetConsumersForGraph(tenantName string) ([]models.ConsumerForGraph, error) {
+// UpdateCgPauseState marks every consumer of the consumer group, returns false when the group does not exist
+func UpdateCgPauseState(stationId int, cgName string, isPaused bool, pausedUntil *time.Time) (bool, error) {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
 	conn, err := MetadataDbClient.Client.Acquire(ctx)
 	if err != nil {
-		return []models.ConsumerForGraph{}, err
+		return false, err
 	}
 	defer conn.Release()
-	query := `SELECT c.name, c.consumers_group, c.station_id, c.app_id, c.type
-				FROM consumers AS c
-				WHERE c.tenant_name = $1 AND c.is_active = true
-				ORDER BY c.name, c.station_id DESC
-				LIMIT 10000;`
-	stmt, err := conn.Conn().Prepare(ctx, "get_consumers_for_graph", query)
-	if err != nil {
-		return []models.ConsumerForGraph{}, err
-	}
-	rows, err := conn.Conn().Query(ctx, stmt.Name, tenantName)
+	query := `UPDATE consumers SET is_paused = $3, paused_until = $4 WHERE station_id = $1 AND consumers_group = $2 AND type = 'application'`
+	stmt, err := conn.Conn().Prepare(ctx, "update_cg_pause_state", query)
 	if err != nil {
-		return []models.ConsumerForGraph{}, err
+		return false, err
 	}
-	defer rows.Close()
-	cgs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.ConsumerForGraph])
+	res, err := conn.Conn().Exec(ctx, stmt.Name, stationId, cgName, isPaused, pausedUntil)
 	if err != nil {
-		return []models.ConsumerForGraph{}, err
-	}
-	if len(cgs) == 0 {
-		return []models.ConsumerForGraph{}, nil
+		return false, err
 	}
-	return cgs, nil
+	return res.RowsAffected() > 0, nil
 }
 
-func DeleteConsumerByNameStationIDAndConnID(connectionId, name string, stationId int) (bool, models.Consumer, error) {
+func GetPausedCgs() ([]models.PausedCg, error) {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
 	conn, err := MetadataDbClient.Client.Acquire(ctx)
 	if err != nil {
-		return false, models.Consumer{}, err
+		return []models.PausedCg{}, err
 	}
 	defer conn.Release()
-	query := ` DELETE FROM consumers WHERE ctid = ( SELECT ctid FROM consumers WHERE connection_id = $1 AND name = $2 AND station_id = $3 LIMIT 1) RETURNING *`
-	deleteStmt, err := conn.Conn().Prepare(ctx, "delete_consumers", query)
+	query := `SELECT DISTINCT c.consumers_group, s.name, c.tenant_name, c.paused_until, s.partitions
+	FROM consumers AS c
+	INNER JOIN stations AS s ON s.id = c.station_id
+	WHERE c.is_paused = true AND s.is_deleted = false`
+	stmt, err := conn.Conn().Prepare(ctx, "get_paused_cgs", query)
 	if err != nil {
-		return false, models.Consumer{}, err
+		return []models.PausedCg{}, err
 	}
-	rows, err := conn.Conn().Query(ctx, deleteStmt.Name, connectionId, name, stationId)
+	rows, err := conn.Conn().Query(ctx, stmt.Name)
 	if err != nil {
-		return false, models.Consumer{}, err
+		return []models.PausedCg{}, err
 	}
 	defer rows.Close()
-	consumers, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Consumer])
+	cgs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.PausedCg])
 	if err != nil {
-		return false, models.Consumer{}, err
-	}
-	if len(consumers) == 0 {
-		return false, models.Consumer{}, err
+		return []models.PausedCg{}, err
 	}
-	return true, consumers[0], nil
+	return cgs, nil
 }
 
-func DeleteConsumerByNameStationIDAndType(consumerType, name string, stationId int) (bool, models.Consumer, error) {
+// ResumeExpiredCgs clears pauses whose paused_until has passed and returns the resumed consumer groups
+func ResumeExpiredCgs() ([]models.PausedCg, error) {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
 	conn, err := MetadataDbClient.Client.Acquire(ctx)
 	if err != nil {
-		return false, models.Consumer{}, err
+		return []models.PausedCg{}, err
 	}
 	defer conn.Release()
-	query := ` DELETE FROM consumers WHERE ctid = ( SELECT ctid FROM consumers WHERE type = $1 AND name = $2 AND station_id = $3 LIMIT 1) RETURNING *`
-	deleteStmt, err := conn.Conn().Prepare(ctx, "delete_consumers", query)
+	query := `WITH resumed AS (
+		UPDATE consumers SET is_paused = false, paused_until = NULL
+		WHERE is_paused = true AND paused_until IS NOT NULL AND paused_until <= NOW()
+		RETURNING consumers_group, station_id, tenant_name
+	)
+	SELECT DISTINCT r.consumers_group, s.name, r.tenant_name, NULL::TIMESTAMPTZ, s.partitions
+	FROM resumed AS r
+	INNER JOIN stations AS s ON s.id = r.station_id`
+	stmt, err := conn.Conn().Prepare(ctx, "resume_expired_cgs", query)
 	if err != nil {
-		return false, models.Consumer{}, err
+		return []models.PausedCg{}, err
 	}
-	rows, err := conn.Conn().Query(ctx, deleteStmt.Name, consumerType, name, stationId)
+	rows, err := conn.Conn().Query(ctx, stmt.Name)
 	if err != nil {
-		return false, models.Consumer{}, err
+		return []models.PausedCg{}, err
 	}
 	defer rows.Close()
-	consumers, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Consumer])
+	cgs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.PausedCg])
 	if err != nil {
-		return false, models.Consumer{}, err
-	}
-	if len(consumers) == 0 {
-		return false, models.Consumer{}, err
+		return []models.PausedCg{}, err
 	}
-	return true, consumers[0], nil
+	return cgs, nil
 }
 
-func DeleteConsumerByNameAndStationId(name string, stationId int) (bool, models.Consumer, error) {
+func UpsertCgLagThreshold(threshold models.CgLagThreshold) (models.CgLagThreshold, error) {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
 	conn, err := MetadataDbClient.Client.Acquire(ctx)
 	if err != nil {
-		return false, models.Consumer{}, err
+		return models.CgLagThreshold{}, err
 	}
 	defer conn.Release()
-	query := ` DELETE FROM consumers WHERE ctid = ( SELECT ctid FROM consumers WHERE name = $1 AND station_id = $2 LIMIT 1) RETURNING *`
-	deleteStmt, err := conn.Conn().Prepare(ctx, "delete_consumers", query)
+	query := `INSERT INTO cg_lag_thresholds (station_id, cg_name, max_pending_msgs, max_oldest_unacked_age_ms, sustained_for_sec, tenant_name, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, NOW())
+	ON CONFLICT (station_id, cg_name, tenant_name) DO UPDATE SET max_pending_msgs = EXCLUDED.max_pending_msgs,
+	max_oldest_unacked_age_ms = EXCLUDED.max_oldest_unacked_age_ms, sustained_for_sec = EXCLUDED.sustained_for_sec, updated_at = NOW()
+	RETURNING *`
+	stmt, err := conn.Conn().Prepare(ctx, "upsert_cg_lag_threshold", query)
 	if err != nil {
-		return false, models.Consumer{}, err
+		return models.CgLagThreshold{}, err
 	}
-	rows, err := conn.Conn().Query(ctx, deleteStmt.Name, name, stationId)
+	tenantName := threshold.TenantName
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, threshold.StationId, threshold.CgName, threshold.MaxPendingMsgs, threshold.MaxOldestUnackedAgeMs, threshold.SustainedForSec, tenantName)
 	if err != nil {
-		return false, models.Consumer{}, err
+		return models.CgLagThreshold{}, err
 	}
 	defer rows.Close()
-	consumers, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Consumer])
+	thresholds, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.CgLagThreshold])
 	if err != nil {
-		return false, models.Consumer{}, err
+		return models.CgLagThreshold{}, err
 	}
-	if len(consumers) == 0 {
-		return false, models.Consumer{}, err
+	if len(thresholds) == 0 {
+		return models.CgLagThreshold{}, errors.New("cg lag threshold was not saved")
 	}
-	return true, consumers[0], nil
+	return thresholds[0], nil
 }
 
-func DeleteAllConsumersByStationID(stationId int) error {
+func DeleteCgLagThreshold(stationId int, cgName string, tenantName string) (bool, error) {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
 	conn, err := MetadataDbClient.Client.Acquire(ctx)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer conn.Release()
-	query := `DELETE FROM consumers WHERE station_id = $1`
-	stmt, err := conn.Conn().Prepare(ctx, "delete_consumers_by_station_id", query)
+	query := `DELETE FROM cg_lag_thresholds WHERE station_id = $1 AND cg_name = $2 AND tenant_name = $3`
+	stmt, err := conn.Conn().Prepare(ctx, "delete_cg_lag_threshold", query)
 	if err != nil {
-		return err
+		return false, err
 	}
-	_, err = conn.Conn().Query(ctx, stmt.Name, stationId)
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
+	}
+	res, err := conn.Conn().Exec(ctx, stmt.Name, stationId, cgName, tenantName)
 	if err != nil {
-		return err
+		return false, err
 	}
-	return nil
+	return res.RowsAffected() > 0, nil
 }
 
-func DeleteDLSMessagesByStationID(stationId int) error {
+func GetCgLagThresholdsByStation(stationId int, tenantName string) ([]models.CgLagThreshold, error) {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
 	conn, err := MetadataDbClient.Client.Acquire(ctx)
 	if err != nil {
-		return err
+		return []models.CgLagThreshold{}, err
 	}
 	defer conn.Release()
-	query := `DELETE FROM dls_messages WHERE station_id = $1`
-	stmt, err := conn.Conn().Prepare(ctx, "delete_messages_from_chosen_dls", query)
+	query := `SELECT * FROM cg_lag_thresholds WHERE station_id = $1 AND tenant_name = $2 ORDER BY cg_name`
+	stmt, err := conn.Conn().Prepare(ctx, "get_cg_lag_thresholds_by_station", query)
 	if err != nil {
-		return err
+		return []models.CgLagThreshold{}, err
 	}
-	_, err = conn.Conn().Query(ctx, stmt.Name, stationId)
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, stationId, tenantName)
 	if err != nil {
-		return err
+		return []models.CgLagThreshold{}, err
 	}
-	return nil
+	defer rows.Close()
+	thresholds, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.CgLagThreshold])
+	if err != nil {
+		return []models.CgLagThreshold{}, err
+	}
+	return thresholds, nil
 }
 
-func CountActiveConsumersInCG(consumersGroup string, stationId int) (int64, error) {
+func GetAllCgLagThresholds() ([]models.CgLagThreshold, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return []models.CgLagThreshold{}, err
+	}
+	defer conn.Release()
+	query := `SELECT t.* FROM cg_lag_thresholds AS t
+	INNER JOIN stations AS s ON s.id = t.station_id
+	WHERE s.is_deleted = false`
+	stmt, err := conn.Conn().Prepare(ctx, "get_all_cg_lag_thresholds", query)
+	if err != nil {
+		return []models.CgLagThreshold{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name)
+	if err != nil {
+		return []models.CgLagThreshold{}, err
+	}
+	defer rows.Close()
+	thresholds, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.CgLagThreshold])
+	if err != nil {
+		return []models.CgLagThreshold{}, err
+	}
+	return thresholds, nil
+}
+
+func GetConsumersForGraph(tenantName string) ([]models.ConsumerForGraph, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return []models.ConsumerForGraph{}, err
+	}
+	defer conn.Release()
+	query := `SELECT c.name, c.consumers_group, c.station_id, c.app_id, c.type
+				FROM consumers AS c
+				WHERE c.tenant_name = $1 AND c.is_active = true
+				ORDER BY c.name, c.station_id DESC
+				LIMIT 10000;`
+	stmt, err := conn.Conn().Prepare(ctx, "get_consumers_for_graph", query)
+	if err != nil {
+		return []models.ConsumerForGraph{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, tenantName)
+	if err != nil {
+		return []models.ConsumerForGraph{}, err
+	}
+	defer rows.Close()
+	cgs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.ConsumerForGraph])
+	if err != nil {
+		return []models.ConsumerForGraph{}, err
+	}
+	if len(cgs) == 0 {
+		return []models.ConsumerForGraph{}, nil
+	}
+	return cgs, nil
+}
+
+func DeleteConsumerByNameStationIDAndConnID(connectionId, name string, stationId int) (bool, models.Consumer, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return false, models.Consumer{}, err
+	}
+	defer conn.Release()
+	query := ` DELETE FROM consumers WHERE ctid = ( SELECT ctid FROM consumers WHERE connection_id = $1 AND name = $2 AND station_id = $3 LIMIT 1) RETURNING *`
+	deleteStmt, err := conn.Conn().Prepare(ctx, "delete_consumers", query)
+	if err != nil {
+		return false, models.Consumer{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, deleteStmt.Name, connectionId, name, stationId)
+	if err != nil {
+		return false, models.Consumer{}, err
+	}
+	defer rows.Close()
+	consumers, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Consumer])
+	if err != nil {
+		return false, models.Consumer{}, err
+	}
+	if len(consumers) == 0 {
+		return false, models.Consumer{}, err
+	}
+	return true, consumers[0], nil
+}
+
+func DeleteConsumerByNameStationIDAndType(consumerType, name string, stationId int) (bool, models.Consumer, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return false, models.Consumer{}, err
+	}
+	defer conn.Release()
+	query := ` DELETE FROM consumers WHERE ctid = ( SELECT ctid FROM consumers WHERE type = $1 AND name = $2 AND station_id = $3 LIMIT 1) RETURNING *`
+	deleteStmt, err := conn.Conn().Prepare(ctx, "delete_consumers", query)
+	if err != nil {
+		return false, models.Consumer{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, deleteStmt.Name, consumerType, name, stationId)
+	if err != nil {
+		return false, models.Consumer{}, err
+	}
+	defer rows.Close()
+	consumers, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Consumer])
+	if err != nil {
+		return false, models.Consumer{}, err
+	}
+	if len(consumers) == 0 {
+		return false, models.Consumer{}, err
+	}
+	return true, consumers[0], nil
+}
+
+func DeleteConsumerByNameAndStationId(name string, stationId int) (bool, models.Consumer, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return false, models.Consumer{}, err
+	}
+	defer conn.Release()
+	query := ` DELETE FROM consumers WHERE ctid = ( SELECT ctid FROM consumers WHERE name = $1 AND station_id = $2 LIMIT 1) RETURNING *`
+	deleteStmt, err := conn.Conn().Prepare(ctx, "delete_consumers", query)
+	if err != nil {
+		return false, models.Consumer{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, deleteStmt.Name, name, stationId)
+	if err != nil {
+		return false, models.Consumer{}, err
+	}
+	defer rows.Close()
+	consumers, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Consumer])
+	if err != nil {
+		return false, models.Consumer{}, err
+	}
+	if len(consumers) == 0 {
+		return false, models.Consumer{}, err
+	}
+	return true, consumers[0], nil
+}
+
+func DeleteAllConsumersByStationID(stationId int) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	query := `DELETE FROM consumers WHERE station_id = $1`
+	stmt, err := conn.Conn().Prepare(ctx, "delete_consumers_by_station_id", query)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Conn().Query(ctx, stmt.Name, stationId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func DeleteDLSMessagesByStationID(stationId int) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	query := `DELETE FROM dls_messages WHERE station_id = $1`
+	stmt, err := conn.Conn().Prepare(ctx, "delete_messages_from_chosen_dls", query)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Conn().Query(ctx, stmt.Name, stationId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func CountActiveConsumersInCG(consumersGroup string, stationId int) (int64, error) {
 	var count int64
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
@@ -3607,6 +4068,94 @@ func KillConsumersByConnections(connectionIds []string) error {
 	return nil
 }
 
+func GetStationsClientsCount() ([]models.StationClientsCount, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return []models.StationClientsCount{}, err
+	}
+	defer conn.Release()
+	query := `SELECT s.tenant_name, s.name,
+		(SELECT COUNT(*) FROM producers AS p WHERE p.station_id = s.id AND p.is_active = true AND p.type = 'application'),
+		(SELECT COUNT(*) FROM consumers AS c WHERE c.station_id = s.id AND c.is_active = true AND c.type = 'application')
+	FROM stations AS s
+	WHERE s.is_deleted = false`
+	stmt, err := conn.Conn().Prepare(ctx, "get_stations_clients_count", query)
+	if err != nil {
+		return []models.StationClientsCount{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name)
+	if err != nil {
+		return []models.StationClientsCount{}, err
+	}
+	defer rows.Close()
+	counts, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.StationClientsCount])
+	if err != nil {
+		return []models.StationClientsCount{}, err
+	}
+	return counts, nil
+}
+
+func GetDlsMsgsCountByType() ([]models.DlsMsgsCount, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return []models.DlsMsgsCount{}, err
+	}
+	defer conn.Release()
+	query := `SELECT s.tenant_name, s.name, d.message_type, COUNT(*)
+	FROM dls_messages AS d
+	INNER JOIN stations AS s ON s.id = d.station_id
+	WHERE s.is_deleted = false
+	GROUP BY s.tenant_name, s.name, d.message_type`
+	stmt, err := conn.Conn().Prepare(ctx, "get_dls_msgs_count_by_type", query)
+	if err != nil {
+		return []models.DlsMsgsCount{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name)
+	if err != nil {
+		return []models.DlsMsgsCount{}, err
+	}
+	defer rows.Close()
+	counts, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.DlsMsgsCount])
+	if err != nil {
+		return []models.DlsMsgsCount{}, err
+	}
+	return counts, nil
+}
+
+func GetPoisonMsgsCountPerCg() ([]models.CgPoisonMsgsCount, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return []models.CgPoisonMsgsCount{}, err
+	}
+	defer conn.Release()
+	query := `SELECT s.tenant_name, s.name, cg.name, COUNT(*)
+	FROM dls_messages AS d
+	INNER JOIN stations AS s ON s.id = d.station_id
+	CROSS JOIN LATERAL unnest(d.poisoned_cgs) AS cg(name)
+	WHERE s.is_deleted = false AND d.message_type = 'poison'
+	GROUP BY s.tenant_name, s.name, cg.name`
+	stmt, err := conn.Conn().Prepare(ctx, "get_poison_msgs_count_per_cg", query)
+	if err != nil {
+		return []models.CgPoisonMsgsCount{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name)
+	if err != nil {
+		return []models.CgPoisonMsgsCount{}, err
+	}
+	defer rows.Close()
+	counts, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.CgPoisonMsgsCount])
+	if err != nil {
+		return []models.CgPoisonMsgsCount{}, err
+	}
+	return counts, nil
+}
+
 func GetActiveCgsByName(names []string, tenantName string) ([]models.LightConsumer, error) {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
@@ -3901,44 +4450,119 @@ func GetSchemaVersionByNumberAndID(version int, schemaId int) (bool, models.Sche
 	return true, schemaVersion, nil
 }
 
-func UpdateSchemaActiveVersion(schemaId int, versionNumber int) error {
+func GetSchemaVersionByID(id int, tenantName string) (bool, models.SchemaVersion, error) {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
 	conn, err := MetadataDbClient.Client.Acquire(ctx)
 	if err != nil {
-		return err
+		return false, models.SchemaVersion{}, err
 	}
 	defer conn.Release()
-	query := `UPDATE schema_versions
-		SET active = CASE
-		WHEN version_number = $2 THEN true
-		ELSE false
-		END
-	WHERE schema_id = $1
-`
-	stmt, err := conn.Conn().Prepare(ctx, "update_schema_active_version", query)
+	query := `SELECT * FROM schema_versions WHERE id=$1 AND tenant_name=$2 LIMIT 1`
+	stmt, err := conn.Conn().Prepare(ctx, "get_schema_version_by_id", query)
 	if err != nil {
-		return err
+		return false, models.SchemaVersion{}, err
 	}
-	_, err = conn.Conn().Query(ctx, stmt.Name, schemaId, versionNumber)
-	if err != nil {
-		return err
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
 	}
-	return nil
-}
-
-func GetShcemaVersionsCount(schemaId int, tenantName string) (int, error) {
-	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
-	defer cancelfunc()
-	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	rows, err := conn.Conn().Query(ctx, stmt.Name, id, tenantName)
 	if err != nil {
-		return 0, err
+		return false, models.SchemaVersion{}, err
 	}
-	defer conn.Release()
-	query := `SELECT COUNT(*) FROM schema_versions WHERE schema_id=$1 AND tenant_name=$2`
-	stmt, err := conn.Conn().Prepare(ctx, "get_schema_versions_count", query)
+	defer rows.Close()
+	schemas, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.SchemaVersionResponse])
 	if err != nil {
-		return 0, err
+		return false, models.SchemaVersion{}, err
+	}
+	if len(schemas) == 0 {
+		return false, models.SchemaVersion{}, nil
+	}
+	schemaVersion := models.SchemaVersion{
+		ID:                schemas[0].ID,
+		VersionNumber:     schemas[0].VersionNumber,
+		Active:            schemas[0].Active,
+		CreatedBy:         schemas[0].CreatedBy,
+		CreatedByUsername: schemas[0].CreatedByUsername,
+		CreatedAt:         schemas[0].CreatedAt,
+		SchemaContent:     schemas[0].SchemaContent,
+		SchemaId:          schemas[0].SchemaId,
+		MessageStructName: schemas[0].MessageStructName,
+		Descriptor:        string(schemas[0].Descriptor),
+		TenantName:        strings.ToLower(schemas[0].TenantName),
+	}
+	return true, schemaVersion, nil
+}
+
+func GetSchemaByID(id int, tenantName string) (bool, models.Schema, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return false, models.Schema{}, err
+	}
+	defer conn.Release()
+	query := `SELECT * FROM schemas WHERE id = $1 AND tenant_name = $2 LIMIT 1`
+	stmt, err := conn.Conn().Prepare(ctx, "get_schema_by_id", query)
+	if err != nil {
+		return false, models.Schema{}, err
+	}
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, id, tenantName)
+	if err != nil {
+		return false, models.Schema{}, err
+	}
+	defer rows.Close()
+	schemas, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Schema])
+	if err != nil {
+		return false, models.Schema{}, err
+	}
+	if len(schemas) == 0 {
+		return false, models.Schema{}, nil
+	}
+	return true, schemas[0], nil
+}
+
+func UpdateSchemaActiveVersion(schemaId int, versionNumber int) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	query := `UPDATE schema_versions
+		SET active = CASE
+		WHEN version_number = $2 THEN true
+		ELSE false
+		END
+	WHERE schema_id = $1
+`
+	stmt, err := conn.Conn().Prepare(ctx, "update_schema_active_version", query)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Conn().Query(ctx, stmt.Name, schemaId, versionNumber)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func GetShcemaVersionsCount(schemaId int, tenantName string) (int, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+	query := `SELECT COUNT(*) FROM schema_versions WHERE schema_id=$1 AND tenant_name=$2`
+	stmt, err := conn.Conn().Prepare(ctx, "get_schema_versions_count", query)
+	if err != nil {
+		return 0, err
 	}
 	var count int
 	if tenantName != conf.GlobalAccount {
@@ -4034,7 +4658,7 @@ func FindAndDeleteSchema(schemaIds []int) error {
 	return nil
 }
 
-func InsertNewSchema(schemaName string, schemaType string, createdByUsername string, tenantName string) (models.Schema, int64, error) {
+func InsertNewSchema(schemaName string, schemaType string, createdByUsername string, tenantName string, compatibility string) (models.Schema, int64, error) {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
 
@@ -4048,8 +4672,9 @@ func InsertNewSchema(schemaName string, schemaType string, createdByUsername str
 		name, 
 		type,
 		created_by_username,
-		tenant_name) 
-    VALUES($1, $2, $3, $4) RETURNING id`
+		tenant_name,
+		compatibility) 
+    VALUES($1, $2, $3, $4, $5) RETURNING id`
 
 	stmt, err := conn.Conn().Prepare(ctx, "insert_new_schema", query)
 	if err != nil {
@@ -4060,7 +4685,7 @@ func InsertNewSchema(schemaName string, schemaType string, createdByUsername str
 	if tenantName != conf.GlobalAccount {
 		tenantName = strings.ToLower(tenantName)
 	}
-	rows, err := conn.Conn().Query(ctx, stmt.Name, schemaName, schemaType, createdByUsername, tenantName)
+	rows, err := conn.Conn().Query(ctx, stmt.Name, schemaName, schemaType, createdByUsername, tenantName, compatibility)
 	if err != nil {
 		return models.Schema{}, 0, err
 	}
@@ -4095,10 +4720,32 @@ func InsertNewSchema(schemaName string, schemaType string, createdByUsername str
 		Name:              schemaName,
 		Type:              schemaType,
 		CreatedByUsername: createdByUsername,
+		TenantName:        tenantName,
+		Compatibility:     compatibility,
 	}
 	return newSchema, rowsAffected, nil
 }
 
+func UpdateSchemaCompatibility(schemaId int, compatibility string) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	query := `UPDATE schemas SET compatibility = $2 WHERE id = $1`
+	stmt, err := conn.Conn().Prepare(ctx, "update_schema_compatibility", query)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Conn().Query(ctx, stmt.Name, schemaId, compatibility)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func InsertNewSchemaVersion(schemaVersionNumber int, userId int, username string, schemaContent string, schemaId int, messageStructName string, descriptor string, active bool, tenantName string) (models.SchemaVersion, int64, error) {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
@@ -4178,6 +4825,134 @@ func InsertNewSchemaVersion(schemaVersionNumber int, userId int, username string
 	return newSchemaVersion, rowsAffected, nil
 }
 
+func InsertSchemaReferences(schemaVersionId int, references []models.SchemaReference, tenantName string) error {
+	if len(references) == 0 {
+		return nil
+	}
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	query := `INSERT INTO schema_references (schema_version_id, name, referenced_schema_id, referenced_version_number, tenant_name)
+	SELECT $1, $2, s.id, $4, $5 FROM schemas AS s WHERE s.name = $3 AND s.tenant_name = $5`
+	stmt, err := conn.Conn().Prepare(ctx, "insert_schema_references", query)
+	if err != nil {
+		return err
+	}
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
+	}
+	for _, ref := range references {
+		tag, err := conn.Conn().Exec(ctx, stmt.Name, schemaVersionId, ref.Name, ref.SchemaName, ref.VersionNumber, tenantName)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("referenced schema %v does not exist", ref.SchemaName)
+		}
+	}
+	return nil
+}
+
+func GetSchemaVersionReferences(schemaVersionId int) ([]models.SchemaReference, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return []models.SchemaReference{}, err
+	}
+	defer conn.Release()
+	query := `SELECT r.name, s.name, r.referenced_version_number FROM schema_references AS r
+	INNER JOIN schemas AS s ON s.id = r.referenced_schema_id
+	WHERE r.schema_version_id = $1 ORDER BY r.id`
+	stmt, err := conn.Conn().Prepare(ctx, "get_schema_version_references", query)
+	if err != nil {
+		return []models.SchemaReference{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, schemaVersionId)
+	if err != nil {
+		return []models.SchemaReference{}, err
+	}
+	defer rows.Close()
+	references, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.SchemaReference])
+	if err != nil {
+		return []models.SchemaReference{}, err
+	}
+	return references, nil
+}
+
+func GetSchemaReferencesBySchemaID(schemaId int) (map[int][]models.SchemaReference, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return map[int][]models.SchemaReference{}, err
+	}
+	defer conn.Release()
+	query := `SELECT r.schema_version_id, r.name, s.name, r.referenced_version_number FROM schema_references AS r
+	INNER JOIN schema_versions AS v ON v.id = r.schema_version_id
+	INNER JOIN schemas AS s ON s.id = r.referenced_schema_id
+	WHERE v.schema_id = $1 ORDER BY r.id`
+	stmt, err := conn.Conn().Prepare(ctx, "get_schema_references_by_schema_id", query)
+	if err != nil {
+		return map[int][]models.SchemaReference{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, schemaId)
+	if err != nil {
+		return map[int][]models.SchemaReference{}, err
+	}
+	defer rows.Close()
+	references := map[int][]models.SchemaReference{}
+	for rows.Next() {
+		var versionId int
+		var ref models.SchemaReference
+		err := rows.Scan(&versionId, &ref.Name, &ref.SchemaName, &ref.VersionNumber)
+		if err != nil {
+			return map[int][]models.SchemaReference{}, err
+		}
+		references[versionId] = append(references[versionId], ref)
+	}
+	return references, nil
+}
+
+func GetSchemaNamesReferencingSchema(schemaId int) ([]string, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return []string{}, err
+	}
+	defer conn.Release()
+	query := `SELECT DISTINCT s.name FROM schema_references AS r
+	INNER JOIN schema_versions AS v ON v.id = r.schema_version_id
+	INNER JOIN schemas AS s ON s.id = v.schema_id
+	WHERE r.referenced_schema_id = $1 AND v.schema_id <> $1`
+	stmt, err := conn.Conn().Prepare(ctx, "get_schema_names_referencing_schema", query)
+	if err != nil {
+		return []string{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, schemaId)
+	if err != nil {
+		return []string{}, err
+	}
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return []string{}, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
 func CountAllSchemasByTenant(tenantName string) (int64, error) {
 	var count int64
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
@@ -4442,6 +5217,65 @@ func UpdateIsValidIntegration(tenantName, integrationName string, isValid bool)
 	return nil
 }
 
+func GetTieredStorageCheckpoint(tenantName, integrationType, streamName string) (uint64, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+	query := `SELECT * FROM tiered_storage_checkpoints WHERE tenant_name = $1 AND integration_type = $2 AND stream_name = $3 LIMIT 1`
+	stmt, err := conn.Conn().Prepare(ctx, "get_tiered_storage_checkpoint", query)
+	if err != nil {
+		return 0, err
+	}
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, tenantName, integrationType, streamName)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	checkpoints, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.TieredStorageCheckpoint])
+	if err != nil {
+		return 0, err
+	}
+	if len(checkpoints) == 0 {
+		return 0, nil
+	}
+	return uint64(checkpoints[0].HighWaterMark), nil
+}
+
+// AdvanceTieredStorageCheckpoint moves the high water mark of a stream to lastSeq only when the uploaded range
+// continues it, so everything at or below the mark is known to be archived even when brokers upload ranges out of order
+func AdvanceTieredStorageCheckpoint(tenantName, integrationType, streamName string, firstSeq, lastSeq uint64) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	query := `INSERT INTO tiered_storage_checkpoints (tenant_name, integration_type, stream_name, high_water_mark, updated_at)
+	VALUES ($1, $2, $3, $5, NOW())
+	ON CONFLICT (tenant_name, integration_type, stream_name) DO UPDATE SET high_water_mark = EXCLUDED.high_water_mark, updated_at = NOW()
+	WHERE tiered_storage_checkpoints.high_water_mark >= $4 - 1 AND tiered_storage_checkpoints.high_water_mark < EXCLUDED.high_water_mark`
+	stmt, err := conn.Conn().Prepare(ctx, "advance_tiered_storage_checkpoint", query)
+	if err != nil {
+		return err
+	}
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
+	}
+	_, err = conn.Conn().Exec(ctx, stmt.Name, tenantName, integrationType, streamName, int64(firstSeq), int64(lastSeq))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 // User Functions
 func UpdatePendingUser(tenantName, username string, pending bool) error {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
@@ -6090,7 +6924,7 @@ func DeleteOldDlsMessageByRetention(updatedAt time.Time, tenantName string) erro
 	return nil
 }
 
-func DropDlsMessages(messageIds []int) error {
+func DropDlsMessages(stationId int, messageIds []int) error {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
 	conn, err := MetadataDbClient.Client.Acquire(ctx)
@@ -6099,13 +6933,13 @@ func DropDlsMessages(messageIds []int) error {
 	}
 	defer conn.Release()
 
-	query := `DELETE FROM dls_messages where id=ANY($1)`
+	query := `DELETE FROM dls_messages where station_id = $1 AND id=ANY($2)`
 	stmt, err := conn.Conn().Prepare(ctx, "drop_dls_schema_msg", query)
 	if err != nil {
 		return err
 	}
 
-	_, err = conn.Conn().Exec(ctx, stmt.Name, messageIds)
+	_, err = conn.Conn().Exec(ctx, stmt.Name, stationId, messageIds)
 	if err != nil {
 		return errors.New("dropSchemaDlsMsg: " + err.Error())
 	}
@@ -6241,47 +7075,131 @@ func GetDlsMessageById(messageId int) (bool, models.DlsMessage, error) {
 	return true, dlsMsgs[0], nil
 }
 
-func GetTotalDlsMessages(tenantName string) (uint64, error) {
+// ScheduleDlsMsgRetry sets the first automatic retry of a poison message unless one is already pending,
+// it returns false once the message has used all of its retry attempts
+func ScheduleDlsMsgRetry(messageId int, nextRetryAt time.Time, maxRetries int) (bool, error) {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
 	conn, err := MetadataDbClient.Client.Acquire(ctx)
 	if err != nil {
-		return 0, err
+		return false, err
 	}
 	defer conn.Release()
-	query := `SELECT COUNT(*) FROM dls_messages WHERE tenant_name=$1`
-	stmt, err := conn.Conn().Prepare(ctx, "get_total_dls_msgs", query)
+	query := `UPDATE dls_messages SET next_retry_at = COALESCE(next_retry_at, $2)
+	WHERE id = $1 AND retry_attempts < $3 RETURNING id`
+	stmt, err := conn.Conn().Prepare(ctx, "schedule_dls_msg_retry", query)
 	if err != nil {
-		return 0, err
-	}
-	var count uint64
-	if tenantName != conf.GlobalAccount {
-		tenantName = strings.ToLower(tenantName)
+		return false, err
 	}
-	err = conn.Conn().QueryRow(ctx, stmt.Name, tenantName).Scan(&count)
+	rows, err := conn.Conn().Query(ctx, stmt.Name, messageId, nextRetryAt, maxRetries)
 	if err != nil {
-		return 0, err
+		return false, err
 	}
-
-	return count, nil
+	defer rows.Close()
+	scheduled := rows.Next()
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	return scheduled, nil
 }
 
-func GetStationIdsFromDlsMsgs(tenantName string) (map[int]string, error) {
+// ClaimDueDlsMsgRetries returns the poison messages whose retry time has passed and pushes their
+// next_retry_at to leaseUntil so other brokers will not pick them up at the same time
+func ClaimDueDlsMsgRetries(now time.Time, leaseUntil time.Time, limit int) ([]models.DlsMessage, error) {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
-	stationIds := map[int]string{}
 	conn, err := MetadataDbClient.Client.Acquire(ctx)
 	if err != nil {
-		return stationIds, err
+		return []models.DlsMessage{}, err
 	}
 	defer conn.Release()
-	query := `SELECT DISTINCT station_id FROM dls_messages WHERE tenant_name=$1`
-	stmt, err := conn.Conn().Prepare(ctx, "get_station_ids_in_dls_messages", query)
+	query := `UPDATE dls_messages SET next_retry_at = $2
+	WHERE id IN (
+		SELECT id FROM dls_messages
+		WHERE next_retry_at <= $1 AND message_type = 'poison'
+		ORDER BY next_retry_at ASC
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING *`
+	stmt, err := conn.Conn().Prepare(ctx, "claim_due_dls_msg_retries", query)
 	if err != nil {
-		return stationIds, err
+		return []models.DlsMessage{}, err
 	}
-	if tenantName != conf.GlobalAccount {
-		tenantName = strings.ToLower(tenantName)
+	rows, err := conn.Conn().Query(ctx, stmt.Name, now, leaseUntil, limit)
+	if err != nil {
+		return []models.DlsMessage{}, err
+	}
+	defer rows.Close()
+	dlsMsgs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.DlsMessage])
+	if err != nil {
+		return []models.DlsMessage{}, err
+	}
+	return dlsMsgs, nil
+}
+
+func UpdateDlsMsgRetry(messageId int, retryAttempts int, nextRetryAt *time.Time) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	query := `UPDATE dls_messages SET retry_attempts = $2, next_retry_at = $3 WHERE id = $1`
+	stmt, err := conn.Conn().Prepare(ctx, "update_dls_msg_retry", query)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Conn().Exec(ctx, stmt.Name, messageId, retryAttempts, nextRetryAt)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func GetTotalDlsMessages(tenantName string) (uint64, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+	query := `SELECT COUNT(*) FROM dls_messages WHERE tenant_name=$1`
+	stmt, err := conn.Conn().Prepare(ctx, "get_total_dls_msgs", query)
+	if err != nil {
+		return 0, err
+	}
+	var count uint64
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
+	}
+	err = conn.Conn().QueryRow(ctx, stmt.Name, tenantName).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func GetStationIdsFromDlsMsgs(tenantName string) (map[int]string, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	stationIds := map[int]string{}
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return stationIds, err
+	}
+	defer conn.Release()
+	query := `SELECT DISTINCT station_id FROM dls_messages WHERE tenant_name=$1`
+	stmt, err := conn.Conn().Prepare(ctx, "get_station_ids_in_dls_messages", query)
+	if err != nil {
+		return stationIds, err
+	}
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
 	}
 	rows, err := conn.Conn().Query(ctx, stmt.Name, tenantName)
 	if err != nil {
@@ -6469,6 +7387,74 @@ func GetDlsMsgsByStationAndPartition(stationId, partitionNumber int) ([]models.D
 	return dlsMsgs, nil
 }
 
+func SearchDlsMessages(filter models.DlsMessagesFilter) ([]models.DlsMessage, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return []models.DlsMessage{}, err
+	}
+	defer conn.Release()
+
+	sortColumn := "updated_at"
+	switch filter.SortBy {
+	case "message_seq", "id":
+		sortColumn = filter.SortBy
+	}
+	sortOrder, cursorOp := "ASC", ">"
+	if filter.SortDesc {
+		sortOrder, cursorOp = "DESC", "<"
+	}
+
+	query := `SELECT * FROM dls_messages
+	WHERE station_id = $1
+	AND ($2 = -1 OR partition_number = $2)
+	AND ($3 = '' OR message_type = $3)
+	AND ($4 = '' OR $4 = ANY(poisoned_cgs))
+	AND ($5 = '' OR producer_name = $5)
+	AND ($6::TIMESTAMPTZ IS NULL OR updated_at >= $6)
+	AND ($7::TIMESTAMPTZ IS NULL OR updated_at <= $7)
+	AND ($8 = '' OR strpos(lower(validation_error), lower($8)) > 0)
+	AND ($9 = '' OR (message_details::jsonb -> 'headers') ? $9)
+	AND ($10 = '' OR (message_details::jsonb -> 'headers' ->> $9) = $10)`
+	args := []interface{}{filter.StationId, filter.PartitionNumber, filter.MessageType, filter.CgName, filter.ProducerName, filter.From, filter.To, filter.ValidationError, filter.HeaderKey, filter.HeaderValue, filter.Limit}
+	stmtName := "search_dls_messages_" + sortColumn + "_" + strings.ToLower(sortOrder)
+	if filter.Cursor != nil {
+		switch sortColumn {
+		case "updated_at":
+			query += ` AND (updated_at, id) ` + cursorOp + ` ($12::TIMESTAMPTZ, $13::INTEGER)`
+			args = append(args, filter.Cursor.UpdatedAt, filter.Cursor.ID)
+		case "message_seq":
+			query += ` AND (message_seq, id) ` + cursorOp + ` ($12::INTEGER, $13::INTEGER)`
+			args = append(args, filter.Cursor.MessageSeq, filter.Cursor.ID)
+		default:
+			query += ` AND id ` + cursorOp + ` $12`
+			args = append(args, filter.Cursor.ID)
+		}
+		stmtName += "_cursor"
+	}
+	if sortColumn == "id" {
+		query += ` ORDER BY id ` + sortOrder + ` LIMIT $11`
+	} else {
+		query += ` ORDER BY ` + sortColumn + ` ` + sortOrder + `, id ` + sortOrder + ` LIMIT $11`
+	}
+
+	stmt, err := conn.Conn().Prepare(ctx, stmtName, query)
+	if err != nil {
+		return []models.DlsMessage{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, args...)
+	if err != nil {
+		return []models.DlsMessage{}, err
+	}
+	defer rows.Close()
+	dlsMsgs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.DlsMessage])
+	if err != nil {
+		return []models.DlsMessage{}, err
+	}
+	return dlsMsgs, nil
+}
+
 // Tenants functions
 func UpsertTenant(name string, encryptrdInternalWSPass string) (models.Tenant, error) {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
@@ -7533,6 +8519,105 @@ func UpdateStatusAsyncTask(task, tenantName, status string, stationId int, failu
 	return nil
 }
 
+func GetAsyncTaskById(id int, tenantName string) (bool, models.AsyncTask, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return false, models.AsyncTask{}, err
+	}
+	defer conn.Release()
+
+	query := `SELECT * FROM async_tasks WHERE id = $1 AND tenant_name = $2 LIMIT 1`
+	stmt, err := conn.Conn().Prepare(ctx, "get_async_task_by_id", query)
+	if err != nil {
+		return false, models.AsyncTask{}, err
+	}
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, id, tenantName)
+	if err != nil {
+		return false, models.AsyncTask{}, err
+	}
+	defer rows.Close()
+	asyncTasks, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.AsyncTask])
+	if err != nil {
+		return false, models.AsyncTask{}, err
+	}
+	if len(asyncTasks) == 0 {
+		return false, models.AsyncTask{}, nil
+	}
+	return true, asyncTasks[0], nil
+}
+
+func IsAsyncTaskRunning(task, tenantName string, stationId int) (bool, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+
+	query := `SELECT EXISTS(SELECT 1 FROM async_tasks WHERE name = $1 AND tenant_name = $2 AND station_id = $3 AND status = 'running')`
+	stmt, err := conn.Conn().Prepare(ctx, "is_async_task_running", query)
+	if err != nil {
+		return false, err
+	}
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
+	}
+	var running bool
+	err = conn.Conn().QueryRow(ctx, stmt.Name, task, tenantName, stationId).Scan(&running)
+	if err != nil {
+		return false, err
+	}
+	return running, nil
+}
+
+func UpdateAsyncTaskById(id int, updatedAt time.Time, metaData interface{}) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	query := `UPDATE async_tasks SET updated_at = $2, meta_data = $3 WHERE id = $1`
+	stmt, err := conn.Conn().Prepare(ctx, "edit_async_task_by_id", query)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Conn().Exec(ctx, stmt.Name, id, updatedAt, metaData)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func UpdateStatusAsyncTaskById(id int, status, failureReason string) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	query := `UPDATE async_tasks SET status = $2, failure_reason = $3, updated_at = $4 WHERE id = $1`
+	stmt, err := conn.Conn().Prepare(ctx, "edit_status_async_task_by_id", query)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Conn().Exec(ctx, stmt.Name, id, status, failureReason, time.Now())
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func RemoveOldAsyncTasks() error {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
@@ -7955,6 +9040,9 @@ func InsertRole(name, tenantName, roleType string) (models.Role, error) {
 }
 
 func InsertPermissions(allowReadPermissions, allowWritePermissions, denyReadPermissions, denyWritePermissions []string, roleID int, tenantName string) error {
+	if len(allowReadPermissions)+len(allowWritePermissions)+len(denyReadPermissions)+len(denyWritePermissions) == 0 {
+		return nil
+	}
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
 	conn, err := MetadataDbClient.Client.Acquire(ctx)
@@ -7962,37 +9050,35 @@ func InsertPermissions(allowReadPermissions, allowWritePermissions, denyReadPerm
 		return err
 	}
 	defer conn.Release()
+	tenantName = strings.ToLower(tenantName)
 
-	query := `INSERT INTO permissions (pattern, role_id, type, restriction_type) VALUES`
+	query := `INSERT INTO permissions (pattern, role_id, type, restriction_type, tenant_name) VALUES`
 	var values []interface{}
 	i := 0
 	for _, permission := range allowReadPermissions {
-		values = append(values, permission, roleID, "read", "allow")
-		query += fmt.Sprintf(" ($%d, $%d, $%d, $%d),", i*4+1, i*4+2, i*4+3, i*4+4)
+		values = append(values, permission, roleID, "read", "allow", tenantName)
+		query += fmt.Sprintf(" ($%d, $%d, $%d, $%d, $%d),", i*5+1, i*5+2, i*5+3, i*5+4, i*5+5)
 		i++
 	}
 	for _, permission := range allowWritePermissions {
-		values = append(values, permission, roleID, "write", "allow")
-		query += fmt.Sprintf(" ($%d, $%d, $%d, $%d),", i*4+1, i*4+2, i*4+3, i*4+4)
+		values = append(values, permission, roleID, "write", "allow", tenantName)
+		query += fmt.Sprintf(" ($%d, $%d, $%d, $%d, $%d),", i*5+1, i*5+2, i*5+3, i*5+4, i*5+5)
 		i++
 	}
 	for _, permission := range denyReadPermissions {
-		values = append(values, permission, roleID, "read", "deny")
-		query += fmt.Sprintf(" ($%d, $%d, $%d, $%d),", i*4+1, i*4+2, i*4+3, i*4+4)
+		values = append(values, permission, roleID, "read", "deny", tenantName)
+		query += fmt.Sprintf(" ($%d, $%d, $%d, $%d, $%d),", i*5+1, i*5+2, i*5+3, i*5+4, i*5+5)
 		i++
 	}
 	for _, permission := range denyWritePermissions {
-		values = append(values, permission, roleID, "write", "deny")
-		query += fmt.Sprintf(" ($%d, $%d, $%d, $%d),", i*4+1, i*4+2, i*4+3, i*4+4)
+		values = append(values, permission, roleID, "write", "deny", tenantName)
+		query += fmt.Sprintf(" ($%d, $%d, $%d, $%d, $%d),", i*5+1, i*5+2, i*5+3, i*5+4, i*5+5)
 		i++
 	}
 	query = query[:len(query)-1]
 
-	stmt, err := conn.Conn().Prepare(ctx, "insert_permissions", query)
-	if err != nil {
-		return err
-	}
-	_, err = conn.Conn().Query(ctx, stmt.Name, values...)
+	// the statement shape depends on the number of patterns, so it is not prepared under a fixed name
+	_, err = conn.Conn().Exec(ctx, query, values...)
 	if err != nil {
 		return err
 	}
@@ -8017,12 +9103,11 @@ func CreateNewRole(name, tenantName, roleType string, allowReadPermissions, allo
 		return models.Role{}, models.Permissions{}, err
 	}
 	defer func() {
-		// if err != nil {
-		// 	tx.Rollback(ctx)
-		// } else {
-		// 	tx.Commit(ctx)
-		// }
-		tx.Commit(ctx)
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
 	}()
 
 	query := `INSERT INTO roles (name, tenant_name, type) VALUES($1, $2, $3) RETURNING id`
@@ -8063,15 +9148,12 @@ func CreateNewRole(name, tenantName, roleType string, allowReadPermissions, allo
 		query += fmt.Sprintf(" ($%d, $%d, $%d, $%d, $%d),", i*5+1, i*5+2, i*5+3, i*5+4, i*5+5)
 		i++
 	}
-	query = query[:len(query)-1]
-	stmt, err = tx.Prepare(ctx, "insert_permissions_create_new_roll", query)
-	if err != nil {
-		return models.Role{}, models.Permissions{}, err
-	}
-
-	_, err = tx.Exec(ctx, stmt.Name, values...)
-	if err != nil {
-		return models.Role{}, models.Permissions{}, err
+	if i > 0 {
+		query = query[:len(query)-1]
+		_, err = tx.Exec(ctx, query, values...)
+		if err != nil {
+			return models.Role{}, models.Permissions{}, err
+		}
 	}
 	permissionsRes := models.Permissions{
 		AllowReadPermissions:  allowReadPermissions,
@@ -8206,39 +9288,6 @@ func UpdatePermissions(tenantName, username string, readPermissions, writePermis
 	return nil
 }
 
-func CheckUserStationPermissions(rolesId []int, stationName, operation string) (bool, error) {
-	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
-	defer cancelfunc()
-
-	conn, err := MetadataDbClient.Client.Acquire(ctx)
-	if err != nil {
-		return false, err
-	}
-	defer conn.Release()
-	query := `SELECT COUNT(*)
-	FROM permissions
-	WHERE role_id = ANY($1)
-	  AND type = $2
-	  AND restriction_type = 'allow'
-	  AND (
-		(position('*' in pattern) > 0 AND $3 ~ pattern) OR
-		(position('*' in pattern) = 0 AND $3 = pattern)
-	  );`
-	stmt, err := conn.Conn().Prepare(ctx, "check_user_station_permissions", query)
-	if err != nil {
-		return false, err
-	}
-	var count int
-	err = conn.Conn().QueryRow(ctx, stmt.Name, rolesId, operation, stationName).Scan(&count)
-	if err != nil {
-		return false, err
-	}
-	if count == 0 {
-		return false, nil
-	}
-	return true, nil
-}
-
 func CheckTenantPermissionsUsage(tenantName string) (bool, error) {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
@@ -8296,79 +9345,6 @@ func GetUserPermissions(userRoles []int, tenantName string) ([]models.Permission
 
 }
 
-func GetStationsByPattern(patterns []string, tenantName string) ([]models.Station, error) {
-	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
-	defer cancelfunc()
-	tenantName = strings.ToLower(tenantName)
-
-	var stationsList []models.Station
-	var plainNames []string
-	var regexPatterns []string
-	for _, pattern := range patterns {
-		if strings.Contains(pattern, "*") {
-			regexPatterns = append(regexPatterns, pattern)
-		} else {
-			plainNames = append(plainNames, pattern)
-		}
-	}
-
-	conn, err := MetadataDbClient.Client.Acquire(ctx)
-	if err != nil {
-		return []models.Station{}, err
-	}
-	defer conn.Release()
-
-	if len(regexPatterns) > 0 {
-		query := `SELECT * FROM stations WHERE name ~ ANY($1) AND tenant_name = $2`
-		stmt, err := conn.Conn().Prepare(ctx, "get_stations_by_pattern", query)
-		if err != nil {
-			return []models.Station{}, err
-		}
-
-		rows, err := conn.Conn().Query(ctx, stmt.Name, regexPatterns, tenantName)
-		if err != nil {
-			return []models.Station{}, err
-		}
-		defer rows.Close()
-
-		stations, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Station])
-		if err != nil {
-			return []models.Station{}, err
-		}
-		if len(stations) != 0 {
-			stationsList = append(stationsList, stations...)
-		}
-	}
-
-	if len(plainNames) > 0 {
-		query := `SELECT * FROM stations WHERE name = ANY($1) AND tenant_name = $2`
-		stmt, err := conn.Conn().Prepare(ctx, "get_stations_by_plain_names", query)
-		if err != nil {
-			return []models.Station{}, err
-		}
-
-		rows, err := conn.Conn().Query(ctx, stmt.Name, plainNames, tenantName)
-		if err != nil {
-			return []models.Station{}, err
-		}
-		defer rows.Close()
-
-		stations, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Station])
-		if err != nil {
-			return []models.Station{}, err
-		}
-		if len(stations) != 0 {
-			stationsList = append(stationsList, stations...)
-		}
-	}
-
-	if len(stationsList) == 0 {
-		return []models.Station{}, nil
-	} else {
-		return stationsList, nil
-	}
-}
-
 func RemoveRoleAndPermissions(roleID []int, tenantName string) error {
 	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
 	defer cancelfunc()
@@ -8459,3 +9435,424 @@ func RemoveRolesByTenant(tenantName string) error {
 
 	return nil
 }
+
+func GetRolesByTenant(tenantName string) ([]models.Role, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	tenantName = strings.ToLower(tenantName)
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return []models.Role{}, err
+	}
+	defer conn.Release()
+
+	query := `SELECT * FROM roles WHERE tenant_name = $1 ORDER BY id`
+	stmt, err := conn.Conn().Prepare(ctx, "get_roles_by_tenant", query)
+	if err != nil {
+		return []models.Role{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, tenantName)
+	if err != nil {
+		return []models.Role{}, err
+	}
+	defer rows.Close()
+	roles, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Role])
+	if err != nil {
+		return []models.Role{}, err
+	}
+	if len(roles) == 0 {
+		return []models.Role{}, nil
+	}
+	return roles, nil
+}
+
+func GetRolesByIds(roleIDs []int, tenantName string) ([]models.Role, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	tenantName = strings.ToLower(tenantName)
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return []models.Role{}, err
+	}
+	defer conn.Release()
+
+	query := `SELECT * FROM roles WHERE id = ANY($1) AND tenant_name = $2 ORDER BY id`
+	stmt, err := conn.Conn().Prepare(ctx, "get_roles_by_ids", query)
+	if err != nil {
+		return []models.Role{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, roleIDs, tenantName)
+	if err != nil {
+		return []models.Role{}, err
+	}
+	defer rows.Close()
+	roles, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Role])
+	if err != nil {
+		return []models.Role{}, err
+	}
+	if len(roles) == 0 {
+		return []models.Role{}, nil
+	}
+	return roles, nil
+}
+
+func GetRoleById(roleID int, tenantName string) (bool, models.Role, error) {
+	roles, err := GetRolesByIds([]int{roleID}, tenantName)
+	if err != nil {
+		return false, models.Role{}, err
+	}
+	if len(roles) == 0 {
+		return false, models.Role{}, nil
+	}
+	return true, roles[0], nil
+}
+
+func UpdateRoleName(roleID int, tenantName, name string) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	tenantName = strings.ToLower(tenantName)
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	query := `UPDATE roles SET name = $1 WHERE id = $2 AND tenant_name = $3`
+	stmt, err := conn.Conn().Prepare(ctx, "update_role_name", query)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Conn().Exec(ctx, stmt.Name, name, roleID, tenantName)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && strings.Contains(pgErr.Detail, "already exists") {
+			return errors.New("role " + name + " already exists")
+		}
+		return err
+	}
+	return nil
+}
+
+func GetPermissionsByRoleIdAndTenant(roleID int, tenantName string) ([]models.Permission, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	tenantName = strings.ToLower(tenantName)
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return []models.Permission{}, err
+	}
+	defer conn.Release()
+
+	query := `SELECT * FROM permissions WHERE role_id = $1 AND tenant_name = $2 ORDER BY id`
+	stmt, err := conn.Conn().Prepare(ctx, "get_permissions_by_role_id_and_tenant", query)
+	if err != nil {
+		return []models.Permission{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, roleID, tenantName)
+	if err != nil {
+		return []models.Permission{}, err
+	}
+	defer rows.Close()
+	permissions, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Permission])
+	if err != nil {
+		return []models.Permission{}, err
+	}
+	if len(permissions) == 0 {
+		return []models.Permission{}, nil
+	}
+	return permissions, nil
+}
+
+func RemovePermissionsByIds(roleID int, permissionIDs []int, tenantName string) (int64, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	tenantName = strings.ToLower(tenantName)
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	query := `DELETE FROM permissions WHERE id = ANY($1) AND role_id = $2 AND tenant_name = $3`
+	stmt, err := conn.Conn().Prepare(ctx, "remove_permissions_by_ids", query)
+	if err != nil {
+		return 0, err
+	}
+	res, err := conn.Conn().Exec(ctx, stmt.Name, permissionIDs, roleID, tenantName)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
+
+func GetUsersByRoleId(roleID int, tenantName string) ([]models.User, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	tenantName = strings.ToLower(tenantName)
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return []models.User{}, err
+	}
+	defer conn.Release()
+
+	query := `SELECT * FROM users WHERE $1 = ANY(roles) AND tenant_name = $2`
+	stmt, err := conn.Conn().Prepare(ctx, "get_users_by_role_id", query)
+	if err != nil {
+		return []models.User{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, roleID, tenantName)
+	if err != nil {
+		return []models.User{}, err
+	}
+	defer rows.Close()
+	users, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.User])
+	if err != nil {
+		return []models.User{}, err
+	}
+	if len(users) == 0 {
+		return []models.User{}, nil
+	}
+	return users, nil
+}
+
+func RemoveRoleFromUsers(roleID int, tenantName string) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	tenantName = strings.ToLower(tenantName)
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	query := `UPDATE users SET roles = array_remove(roles, $1) WHERE $1 = ANY(roles) AND tenant_name = $2`
+	stmt, err := conn.Conn().Prepare(ctx, "remove_role_from_users", query)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Conn().Exec(ctx, stmt.Name, roleID, tenantName)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Api Keys Functions
+func CreateApiKey(name, keyPrefix, keyHash, scope string, roleIDs []int, userID int, createdBy, tenantName string, expiresAt *time.Time) (models.ApiKey, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	tenantName = strings.ToLower(tenantName)
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return models.ApiKey{}, err
+	}
+	defer conn.Release()
+
+	query := `INSERT INTO api_keys (name, key_prefix, key_hash, scope, role_ids, user_id, created_by, tenant_name, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING *`
+	stmt, err := conn.Conn().Prepare(ctx, "create_api_key", query)
+	if err != nil {
+		return models.ApiKey{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, name, keyPrefix, keyHash, scope, roleIDs, userID, createdBy, tenantName, expiresAt)
+	if err != nil {
+		return models.ApiKey{}, err
+	}
+	defer rows.Close()
+	apiKeys, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.ApiKey])
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && strings.Contains(pgErr.Detail, "already exists") {
+			return models.ApiKey{}, errors.New("api key " + name + " already exists")
+		}
+		return models.ApiKey{}, err
+	}
+	if len(apiKeys) == 0 {
+		return models.ApiKey{}, errors.New("api key " + name + " was not created")
+	}
+	return apiKeys[0], nil
+}
+
+func GetApiKeyByHash(keyHash string) (bool, models.ApiKey, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return false, models.ApiKey{}, err
+	}
+	defer conn.Release()
+
+	query := `SELECT * FROM api_keys WHERE key_hash = $1 LIMIT 1`
+	stmt, err := conn.Conn().Prepare(ctx, "get_api_key_by_hash", query)
+	if err != nil {
+		return false, models.ApiKey{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, keyHash)
+	if err != nil {
+		return false, models.ApiKey{}, err
+	}
+	defer rows.Close()
+	apiKeys, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.ApiKey])
+	if err != nil {
+		return false, models.ApiKey{}, err
+	}
+	if len(apiKeys) == 0 {
+		return false, models.ApiKey{}, nil
+	}
+	return true, apiKeys[0], nil
+}
+
+func GetApiKeyById(id int, tenantName string) (bool, models.ApiKey, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	tenantName = strings.ToLower(tenantName)
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return false, models.ApiKey{}, err
+	}
+	defer conn.Release()
+
+	query := `SELECT * FROM api_keys WHERE id = $1 AND tenant_name = $2 LIMIT 1`
+	stmt, err := conn.Conn().Prepare(ctx, "get_api_key_by_id", query)
+	if err != nil {
+		return false, models.ApiKey{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, id, tenantName)
+	if err != nil {
+		return false, models.ApiKey{}, err
+	}
+	defer rows.Close()
+	apiKeys, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.ApiKey])
+	if err != nil {
+		return false, models.ApiKey{}, err
+	}
+	if len(apiKeys) == 0 {
+		return false, models.ApiKey{}, nil
+	}
+	return true, apiKeys[0], nil
+}
+
+func GetApiKeysByTenant(tenantName string) ([]models.ApiKey, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	tenantName = strings.ToLower(tenantName)
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return []models.ApiKey{}, err
+	}
+	defer conn.Release()
+
+	query := `SELECT * FROM api_keys WHERE tenant_name = $1 ORDER BY created_at DESC`
+	stmt, err := conn.Conn().Prepare(ctx, "get_api_keys_by_tenant", query)
+	if err != nil {
+		return []models.ApiKey{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, tenantName)
+	if err != nil {
+		return []models.ApiKey{}, err
+	}
+	defer rows.Close()
+	apiKeys, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.ApiKey])
+	if err != nil {
+		return []models.ApiKey{}, err
+	}
+	if len(apiKeys) == 0 {
+		return []models.ApiKey{}, nil
+	}
+	return apiKeys, nil
+}
+
+func RotateApiKey(id int, tenantName, keyPrefix, keyHash string) (models.ApiKey, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	tenantName = strings.ToLower(tenantName)
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return models.ApiKey{}, err
+	}
+	defer conn.Release()
+
+	query := `UPDATE api_keys SET key_prefix = $1, key_hash = $2, rotated_at = NOW() WHERE id = $3 AND tenant_name = $4 RETURNING *`
+	stmt, err := conn.Conn().Prepare(ctx, "rotate_api_key", query)
+	if err != nil {
+		return models.ApiKey{}, err
+	}
+	rows, err := conn.Conn().Query(ctx, stmt.Name, keyPrefix, keyHash, id, tenantName)
+	if err != nil {
+		return models.ApiKey{}, err
+	}
+	defer rows.Close()
+	apiKeys, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.ApiKey])
+	if err != nil {
+		return models.ApiKey{}, err
+	}
+	if len(apiKeys) == 0 {
+		return models.ApiKey{}, errors.New("api key does not exist")
+	}
+	return apiKeys[0], nil
+}
+
+func UpdateApiKeyLastUsed(id int) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	query := `UPDATE api_keys SET last_used_at = NOW() WHERE id = $1`
+	stmt, err := conn.Conn().Prepare(ctx, "update_api_key_last_used", query)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Conn().Exec(ctx, stmt.Name, id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func RemoveApiKey(id int, tenantName string) (bool, error) {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	tenantName = strings.ToLower(tenantName)
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+
+	query := `DELETE FROM api_keys WHERE id = $1 AND tenant_name = $2`
+	stmt, err := conn.Conn().Prepare(ctx, "remove_api_key", query)
+	if err != nil {
+		return false, err
+	}
+	res, err := conn.Conn().Exec(ctx, stmt.Name, id, tenantName)
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+func RemoveApiKeysByTenant(tenantName string) error {
+	ctx, cancelfunc := context.WithTimeout(context.Background(), DbOperationTimeout*time.Second)
+	defer cancelfunc()
+	tenantName = strings.ToLower(tenantName)
+	conn, err := MetadataDbClient.Client.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	query := `DELETE FROM api_keys WHERE tenant_name = $1`
+	stmt, err := conn.Conn().Prepare(ctx, "remove_api_keys_by_tenant", query)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Conn().Exec(ctx, stmt.Name, tenantName)
+	if err != nil {
+		return err
+	}
+	return nil
+}
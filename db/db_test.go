@@ -0,0 +1,34 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestCreateTablesUpgradeIsIdempotent(t *testing.T) {
+	client, err := InitalizeMetadataDbConnection()
+	if err != nil {
+		t.Skipf("metadata db is not available: %v", err)
+	}
+	defer client.Client.Close()
+
+	// simulate a database created before schema compatibility modes, its schemas type enum already includes avro
+	_, err = client.Client.Exec(client.Ctx, `ALTER TABLE schemas DROP COLUMN IF EXISTS compatibility`)
+	if err != nil {
+		t.Fatalf("failed dropping the compatibility column: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := createTables(client); err != nil {
+			t.Fatalf("migration run %v failed: %v", i+1, err)
+		}
+	}
+
+	var exist bool
+	err = client.Client.QueryRow(client.Ctx, `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'schemas' AND column_name = 'compatibility')`).Scan(&exist)
+	if err != nil {
+		t.Fatalf("failed checking the compatibility column: %v", err)
+	}
+	if !exist {
+		t.Fatalf("expected the migration to add the compatibility column to an existing schemas table")
+	}
+}
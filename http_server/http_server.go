@@ -27,6 +27,7 @@ func InitializeHttpServer(s *server.Server) {
 		Monitoring:     server.MonitoringHandler{S: s},
 		PoisonMsgs:     server.PoisonMessagesHandler{S: s},
 		Schemas:        server.SchemasHandler{S: s},
+		SchemaRegistry: server.SchemaRegistryHandler{S: s},
 		Configurations: server.ConfigurationsHandler{S: s},
 		Integrations:   server.IntegrationsHandler{S: s},
 		Tenants:        server.TenantHandler{S: s},
@@ -28,4 +28,5 @@ func InitializeIntegrationsRoutes(router *gin.RouterGroup, h *server.Handlers) {
 	integrationsRoutes.GET("/getAllIntegrations", integrationsHandler.GetAllIntegrations)
 	integrationsRoutes.POST("/requestIntegration", integrationsHandler.RequestIntegration) // TODO to be deleted
 	integrationsRoutes.GET("/getAuditLogs", integrationsHandler.GetIntegrationAuditLogs)
+	integrationsRoutes.POST("/testWebhookIntegration", integrationsHandler.TestWebhookIntegration)
 }
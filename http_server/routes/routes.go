@@ -30,15 +30,19 @@ func InitializeHttpRoutes(handlers *server.Handlers) *gin.Engine {
 	utils.InitializeValidations()
 	InitializeUserMgmtRoutes(mainRouter)
 	InitializeStationsRoutes(mainRouter, handlers)
+	InitializeConsumersRoutes(mainRouter, handlers)
 	InitializeMonitoringRoutes(mainRouter, handlers)
 	InitializeTagsRoutes(mainRouter, handlers)
 	InitializeSchemasRoutes(mainRouter, handlers)
+	InitializeSchemaRegistryRoutes(mainRouter, handlers)
 	InitializeIntegrationsRoutes(mainRouter, handlers)
 	InitializeConfigurationsRoutes(mainRouter, handlers)
 	server.InitializeTenantsRoutes(mainRouter, handlers)
 	server.InitializeBillingRoutes(mainRouter, handlers)
 	InitializeAsyncTasksRoutes(mainRouter, handlers)
 	InitializeFunctionsRoutes(mainRouter, handlers)
+	InitializeRbacRoutes(mainRouter, handlers)
+	InitializeApiKeysRoutes(mainRouter, handlers)
 	ui.InitializeUIRoutes(router)
 
 	mainRouter.GET("/status", func(c *gin.Context) {
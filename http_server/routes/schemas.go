@@ -26,5 +26,6 @@ func InitializeSchemasRoutes(router *gin.RouterGroup, h *server.Handlers) {
 	schemasRoutes.DELETE("/removeSchema", schemasHandler.RemoveSchema)
 	schemasRoutes.POST("/createNewVersion", schemasHandler.CreateNewVersion)
 	schemasRoutes.PUT("/rollBackVersion", schemasHandler.RollBackVersion)
+	schemasRoutes.PUT("/updateCompatibility", schemasHandler.UpdateCompatibility)
 	schemasRoutes.POST("/validateSchema", schemasHandler.ValidateSchema)
 }
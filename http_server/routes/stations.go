@@ -25,6 +25,9 @@ func InitializeStationsRoutes(router *gin.RouterGroup, h *server.Handlers) {
 	stationsRoutes.GET("/getAllStations", stationsHandler.GetAllStations)
 	stationsRoutes.GET("/getStations", stationsHandler.GetStations)
 	stationsRoutes.GET("/getPoisonMessageJourney", stationsHandler.GetPoisonMessageJourney)
+	stationsRoutes.GET("/searchDlsMessages", stationsHandler.SearchDlsMessages)
+	stationsRoutes.POST("/exportDlsMessages", stationsHandler.ExportDlsMessages)
+	stationsRoutes.POST("/importDlsMessages", stationsHandler.ImportDlsMessages)
 	stationsRoutes.POST("/createStation", stationsHandler.CreateStation)
 	stationsRoutes.POST("/resendPoisonMessages", stationsHandler.ResendPoisonMessages)
 	stationsRoutes.DELETE("/removeStation", stationsHandler.RemoveStation)
@@ -32,6 +35,12 @@ func InitializeStationsRoutes(router *gin.RouterGroup, h *server.Handlers) {
 	stationsRoutes.DELETE("/removeSchemaFromStation", stationsHandler.RemoveSchemaFromStation)
 	stationsRoutes.GET("/getUpdatesForSchemaByStation", stationsHandler.GetUpdatesForSchemaByStation)
 	stationsRoutes.PUT("/updateDlsConfig", stationsHandler.UpdateDlsConfig)
+	stationsRoutes.PUT("/updateSchemaEnforcement", stationsHandler.UpdateSchemaEnforcement)
+	stationsRoutes.PUT("/updateDlsRetryPolicy", stationsHandler.UpdateDlsRetryPolicy)
+	stationsRoutes.PUT("/updateTieredStorageFormat", stationsHandler.UpdateTieredStorageFormat)
+	stationsRoutes.POST("/rehydrateFromTieredStorage", stationsHandler.RehydrateFromTieredStorage)
+	stationsRoutes.GET("/getTieredStorageReconciliation", stationsHandler.GetTieredStorageReconciliation)
+	stationsRoutes.PUT("/addPartitions", stationsHandler.AddPartitions)
 	stationsRoutes.POST("/dropDlsMessages", stationsHandler.DropDlsMessages)
 	stationsRoutes.DELETE("/purgeStation", stationsHandler.PurgeStation)
 	stationsRoutes.DELETE("/removeMessages", stationsHandler.RemoveMessages)
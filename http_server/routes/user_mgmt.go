@@ -21,6 +21,8 @@ func InitializeUserMgmtRoutes(router *gin.RouterGroup) {
 	userMgmtHandler := server.UserMgmtHandler{}
 	userMgmtRoutes := router.Group("/usermgmt")
 	userMgmtRoutes.POST("/login", userMgmtHandler.Login)
+	userMgmtRoutes.GET("/oidc/login", userMgmtHandler.OidcLogin)
+	userMgmtRoutes.POST("/oidc/callback", userMgmtHandler.OidcCallback)
 	userMgmtRoutes.POST("/doneNextSteps", userMgmtHandler.DoneNextSteps)
 	userMgmtRoutes.POST("/refreshToken", userMgmtHandler.RefreshToken)
 	userMgmtRoutes.POST("/addUser", userMgmtHandler.AddUser)
@@ -0,0 +1,117 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package idp
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var usernameRegex = regexp.MustCompile("^[a-z0-9_.-]*$")
+
+var (
+	ErrUserNotMapped = errors.New("the identity provider user is not mapped to a memphis user type")
+	ErrUserRejected  = errors.New("the user can not be provisioned from the identity provider")
+)
+
+// Identity is a user authenticated by an external identity provider mapped to memphis terms
+type Identity struct {
+	Username string
+	FullName string
+	UserType string
+	Groups   []string
+	Roles    []string
+}
+
+type Mapping struct {
+	// group name -> memphis user type
+	UserTypes map[string]string
+	// group name -> memphis role names
+	Roles           map[string][]string
+	DefaultUserType string
+}
+
+func NewMapping(userTypeMapping, roleMapping, defaultUserType string) Mapping {
+	return Mapping{
+		UserTypes:       ParseUserTypeMapping(userTypeMapping),
+		Roles:           ParseRoleMapping(roleMapping),
+		DefaultUserType: strings.ToLower(defaultUserType),
+	}
+}
+
+// parseMapping parses "group=value,group=value" pairs
+func parseMapping(mapping string, add func(group, value string)) {
+	for _, pair := range strings.Split(mapping, ",") {
+		group, value, found := strings.Cut(pair, "=")
+		group = strings.TrimSpace(group)
+		value = strings.TrimSpace(value)
+		if !found || group == "" || value == "" {
+			continue
+		}
+		add(group, value)
+	}
+}
+
+func ParseUserTypeMapping(mapping string) map[string]string {
+	userTypes := map[string]string{}
+	parseMapping(mapping, func(group, value string) {
+		userTypes[group] = strings.ToLower(value)
+	})
+	return userTypes
+}
+
+func ParseRoleMapping(mapping string) map[string][]string {
+	roles := map[string][]string{}
+	parseMapping(mapping, func(group, value string) {
+		roles[group] = append(roles[group], value)
+	})
+	return roles
+}
+
+func ParseTenantMapping(mapping string) map[string]string {
+	tenants := map[string]string{}
+	parseMapping(mapping, func(group, value string) {
+		tenants[group] = strings.ToLower(value)
+	})
+	return tenants
+}
+
+func ValidateUsername(username string) bool {
+	return username != "" && len(username) <= 60 && usernameRegex.MatchString(username)
+}
+
+// MapGroups sets the user type and role names of the identity from its groups,
+// a group mapped to management takes precedence over one mapped to application
+func (m Mapping) MapGroups(identity *Identity) error {
+	identity.UserType = ""
+	identity.Roles = []string{}
+	seenRoles := map[string]bool{}
+	for _, group := range identity.Groups {
+		if userType, ok := m.UserTypes[group]; ok && identity.UserType != "management" {
+			identity.UserType = userType
+		}
+		for _, role := range m.Roles[group] {
+			if !seenRoles[role] {
+				seenRoles[role] = true
+				identity.Roles = append(identity.Roles, role)
+			}
+		}
+	}
+	if identity.UserType == "" {
+		identity.UserType = m.DefaultUserType
+	}
+	if identity.UserType != "management" && identity.UserType != "application" {
+		return ErrUserNotMapped
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package idp
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/memphisdev/memphis/models"
+)
+
+func TestMapGroups(t *testing.T) {
+	mapping := NewMapping("apps=application, admins=Management,broken", "apps=producers,admins=producers,admins=auditors", "")
+	if len(mapping.UserTypes) != 2 || mapping.UserTypes["admins"] != "management" {
+		t.Fatalf("unexpected user type mapping %v", mapping.UserTypes)
+	}
+
+	identity := Identity{Username: "svc", Groups: []string{"apps", "admins"}}
+	if err := mapping.MapGroups(&identity); err != nil || identity.UserType != "management" {
+		t.Fatalf("expected management to take precedence, got %+v %v", identity, err)
+	}
+	if !reflect.DeepEqual(identity.Roles, []string{"producers", "auditors"}) {
+		t.Fatalf("expected unique role names, got %v", identity.Roles)
+	}
+
+	identity = Identity{Username: "svc", Groups: []string{"others"}}
+	if err := mapping.MapGroups(&identity); err != ErrUserNotMapped {
+		t.Fatalf("expected an unmapped user error, got %v", err)
+	}
+	mapping.DefaultUserType = "application"
+	if err := mapping.MapGroups(&identity); err != nil || identity.UserType != "application" || len(identity.Roles) != 0 {
+		t.Fatalf("expected the default user type, got %+v %v", identity, err)
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	for username, valid := range map[string]bool{"jdoe": true, "j.doe-1_a": true, "": false, "JDoe": false, "j doe": false, "jdoe@example.com": false} {
+		if ValidateUsername(username) != valid {
+			t.Fatalf("expected ValidateUsername(%q) to be %v", username, valid)
+		}
+	}
+}
+
+func TestCheckExistingUser(t *testing.T) {
+	identity := Identity{Username: "dev", UserType: "management"}
+	if err := checkExistingUser(models.User{Username: "dev", UserType: "management", Owner: "ldap"}, identity, "ldap"); err != nil {
+		t.Fatalf("expected a user the provider created to be linked, got %v", err)
+	}
+	rejected := []models.User{
+		{Username: "dev", UserType: "management", Owner: "root"},
+		{Username: "dev", UserType: "management", Owner: "sso"},
+		{Username: "dev", UserType: "management"},
+		{Username: "dev", UserType: "application", Owner: "ldap"},
+		{Username: "root", UserType: "root", Owner: "ldap"},
+	}
+	for _, user := range rejected {
+		if err := checkExistingUser(user, identity, "ldap"); !errors.Is(err, ErrUserRejected) {
+			t.Fatalf("expected user %+v to be rejected, got %v", user, err)
+		}
+	}
+}
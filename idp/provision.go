@@ -0,0 +1,131 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package idp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/memphisdev/memphis/conf"
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/memphis_cache"
+	"github.com/memphisdev/memphis/models"
+)
+
+// ProvisionUser returns the memphis user of the identity and creates it on its first login,
+// when syncRoles is set the user's roles follow the identity provider groups on every login
+func ProvisionUser(identity Identity, tenantName, owner string, syncRoles bool) (models.User, bool, error) {
+	if tenantName != conf.GlobalAccount {
+		tenantName = strings.ToLower(tenantName)
+	}
+
+	exist, user, err := memphis_cache.GetUser(identity.Username, tenantName, false)
+	if err != nil {
+		return models.User{}, false, err
+	}
+	if exist {
+		if err := checkExistingUser(user, identity, owner); err != nil {
+			return models.User{}, false, err
+		}
+	} else {
+		// users provisioned from an identity provider have no local password
+		user, err = db.CreateUser(identity.Username, identity.UserType, "", identity.FullName, false, 1, tenantName, false, "", "", owner, "")
+		if err != nil {
+			if !strings.Contains(err.Error(), "already exist") {
+				return models.User{}, false, err
+			}
+			// provisioned concurrently by another request
+			exist, user, err = memphis_cache.GetUser(identity.Username, tenantName, true)
+			if err != nil {
+				return models.User{}, false, err
+			}
+			if !exist {
+				return models.User{}, false, fmt.Errorf("user %v could not be provisioned", identity.Username)
+			}
+			if err := checkExistingUser(user, identity, owner); err != nil {
+				return models.User{}, false, err
+			}
+		}
+	}
+
+	rolesChanged := false
+	if syncRoles {
+		roleIDs, err := mappedRoleIds(identity, tenantName)
+		if err != nil {
+			return models.User{}, false, err
+		}
+		if !sameRoleIds(user.Roles, roleIDs) {
+			err = db.UpdateUserRole(tenantName, user.Username, roleIDs)
+			if err != nil {
+				return models.User{}, false, err
+			}
+			user.Roles = roleIDs
+			rolesChanged = true
+		}
+	}
+	if !exist || rolesChanged {
+		memphis_cache.SetUser(user)
+	}
+
+	return user, rolesChanged, nil
+}
+
+// checkExistingUser makes sure an identity is only linked to a user the same identity provider created,
+// so a local user or a user of another provider can not be taken over by logging in with the same username
+func checkExistingUser(user models.User, identity Identity, owner string) error {
+	if user.UserType == "root" {
+		return fmt.Errorf("%w: the root user can not authenticate with an identity provider", ErrUserRejected)
+	}
+	if user.Owner != owner {
+		return fmt.Errorf("%w: user %v was not created by the identity provider", ErrUserRejected, user.Username)
+	}
+	if user.UserType != identity.UserType {
+		return fmt.Errorf("%w: user %v already exists as a %v user", ErrUserRejected, user.Username, user.UserType)
+	}
+	return nil
+}
+
+func mappedRoleIds(identity Identity, tenantName string) ([]int, error) {
+	roleIDs := []int{}
+	if len(identity.Roles) == 0 {
+		return roleIDs, nil
+	}
+	roles, err := db.GetRolesByTenant(tenantName)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range identity.Roles {
+		for _, role := range roles {
+			if role.Name == name && role.Type == identity.UserType {
+				roleIDs = append(roleIDs, role.ID)
+			}
+		}
+	}
+	return roleIDs, nil
+}
+
+func sameRoleIds(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ids := map[int]int{}
+	for _, id := range a {
+		ids[id]++
+	}
+	for _, id := range b {
+		if ids[id] == 0 {
+			return false
+		}
+		ids[id]--
+	}
+	return true
+}
@@ -0,0 +1,300 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package ldap_auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/memphisdev/memphis/conf"
+	"github.com/memphisdev/memphis/idp"
+	"github.com/memphisdev/memphis/memphis_cache"
+	"github.com/memphisdev/memphis/models"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	provisionedUsersOwner = "ldap"
+	dialTimeout           = 10 * time.Second
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrTimeout            = errors.New("the directory did not answer in time")
+)
+
+type Config struct {
+	URL               string
+	StartTLS          bool
+	TLSConfig         *tls.Config
+	BindDN            string
+	BindPassword      string
+	BaseDN            string
+	UserFilter        string
+	FullNameAttribute string
+	GroupAttribute    string
+	Mapping           idp.Mapping
+	// group name -> tenant name
+	Tenants map[string]string
+	Timeout time.Duration
+}
+
+type Authenticator struct {
+	config Config
+}
+
+var (
+	configuration            = conf.GetConfig()
+	defaultAuthenticator     *Authenticator
+	defaultAuthenticatorErr  error
+	defaultAuthenticatorOnce sync.Once
+)
+
+func Enabled() bool {
+	return configuration.LDAP_ENABLED && configuration.LDAP_URL != "" && configuration.LDAP_BASE_DN != ""
+}
+
+// ProvisionedUser reports whether the user was created by the directory, only those users authenticate against it
+func ProvisionedUser(user models.User) bool {
+	return user.Owner == provisionedUsersOwner
+}
+
+// GetAuthenticator returns the authenticator configured through the LDAP_* configuration keys
+func GetAuthenticator() (*Authenticator, error) {
+	defaultAuthenticatorOnce.Do(func() {
+		config, err := ConfigFromConfiguration(configuration)
+		if err != nil {
+			defaultAuthenticatorErr = err
+			return
+		}
+		defaultAuthenticator = NewAuthenticator(config)
+	})
+	return defaultAuthenticator, defaultAuthenticatorErr
+}
+
+func ConfigFromConfiguration(configuration conf.Configuration) (Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: configuration.LDAP_INSECURE_SKIP_VERIFY}
+	if configuration.LDAP_TLS_CA != "" {
+		caCert, err := os.ReadFile(configuration.LDAP_TLS_CA)
+		if err != nil {
+			return Config{}, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return Config{}, fmt.Errorf("no certificates found in %v", configuration.LDAP_TLS_CA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return Config{
+		URL:               configuration.LDAP_URL,
+		StartTLS:          configuration.LDAP_START_TLS,
+		TLSConfig:         tlsConfig,
+		BindDN:            configuration.LDAP_BIND_DN,
+		BindPassword:      configuration.LDAP_BIND_PASSWORD,
+		BaseDN:            configuration.LDAP_BASE_DN,
+		UserFilter:        configuration.LDAP_USER_FILTER,
+		FullNameAttribute: configuration.LDAP_FULL_NAME_ATTRIBUTE,
+		GroupAttribute:    configuration.LDAP_GROUP_ATTRIBUTE,
+		Mapping:           idp.NewMapping(configuration.LDAP_USER_TYPE_MAPPING, configuration.LDAP_ROLE_MAPPING, configuration.LDAP_DEFAULT_USER_TYPE),
+		Tenants:           idp.ParseTenantMapping(configuration.LDAP_TENANT_MAPPING),
+	}, nil
+}
+
+func NewAuthenticator(config Config) *Authenticator {
+	if config.Timeout <= 0 {
+		config.Timeout = dialTimeout
+	}
+	return &Authenticator{config: config}
+}
+
+func (a *Authenticator) connect() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(a.config.URL, ldap.DialWithDialer(&net.Dialer{Timeout: a.config.Timeout}), ldap.DialWithTLSConfig(a.config.TLSConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(a.config.Timeout)
+	if _, isTLS := conn.TLSConnectionState(); a.config.StartTLS && !isTLS {
+		// unlike ldaps urls, start tls does not verify the certificate against the url's host by itself
+		tlsConfig := a.config.TLSConfig.Clone()
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName == "" {
+			if u, err := url.Parse(a.config.URL); err == nil {
+				tlsConfig.ServerName = u.Hostname()
+			}
+		}
+		err = conn.StartTLS(tlsConfig)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Authenticate verifies the credentials against the directory with a search and bind,
+// successful authentications are cached so repeated connections do not bind every time
+func (a *Authenticator) Authenticate(username, password string) (idp.Identity, error) {
+	username, identity, cached, err := cachedIdentity(username, password)
+	if err != nil || cached {
+		return identity, err
+	}
+	return a.authenticate(username, password)
+}
+
+// AuthenticateWithTimeout is Authenticate for paths which hold up a connection, a cached authentication returns right away
+// and the directory gets at most the timeout to answer, an answer arriving later is still cached for the next attempt
+func (a *Authenticator) AuthenticateWithTimeout(username, password string, timeout time.Duration) (idp.Identity, error) {
+	username, identity, cached, err := cachedIdentity(username, password)
+	if err != nil || cached {
+		return identity, err
+	}
+
+	type result struct {
+		identity idp.Identity
+		err      error
+	}
+	results := make(chan result, 1)
+	go func() {
+		identity, err := a.authenticate(username, password)
+		results <- result{identity: identity, err: err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-results:
+		return r.identity, r.err
+	case <-timer.C:
+		return idp.Identity{}, ErrTimeout
+	}
+}
+
+func cachedIdentity(username, password string) (string, idp.Identity, bool, error) {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if password == "" || !idp.ValidateUsername(username) {
+		return username, idp.Identity{}, false, ErrInvalidCredentials
+	}
+	var identity idp.Identity
+	cached, err := memphis_cache.GetLdapIdentity(username, password, &identity)
+	if err == nil && cached {
+		return username, identity, true, nil
+	}
+	return username, idp.Identity{}, false, nil
+}
+
+func (a *Authenticator) authenticate(username, password string) (idp.Identity, error) {
+	conn, err := a.connect()
+	if err != nil {
+		return idp.Identity{}, err
+	}
+	defer conn.Close()
+
+	if a.config.BindDN != "" {
+		err = conn.Bind(a.config.BindDN, a.config.BindPassword)
+		if err != nil {
+			return idp.Identity{}, fmt.Errorf("service account bind: %v", err)
+		}
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.config.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2,
+		0,
+		false,
+		strings.ReplaceAll(a.config.UserFilter, "{username}", ldap.EscapeFilter(username)),
+		[]string{a.config.FullNameAttribute, a.config.GroupAttribute},
+		nil,
+	))
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) || (err == nil && len(result.Entries) > 1) {
+		return idp.Identity{}, fmt.Errorf("the user filter matches more than one entry for %v", username)
+	} else if err != nil {
+		return idp.Identity{}, err
+	}
+	if len(result.Entries) == 0 {
+		return idp.Identity{}, ErrInvalidCredentials
+	}
+	entry := result.Entries[0]
+	err = conn.Bind(entry.DN, password)
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		return idp.Identity{}, ErrInvalidCredentials
+	} else if err != nil {
+		return idp.Identity{}, err
+	}
+
+	identity := idp.Identity{
+		Username: username,
+		FullName: strings.ToLower(entry.GetAttributeValue(a.config.FullNameAttribute)),
+		Groups:   groupNames(entry.GetAttributeValues(a.config.GroupAttribute)),
+	}
+	err = a.config.Mapping.MapGroups(&identity)
+	if err != nil {
+		return idp.Identity{}, err
+	}
+	memphis_cache.SetLdapIdentity(username, password, identity)
+	return identity, nil
+}
+
+// TenantAllowed reports whether the directory groups of the identity map it to the tenant,
+// without a tenant mapping directory users only belong to the global tenant
+func (a *Authenticator) TenantAllowed(identity idp.Identity, tenantName string) bool {
+	tenantName = strings.ToLower(tenantName)
+	if len(a.config.Tenants) == 0 {
+		return tenantName == conf.MemphisGlobalAccountName
+	}
+	for _, group := range identity.Groups {
+		if tenant, ok := a.config.Tenants[group]; ok && tenant == tenantName {
+			return true
+		}
+	}
+	return false
+}
+
+// groupNames returns both the DN and the common name of every group so either can be used in the mappings
+func groupNames(groupDNs []string) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, groupDN := range groupDNs {
+		add(groupDN)
+		dn, err := ldap.ParseDN(groupDN)
+		if err != nil || len(dn.RDNs) == 0 {
+			continue
+		}
+		for _, attribute := range dn.RDNs[0].Attributes {
+			if strings.EqualFold(attribute.Type, "cn") {
+				add(attribute.Value)
+			}
+		}
+	}
+	return names
+}
+
+// ProvisionUser returns the memphis user of a directory identity and creates it on its first login
+func (a *Authenticator) ProvisionUser(identity idp.Identity, tenantName string) (models.User, bool, error) {
+	return idp.ProvisionUser(identity, tenantName, provisionedUsersOwner, len(a.config.Mapping.Roles) > 0)
+}
@@ -0,0 +1,335 @@
+package ldap_auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/memphisdev/memphis/idp"
+	"github.com/memphisdev/memphis/memphis_cache"
+	"github.com/memphisdev/memphis/models"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+func TestGroupNames(t *testing.T) {
+	names := groupNames([]string{
+		"CN=Memphis Admins,OU=Groups,DC=example,DC=com",
+		"cn=apps,ou=groups,dc=example,dc=com",
+		"cn=apps,ou=other,dc=example,dc=com",
+		"not a dn",
+	})
+	expected := []string{
+		"CN=Memphis Admins,OU=Groups,DC=example,DC=com", "Memphis Admins",
+		"cn=apps,ou=groups,dc=example,dc=com", "apps",
+		"cn=apps,ou=other,dc=example,dc=com",
+		"not a dn",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	if err := memphis_cache.InitializeLdapCache(60); err != nil {
+		t.Fatalf("InitializeLdapCache: %v", err)
+	}
+	// nothing listens on the url, so only rejected input and cached authentications succeed without an error
+	authenticator := NewAuthenticator(Config{URL: "ldap://127.0.0.1:1", BaseDN: "dc=example,dc=com", UserFilter: "(uid={username})"})
+
+	for _, credentials := range [][2]string{{"jdoe", ""}, {"", "secret"}, {"jdoe)(uid=*", "secret"}} {
+		if _, err := authenticator.Authenticate(credentials[0], credentials[1]); err != ErrInvalidCredentials {
+			t.Fatalf("expected %v to be rejected before binding, got %v", credentials, err)
+		}
+	}
+
+	cached := idp.Identity{Username: "jdoe", UserType: "application", Groups: []string{"apps"}, Roles: []string{"producers"}}
+	if err := memphis_cache.SetLdapIdentity("jdoe", "secret", cached); err != nil {
+		t.Fatalf("SetLdapIdentity: %v", err)
+	}
+	identity, err := authenticator.Authenticate("JDoe", "secret")
+	if err != nil || !reflect.DeepEqual(identity, cached) {
+		t.Fatalf("expected the cached identity, got %+v %v", identity, err)
+	}
+	if _, err = authenticator.Authenticate("jdoe", "other"); err == nil || err == ErrInvalidCredentials {
+		t.Fatalf("expected a different password to reach the directory, got %v", err)
+	}
+}
+
+func TestProvisionedUser(t *testing.T) {
+	if !ProvisionedUser(models.User{Username: "dev", Owner: "ldap"}) {
+		t.Fatalf("expected a user created by the directory to authenticate against it")
+	}
+	for _, owner := range []string{"", "root", "sso"} {
+		if ProvisionedUser(models.User{Username: "dev", Owner: owner}) {
+			t.Fatalf("expected a user owned by %q not to authenticate against the directory", owner)
+		}
+	}
+}
+
+type mockEntry struct {
+	dn         string
+	attributes map[string][]string
+}
+
+// mockDirectory answers the start tls, bind and search requests of an authentication
+type mockDirectory struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	passwords map[string]string
+	entries   []mockEntry
+}
+
+func newMockDirectory(t *testing.T) (*mockDirectory, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ldap.test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	d := &mockDirectory{
+		listener:  listener,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}},
+		passwords: map[string]string{
+			"cn=service,dc=example,dc=com":         "service-secret",
+			"uid=jdoe,ou=people,dc=example,dc=com": "jdoe-secret",
+		},
+		entries: []mockEntry{
+			{dn: "uid=jdoe,ou=people,dc=example,dc=com", attributes: map[string][]string{
+				"uid":      {"jdoe"},
+				"cn":       {"John Doe"},
+				"memberOf": {"cn=admins,ou=groups,dc=example,dc=com", "cn=apps,ou=groups,dc=example,dc=com"},
+			}},
+			{dn: "uid=twin,ou=people,dc=example,dc=com", attributes: map[string][]string{"uid": {"twin"}}},
+			{dn: "uid=twin,ou=partners,dc=example,dc=com", attributes: map[string][]string{"uid": {"twin"}}},
+		},
+	}
+	go d.serve()
+	t.Cleanup(func() { listener.Close() })
+	return d, pool
+}
+
+func (d *mockDirectory) serve() {
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		go d.handle(conn)
+	}
+}
+
+func (d *mockDirectory) reply(conn net.Conn, id int64, op *ber.Packet) {
+	envelope := ber.NewSequence("LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	envelope.AppendChild(op)
+	conn.Write(envelope.Bytes())
+}
+
+func mockResult(tag ber.Tag, code uint16) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return result
+}
+
+func (d *mockDirectory) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	for {
+		message, err := ber.ReadPacket(conn)
+		if err != nil || len(message.Children) < 2 {
+			return
+		}
+		id, _ := message.Children[0].Value.(int64)
+		op := message.Children[1]
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, _ := op.Children[1].Value.(string)
+			if expected, ok := d.passwords[dn]; ok && expected == op.Children[2].Data.String() {
+				d.reply(conn, id, mockResult(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess))
+			} else {
+				d.reply(conn, id, mockResult(ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials))
+			}
+		case ldap.ApplicationSearchRequest:
+			// the mock only supports equality filters
+			filter := op.Children[6]
+			attribute, value := "", ""
+			if filter.ClassType == ber.ClassContext && filter.Tag == ldap.FilterEqualityMatch {
+				attribute, _ = filter.Children[0].Value.(string)
+				value, _ = filter.Children[1].Value.(string)
+			}
+			for _, entry := range d.entries {
+				if len(entry.attributes[attribute]) == 0 || entry.attributes[attribute][0] != value {
+					continue
+				}
+				result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+				result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "DN"))
+				attributes := ber.NewSequence("Attributes")
+				for name, values := range entry.attributes {
+					attr := ber.NewSequence("Attribute")
+					attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+					set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+					for _, v := range values {
+						set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+					}
+					attr.AppendChild(set)
+					attributes.AppendChild(attr)
+				}
+				result.AppendChild(attributes)
+				d.reply(conn, id, result)
+			}
+			d.reply(conn, id, mockResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+		case ldap.ApplicationExtendedRequest:
+			d.reply(conn, id, mockResult(ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess))
+			tlsConn := tls.Server(conn, d.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func TestAuthenticateAgainstDirectory(t *testing.T) {
+	if err := memphis_cache.InitializeLdapCache(60); err != nil {
+		t.Fatalf("InitializeLdapCache: %v", err)
+	}
+	directory, pool := newMockDirectory(t)
+	authenticator := NewAuthenticator(Config{
+		URL:               "ldap://" + directory.listener.Addr().String(),
+		StartTLS:          true,
+		TLSConfig:         &tls.Config{RootCAs: pool},
+		BindDN:            "cn=service,dc=example,dc=com",
+		BindPassword:      "service-secret",
+		BaseDN:            "dc=example,dc=com",
+		UserFilter:        "(uid={username})",
+		FullNameAttribute: "cn",
+		GroupAttribute:    "memberOf",
+		Mapping:           idp.NewMapping("admins=management", "admins=auditors", ""),
+		Timeout:           time.Second,
+	})
+
+	identity, err := authenticator.Authenticate("jdoe", "jdoe-secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if identity.Username != "jdoe" || identity.FullName != "john doe" || identity.UserType != "management" || !reflect.DeepEqual(identity.Roles, []string{"auditors"}) {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+	if _, err = authenticator.Authenticate("jdoe", "wrong"); err != ErrInvalidCredentials {
+		t.Fatalf("expected a wrong password to be rejected, got %v", err)
+	}
+	if _, err = authenticator.Authenticate("nobody", "secret"); err != ErrInvalidCredentials {
+		t.Fatalf("expected an unknown user to be rejected, got %v", err)
+	}
+	if _, err = authenticator.Authenticate("twin", "secret"); err == nil || err == ErrInvalidCredentials {
+		t.Fatalf("expected a filter matching more than one entry to fail, got %v", err)
+	}
+
+	authenticator.config.BindPassword = "wrong"
+	if _, err = authenticator.Authenticate("jdoe", "other-password"); err == nil || err == ErrInvalidCredentials {
+		t.Fatalf("expected a failed service account bind to be an error, got %v", err)
+	}
+
+	authenticator.config.BindPassword = "service-secret"
+	authenticator.config.TLSConfig = &tls.Config{}
+	if _, err = authenticator.Authenticate("jdoe", "another-password"); err == nil {
+		t.Fatalf("expected a certificate which is not trusted to be rejected")
+	}
+}
+
+func TestAuthenticateWithTimeout(t *testing.T) {
+	if err := memphis_cache.InitializeLdapCache(60); err != nil {
+		t.Fatalf("InitializeLdapCache: %v", err)
+	}
+	// the directory accepts connections but never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+	authenticator := NewAuthenticator(Config{URL: "ldap://" + listener.Addr().String(), BaseDN: "dc=example,dc=com", UserFilter: "(uid={username})", Timeout: 5 * time.Second})
+
+	start := time.Now()
+	if _, err = authenticator.AuthenticateWithTimeout("slow", "secret", 100*time.Millisecond); err != ErrTimeout {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the authentication to give up after the timeout, took %v", elapsed)
+	}
+
+	cached := idp.Identity{Username: "fast", UserType: "application", Groups: []string{"apps"}, Roles: []string{}}
+	if err := memphis_cache.SetLdapIdentity("fast", "secret", cached); err != nil {
+		t.Fatalf("SetLdapIdentity: %v", err)
+	}
+	identity, err := authenticator.AuthenticateWithTimeout("fast", "secret", time.Nanosecond)
+	if err != nil || !reflect.DeepEqual(identity, cached) {
+		t.Fatalf("expected the cached identity without reaching the directory, got %+v %v", identity, err)
+	}
+}
+
+func TestTenantAllowed(t *testing.T) {
+	identity := idp.Identity{Username: "jdoe", Groups: []string{"cn=apps,ou=groups,dc=example,dc=com", "apps"}}
+
+	authenticator := NewAuthenticator(Config{})
+	if !authenticator.TenantAllowed(identity, "$memphis") || authenticator.TenantAllowed(identity, "acme") {
+		t.Fatalf("expected directory users to only belong to the global tenant without a tenant mapping")
+	}
+
+	authenticator = NewAuthenticator(Config{Tenants: idp.ParseTenantMapping("apps=Acme, others=globex")})
+	if !authenticator.TenantAllowed(identity, "acme") {
+		t.Fatalf("expected the mapped tenant to be allowed")
+	}
+	if authenticator.TenantAllowed(identity, "globex") || authenticator.TenantAllowed(identity, "$memphis") {
+		t.Fatalf("expected tenants the user's groups are not mapped to to be rejected")
+	}
+}
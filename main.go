@@ -24,6 +24,7 @@ import (
 	"github.com/memphisdev/memphis/db"
 	"github.com/memphisdev/memphis/http_server"
 	"github.com/memphisdev/memphis/server"
+	"github.com/memphisdev/memphis/tracing"
 
 	"os"
 
@@ -106,6 +107,7 @@ func runMemphis(s *server.Server) {
 	if err != nil {
 		s.Errorf("Failed initializing analytics: " + err.Error())
 	}
+	s.InitializeTracing()
 
 	isUserPassBased := os.Getenv("USER_PASS_BASED_AUTH") == "true"
 
@@ -229,5 +231,6 @@ func main() {
 	runMemphis(s)
 	defer db.CloseMetadataDb(metadataDb, s)
 	defer analytics.Close()
+	defer tracing.Close()
 	s.WaitForShutdown()
 }
@@ -0,0 +1,110 @@
+package memphis_cache
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+)
+
+var LCache LdapCache
+
+// LdapCache keeps successful directory authentications so a client does not need a bind per connect,
+// passwords are kept as an HMAC with a key that never leaves the process
+type LdapCache struct {
+	Cache *MemphisCache
+	key   []byte
+	ttl   time.Duration
+}
+
+type ldapCacheEntry struct {
+	PasswordHash []byte          `json:"password_hash"`
+	ExpiresAt    time.Time       `json:"expires_at"`
+	Identity     json.RawMessage `json:"identity"`
+}
+
+func InitializeLdapCache(ttlSeconds int) error {
+	if ttlSeconds <= 0 {
+		LCache = LdapCache{}
+		return nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	lifeMinutes := (ttlSeconds + 59) / 60
+	cache, err := New(context.Background(), lifeMinutes, lifeMinutes, configuration.USER_CACHE_MAX_SIZE_MB)
+	if err != nil {
+		return err
+	}
+	LCache = LdapCache{Cache: cache, key: key, ttl: time.Duration(ttlSeconds) * time.Second}
+	return nil
+}
+
+func (lc *LdapCache) passwordHash(username, password string) []byte {
+	mac := hmac.New(sha256.New, lc.key)
+	mac.Write([]byte(username))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+// GetLdapIdentity loads the cached identity of the user into identity when the password matches the cached one
+func GetLdapIdentity(username, password string, identity interface{}) (bool, error) {
+	if LCache.Cache == nil {
+		return false, nil
+	}
+	data, err := LCache.Cache.Get(username)
+	if err == bigcache.ErrEntryNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	var entry ldapCacheEntry
+	err = json.Unmarshal(data, &entry)
+	if err != nil {
+		return false, err
+	}
+	if time.Now().After(entry.ExpiresAt) || !hmac.Equal(entry.PasswordHash, LCache.passwordHash(username, password)) {
+		return false, nil
+	}
+	err = json.Unmarshal(entry.Identity, identity)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func SetLdapIdentity(username, password string, identity interface{}) error {
+	if LCache.Cache == nil {
+		return nil
+	}
+	rawIdentity, err := json.Marshal(identity)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(ldapCacheEntry{
+		PasswordHash: LCache.passwordHash(username, password),
+		ExpiresAt:    time.Now().Add(LCache.ttl),
+		Identity:     rawIdentity,
+	})
+	if err != nil {
+		return err
+	}
+	return LCache.Cache.Set(username, data)
+}
+
+func DeleteLdapIdentity(username string) error {
+	if LCache.Cache == nil {
+		return nil
+	}
+	err := LCache.Cache.Delete(username)
+	if err == bigcache.ErrEntryNotFound {
+		return nil
+	}
+	return err
+}
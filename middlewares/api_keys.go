@@ -0,0 +1,200 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package middlewares
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/memphis_cache"
+	"github.com/memphisdev/memphis/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	apiKeyAuthScheme          = "apikey"
+	apiKeyPrefix              = "memphis_"
+	apiKeyPublicIdSize        = 6
+	apiKeySecretSize          = 32
+	apiKeyLastUsedGranularity = time.Minute
+)
+
+// routes which accept an api key sent as basic auth credentials
+var apiKeyBasicAuthRoutes = []string{
+	"/api/schema-registry/",
+}
+
+// routes an api key can never call, so a leaked key can not manage users, roles, keys or tenant settings
+var apiKeyForbiddenRoutes = []string{
+	"/api/usermgmt/",
+	"/api/rbac/",
+	"/api/apikeys/",
+	"/api/tenants/",
+	"/api/billing/",
+	"/api/integrations/",
+	"/api/configurations/",
+}
+
+// on top of read requests every scope can modify the resources under its routes
+var apiKeyScopeRoutes = map[string][]string{
+	models.ApiKeyScopeReadOnly:     {},
+	models.ApiKeyScopeStationAdmin: {"/api/stations/", "/api/consumers/"},
+	models.ApiKeyScopeSchemaAdmin:  {"/api/schemas/", "/api/schema-registry/"},
+}
+
+func IsValidApiKeyScope(scope string) bool {
+	_, ok := apiKeyScopeRoutes[scope]
+	return ok
+}
+
+// GenerateApiKey returns a new key and its public prefix, only the hash of the key is stored
+func GenerateApiKey() (string, string, error) {
+	publicId := make([]byte, apiKeyPublicIdSize)
+	if _, err := rand.Read(publicId); err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, apiKeySecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix := apiKeyPrefix + hex.EncodeToString(publicId)
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// HashApiKey hashes a key for storage and lookup, keys are random so a plain SHA-256 is enough
+func HashApiKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func extractApiKey(authHeader string) (string, bool) {
+	scheme, key, found := strings.Cut(authHeader, " ")
+	if !found || !strings.EqualFold(scheme, apiKeyAuthScheme) {
+		return "", false
+	}
+	return strings.TrimSpace(key), true
+}
+
+func isBasicAuthRoute(path string) bool {
+	for _, route := range apiKeyBasicAuthRoutes {
+		if strings.HasPrefix(path, route) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractBasicAuthApiKey reads a key sent as basic auth credentials the way schema registry clients do,
+// either the key prefix as the username and the rest of the key as the password or the whole key as the password
+func extractBasicAuthApiKey(r *http.Request) (string, bool) {
+	username, password, ok := r.BasicAuth()
+	if !ok || password == "" {
+		return "", false
+	}
+	if strings.HasPrefix(password, apiKeyPrefix) {
+		return password, true
+	}
+	return username + "_" + password, true
+}
+
+func apiKeyScopeAllows(scope, method, path string) bool {
+	for _, route := range apiKeyForbiddenRoutes {
+		if strings.HasPrefix(path, route) {
+			return false
+		}
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return IsValidApiKeyScope(scope)
+	}
+	for _, route := range apiKeyScopeRoutes[scope] {
+		if strings.HasPrefix(path, route) {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeyRoles returns the roles a key acts with, a key can never exceed the roles of the user who created it
+func apiKeyRoles(keyRoles, userRoles []int) ([]int, bool) {
+	if len(keyRoles) == 0 {
+		return userRoles, true
+	}
+	if len(userRoles) == 0 {
+		return keyRoles, true
+	}
+	roles := []int{}
+	for _, keyRole := range keyRoles {
+		for _, userRole := range userRoles {
+			if keyRole == userRole {
+				roles = append(roles, keyRole)
+				break
+			}
+		}
+	}
+	// no roles at all means no restrictions, so a key whose roles were all taken from its user is rejected
+	return roles, len(roles) > 0
+}
+
+// authenticateApiKey authenticates a request made with an "Authorization: ApiKey <key>" header,
+// the request is made on behalf of the user who created the key
+func authenticateApiKey(c *gin.Context, key, path string) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		c.AbortWithStatusJSON(401, gin.H{"message": "Unauthorized"})
+		return
+	}
+	exist, apiKey, err := db.GetApiKeyByHash(HashApiKey(key))
+	if err != nil {
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist || (apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt)) {
+		c.AbortWithStatusJSON(401, gin.H{"message": "Unauthorized"})
+		return
+	}
+	if !apiKeyScopeAllows(apiKey.Scope, c.Request.Method, path) {
+		c.AbortWithStatusJSON(403, gin.H{"message": "This API key is not allowed to perform this operation"})
+		return
+	}
+
+	exists, user, err := memphis_cache.GetUser(apiKey.CreatedBy, apiKey.TenantName, false)
+	if err != nil {
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	// a user removed and created again with the same name does not inherit the keys of the removed one
+	if !exists || user.ID != apiKey.UserID || user.UserType == "application" {
+		c.AbortWithStatusJSON(401, gin.H{"message": "Unauthorized"})
+		return
+	}
+	roles, ok := apiKeyRoles(apiKey.RoleIDs, user.Roles)
+	if !ok {
+		c.AbortWithStatusJSON(403, gin.H{"message": "This API key is not allowed to perform this operation"})
+		return
+	}
+	user.Roles = roles
+	user.Password = ""
+
+	if apiKey.LastUsedAt == nil || time.Since(*apiKey.LastUsedAt) > apiKeyLastUsedGranularity {
+		go db.UpdateApiKeyLastUsed(apiKey.ID)
+	}
+
+	c.Set("user", user)
+	c.Set("api_key", apiKey)
+	c.Next()
+}
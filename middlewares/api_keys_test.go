@@ -0,0 +1,89 @@
+package middlewares
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/memphisdev/memphis/models"
+)
+
+func TestGenerateApiKey(t *testing.T) {
+	key, prefix, err := GenerateApiKey()
+	if err != nil {
+		t.Fatalf("GenerateApiKey: %v", err)
+	}
+	if !strings.HasPrefix(key, prefix+"_") || !strings.HasPrefix(prefix, apiKeyPrefix) {
+		t.Fatalf("expected key %v to start with prefix %v", key, prefix)
+	}
+	other, _, _ := GenerateApiKey()
+	if other == key || HashApiKey(other) == HashApiKey(key) {
+		t.Fatalf("expected generated keys to be unique")
+	}
+	if extracted, ok := extractApiKey("ApiKey " + key); !ok || extracted != key {
+		t.Fatalf("expected the key to be extracted from the header, got %v %v", extracted, ok)
+	}
+	if _, ok := extractApiKey("Bearer " + key); ok {
+		t.Fatalf("expected a bearer header not to be treated as an api key")
+	}
+}
+
+func TestExtractBasicAuthApiKey(t *testing.T) {
+	key, prefix, _ := GenerateApiKey()
+	req, _ := http.NewRequest(http.MethodGet, "/api/schema-registry/subjects", nil)
+	req.SetBasicAuth(prefix, strings.TrimPrefix(key, prefix+"_"))
+	if extracted, ok := extractBasicAuthApiKey(req); !ok || extracted != key {
+		t.Fatalf("expected the key to be joined from the prefix and the secret, got %v %v", extracted, ok)
+	}
+	req.SetBasicAuth("registry-client", key)
+	if extracted, ok := extractBasicAuthApiKey(req); !ok || extracted != key {
+		t.Fatalf("expected the whole key to be taken from the password, got %v %v", extracted, ok)
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	if _, ok := extractBasicAuthApiKey(req); ok {
+		t.Fatalf("expected a bearer header not to be treated as basic auth")
+	}
+	if !isBasicAuthRoute("/api/schema-registry/config") || isBasicAuthRoute("/api/stations/getallstations") {
+		t.Fatalf("expected basic auth to be accepted on the schema registry routes only")
+	}
+}
+
+func TestApiKeyScopeAllows(t *testing.T) {
+	cases := []struct {
+		scope, method, path string
+		allowed             bool
+	}{
+		{models.ApiKeyScopeReadOnly, http.MethodGet, "/api/stations/getallstations", true},
+		{models.ApiKeyScopeReadOnly, http.MethodPost, "/api/stations/createstation", false},
+		{models.ApiKeyScopeReadOnly, http.MethodGet, "/api/usermgmt/getallusers", false},
+		{models.ApiKeyScopeStationAdmin, http.MethodPost, "/api/stations/createstation", true},
+		{models.ApiKeyScopeStationAdmin, http.MethodPost, "/api/consumers/resetcgoffsets", true},
+		{models.ApiKeyScopeStationAdmin, http.MethodPost, "/api/schemas/createnewschema", false},
+		{models.ApiKeyScopeSchemaAdmin, http.MethodPost, "/api/schema-registry/subjects/orders/versions", true},
+		{models.ApiKeyScopeSchemaAdmin, http.MethodDelete, "/api/stations/removestation", false},
+		{models.ApiKeyScopeSchemaAdmin, http.MethodPost, "/api/apikeys/createapikey", false},
+		{models.ApiKeyScopeStationAdmin, http.MethodGet, "/api/apikeys/getapikeys", false},
+		{"admin", http.MethodGet, "/api/stations/getallstations", false},
+	}
+	for _, tc := range cases {
+		if allowed := apiKeyScopeAllows(tc.scope, tc.method, tc.path); allowed != tc.allowed {
+			t.Fatalf("expected %v %v with scope %v allowed to be %v", tc.method, tc.path, tc.scope, tc.allowed)
+		}
+	}
+}
+
+func TestApiKeyRoles(t *testing.T) {
+	if roles, ok := apiKeyRoles(nil, []int{1, 2}); !ok || !reflect.DeepEqual(roles, []int{1, 2}) {
+		t.Fatalf("expected a key without roles to act with the user roles, got %v %v", roles, ok)
+	}
+	if roles, ok := apiKeyRoles([]int{3}, nil); !ok || !reflect.DeepEqual(roles, []int{3}) {
+		t.Fatalf("expected the key roles for an unrestricted user, got %v %v", roles, ok)
+	}
+	if roles, ok := apiKeyRoles([]int{1, 3}, []int{1, 2}); !ok || !reflect.DeepEqual(roles, []int{1}) {
+		t.Fatalf("expected the key roles to be limited to the user roles, got %v %v", roles, ok)
+	}
+	if _, ok := apiKeyRoles([]int{3}, []int{1, 2}); ok {
+		t.Fatalf("expected a key without any of the user roles to be rejected")
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/memphisdev/memphis/conf"
 	"github.com/memphisdev/memphis/memphis_cache"
 	"github.com/memphisdev/memphis/models"
+	"github.com/memphisdev/memphis/oidc"
 
 	"strings"
 	"time"
@@ -35,6 +36,8 @@ var noNeedAuthRoutes = []string{
 	"/api/monitoring/getclusterinfo",
 	"/api/tenants/createtenant",
 	"/api/usermgmt/approveinvitation",
+	"/api/usermgmt/oidc/login",
+	"/api/usermgmt/oidc/callback",
 }
 
 var refreshTokenRoute string = "/api/usermgmt/refreshtoken"
@@ -108,13 +111,27 @@ func Authenticate(c *gin.Context) {
 	var user models.User
 	shouldCheckUser := false
 	if needToAuthenticate {
+		if apiKey, ok := extractApiKey(c.GetHeader("authorization")); ok {
+			authenticateApiKey(c, apiKey, path)
+			return
+		}
+		if isBasicAuthRoute(path) {
+			if apiKey, ok := extractBasicAuthApiKey(c.Request); ok {
+				authenticateApiKey(c, apiKey, path)
+				return
+			}
+		}
 		tokenString, err = extractToken(c.GetHeader("authorization"))
 		if err != nil || tokenString == "" {
 			c.AbortWithStatusJSON(401, gin.H{"message": "Unauthorized"})
 			return
 		}
 
-		user, err = verifyToken(tokenString, configuration.JWT_SECRET)
+		if oidc.Enabled() && oidc.IsIdpToken(tokenString) {
+			user, err = oidc.AuthenticateBearer(c.Request.Context(), tokenString)
+		} else {
+			user, err = verifyToken(tokenString, configuration.JWT_SECRET)
+		}
 		if err != nil {
 			c.AbortWithStatusJSON(401, gin.H{"message": "Unauthorized"})
 			return
@@ -143,7 +160,7 @@ func Authenticate(c *gin.Context) {
 			user.TenantName = strings.ToLower(user.TenantName)
 		}
 
-		exists, _, err := memphis_cache.GetUser(username, user.TenantName, false)
+		exists, cachedUser, err := memphis_cache.GetUser(username, user.TenantName, false)
 		if err != nil {
 			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 			return
@@ -152,6 +169,8 @@ func Authenticate(c *gin.Context) {
 			c.AbortWithStatusJSON(401, gin.H{"message": "Unauthorized"})
 			return
 		}
+		// roles are not part of the token, so rbac changes apply without a new login
+		user.Roles = cachedUser.Roles
 	}
 
 	c.Set("user", user)
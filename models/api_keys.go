@@ -0,0 +1,57 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package models
+
+import "time"
+
+const (
+	ApiKeyScopeReadOnly     = "read_only"
+	ApiKeyScopeStationAdmin = "station_admin"
+	ApiKeyScopeSchemaAdmin  = "schema_admin"
+)
+
+type ApiKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	KeyPrefix  string     `json:"key_prefix"`
+	KeyHash    string     `json:"-"`
+	Scope      string     `json:"scope"`
+	RoleIDs    []int      `json:"role_ids"`
+	UserID     int        `json:"user_id"`
+	CreatedBy  string     `json:"created_by"`
+	TenantName string     `json:"tenant_name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RotatedAt  *time.Time `json:"rotated_at"`
+}
+
+type CreateApiKeyRes struct {
+	ApiKey
+	// the key is only returned when it is created or rotated
+	Key string `json:"key"`
+}
+
+type CreateApiKeySchema struct {
+	Name          string `json:"name" binding:"required,min=1,max=128"`
+	Scope         string `json:"scope" binding:"required"`
+	RoleIDs       []int  `json:"role_ids"`
+	ExpiresInDays int    `json:"expires_in_days" binding:"min=0"`
+}
+
+type RotateApiKeySchema struct {
+	ID int `json:"id" binding:"required"`
+}
+
+type RemoveApiKeySchema struct {
+	ID int `json:"id" binding:"required"`
+}
@@ -16,38 +16,42 @@ import (
 )
 
 type Consumer struct {
-	ID                  int       `json:"id"`
-	Name                string    `json:"name"`
-	StationId           int       `json:"station_id"`
-	Type                string    `json:"type"`
-	ConnectionId        string    `json:"connection_id"`
-	ConsumersGroup      string    `json:"consumers_group"`
-	MaxAckTimeMs        int64     `json:"max_ack_time_ms"`
-	IsActive            bool      `json:"is_active"`
-	UpdatedAt           time.Time `json:"updated_at"`
-	MaxMsgDeliveries    int       `json:"max_msg_deliveries"`
-	StartConsumeFromSeq uint64    `json:"start_consume_from_seq"`
-	LastMessages        int64     `json:"last_messages"`
-	TenantName          string    `json:"tenant_name"`
-	PartitionsList      []int     `json:"partitions_list"`
-	Version             int       `json:"version"`
-	Sdk                 string    `json:"sdk"`
-	AppId               string    `json:"app_id"`
+	ID                  int        `json:"id"`
+	Name                string     `json:"name"`
+	StationId           int        `json:"station_id"`
+	Type                string     `json:"type"`
+	ConnectionId        string     `json:"connection_id"`
+	ConsumersGroup      string     `json:"consumers_group"`
+	MaxAckTimeMs        int64      `json:"max_ack_time_ms"`
+	IsActive            bool       `json:"is_active"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	MaxMsgDeliveries    int        `json:"max_msg_deliveries"`
+	StartConsumeFromSeq uint64     `json:"start_consume_from_seq"`
+	LastMessages        int64      `json:"last_messages"`
+	TenantName          string     `json:"tenant_name"`
+	PartitionsList      []int      `json:"partitions_list"`
+	Version             int        `json:"version"`
+	Sdk                 string     `json:"sdk"`
+	AppId               string     `json:"app_id"`
+	IsPaused            bool       `json:"is_paused"`
+	PausedUntil         *time.Time `json:"paused_until"`
 }
 
 type ExtendedConsumer struct {
-	ID               int       `json:"id"`
-	Name             string    `json:"name"`
-	UpdatedAt        time.Time `json:"updated_at"`
-	IsActive         bool      `json:"is_active"`
-	ConsumersGroup   string    `json:"consumers_group"`
-	MaxAckTimeMs     int64     `json:"max_ack_time_ms"`
-	MaxMsgDeliveries int       `json:"max_msg_deliveries"`
-	StationName      string    `json:"station_name,omitempty"`
-	PartitionsList   []int     `json:"partitions_list"`
-	Count            int       `json:"count"`
-	Version          int       `json:"version"`
-	Sdk              string    `json:"sdk"`
+	ID               int        `json:"id"`
+	Name             string     `json:"name"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	IsActive         bool       `json:"is_active"`
+	ConsumersGroup   string     `json:"consumers_group"`
+	MaxAckTimeMs     int64      `json:"max_ack_time_ms"`
+	MaxMsgDeliveries int        `json:"max_msg_deliveries"`
+	StationName      string     `json:"station_name,omitempty"`
+	PartitionsList   []int      `json:"partitions_list"`
+	Count            int        `json:"count"`
+	Version          int        `json:"version"`
+	Sdk              string     `json:"sdk"`
+	IsPaused         bool       `json:"is_paused"`
+	PausedUntil      *time.Time `json:"paused_until"`
 }
 
 type ExtendedConsumerResponse struct {
@@ -86,6 +90,8 @@ type Cg struct {
 	PartitionsList        []int                      `json:"partitions_list"`
 	SdkLanguage           string                     `json:"sdk_language"`
 	UpdateAvailable       bool                       `json:"update_available"`
+	IsPaused              bool                       `json:"is_paused"`
+	PausedUntil           *time.Time                 `json:"paused_until"`
 }
 
 type GetAllConsumersByStationSchema struct {
@@ -142,3 +148,98 @@ type ConsumerForGraph struct {
 	AppId     string `json:"app_id"`
 	Type      string `json:"type"`
 }
+
+type ResetCgOffsetsSchema struct {
+	StationName string    `json:"station_name" binding:"required"`
+	CgName      string    `json:"cg_name" binding:"required"`
+	Position    string    `json:"position" binding:"required"`
+	Sequence    uint64    `json:"sequence"`
+	Timestamp   time.Time `json:"timestamp"`
+	Partitions  []int     `json:"partitions"`
+	DryRun      bool      `json:"dry_run"`
+}
+
+type CgPartitionOffsetReset struct {
+	PartitionNumber     int    `json:"partition_number"`
+	CurrentAckFloorSeq  uint64 `json:"current_ack_floor_seq"`
+	CurrentDeliveredSeq uint64 `json:"current_delivered_seq"`
+	NewStartSeq         uint64 `json:"new_start_seq"`
+	PendingMessages     uint64 `json:"pending_messages"`
+	RedeliveredMessages uint64 `json:"redelivered_messages"`
+	SkippedMessages     uint64 `json:"skipped_messages"`
+}
+
+type ResetCgOffsetsResponse struct {
+	DryRun     bool                     `json:"dry_run"`
+	Partitions []CgPartitionOffsetReset `json:"partitions"`
+}
+
+type GetCgLagSchema struct {
+	StationName string `form:"station_name" json:"station_name" binding:"required"`
+	CgName      string `form:"cg_name" json:"cg_name"`
+}
+
+type CgPartitionLag struct {
+	PartitionNumber    int    `json:"partition_number"`
+	PendingMsgs        uint64 `json:"pending_msgs"`
+	AckPendingMsgs     int    `json:"ack_pending_msgs"`
+	RedeliveredMsgs    int    `json:"redelivered_msgs"`
+	OldestUnackedAgeMs int64  `json:"oldest_unacked_age_ms"`
+}
+
+type CgLag struct {
+	CgName             string           `json:"cg_name"`
+	PendingMsgs        uint64           `json:"pending_msgs"`
+	AckPendingMsgs     int              `json:"ack_pending_msgs"`
+	RedeliveredMsgs    int              `json:"redelivered_msgs"`
+	OldestUnackedAgeMs int64            `json:"oldest_unacked_age_ms"`
+	Partitions         []CgPartitionLag `json:"partitions"`
+}
+
+type CgLagThreshold struct {
+	ID                    int       `json:"id"`
+	StationId             int       `json:"station_id"`
+	CgName                string    `json:"cg_name"`
+	MaxPendingMsgs        int64     `json:"max_pending_msgs"`
+	MaxOldestUnackedAgeMs int64     `json:"max_oldest_unacked_age_ms"`
+	SustainedForSec       int       `json:"sustained_for_sec"`
+	TenantName            string    `json:"tenant_name"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+type UpdateCgLagThresholdSchema struct {
+	StationName           string `json:"station_name" binding:"required"`
+	CgName                string `json:"cg_name"`
+	MaxPendingMsgs        int64  `json:"max_pending_msgs"`
+	MaxOldestUnackedAgeMs int64  `json:"max_oldest_unacked_age_ms"`
+	SustainedForSec       int    `json:"sustained_for_sec"`
+}
+
+type RemoveCgLagThresholdSchema struct {
+	StationName string `json:"station_name" binding:"required"`
+	CgName      string `json:"cg_name"`
+}
+
+type GetCgLagResponse struct {
+	Cgs        []CgLag          `json:"cgs"`
+	Thresholds []CgLagThreshold `json:"thresholds"`
+}
+
+type PausedCg struct {
+	CgName         string     `json:"cg_name"`
+	StationName    string     `json:"station_name"`
+	TenantName     string     `json:"tenant_name"`
+	PausedUntil    *time.Time `json:"paused_until"`
+	PartitionsList []int      `json:"partitions_list"`
+}
+
+type PauseCgSchema struct {
+	StationName string     `json:"station_name" binding:"required"`
+	CgName      string     `json:"cg_name" binding:"required"`
+	PausedUntil *time.Time `json:"paused_until"`
+}
+
+type ResumeCgSchema struct {
+	StationName string `json:"station_name" binding:"required"`
+	CgName      string `json:"cg_name" binding:"required"`
+}
@@ -102,6 +102,8 @@ type DlsMessage struct {
 	ProducerName    string         `json:"producer_name"`
 	PartitionNumber int            `json:"partition_number"`
 	FunctionId      int            `json:"function_id"`
+	RetryAttempts   int            `json:"retry_attempts"`
+	NextRetryAt     *time.Time     `json:"next_retry_at"`
 }
 
 type DlsMsgResendAll struct {
@@ -119,6 +121,104 @@ type DlsMessageResponse struct {
 	Message         MessagePayload      `json:"message"`
 	UpdatedAt       time.Time           `json:"updated_at"`
 	ValidationError string              `json:"validation_error"`
+	RetryAttempts   int                 `json:"retry_attempts"`
+}
+
+type SearchDlsMessagesSchema struct {
+	StationName     string    `form:"station_name" json:"station_name" binding:"required"`
+	PartitionNumber int       `form:"partition_number" json:"partition_number"`
+	DlsType         string    `form:"dls_type" json:"dls_type"`
+	CgName          string    `form:"cg_name" json:"cg_name"`
+	ProducerName    string    `form:"producer_name" json:"producer_name"`
+	From            time.Time `form:"from" json:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To              time.Time `form:"to" json:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	ValidationError string    `form:"validation_error" json:"validation_error"`
+	HeaderKey       string    `form:"header_key" json:"header_key"`
+	HeaderValue     string    `form:"header_value" json:"header_value"`
+	SortBy          string    `form:"sort_by" json:"sort_by"`
+	SortOrder       string    `form:"sort_order" json:"sort_order"`
+	Cursor          string    `form:"cursor" json:"cursor"`
+	Limit           int       `form:"limit" json:"limit"`
+}
+
+type DlsMessagesFilter struct {
+	StationId       int
+	PartitionNumber int
+	MessageType     string
+	CgName          string
+	ProducerName    string
+	From            *time.Time
+	To              *time.Time
+	ValidationError string
+	HeaderKey       string
+	HeaderValue     string
+	SortBy          string
+	SortDesc        bool
+	Cursor          *DlsMessagesCursor
+	Limit           int
+}
+
+type DlsMessagesCursor struct {
+	UpdatedAt  time.Time `json:"updated_at"`
+	MessageSeq int       `json:"message_seq"`
+	ID         int       `json:"id"`
+}
+
+type SearchedDlsMessage struct {
+	ID              int            `json:"id"`
+	MessageSeq      int            `json:"message_seq"`
+	MessageType     string         `json:"message_type"`
+	PartitionNumber int            `json:"partition_number"`
+	ProducerName    string         `json:"producer_name"`
+	PoisonedCgs     []string       `json:"poisoned_cgs"`
+	ValidationError string         `json:"validation_error"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	RetryAttempts   int            `json:"retry_attempts"`
+	Message         MessagePayload `json:"message"`
+}
+
+type SearchDlsMessagesResponse struct {
+	Messages   []SearchedDlsMessage `json:"messages"`
+	NextCursor string               `json:"next_cursor"`
+}
+
+type ExportDlsMessagesSchema struct {
+	StationName string `json:"station_name" binding:"required"`
+	DlsType     string `json:"dls_type"`
+	Destination string `json:"destination"`
+}
+
+type DownloadDlsExportSchema struct {
+	TaskId int `form:"task_id" json:"task_id" binding:"required"`
+}
+
+// DlsExportRecord is a single line of a dls export file, the data is hex encoded as it is stored in dls_messages
+type DlsExportRecord struct {
+	ID              int               `json:"id"`
+	StationName     string            `json:"station_name"`
+	MessageType     string            `json:"message_type"`
+	MessageSeq      int               `json:"message_seq"`
+	PartitionNumber int               `json:"partition_number"`
+	ProducerName    string            `json:"producer_name"`
+	PoisonedCgs     []string          `json:"poisoned_cgs"`
+	ValidationError string            `json:"validation_error"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	TimeSent        time.Time         `json:"time_sent"`
+	Size            int               `json:"size"`
+	Headers         map[string]string `json:"headers"`
+	Data            string            `json:"data"`
+}
+
+type DlsExportMetaData struct {
+	Offset      int    `json:"offset"`
+	Exported    int    `json:"exported"`
+	Destination string `json:"destination"`
+	Location    string `json:"location"`
+}
+
+type DlsImportResponse struct {
+	Imported int `json:"imported"`
+	Failed   int `json:"failed"`
 }
 
 type PmAckMsg struct {
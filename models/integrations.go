@@ -33,6 +33,18 @@ type SlackIntegration struct {
 	Client     *slack.Client     `json:"client"`
 }
 
+type WebhookIntegration struct {
+	Name       string            `json:"name"`
+	Keys       map[string]string `json:"keys"`
+	Headers    map[string]string `json:"headers"`
+	Templates  map[string]string `json:"templates"`
+	Properties map[string]bool   `json:"properties"`
+}
+
+type TestWebhookIntegrationSchema struct {
+	Keys map[string]interface{} `json:"keys"`
+}
+
 type CreateIntegrationSchema struct {
 	Name       string                 `json:"name"`
 	Keys       map[string]interface{} `json:"keys"`
@@ -78,3 +90,31 @@ type IntegrationsAuditLog struct {
 	CreatedAt  time.Time `json:"created_at"`
 	TenantName string    `json:"tenant_name"`
 }
+
+type TieredStorageCheckpoint struct {
+	ID              int       `json:"id"`
+	TenantName      string    `json:"tenant_name"`
+	IntegrationType string    `json:"integration_type"`
+	StreamName      string    `json:"stream_name"`
+	HighWaterMark   int64     `json:"high_water_mark"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type GetTieredStorageReconciliationSchema struct {
+	StationName     string `form:"station_name" json:"station_name" binding:"required"`
+	IntegrationType string `form:"integration_type" json:"integration_type"`
+}
+
+type TieredStorageSeqRange struct {
+	Partition int    `json:"partition"`
+	From      uint64 `json:"from"`
+	To        uint64 `json:"to"`
+}
+
+type TieredStorageReconciliation struct {
+	Integration   string                  `json:"integration"`
+	Objects       int                     `json:"objects"`
+	LegacyObjects int                     `json:"legacy_objects"`
+	Gaps          []TieredStorageSeqRange `json:"gaps"`
+	Duplicates    []TieredStorageSeqRange `json:"duplicates"`
+}
@@ -108,3 +108,24 @@ type Throughput struct {
 	BytesPerSec int64 `json:"bytes_per_sec"`
 }
 
+
+type StationClientsCount struct {
+	TenantName     string `json:"tenant_name"`
+	StationName    string `json:"station_name"`
+	ProducersCount int    `json:"producers_count"`
+	ConsumersCount int    `json:"consumers_count"`
+}
+
+type DlsMsgsCount struct {
+	TenantName  string `json:"tenant_name"`
+	StationName string `json:"station_name"`
+	MessageType string `json:"message_type"`
+	Count       int    `json:"count"`
+}
+
+type CgPoisonMsgsCount struct {
+	TenantName  string `json:"tenant_name"`
+	StationName string `json:"station_name"`
+	CgName      string `json:"cg_name"`
+	Count       int    `json:"count"`
+}
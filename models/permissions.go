@@ -33,3 +33,51 @@ type Permissions struct {
 	DenyReadPermissions   []string `json:"deny_read_permissions"`
 	DenyWritePermissions  []string `json:"deny_write_permissions"`
 }
+
+type RoleRes struct {
+	ID          int          `json:"id"`
+	Name        string       `json:"name"`
+	Type        string       `json:"type"`
+	Permissions []Permission `json:"permissions"`
+	Users       []string     `json:"users"`
+}
+
+type CreateRoleSchema struct {
+	Name                  string   `json:"name" binding:"required,min=1,max=128"`
+	Type                  string   `json:"type" binding:"required"`
+	AllowReadPermissions  []string `json:"allow_read_permissions"`
+	AllowWritePermissions []string `json:"allow_write_permissions"`
+	DenyReadPermissions   []string `json:"deny_read_permissions"`
+	DenyWritePermissions  []string `json:"deny_write_permissions"`
+}
+
+type GetRoleSchema struct {
+	RoleID int `form:"role_id" json:"role_id" binding:"required"`
+}
+
+type UpdateRoleSchema struct {
+	RoleID int    `json:"role_id" binding:"required"`
+	Name   string `json:"name" binding:"required,min=1,max=128"`
+}
+
+type RemoveRoleSchema struct {
+	RoleID int `json:"role_id" binding:"required"`
+}
+
+type AddPermissionsSchema struct {
+	RoleID                int      `json:"role_id" binding:"required"`
+	AllowReadPermissions  []string `json:"allow_read_permissions"`
+	AllowWritePermissions []string `json:"allow_write_permissions"`
+	DenyReadPermissions   []string `json:"deny_read_permissions"`
+	DenyWritePermissions  []string `json:"deny_write_permissions"`
+}
+
+type RemovePermissionsSchema struct {
+	RoleID        int   `json:"role_id" binding:"required"`
+	PermissionIDs []int `json:"permission_ids" binding:"required"`
+}
+
+type AssignRolesSchema struct {
+	Username string `json:"username" binding:"required"`
+	RoleIDs  []int  `json:"role_ids" binding:"required"`
+}
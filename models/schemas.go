@@ -21,20 +21,46 @@ type Schema struct {
 	Type              string `json:"type"`
 	CreatedByUsername string `json:"created_by_username"`
 	TenantName        string `json:"tenant_name"`
+	Compatibility     string `json:"compatibility"`
+}
+
+const (
+	SchemaCompatibilityNone               = "NONE"
+	SchemaCompatibilityBackward           = "BACKWARD"
+	SchemaCompatibilityBackwardTransitive = "BACKWARD_TRANSITIVE"
+	SchemaCompatibilityForward            = "FORWARD"
+	SchemaCompatibilityForwardTransitive  = "FORWARD_TRANSITIVE"
+	SchemaCompatibilityFull               = "FULL"
+	SchemaCompatibilityFullTransitive     = "FULL_TRANSITIVE"
+)
+
+type SchemaCompatibilityViolation struct {
+	VersionNumber int    `json:"version_number"`
+	Path          string `json:"path"`
+	Description   string `json:"description"`
 }
 
 type SchemaVersion struct {
-	ID                int       `json:"id" `
-	VersionNumber     int       `json:"version_number"`
-	Active            bool      `json:"active"`
-	CreatedBy         int       `json:"created_by"`
-	CreatedByUsername string    `json:"created_by_username"`
-	CreatedAt         time.Time `json:"created_at"`
-	SchemaContent     string    `json:"schema_content"`
-	SchemaId          int       `json:"schema_id"`
-	MessageStructName string    `json:"message_struct_name"`
-	Descriptor        string    `json:"descriptor"`
-	TenantName        string    `json:"tenant_name"`
+	ID                int               `json:"id" `
+	VersionNumber     int               `json:"version_number"`
+	Active            bool              `json:"active"`
+	CreatedBy         int               `json:"created_by"`
+	CreatedByUsername string            `json:"created_by_username"`
+	CreatedAt         time.Time         `json:"created_at"`
+	SchemaContent     string            `json:"schema_content"`
+	SchemaId          int               `json:"schema_id"`
+	MessageStructName string            `json:"message_struct_name"`
+	Descriptor        string            `json:"descriptor"`
+	TenantName        string            `json:"tenant_name"`
+	References        []SchemaReference `json:"references"`
+}
+
+// SchemaReference points from a schema version to a version of another schema, Name is the
+// import path (protobuf) or the $ref uri (json schema) the referenced content is resolved by
+type SchemaReference struct {
+	Name          string `json:"name"`
+	SchemaName    string `json:"schema_name"`
+	VersionNumber int    `json:"version_number"`
 }
 
 type SchemaVersionResponse struct {
@@ -52,11 +78,13 @@ type SchemaVersionResponse struct {
 }
 
 type CreateNewSchema struct {
-	Name              string      `json:"name" binding:"required,min=1,max=32"`
-	Type              string      `json:"type"`
-	SchemaContent     string      `json:"schema_content"`
-	Tags              []CreateTag `json:"tags"`
-	MessageStructName string      `json:"message_struct_name"`
+	Name              string            `json:"name" binding:"required,min=1,max=32"`
+	Type              string            `json:"type"`
+	SchemaContent     string            `json:"schema_content"`
+	Tags              []CreateTag       `json:"tags"`
+	MessageStructName string            `json:"message_struct_name"`
+	Compatibility     string            `json:"compatibility"`
+	References        []SchemaReference `json:"references"`
 }
 
 type ExtendedSchema struct {
@@ -79,6 +107,8 @@ type ExtendedSchemaDetails struct {
 	UsedStations      []string        `json:"used_stations"`
 	Tags              []CreateTag     `json:"tags"`
 	CreatedByUsername string          `json:"created_by_username"`
+	Compatibility     string          `json:"compatibility"`
+	ReferencedBy      []string        `json:"referenced_by"`
 }
 
 type SchemaUpdateType int
@@ -100,10 +130,11 @@ type SchemaUpdateInit struct {
 }
 
 type SchemaUpdateVersion struct {
-	VersionNumber     int    `json:"version_number"`
-	Descriptor        string `json:"descriptor"`
-	Content           string `json:"schema_content"`
-	MessageStructName string `json:"message_struct_name"`
+	VersionNumber     int               `json:"version_number"`
+	Descriptor        string            `json:"descriptor"`
+	Content           string            `json:"schema_content"`
+	MessageStructName string            `json:"message_struct_name"`
+	References        map[string]string `json:"references,omitempty"`
 }
 
 type GetSchemaDetails struct {
@@ -115,9 +146,10 @@ type RemoveSchema struct {
 }
 
 type CreateNewVersion struct {
-	SchemaName        string `json:"schema_name"`
-	SchemaContent     string `json:"schema_content"`
-	MessageStructName string `json:"message_struct_name"`
+	SchemaName        string            `json:"schema_name"`
+	SchemaContent     string            `json:"schema_content"`
+	MessageStructName string            `json:"message_struct_name"`
+	References        []SchemaReference `json:"references"`
 }
 
 type RollBackVersion struct {
@@ -125,7 +157,37 @@ type RollBackVersion struct {
 	VersionNumber int    `json:"version_number"`
 }
 
+type UpdateSchemaCompatibility struct {
+	SchemaName    string `json:"schema_name" binding:"required"`
+	Compatibility string `json:"compatibility" binding:"required"`
+}
+
 type ValidateSchema struct {
 	SchemaType    string `json:"schema_type"`
 	SchemaContent string `json:"schema_content"`
 }
+
+type SchemaRegistrySchema struct {
+	Schema     string                    `json:"schema" binding:"required"`
+	SchemaType string                    `json:"schemaType"`
+	References []SchemaRegistryReference `json:"references"`
+}
+
+type SchemaRegistryReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+type SchemaRegistrySubjectVersion struct {
+	Subject    string                    `json:"subject"`
+	ID         int                       `json:"id"`
+	Version    int                       `json:"version"`
+	Schema     string                    `json:"schema"`
+	SchemaType string                    `json:"schemaType"`
+	References []SchemaRegistryReference `json:"references,omitempty"`
+}
+
+type SchemaRegistryConfig struct {
+	Compatibility string `json:"compatibility" binding:"required"`
+}
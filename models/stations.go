@@ -41,56 +41,62 @@ type MessageDetails struct {
 }
 
 type Station struct {
-	ID                          int       `json:"id"`
-	Name                        string    `json:"name"`
-	RetentionType               string    `json:"retention_type"`
-	RetentionValue              int       `json:"retention_value"`
-	StorageType                 string    `json:"storage_type"`
-	Replicas                    int       `json:"replicas"`
-	CreatedBy                   int       `json:"created_by,omitempty"`
-	CreatedByUsername           string    `json:"created_by_username"`
-	CreatedAt                   time.Time `json:"created_at"`
-	UpdatedAt                   time.Time `json:"updated_at,omitempty"`
-	IsDeleted                   bool      `json:"is_deleted,omitempty"`
-	SchemaName                  string    `json:"schema_name,omitempty"`
-	SchemaVersionNumber         int       `json:"schema_vesrion_number,omitempty"`
-	IdempotencyWindow           int64     `json:"idempotency_window_in_ms,omitempty"`
-	IsNative                    bool      `json:"is_native"`
-	DlsConfigurationPoison      bool      `json:"dls_configuration_poison,omitempty"`
-	DlsConfigurationSchemaverse bool      `json:"dls_configuration_schemaverse,omitempty"`
-	TieredStorageEnabled        bool      `json:"tiered_storage_enabled"`
-	TenantName                  string    `json:"tenant_name"`
-	ResendDisabled              bool      `json:"resend_disabled"`
-	PartitionsList              []int     `json:"partitions_list"`
-	Version                     int       `json:"version"`
-	DlsStation                  string    `json:"dls_station"`
-	FunctionsLockHeld           bool      `json:"functions_lock_held"`
-	FunctionsLockedAt           time.Time `json:"functions_locked_at,omitempty"`
+	ID                          int            `json:"id"`
+	Name                        string         `json:"name"`
+	RetentionType               string         `json:"retention_type"`
+	RetentionValue              int            `json:"retention_value"`
+	StorageType                 string         `json:"storage_type"`
+	Replicas                    int            `json:"replicas"`
+	CreatedBy                   int            `json:"created_by,omitempty"`
+	CreatedByUsername           string         `json:"created_by_username"`
+	CreatedAt                   time.Time      `json:"created_at"`
+	UpdatedAt                   time.Time      `json:"updated_at,omitempty"`
+	IsDeleted                   bool           `json:"is_deleted,omitempty"`
+	SchemaName                  string         `json:"schema_name,omitempty"`
+	SchemaVersionNumber         int            `json:"schema_vesrion_number,omitempty"`
+	IdempotencyWindow           int64          `json:"idempotency_window_in_ms,omitempty"`
+	IsNative                    bool           `json:"is_native"`
+	DlsConfigurationPoison      bool           `json:"dls_configuration_poison,omitempty"`
+	DlsConfigurationSchemaverse bool           `json:"dls_configuration_schemaverse,omitempty"`
+	TieredStorageEnabled        bool           `json:"tiered_storage_enabled"`
+	TenantName                  string         `json:"tenant_name"`
+	ResendDisabled              bool           `json:"resend_disabled"`
+	PartitionsList              []int          `json:"partitions_list"`
+	Version                     int            `json:"version"`
+	DlsStation                  string         `json:"dls_station"`
+	FunctionsLockHeld           bool           `json:"functions_lock_held"`
+	FunctionsLockedAt           time.Time      `json:"functions_locked_at,omitempty"`
+	BrokerSchemaEnforcement     bool           `json:"broker_schema_enforcement"`
+	DlsRetryPolicy              DlsRetryPolicy `json:"dls_retry_policy"`
+	TieredStorageFormat         string         `json:"tiered_storage_format"`
 }
 
 type GetStationResponseSchema struct {
-	ID                   int              `json:"id"`
-	Name                 string           `json:"name"`
-	RetentionType        string           `json:"retention_type"`
-	RetentionValue       int              `json:"retention_value"`
-	StorageType          string           `json:"storage_type"`
-	Replicas             int              `json:"replicas"`
-	CreatedBy            int              `json:"created_by"`
-	CreatedByUsername    string           `json:"created_by_username"`
-	CreatedAt            time.Time        `json:"created_at"`
-	LastUpdate           time.Time        `json:"last_update"`
-	IsDeleted            bool             `json:"is_deleted"`
-	Tags                 []CreateTag      `json:"tags"`
-	IdempotencyWindow    int64            `json:"idempotency_window_in_ms" `
-	IsNative             bool             `json:"is_native"`
-	DlsConfiguration     DlsConfiguration `json:"dls_configuration"`
-	TieredStorageEnabled bool             `json:"tiered_storage_enabled"`
-	ResendDisabled       bool             `json:"resend_disabled"`
-	PartitionsList       []int            `json:"partitions_list"`
-	PartitionsNumber     int              `json:"partitions_number"`
-	DlsStation           string           `json:"dls_station"`
-	FunctionsLockHeld    bool             `json:"functions_lock_held"`
-	FunctionsLockedAt    time.Time        `json:"functions_locked_at"`
+	ID                      int              `json:"id"`
+	Name                    string           `json:"name"`
+	RetentionType           string           `json:"retention_type"`
+	RetentionValue          int              `json:"retention_value"`
+	StorageType             string           `json:"storage_type"`
+	Replicas                int              `json:"replicas"`
+	CreatedBy               int              `json:"created_by"`
+	CreatedByUsername       string           `json:"created_by_username"`
+	CreatedAt               time.Time        `json:"created_at"`
+	LastUpdate              time.Time        `json:"last_update"`
+	IsDeleted               bool             `json:"is_deleted"`
+	Tags                    []CreateTag      `json:"tags"`
+	IdempotencyWindow       int64            `json:"idempotency_window_in_ms" `
+	IsNative                bool             `json:"is_native"`
+	DlsConfiguration        DlsConfiguration `json:"dls_configuration"`
+	TieredStorageEnabled    bool             `json:"tiered_storage_enabled"`
+	ResendDisabled          bool             `json:"resend_disabled"`
+	PartitionsList          []int            `json:"partitions_list"`
+	PartitionsNumber        int              `json:"partitions_number"`
+	DlsStation              string           `json:"dls_station"`
+	FunctionsLockHeld       bool             `json:"functions_lock_held"`
+	FunctionsLockedAt       time.Time        `json:"functions_locked_at"`
+	BrokerSchemaEnforcement bool             `json:"broker_schema_enforcement"`
+	TieredStorageFormat     string           `json:"tiered_storage_format"`
+	DlsRetryPolicy          DlsRetryPolicy   `json:"dls_retry_policy"`
 }
 
 type ExtendedStation struct {
@@ -120,35 +126,38 @@ type ExtendedStation struct {
 }
 
 type ExtendedStationLight struct {
-	ID                          int         `json:"id"`
-	Name                        string      `json:"name"`
-	RetentionType               string      `json:"retention_type,omitempty"`
-	RetentionValue              int         `json:"retention_value,omitempty"`
-	StorageType                 string      `json:"storage_type,omitempty"`
-	Replicas                    int         `json:"replicas,omitempty"`
-	CreatedBy                   int         `json:"created_by,omitempty"`
-	CreatedByUsername           string      `json:"created_by_username"`
-	CreatedAt                   time.Time   `json:"created_at"`
-	UpdatedAt                   time.Time   `json:"updated_at,omitempty"`
-	IsDeleted                   bool        `json:"is_deleted,omitempty"`
-	TotalMessages               int         `json:"total_messages"`
-	SchemaName                  string      `json:"schema_name,omitempty"`
-	SchemaVersionNumber         int         `json:"schema_vesrion_number,omitempty"`
-	Tags                        []CreateTag `json:"tags,omitempty"`
-	IdempotencyWindow           int64       `json:"idempotency_window_in_ms,omitempty"`
-	IsNative                    bool        `json:"is_native"`
-	DlsConfigurationPoison      bool        `json:"dls_configuration_poison,omitempty"`
-	DlsConfigurationSchemaverse bool        `json:"dls_configuration_schemaverse,omitempty"`
-	HasDlsMsgs                  bool        `json:"has_dls_messages"`
-	Activity                    bool        `json:"activity"`
-	TieredStorageEnabled        bool        `json:"tiered_storage_enabled,omitempty"`
-	TenantName                  string      `json:"tenant_name"`
-	ResendDisabled              bool        `json:"resend_disabled"`
-	PartitionsList              []int       `json:"partitions_list"`
-	Version                     int         `json:"version"`
-	DlsStation                  string      `json:"dls_station"`
-	FunctionsLockHeld           bool        `json:"functions_lock_held"`
-	FunctionsLockedAt           time.Time   `json:"functions_locked_at"`
+	ID                          int            `json:"id"`
+	Name                        string         `json:"name"`
+	RetentionType               string         `json:"retention_type,omitempty"`
+	RetentionValue              int            `json:"retention_value,omitempty"`
+	StorageType                 string         `json:"storage_type,omitempty"`
+	Replicas                    int            `json:"replicas,omitempty"`
+	CreatedBy                   int            `json:"created_by,omitempty"`
+	CreatedByUsername           string         `json:"created_by_username"`
+	CreatedAt                   time.Time      `json:"created_at"`
+	UpdatedAt                   time.Time      `json:"updated_at,omitempty"`
+	IsDeleted                   bool           `json:"is_deleted,omitempty"`
+	TotalMessages               int            `json:"total_messages"`
+	SchemaName                  string         `json:"schema_name,omitempty"`
+	SchemaVersionNumber         int            `json:"schema_vesrion_number,omitempty"`
+	Tags                        []CreateTag    `json:"tags,omitempty"`
+	IdempotencyWindow           int64          `json:"idempotency_window_in_ms,omitempty"`
+	IsNative                    bool           `json:"is_native"`
+	DlsConfigurationPoison      bool           `json:"dls_configuration_poison,omitempty"`
+	DlsConfigurationSchemaverse bool           `json:"dls_configuration_schemaverse,omitempty"`
+	HasDlsMsgs                  bool           `json:"has_dls_messages"`
+	Activity                    bool           `json:"activity"`
+	TieredStorageEnabled        bool           `json:"tiered_storage_enabled,omitempty"`
+	TenantName                  string         `json:"tenant_name"`
+	ResendDisabled              bool           `json:"resend_disabled"`
+	PartitionsList              []int          `json:"partitions_list"`
+	Version                     int            `json:"version"`
+	DlsStation                  string         `json:"dls_station"`
+	FunctionsLockHeld           bool           `json:"functions_lock_held"`
+	FunctionsLockedAt           time.Time      `json:"functions_locked_at"`
+	BrokerSchemaEnforcement     bool           `json:"broker_schema_enforcement"`
+	DlsRetryPolicy              DlsRetryPolicy `json:"dls_retry_policy"`
+	TieredStorageFormat         string         `json:"tiered_storage_format"`
 }
 
 type StationLight struct {
@@ -204,12 +213,79 @@ type DlsConfiguration struct {
 	Schemaverse bool `json:"schemaverse"`
 }
 
+// DlsRetryPolicy controls the automatic redelivery of poison messages,
+// a zero MaxRetries keeps the manual resend only behavior
+type DlsRetryPolicy struct {
+	MaxRetries        int     `json:"max_retries"`
+	InitialDelayMs    int     `json:"initial_delay_ms"`
+	BackoffMultiplier float64 `json:"backoff_multiplier"`
+	MaxDelayMs        int     `json:"max_delay_ms"`
+	Jitter            float64 `json:"jitter"`
+}
+
 type UpdateDlsConfigSchema struct {
 	StationName string `json:"station_name" binding:"required"`
 	Poison      bool   `json:"poison"`
 	Schemaverse bool   `json:"schemaverse"`
 }
 
+type UpdateDlsRetryPolicySchema struct {
+	StationName    string         `json:"station_name" binding:"required"`
+	DlsRetryPolicy DlsRetryPolicy `json:"dls_retry_policy"`
+}
+
+type UpdateTieredStorageFormatSchema struct {
+	StationName string `json:"station_name" binding:"required"`
+	Format      string `json:"format" binding:"required"`
+}
+
+type RehydrateFromTieredStorageSchema struct {
+	StationName       string    `json:"station_name" binding:"required"`
+	TargetStationName string    `json:"target_station_name"`
+	IntegrationType   string    `json:"integration_type"`
+	FromTime          time.Time `json:"from_time"`
+	ToTime            time.Time `json:"to_time"`
+	FromSeq           uint64    `json:"from_seq"`
+	ToSeq             uint64    `json:"to_seq"`
+}
+
+type TieredRehydrateMetaData struct {
+	Integration    string `json:"integration"`
+	TargetStation  string `json:"target_station"`
+	TotalObjects   int    `json:"total_objects"`
+	ReadObjects    int    `json:"read_objects"`
+	SkippedObjects int    `json:"skipped_objects"`
+	Republished    int    `json:"republished"`
+	Rejected       int    `json:"rejected"`
+}
+
+type UpdateSchemaEnforcementSchema struct {
+	StationName string `json:"station_name" binding:"required"`
+	Enforced    bool   `json:"enforced"`
+}
+
+type SchemaEnforcedStation struct {
+	StationName                 string `json:"station_name"`
+	TenantName                  string `json:"tenant_name"`
+	DlsConfigurationSchemaverse bool   `json:"dls_configuration_schemaverse"`
+	SchemaType                  string `json:"schema_type"`
+	SchemaVersionId             int    `json:"schema_version_id"`
+	VersionNumber               int    `json:"version_number"`
+	SchemaContent               string `json:"schema_content"`
+	MessageStructName           string `json:"message_struct_name"`
+}
+
+type AddStationPartitionsSchema struct {
+	StationName      string `json:"station_name" binding:"required"`
+	PartitionsNumber int    `json:"partitions_number" binding:"required"`
+}
+
+type PartitionedStation struct {
+	StationName    string `json:"station_name"`
+	TenantName     string `json:"tenant_name"`
+	PartitionsList []int  `json:"partitions_list"`
+}
+
 type DropDlsMessagesSchema struct {
 	DlsMsgType    string `json:"dls_type" binding:"required"`
 	DlsMessageIds []int  `json:"dls_message_ids" binding:"required"`
@@ -86,6 +86,11 @@ type AuthenticateNatsSchema struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type OidcCallbackSchema struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
 type RemoveUserSchema struct {
 	Username string `json:"username" binding:"required"`
 }
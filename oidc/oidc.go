@@ -0,0 +1,488 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/memphisdev/memphis/conf"
+	"github.com/memphisdev/memphis/idp"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	discoveryPath          = "/.well-known/openid-configuration"
+	httpTimeout            = 10 * time.Second
+	jwksMinRefreshInterval = 30 * time.Second
+	maxResponseSize        = 1 << 20
+)
+
+// only asymmetric algorithms are accepted for IdP issued tokens, Memphis issued tokens use HS256
+var supportedSigningMethods = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}
+
+var ErrUserNotMapped = idp.ErrUserNotMapped
+
+type Config struct {
+	IssuerURL     string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Audience      string
+	Scopes        []string
+	UsernameClaim string
+	GroupsClaim   string
+	// group name -> memphis user type
+	UserTypeMapping map[string]string
+	// group name -> memphis role names
+	RoleMapping     map[string][]string
+	DefaultUserType string
+}
+
+type Identity = idp.Identity
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	AccessToken      string `json:"access_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type Provider struct {
+	config        Config
+	client        *http.Client
+	mu            sync.Mutex
+	discovery     *discoveryDocument
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+var (
+	configuration       = conf.GetConfig()
+	defaultProvider     *Provider
+	defaultProviderOnce sync.Once
+)
+
+func Enabled() bool {
+	return configuration.OIDC_ENABLED && configuration.OIDC_ISSUER_URL != "" && configuration.OIDC_CLIENT_ID != ""
+}
+
+// GetProvider returns the provider configured through the OIDC_* configuration keys
+func GetProvider() *Provider {
+	defaultProviderOnce.Do(func() {
+		defaultProvider = NewProvider(ConfigFromConfiguration(configuration))
+	})
+	return defaultProvider
+}
+
+func ConfigFromConfiguration(configuration conf.Configuration) Config {
+	return Config{
+		IssuerURL:       strings.TrimSuffix(configuration.OIDC_ISSUER_URL, "/"),
+		ClientID:        configuration.OIDC_CLIENT_ID,
+		ClientSecret:    configuration.OIDC_CLIENT_SECRET,
+		RedirectURL:     configuration.OIDC_REDIRECT_URL,
+		Audience:        configuration.OIDC_AUDIENCE,
+		Scopes:          strings.Fields(configuration.OIDC_SCOPES),
+		UsernameClaim:   configuration.OIDC_USERNAME_CLAIM,
+		GroupsClaim:     configuration.OIDC_GROUPS_CLAIM,
+		UserTypeMapping: idp.ParseUserTypeMapping(configuration.OIDC_USER_TYPE_MAPPING),
+		RoleMapping:     idp.ParseRoleMapping(configuration.OIDC_ROLE_MAPPING),
+		DefaultUserType: strings.ToLower(configuration.OIDC_DEFAULT_USER_TYPE),
+	}
+}
+
+func NewProvider(config Config) *Provider {
+	config.IssuerURL = strings.TrimSuffix(config.IssuerURL, "/")
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid"}
+	}
+	return &Provider{
+		config: config,
+		client: &http.Client{Timeout: httpTimeout},
+		keys:   map[string]interface{}{},
+	}
+}
+
+func (p *Provider) Config() Config {
+	return p.config
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%v returned status %v", endpoint, resp.StatusCode)
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	var doc discoveryDocument
+	err := p.getJSON(ctx, p.config.IssuerURL+discoveryPath, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %v", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != p.config.IssuerURL {
+		return nil, fmt.Errorf("oidc discovery: issuer %v does not match the configured issuer %v", doc.Issuer, p.config.IssuerURL)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JwksURI == "" {
+		return nil, errors.New("oidc discovery: the provider metadata is missing required endpoints")
+	}
+	p.discovery = &doc
+	return p.discovery, nil
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewState returns random values for the state and nonce parameters of an authorization request
+func NewState() (string, string, error) {
+	state, err := randomString(24)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString(24)
+	if err != nil {
+		return "", "", err
+	}
+	return state, nonce, nil
+}
+
+// NewPKCE returns a code verifier and its S256 code challenge (RFC 7636)
+func NewPKCE() (string, string, error) {
+	verifier, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, codeChallenge(verifier), nil
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+	authURL, err := url.Parse(doc.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", challenge)
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+// Exchange redeems an authorization code and returns the raw ID token
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("code_verifier", verifier)
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return "", err
+	}
+	var tokens tokenResponse
+	err = json.Unmarshal(body, &tokens)
+	if err != nil {
+		return "", fmt.Errorf("token endpoint returned status %v", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || tokens.Error != "" {
+		return "", fmt.Errorf("token endpoint returned status %v: %v %v", resp.StatusCode, tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.IDToken == "" {
+		return "", errors.New("token endpoint did not return an id token")
+	}
+	return tokens.IDToken, nil
+}
+
+func parseJWK(key jsonWebKey) (interface{}, error) {
+	decode := func(v string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(v, "="))
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	switch key.Kty {
+	case "RSA":
+		n, err := decode(key.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(key.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("invalid rsa exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if key.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %v", key.Crv)
+		}
+		x, err := decode(key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(key.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("ec key is not on the P-256 curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %v", key.Kty)
+	}
+}
+
+func (p *Provider) refreshKeys(ctx context.Context) error {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return err
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err = p.getJSON(ctx, doc.JwksURI, &jwks)
+	if err != nil {
+		return fmt.Errorf("oidc jwks: %v", err)
+	}
+	keys := map[string]interface{}{}
+	for _, key := range jwks.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		publicKey, err := parseJWK(key)
+		if err != nil {
+			continue
+		}
+		keys[key.Kid] = publicKey
+	}
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+	p.mu.Unlock()
+	return nil
+}
+
+// publicKey returns the signing key for kid, the key set is refetched when the kid is unknown to support key rotation
+func (p *Provider) publicKey(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	fetchedAt := p.keysFetchedAt
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !fetchedAt.IsZero() && time.Since(fetchedAt) < jwksMinRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %v", kid)
+	}
+	err := p.refreshKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	key, ok = p.keys[kid]
+	p.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %v", kid)
+	}
+	return key, nil
+}
+
+// IsIdpToken reports whether a bearer token is signed with an IdP algorithm and should be verified against the JWKS
+func IsIdpToken(raw string) bool {
+	token, _, err := jwt.NewParser().ParseUnverified(raw, jwt.MapClaims{})
+	if err != nil {
+		return false
+	}
+	alg, _ := token.Header["alg"].(string)
+	for _, method := range supportedSigningMethods {
+		if alg == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify validates the signature, issuer, audience and expiry of an IdP issued token,
+// the nonce is checked when it is not empty
+func (p *Provider) Verify(ctx context.Context, raw, nonce string) (jwt.MapClaims, error) {
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parser := jwt.NewParser(jwt.WithValidMethods(supportedSigningMethods))
+	token, err := parser.Parse(raw, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if !claims.VerifyIssuer(doc.Issuer, true) {
+		return nil, errors.New("token issuer is not the configured identity provider")
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.New("token has no valid expiration")
+	}
+	audienceOk := claims.VerifyAudience(p.config.ClientID, true)
+	if !audienceOk && p.config.Audience != "" {
+		audienceOk = claims.VerifyAudience(p.config.Audience, true)
+	}
+	if !audienceOk {
+		return nil, errors.New("token audience is not accepted")
+	}
+	if nonce != "" {
+		if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+			return nil, errors.New("token nonce does not match the login request")
+		}
+	}
+	return claims, nil
+}
+
+func claimStrings(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := []string{}
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return []string{}
+}
+
+// Identity maps the token claims to a memphis username, user type and role names
+func (p *Provider) Identity(claims jwt.MapClaims) (Identity, error) {
+	username, _ := claims[p.config.UsernameClaim].(string)
+	username = strings.ToLower(strings.TrimSpace(username))
+	if username == "" {
+		return Identity{}, fmt.Errorf("token does not include the %v claim", p.config.UsernameClaim)
+	}
+	if !idp.ValidateUsername(username) {
+		return Identity{}, fmt.Errorf("the %v claim %v is not a valid memphis username", p.config.UsernameClaim, username)
+	}
+	fullName, _ := claims["name"].(string)
+
+	identity := Identity{
+		Username: username,
+		FullName: strings.ToLower(fullName),
+		Groups:   claimStrings(claims[p.config.GroupsClaim]),
+	}
+	mapping := idp.Mapping{UserTypes: p.config.UserTypeMapping, Roles: p.config.RoleMapping, DefaultUserType: p.config.DefaultUserType}
+	if err := mapping.MapGroups(&identity); err != nil {
+		return Identity{}, err
+	}
+	return identity, nil
+}
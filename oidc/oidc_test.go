@@ -0,0 +1,293 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type mockIdp struct {
+	server     *httptest.Server
+	rsaKey     *rsa.PrivateKey
+	ecKey      *ecdsa.PrivateKey
+	mu         sync.Mutex
+	keys       []map[string]string
+	codes      map[string]map[string]string
+	jwksServed int
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func newMockIdp(t *testing.T) *mockIdp {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ec key: %v", err)
+	}
+	idp := &mockIdp{rsaKey: rsaKey, ecKey: ecKey, codes: map[string]map[string]string{}}
+	idp.keys = []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		idp.jwksServed++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": idp.keys})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		idp.mu.Lock()
+		request, ok := idp.codes[r.Form.Get("code")]
+		delete(idp.codes, r.Form.Get("code"))
+		idp.mu.Unlock()
+		if !ok || r.Form.Get("grant_type") != "authorization_code" || codeChallenge(r.Form.Get("code_verifier")) != request["code_challenge"] {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		idToken := idp.sign(t, jwt.SigningMethodRS256, "rsa-1", jwt.MapClaims{
+			"iss":                idp.server.URL,
+			"aud":                request["client_id"],
+			"exp":                time.Now().Add(time.Minute).Unix(),
+			"nonce":              request["nonce"],
+			"preferred_username": "Jane.Doe",
+			"name":               "Jane Doe",
+			"groups":             []string{"memphis-admins", "orders-team"},
+		})
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken, "access_token": "opaque"})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// authorize simulates the browser round trip and returns the issued code
+func (idp *mockIdp) authorize(t *testing.T, authURL string) string {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("auth url: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("expected a S256 code challenge, got %v", authURL)
+	}
+	code := "code-" + query.Get("state")
+	idp.mu.Lock()
+	idp.codes[code] = map[string]string{"code_challenge": query.Get("code_challenge"), "nonce": query.Get("nonce"), "client_id": query.Get("client_id")}
+	idp.mu.Unlock()
+	return code
+}
+
+func (idp *mockIdp) sign(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	var key interface{} = idp.rsaKey
+	if method == jwt.SigningMethodES256 {
+		key = idp.ecKey
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signed
+}
+
+func testProvider(idp *mockIdp) *Provider {
+	return NewProvider(Config{
+		IssuerURL:       idp.server.URL,
+		ClientID:        "memphis-ui",
+		RedirectURL:     "http://localhost:9000/login/sso",
+		Audience:        "memphis-api",
+		Scopes:          []string{"openid", "profile"},
+		UsernameClaim:   "preferred_username",
+		GroupsClaim:     "groups",
+		UserTypeMapping: map[string]string{"memphis-admins": "management", "memphis-apps": "application"},
+		RoleMapping:     map[string][]string{"orders-team": {"orders-readers", "orders-writers"}, "memphis-admins": {"orders-readers"}},
+	})
+}
+
+func TestAuthorizationCodeFlowWithPKCE(t *testing.T) {
+	idp := newMockIdp(t)
+	provider := testProvider(idp)
+	ctx := context.Background()
+
+	state, nonce, err := NewState()
+	if err != nil {
+		t.Fatalf("NewState: %v", err)
+	}
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatalf("NewPKCE: %v", err)
+	}
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	if !strings.HasPrefix(authURL, idp.server.URL+"/authorize?") || !strings.Contains(authURL, "scope=openid+profile") {
+		t.Fatalf("unexpected authorization url %v", authURL)
+	}
+	code := idp.authorize(t, authURL)
+
+	if _, err := provider.Exchange(ctx, code, "wrong-verifier"); err == nil {
+		t.Fatalf("expected the exchange to fail with a wrong code verifier")
+	}
+	code = idp.authorize(t, authURL)
+	idToken, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if _, err := provider.Verify(ctx, idToken, "other-nonce"); err == nil {
+		t.Fatalf("expected the verification to fail with a wrong nonce")
+	}
+	claims, err := provider.Verify(ctx, idToken, nonce)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	identity, err := provider.Identity(claims)
+	if err != nil {
+		t.Fatalf("Identity: %v", err)
+	}
+	if identity.Username != "jane.doe" || identity.FullName != "jane doe" || identity.UserType != "management" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+	if strings.Join(identity.Roles, ",") != "orders-readers,orders-writers" {
+		t.Fatalf("expected the roles of both groups without duplicates, got %v", identity.Roles)
+	}
+}
+
+func TestVerifyBearerTokens(t *testing.T) {
+	idp := newMockIdp(t)
+	provider := testProvider(idp)
+	ctx := context.Background()
+	claims := func(aud string, exp time.Duration) jwt.MapClaims {
+		return jwt.MapClaims{"iss": idp.server.URL, "aud": aud, "exp": time.Now().Add(exp).Unix(), "preferred_username": "ci-bot", "groups": "memphis-admins"}
+	}
+
+	es256 := idp.sign(t, jwt.SigningMethodES256, "ec-1", claims("memphis-api", time.Minute))
+	if !IsIdpToken(es256) {
+		t.Fatalf("expected an ES256 token to be treated as an IdP token")
+	}
+	if _, err := provider.Verify(ctx, es256, ""); err != nil {
+		t.Fatalf("expected the ES256 token with the configured audience to be valid: %v", err)
+	}
+	if _, err := provider.Verify(ctx, idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims("memphis-ui", time.Minute)), ""); err != nil {
+		t.Fatalf("expected the RS256 token with the client id audience to be valid: %v", err)
+	}
+
+	rejected := map[string]string{
+		"wrong audience": idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims("another-app", time.Minute)),
+		"expired":        idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims("memphis-api", -time.Minute)),
+		"wrong key":      idp.sign(t, jwt.SigningMethodES256, "rsa-1", claims("memphis-api", time.Minute)),
+		"unknown kid":    idp.sign(t, jwt.SigningMethodRS256, "rsa-2", claims("memphis-api", time.Minute)),
+	}
+	wrongIssuer := claims("memphis-api", time.Minute)
+	wrongIssuer["iss"] = "https://attacker.example.com"
+	rejected["wrong issuer"] = idp.sign(t, jwt.SigningMethodRS256, "rsa-1", wrongIssuer)
+	hs256, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims("memphis-api", time.Minute)).SignedString([]byte("secret"))
+	rejected["hs256"] = hs256
+	for name, token := range rejected {
+		if _, err := provider.Verify(ctx, token, ""); err == nil {
+			t.Errorf("expected the %v token to be rejected", name)
+		}
+	}
+	if IsIdpToken(hs256) {
+		t.Fatalf("expected a HS256 token to be verified as a memphis token")
+	}
+}
+
+func TestVerifyAfterKeyRotation(t *testing.T) {
+	idp := newMockIdp(t)
+	provider := testProvider(idp)
+	ctx := context.Background()
+	claims := jwt.MapClaims{"iss": idp.server.URL, "aud": "memphis-ui", "exp": time.Now().Add(time.Minute).Unix()}
+
+	if _, err := provider.Verify(ctx, idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims), ""); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	rotated, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa key: %v", err)
+	}
+	idp.mu.Lock()
+	idp.rsaKey = rotated
+	idp.keys = append(idp.keys, map[string]string{"kty": "RSA", "kid": "rsa-2", "n": b64(rotated.N.Bytes()), "e": b64(big.NewInt(int64(rotated.E)).Bytes())})
+	idp.mu.Unlock()
+	rotatedToken := idp.sign(t, jwt.SigningMethodRS256, "rsa-2", claims)
+
+	// unknown kids do not hammer the IdP right after a fetch
+	if _, err := provider.Verify(ctx, rotatedToken, ""); err == nil {
+		t.Fatalf("expected the new key to not be fetched within the refresh interval")
+	}
+	provider.mu.Lock()
+	provider.keysFetchedAt = time.Now().Add(-2 * jwksMinRefreshInterval)
+	provider.mu.Unlock()
+	if _, err := provider.Verify(ctx, rotatedToken, ""); err != nil {
+		t.Fatalf("expected the rotated key to be fetched: %v", err)
+	}
+	if idp.jwksServed != 2 {
+		t.Fatalf("expected 2 jwks fetches, got %v", idp.jwksServed)
+	}
+}
+
+func TestIdentityMapping(t *testing.T) {
+	provider := NewProvider(Config{
+		UsernameClaim:   "email",
+		GroupsClaim:     "roles",
+		UserTypeMapping: map[string]string{"apps": "application", "admins": "management"},
+	})
+
+	identity, err := provider.Identity(jwt.MapClaims{"email": "svc", "roles": []interface{}{"apps", "admins"}})
+	if err != nil || identity.UserType != "management" {
+		t.Fatalf("expected management to take precedence, got %+v %v", identity, err)
+	}
+	identity, err = provider.Identity(jwt.MapClaims{"email": "svc", "roles": "apps"})
+	if err != nil || identity.UserType != "application" {
+		t.Fatalf("expected an application user, got %+v %v", identity, err)
+	}
+	if _, err = provider.Identity(jwt.MapClaims{"email": "svc", "roles": []interface{}{"others"}}); err != ErrUserNotMapped {
+		t.Fatalf("expected an unmapped user error, got %v", err)
+	}
+	if _, err = provider.Identity(jwt.MapClaims{"email": "jane@example.com", "roles": "admins"}); err == nil {
+		t.Fatalf("expected an invalid username to be rejected")
+	}
+	if _, err = provider.Identity(jwt.MapClaims{"roles": "admins"}); err == nil {
+		t.Fatalf("expected a missing username claim to be rejected")
+	}
+
+	provider.config.DefaultUserType = "management"
+	identity, err = provider.Identity(jwt.MapClaims{"email": "jane", "roles": []interface{}{"others"}})
+	if err != nil || identity.UserType != "management" {
+		t.Fatalf("expected the default user type, got %+v %v", identity, err)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package oidc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/memphisdev/memphis/conf"
+	"github.com/memphisdev/memphis/idp"
+	"github.com/memphisdev/memphis/models"
+)
+
+const provisionedUsersOwner = "sso"
+
+// ProvisionUser returns the memphis user of the identity and creates it on its first login,
+// when a role mapping is configured the user's roles follow the IdP groups on every login
+func (p *Provider) ProvisionUser(identity Identity, tenantName string) (models.User, bool, error) {
+	if identity.UserType != "management" {
+		return models.User{}, false, errors.New("only management users can authenticate with the identity provider")
+	}
+	return idp.ProvisionUser(identity, tenantName, provisionedUsersOwner, len(p.config.RoleMapping) > 0)
+}
+
+// AuthenticateBearer verifies an IdP issued bearer token and returns the matching memphis user
+func AuthenticateBearer(ctx context.Context, raw string) (models.User, error) {
+	provider := GetProvider()
+	claims, err := provider.Verify(ctx, raw, "")
+	if err != nil {
+		return models.User{}, err
+	}
+	identity, err := provider.Identity(claims)
+	if err != nil {
+		return models.User{}, err
+	}
+	user, _, err := provider.ProvisionUser(identity, conf.MemphisGlobalAccountName)
+	if err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
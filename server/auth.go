@@ -29,6 +29,7 @@ import (
 	"time"
 
 	"github.com/memphisdev/memphis/internal/ldap"
+	"github.com/memphisdev/memphis/ldap_auth"
 	"github.com/nats-io/jwt/v2"
 	"github.com/nats-io/nkeys"
 	"golang.org/x/crypto/bcrypt"
@@ -827,6 +828,10 @@ func (s *Server) processClientOrLeafAuthentication(c *client, opts *Options) (au
 				if !ok {
 					user, ok = s.users[c.opts.Username+"$1"] // add global tenant id suffix
 				}
+				if !ok && c.kind == CLIENT && configuration.USER_PASS_BASED_AUTH && ldap_auth.Enabled() {
+					s.mu.Unlock()
+					return s.authenticateLdapClient(c)
+				}
 				// Added by Memphis ***
 
 				if !ok || !c.connectionTypeAllowed(user.AllowedConnectionTypes) {
@@ -36,6 +36,7 @@ const CONFIGURATIONS_RELOAD_SIGNAL_SUBJ = "$memphis_config_reload_signal"
 const NOTIFICATION_EVENTS_SUBJ = "$memphis_notifications"
 const PM_RESEND_ACK_SUBJ = "$memphis_pm_acks"
 const TIERED_STORAGE_CONSUMER = "$memphis_tiered_storage_consumer"
+const SCHEDULED_MSGS_CONSUMER = "$memphis_scheduled_msgs_consumer"
 const DLS_UNACKED_CONSUMER = "$memphis_dls_unacked_consumer"
 const NACKED_DLS_SUBJ = "$memphis_nacked_dls"
 const NACKED_DLS_INNER_SUBJ = "$memphis_nacked_inner_dls"
@@ -46,6 +47,7 @@ const SCHEMAVERSE_DLS_CONSUMER = "$memphis_schemaverse_dls_consumer"
 const FUNCTIONS_DLS_INNER_SUBJ = "$memphis_functions_inner_dls"
 const FUNCTIONS_DLS_CONSUMER = "$memphis_functions_dls_consumer"
 const CACHE_UDATES_SUBJ = "$memphis_cache_updates"
+const CG_PAUSE_UPDATES_SUBJ = "$memphis_cg_pause_updates"
 const NOTIFICATIONS_BUFFER_CONSUMER = "$memphis_notifications_buffer_consumer"
 const FUNCTION_TASKS_CONSUMER = "$memphis_function_tasks_consumer"
 
@@ -135,6 +137,10 @@ func (s *Server) ListenForIntegrationsUpdateEvents() error {
 				CacheDetails("slack", integrationUpdate.Keys, integrationUpdate.Properties, integrationUpdate.TenantName)
 			case "s3":
 				CacheDetails("s3", integrationUpdate.Keys, integrationUpdate.Properties, integrationUpdate.TenantName)
+			case "filesystem", "gcs", "azure_blob":
+				CacheDetails(strings.ToLower(integrationUpdate.Name), integrationUpdate.Keys, integrationUpdate.Properties, integrationUpdate.TenantName)
+			case webhookIntegrationName:
+				CacheDetails(webhookIntegrationName, integrationUpdate.Keys, integrationUpdate.Properties, integrationUpdate.TenantName)
 			case "github":
 				CacheDetails("github", integrationUpdate.Keys, integrationUpdate.Properties, integrationUpdate.TenantName)
 			default:
@@ -302,6 +308,7 @@ func (s *Server) CalculateSelfThroughput() {
 			ReadMap:  readMap,
 			WriteMap: writeMap,
 		}
+		lastSelfThroughput.Store(&tpMsg)
 		s.sendInternalAccountMsg(s.MemphisGlobalAccount(), subj, tpMsg)
 	}
 }
@@ -347,6 +354,11 @@ func (s *Server) StartBackgroundTasks() error {
 		return errors.New("Failed to subscribing for cache updates" + err.Error())
 	}
 
+	err = s.ListenForCgPauseUpdates()
+	if err != nil {
+		return errors.New("Failed subscribing for consumer group pause updates: " + err.Error())
+	}
+
 	err = s.ListenForCloudCacheUpdates()
 	if err != nil {
 		return errors.New("Failed to subscribing for cloud cache updates" + err.Error())
@@ -362,6 +374,7 @@ func (s *Server) StartBackgroundTasks() error {
 	go s.ConsumeUnackedMsgs()
 	go s.ConsumeFunctionsDlsMessages()
 	go s.ConsumeTieredStorageMsgs()
+	go s.ConsumeScheduledMsgs()
 	go s.RemoveOldDlsMsgs()
 	go s.uploadMsgsToTier2Storage()
 	go s.InitializeThroughputSampling()
@@ -377,6 +390,11 @@ func (s *Server) StartBackgroundTasks() error {
 	go s.ScaleFunctionWorkers()
 	go s.ConnectorsDeadPodsRescheduler()
 	go s.removeOldAsyncTasks()
+	go s.RefreshBrokerSchemaEnforcement()
+	go s.RefreshPartitionedStations()
+	go s.MonitorCgLag()
+	go s.RefreshPausedCgs()
+	go s.RetryDlsMessages()
 
 	return nil
 }
@@ -407,24 +425,11 @@ func (s *Server) uploadMsgsToTier2Storage() {
 			TIERED_STORAGE_CONSUMER_CREATED = true
 		}
 		tieredStorageMapLock.Lock()
+		// messages are acked per station by flushMapToTier2Storage, the ones that failed are retried on the next tick
 		err := flushMapToTier2Storage()
 		if err != nil {
 			serv.Errorf("Failed upload messages to tiered 2 storage: %v", err.Error())
-			tieredStorageMapLock.Unlock()
-			continue
 		}
-		// ack all messages uploaded to tiered 2 storage or when there is no s3 integaration to tenant
-		for t, tenant := range tieredStorageMsgsMap.m {
-			for i, msgs := range tenant {
-				for _, msg := range msgs {
-					reply := msg.ReplySubject
-					s.sendInternalAccountMsg(s.MemphisGlobalAccount(), reply, []byte(_EMPTY_))
-				}
-				delete(tenant, i)
-			}
-			tieredStorageMsgsMap.Delete(t)
-		}
-
 		tieredStorageMapLock.Unlock()
 	}
 }
@@ -559,6 +564,67 @@ func (s *Server) ConsumeTieredStorageMsgs() {
 	}
 }
 
+func (s *Server) ConsumeScheduledMsgs() {
+	type scheduledMsg struct {
+		Msg          []byte
+		ReplySubject string
+	}
+	amount := 1000
+	req := []byte(strconv.FormatUint(uint64(amount), 10))
+	for {
+		if SCHEDULED_MSGS_CONSUMER_CREATED && SCHEDULED_MSGS_STREAM_CREATED {
+			resp := make(chan scheduledMsg)
+			replySubj := SCHEDULED_MSGS_CONSUMER + "_reply_" + s.memphis.nuid.Next()
+
+			// subscribe to scheduled messages
+			sub, err := s.subscribeOnAcc(s.MemphisGlobalAccount(), replySubj, replySubj+"_sid", func(_ *client, subject, reply string, msg []byte) {
+				go func(subject, reply string, msg []byte) {
+					// Ignore 409 Exceeded MaxWaiting cases
+					if reply != _EMPTY_ {
+						message := scheduledMsg{
+							Msg:          msg,
+							ReplySubject: reply,
+						}
+						resp <- message
+					}
+				}(subject, reply, copyBytes(msg))
+			})
+			if err != nil {
+				s.Errorf("Failed to subscribe to scheduled messages: %v", err.Error())
+				continue
+			}
+
+			// send JS API request to get more messages
+			subject := fmt.Sprintf(JSApiRequestNextT, scheduledMsgsStream, SCHEDULED_MSGS_CONSUMER)
+			s.sendInternalAccountMsgWithReply(s.MemphisGlobalAccount(), subject, replySubj, nil, req, true)
+
+			timeout := time.NewTimer(5 * time.Second)
+			msgs := make([]scheduledMsg, 0)
+			stop := false
+			for {
+				if stop {
+					s.unsubscribeOnAcc(s.MemphisGlobalAccount(), sub)
+					break
+				}
+				select {
+				case msg := <-resp:
+					msgs = append(msgs, msg)
+					if len(msgs) == amount {
+						stop = true
+					}
+				case <-timeout.C:
+					stop = true
+				}
+			}
+			for _, message := range msgs {
+				s.handleScheduledMsg(message.Msg, message.ReplySubject)
+			}
+		} else {
+			time.Sleep(2 * time.Second)
+		}
+	}
+}
+
 func (s *Server) ConsumeSchemaverseDlsMessages() {
 	type schemaverseDlsMsg struct {
 		Msg          []byte
@@ -759,6 +825,15 @@ func (s *Server) CheckBrokenConnectedIntegrations() error {
 						serv.Errorf("[tenant: %s]CheckBrokenConnectedIntegrations at UpdateIsValidIntegration: %v", integration.TenantName, err.Error())
 					}
 				}
+			case "filesystem", "gcs", "azure_blob":
+				err := testTieredStorageIntegration(integration)
+				if err != nil {
+					serv.Warnf("[tenant: %s]CheckBrokenConnectedIntegrations at testTieredStorageIntegration: %v", integration.TenantName, err.Error())
+				}
+				err = db.UpdateIsValidIntegration(integration.TenantName, integration.Name, err == nil)
+				if err != nil {
+					serv.Errorf("[tenant: %s]CheckBrokenConnectedIntegrations at UpdateIsValidIntegration: %v", integration.TenantName, err.Error())
+				}
 			case "slack":
 				key := getAESKey()
 				if _, ok := integration.Keys["auth_token"].(string); !ok {
@@ -877,7 +952,7 @@ func (s *Server) ConsumeNotificationsBufferMessages() error {
 			continue
 		}
 
-		sendSlackNotifications(s, msgs)
+		sendBufferedNotifications(s, msgs)
 	}
 }
 
@@ -3851,6 +3851,26 @@ func (c *client) processInboundClientMsg(msg []byte) (bool, bool) {
 		return false, true
 	}
 
+	// *** added by Memphis
+	if c.kind == CLIENT {
+		// partition routing and scheduled delivery are driven by headers, so a plain publish
+		// only pays for this when some station enforces its schema
+		enforceSchema := hasBrokerEnforcedStations.Load()
+		if enforceSchema || c.pa.hdr > 0 {
+			hdrBytes, msgBytes := c.msgParts(msg[:len(msg)-LEN_CR_LF])
+			if c.pa.hdr > 0 && hasPartitionedStations.Load() {
+				c.routeByPartitionKey(hdrBytes)
+			}
+			if enforceSchema && !c.enforceStationSchema(string(c.pa.subject), string(c.pa.reply), hdrBytes, msgBytes) {
+				return false, false
+			}
+			if c.pa.hdr > 0 && c.scheduleDelayedMsg(string(c.pa.subject), string(c.pa.reply), hdrBytes, msgBytes) {
+				return false, false
+			}
+		}
+	}
+	// added by Memphis ***
+
 	if c.opts.Verbose {
 		c.sendOK()
 	}
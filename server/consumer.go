@@ -382,6 +382,10 @@ type consumer struct {
 
 	// for stream signaling when multiple filters are set.
 	sigSubs []*subscription
+
+	// ** added by Memphis
+	memphisPaused atomic.Bool
+	// added by Memphis **
 }
 
 // A single subject filter.
@@ -1018,6 +1022,11 @@ func (mset *stream) addConsumerWithAssignment(config *ConsumerConfig, oname stri
 	mset.setConsumer(o)
 	mset.mu.Unlock()
 
+	// ** added by Memphis
+	// checked once the consumer is registered so a pause that expires meanwhile clears the flag
+	o.memphisPaused.Store(isConsumerInPausedCg(acc.Name, cfg.Name, o.name))
+	// added by Memphis **
+
 	if config.Direct || (!s.JetStreamIsClustered() && s.standAloneMode()) {
 		o.setLeader(true)
 	}
@@ -3912,6 +3921,12 @@ func (o *consumer) loopAndGatherMsgs(qch chan struct{}) {
 			goto waitForMsgs
 		}
 
+		// ** added by Memphis
+		if o.isPausedByMemphis() {
+			goto waitForMsgs
+		}
+		// added by Memphis **
+
 		// Grab our next msg.
 		pmsg, dc, redelivery, err = o.getNextMsg() // ** redelivery added by memphis
 
@@ -26,6 +26,7 @@ var SourceCodeManagementFunctionsMap map[string]map[string]interface{}
 const PoisonMAlert = "poison_message_alert"
 const SchemaVAlert = "schema_validation_fail_alert"
 const DisconEAlert = "disconnection_events_alert"
+const CgLagAlert = "cg_lag_alert"
 
 func InitializeIntegrations() error {
 	IntegrationsConcurrentCache = NewConcurrentMap[map[string]interface{}]()
@@ -33,7 +34,10 @@ func InitializeIntegrations() error {
 	StorageFunctionsMap = make(map[string]interface{})
 	SourceCodeManagementFunctionsMap = make(map[string]map[string]interface{})
 	NotificationFunctionsMap["slack"] = sendMessageToSlackChannel
-	StorageFunctionsMap["s3"] = serv.uploadToS3Storage
+	NotificationFunctionsMap[webhookIntegrationName] = sendWebhookNotification
+	for integrationType := range tieredStorageBackendTypes {
+		StorageFunctionsMap[integrationType] = serv.tieredStorageUploader(integrationType)
+	}
 	SourceCodeManagementFunctionsMap["github"] = make(map[string]interface{})
 	SourceCodeManagementFunctionsMap["github"]["get_all_repos"] = serv.getGithubRepositories
 	SourceCodeManagementFunctionsMap["github"]["get_all_branches"] = serv.getGithubBranches
@@ -79,6 +83,10 @@ func CacheDetails(integrationType string, keys map[string]interface{}, propertie
 		cacheDetailsSlack(keys, properties, tenantName)
 	case "s3":
 		cacheDetailsS3(keys, properties, tenantName)
+	case "filesystem", "gcs", "azure_blob":
+		cacheDetailsTieredStorage(integrationType, keys, properties, tenantName)
+	case webhookIntegrationName:
+		cacheDetailsWebhook(keys, properties, tenantName)
 	case "github":
 		cacheDetailsGithub(keys, properties, tenantName)
 	}
@@ -148,6 +156,10 @@ func encryptUnencryptedAppUsersPasswords() error {
 	}
 	key := getAESKey()
 	for _, user := range users {
+		if user.Password == _EMPTY_ {
+			// provisioned from the directory
+			continue
+		}
 		_, err := DecryptAES(key, user.Password)
 		if err != nil {
 			password, err := EncryptAES([]byte(user.Password))
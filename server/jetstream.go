@@ -1358,6 +1358,8 @@ func (a *Account) EnableJetStream(limits map[string]JetStreamAccountLimits) erro
 			DLS_FUNCTIONS_STREAM_CREATED = true
 		case connectorsLogsStream:
 			CONNECTORS_LOGS_STREAM_CREATED = true
+		case scheduledMsgsStream:
+			SCHEDULED_MSGS_STREAM_CREATED = true
 		}
 		// added by Memphis ***
 
@@ -1396,6 +1398,8 @@ func (a *Account) EnableJetStream(limits map[string]JetStreamAccountLimits) erro
 			FUNCTIONS_TASKS_CONSUMER_CREATED = true
 		case dlsFunctionsStream:
 			DLS_FUNCTIONS_CONSUMER_CREATED = true
+		case scheduledMsgsStream:
+			SCHEDULED_MSGS_CONSUMER_CREATED = true
 		}
 		// added by Memphis ***
 
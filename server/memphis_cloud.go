@@ -35,6 +35,7 @@ import (
 	"github.com/memphisdev/memphis/db"
 	"github.com/memphisdev/memphis/memphis_cache"
 	"github.com/memphisdev/memphis/models"
+	"github.com/memphisdev/memphis/tracing"
 	"github.com/memphisdev/memphis/utils"
 	"gopkg.in/yaml.v2"
 
@@ -1232,9 +1233,14 @@ func (umh UserMgmtHandler) Login(c *gin.Context) {
 		return
 	}
 
+	loginUser(c, user)
+}
+
+// loginUser issues the memphis tokens of an authenticated management user and sends the login details
+func loginUser(c *gin.Context, user models.User) {
 	token, refreshToken, err := CreateTokens(user)
 	if err != nil {
-		serv.Errorf("[tenant: %v][user: %v]Login at CreateTokens: User %v: %v", user.TenantName, user.Username, body.Username, err.Error())
+		serv.Errorf("[tenant: %v][user: %v]Login at CreateTokens: %v", user.TenantName, user.Username, err.Error())
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 		return
 	}
@@ -1242,7 +1248,7 @@ func (umh UserMgmtHandler) Login(c *gin.Context) {
 	if !user.AlreadyLoggedIn {
 		err = db.UpdateUserAlreadyLoggedIn(user.ID)
 		if err != nil {
-			serv.Errorf("[tenant: %v][user: %v]Login at UpdateUserAlreadyLoggedIn: User %v: %v", user.TenantName, user.Username, body.Username, err.Error())
+			serv.Errorf("[tenant: %v][user: %v]Login at UpdateUserAlreadyLoggedIn: %v", user.TenantName, user.Username, err.Error())
 			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 			return
 		}
@@ -1254,19 +1260,19 @@ func (umh UserMgmtHandler) Login(c *gin.Context) {
 	}
 	exist, tenant, err := db.GetTenantByName(user.TenantName)
 	if err != nil {
-		serv.Errorf("[tenant: %v][user: %v]Login at GetTenantByName: User %v: %v", user.TenantName, user.Username, body.Username, err.Error())
+		serv.Errorf("[tenant: %v][user: %v]Login at GetTenantByName: %v", user.TenantName, user.Username, err.Error())
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 		return
 	}
 	if !exist {
-		serv.Warnf("[tenant: %v][user: %v]Login: User %v: tenant %v does not exist", user.TenantName, user.Username, body.Username, user.TenantName)
+		serv.Warnf("[tenant: %v][user: %v]Login: tenant %v does not exist", user.TenantName, user.Username, user.TenantName)
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 		return
 	}
 	decriptionKey := getAESKey()
 	decryptedUserPassword, err := DecryptAES(decriptionKey, tenant.InternalWSPass)
 	if err != nil {
-		serv.Errorf("Login: User " + body.Username + ": " + err.Error())
+		serv.Errorf("[tenant: %v][user: %v]Login at DecryptAES: %v", user.TenantName, user.Username, err.Error())
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 		return
 	}
@@ -1275,7 +1281,7 @@ func (umh UserMgmtHandler) Login(c *gin.Context) {
 
 	lastLogin, err := db.UpdateLastLoginUser(user.ID)
 	if err != nil {
-		serv.Errorf("[tenant: %v][user: %v]Login at UpdateLastLoginUser: User %v: %v", user.TenantName, user.Username, user.Username, err.Error())
+		serv.Errorf("[tenant: %v][user: %v]Login at UpdateLastLoginUser: %v", user.TenantName, user.Username, err.Error())
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 		return
 	}
@@ -1592,8 +1598,14 @@ func (umh UserMgmtHandler) RemoveUser(c *gin.Context) {
 		return
 	}
 
-	//TODO - at the future, do not remove the role and permissions from the DB, just remove the role from the user
-	err = db.RemoveRoleAndPermissions(userToRemove.Roles, userToRemove.TenantName)
+	// roles managed through the rbac api may be shared with other users, only the user's own role is removed
+	ownRoles, err := getUserOwnRoles(userToRemove)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RemoveUser at getUserOwnRoles: User %v: %v", user.TenantName, user.Username, body.Username, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	err = db.RemoveRoleAndPermissions(ownRoles, userToRemove.TenantName)
 	if err != nil {
 		serv.Errorf("[tenant: %v][user: %v]RemoveUser error with deleting role and permissions from the DB: User %v: %v", user.TenantName, user.Username, body.Username, err.Error())
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
@@ -2198,6 +2210,9 @@ func (sh StationsHandler) Produce(c *gin.Context) {
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 		return
 	}
+	if !validateStationAccess(c, user, stationName.Ext(), "write", "Produce") {
+		return
+	}
 
 	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
 	if err != nil {
@@ -2212,6 +2227,13 @@ func (sh StationsHandler) Produce(c *gin.Context) {
 		return
 	}
 
+	traceHeaders := body.MsgHdrs
+	if traceparent := c.GetHeader(tracing.TraceparentHeader); traceparent != _EMPTY_ {
+		traceHeaders = map[string]string{tracing.TraceparentHeader: traceparent, tracing.TracestateHeader: c.GetHeader(tracing.TracestateHeader)}
+	}
+	span := startStationSpan("produce "+stationName.Ext(), tracing.SpanKindProducer, traceHeaders, user.TenantName, stationName.Ext())
+	defer span.End()
+
 	subject := _EMPTY_
 	shouldRoundRobin := false
 	if station.Version == 0 {
@@ -2222,6 +2244,7 @@ func (sh StationsHandler) Produce(c *gin.Context) {
 
 	account, err := serv.lookupAccount(user.TenantName)
 	if err != nil {
+		span.SetError(err)
 		serv.Errorf("[tenant: %v][user: %v]Produce at lookupAccount: %v", user.TenantName, user.Username, err.Error())
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 		return
@@ -2232,10 +2255,26 @@ func (sh StationsHandler) Produce(c *gin.Context) {
 	}
 	body.MsgHdrs["$memphis_producedBy"] = "UI"
 	body.MsgHdrs["$memphis_connectionId"] = "UI"
+	span.Context().Inject(body.MsgHdrs)
 	if shouldRoundRobin {
-		rand.Seed(time.Now().UnixNano())
-		randomIndex := rand.Intn(len(station.PartitionsList))
-		subject = fmt.Sprintf("%s$%v.final", stationName.Intern(), station.PartitionsList[randomIndex])
+		if key := body.MsgHdrs[partitionKeyHeader]; key != _EMPTY_ {
+			subject = fmt.Sprintf("%s$%v.final", stationName.Intern(), partitionForKey([]byte(key), station.PartitionsList))
+		} else {
+			rand.Seed(time.Now().UnixNano())
+			randomIndex := rand.Intn(len(station.PartitionsList))
+			subject = fmt.Sprintf("%s$%v.final", stationName.Intern(), station.PartitionsList[randomIndex])
+		}
+	}
+
+	enforced, partition, err := validateBrokerEnforcedMsg(user.TenantName, subject, []byte(body.MsgPayload))
+	if err != nil {
+		span.SetError(err)
+		serv.Warnf("[tenant: %v][user: %v]Produce at validateBrokerEnforcedMsg: Station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		if enforced.dlsEnabled {
+			serv.sendBrokerEnforcedMsgToDls(account, stationName.Intern(), partition, body.MsgHdrs, []byte(body.MsgPayload), err)
+		}
+		c.AbortWithStatusJSON(SCHEMA_VALIDATION_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
 	}
 	serv.sendInternalAccountMsgWithHeadersWithEcho(account, subject, body.MsgPayload, body.MsgHdrs)
 
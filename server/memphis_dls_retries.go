@@ -0,0 +1,166 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/models"
+)
+
+const (
+	dlsRetryDefaultInitialDelayMs = 1000
+	dlsRetryDefaultMultiplier     = 2
+	dlsRetryDefaultMaxDelayMs     = 60000
+	dlsRetryMaxRetries            = 50
+	dlsRetryBatchSize             = 100
+	dlsRetryLease                 = 30 * time.Second
+	dlsRetryInterval              = 1 * time.Second
+)
+
+func normalizeDlsRetryPolicy(policy models.DlsRetryPolicy) models.DlsRetryPolicy {
+	if policy.InitialDelayMs <= 0 {
+		policy.InitialDelayMs = dlsRetryDefaultInitialDelayMs
+	}
+	if policy.BackoffMultiplier == 0 {
+		policy.BackoffMultiplier = dlsRetryDefaultMultiplier
+	}
+	if policy.MaxDelayMs <= 0 {
+		policy.MaxDelayMs = dlsRetryDefaultMaxDelayMs
+		if policy.InitialDelayMs > policy.MaxDelayMs {
+			policy.MaxDelayMs = policy.InitialDelayMs
+		}
+	}
+	return policy
+}
+
+func validateDlsRetryPolicy(policy models.DlsRetryPolicy) error {
+	if policy.MaxRetries < 0 || policy.MaxRetries > dlsRetryMaxRetries {
+		return errors.New("max_retries has to be between 0 and " + strconv.Itoa(dlsRetryMaxRetries))
+	}
+	if policy.InitialDelayMs < 0 || policy.MaxDelayMs < 0 {
+		return errors.New("retry delays can not be negative")
+	}
+	if policy.BackoffMultiplier != 0 && policy.BackoffMultiplier < 1 {
+		return errors.New("backoff_multiplier has to be at least 1")
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return errors.New("jitter has to be between 0 and 1")
+	}
+	normalized := normalizeDlsRetryPolicy(policy)
+	if normalized.MaxDelayMs < normalized.InitialDelayMs {
+		return errors.New("max_delay_ms can not be lower than initial_delay_ms")
+	}
+	return nil
+}
+
+// dlsRetryDelay returns the backoff before the retry that follows the given number of attempts
+func dlsRetryDelay(policy models.DlsRetryPolicy, attempts int) time.Duration {
+	policy = normalizeDlsRetryPolicy(policy)
+	delay := float64(policy.InitialDelayMs) * math.Pow(policy.BackoffMultiplier, float64(attempts))
+	if delay > float64(policy.MaxDelayMs) {
+		delay = float64(policy.MaxDelayMs)
+	}
+	if policy.Jitter > 0 {
+		delay += delay * policy.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay) * time.Millisecond
+}
+
+// scheduleDlsRetryOrNotify hands a new poison message to the automatic retries of its station,
+// the poison message alert is only sent when the station has no retries left for it
+func (s *Server) scheduleDlsRetryOrNotify(station models.Station, dlsMsgId int) {
+	policy := station.DlsRetryPolicy
+	if policy.MaxRetries > 0 && station.IsNative {
+		scheduled, err := db.ScheduleDlsMsgRetry(dlsMsgId, time.Now().Add(dlsRetryDelay(policy, 0)), policy.MaxRetries)
+		if err != nil {
+			s.Errorf("[tenant: %v]scheduleDlsRetryOrNotify at ScheduleDlsMsgRetry: station: %v: %v", station.TenantName, station.Name, err.Error())
+		} else if scheduled {
+			return
+		}
+	}
+	s.sendPoisonMessageNotification(station.TenantName, station.Name, dlsMsgId)
+}
+
+func (s *Server) sendPoisonMessageNotification(tenantName, stationName string, dlsMsgId int) {
+	msgUrl := s.opts.UiHost + "/stations/" + stationName + "/" + strconv.Itoa(dlsMsgId)
+	err := s.SendNotification(tenantName, PoisonMessageTitle, "Poison message has been identified, for more details head to: "+msgUrl, PoisonMAlert)
+	if err != nil {
+		s.Warnf("[tenant: %v]sendPoisonMessageNotification at SendNotification: Error while sending a poison message notification: %v", tenantName, err.Error())
+	}
+}
+
+func (s *Server) RetryDlsMessages() {
+	ticker := time.NewTicker(dlsRetryInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		now := time.Now()
+		dlsMsgs, err := db.ClaimDueDlsMsgRetries(now, now.Add(dlsRetryLease), dlsRetryBatchSize)
+		if err != nil {
+			s.Errorf("RetryDlsMessages at ClaimDueDlsMsgRetries: %v", err.Error())
+			continue
+		}
+
+		stations := map[int]models.Station{}
+		for _, dlsMsg := range dlsMsgs {
+			station, ok := stations[dlsMsg.StationId]
+			if !ok {
+				exist, st, err := db.GetStationById(dlsMsg.StationId, dlsMsg.TenantName)
+				if err != nil {
+					s.Errorf("[tenant: %v]RetryDlsMessages at GetStationById: %v", dlsMsg.TenantName, err.Error())
+					continue
+				}
+				if !exist {
+					err = db.UpdateDlsMsgRetry(dlsMsg.ID, dlsMsg.RetryAttempts, nil)
+					if err != nil {
+						s.Errorf("[tenant: %v]RetryDlsMessages at UpdateDlsMsgRetry: %v", dlsMsg.TenantName, err.Error())
+					}
+					continue
+				}
+				station = st
+				stations[dlsMsg.StationId] = station
+			}
+			s.retryDlsMessage(station, dlsMsg)
+		}
+	}
+}
+
+func (s *Server) retryDlsMessage(station models.Station, dlsMsg models.DlsMessage) {
+	policy := station.DlsRetryPolicy
+	if dlsMsg.RetryAttempts >= policy.MaxRetries || len(dlsMsg.PoisonedCgs) == 0 {
+		err := db.UpdateDlsMsgRetry(dlsMsg.ID, dlsMsg.RetryAttempts, nil)
+		if err != nil {
+			s.Errorf("[tenant: %v]retryDlsMessage at UpdateDlsMsgRetry: station: %v: %v", station.TenantName, station.Name, err.Error())
+			return
+		}
+		s.sendPoisonMessageNotification(station.TenantName, station.Name, dlsMsg.ID)
+		return
+	}
+
+	// the resent copies remove the consumer groups from the record once acked,
+	// so a record which still exists when it is due again has failed the previous attempt
+	_, err := s.ResendUnackedMsg(dlsMsg, models.User{TenantName: station.TenantName}, station.Name)
+	if err != nil {
+		s.Warnf("[tenant: %v]retryDlsMessage at ResendUnackedMsg: station: %v: %v", station.TenantName, station.Name, err.Error())
+	}
+	attempts := dlsMsg.RetryAttempts + 1
+	nextRetryAt := time.Now().Add(dlsRetryDelay(policy, attempts))
+	err = db.UpdateDlsMsgRetry(dlsMsg.ID, attempts, &nextRetryAt)
+	if err != nil {
+		s.Errorf("[tenant: %v]retryDlsMessage at UpdateDlsMsgRetry: station: %v: %v", station.TenantName, station.Name, err.Error())
+	}
+}
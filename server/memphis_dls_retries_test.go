@@ -0,0 +1,48 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/memphisdev/memphis/models"
+)
+
+func TestDlsRetryDelay(t *testing.T) {
+	policy := models.DlsRetryPolicy{MaxRetries: 5, InitialDelayMs: 100, BackoffMultiplier: 3, MaxDelayMs: 1000}
+	expected := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second, time.Second}
+	for attempts, delay := range expected {
+		if got := dlsRetryDelay(policy, attempts); got != delay {
+			t.Fatalf("attempt %v: expected %v, got %v", attempts, delay, got)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		got := dlsRetryDelay(policy, 1)
+		if got < 150*time.Millisecond || got > 450*time.Millisecond {
+			t.Fatalf("jittered delay %v is out of range", got)
+		}
+	}
+
+	if got := dlsRetryDelay(models.DlsRetryPolicy{MaxRetries: 1}, 0); got != time.Second {
+		t.Fatalf("expected the default initial delay, got %v", got)
+	}
+}
+
+func TestValidateDlsRetryPolicy(t *testing.T) {
+	invalid := []models.DlsRetryPolicy{
+		{MaxRetries: -1},
+		{MaxRetries: dlsRetryMaxRetries + 1},
+		{MaxRetries: 3, BackoffMultiplier: 0.5},
+		{MaxRetries: 3, Jitter: 1.5},
+		{MaxRetries: 3, InitialDelayMs: 5000, MaxDelayMs: 1000},
+	}
+	for _, policy := range invalid {
+		if validateDlsRetryPolicy(policy) == nil {
+			t.Fatalf("expected %+v to be rejected", policy)
+		}
+	}
+	if err := validateDlsRetryPolicy(models.DlsRetryPolicy{MaxRetries: 3, InitialDelayMs: 500, BackoffMultiplier: 2, MaxDelayMs: 10000, Jitter: 0.2}); err != nil {
+		t.Fatalf("expected a valid policy, got: %v", err)
+	}
+}
@@ -35,6 +35,7 @@ type Handlers struct {
 	PoisonMsgs     PoisonMessagesHandler
 	Tags           TagsHandler
 	Schemas        SchemasHandler
+	SchemaRegistry SchemaRegistryHandler
 	Integrations   IntegrationsHandler
 	Configurations ConfigurationsHandler
 	Tenants        TenantHandler
@@ -42,6 +43,8 @@ type Handlers struct {
 	userMgmt       UserMgmtHandler
 	AsyncTasks     AsyncTasksHandler
 	Functions      FunctionsHandler
+	Rbac           RbacHandler
+	ApiKeys        ApiKeysHandler
 }
 
 var serv *Server
@@ -189,7 +192,7 @@ func CreateDefaultSchema(username, tenantName string, userId int) (string, error
 		},
 		"required": [ "locality" ]
 	}`
-	newSchema, rowsUpdated, err := db.InsertNewSchema(defaultSchemaName, defualtSchemaType, username, tenantName)
+	newSchema, rowsUpdated, err := db.InsertNewSchema(defaultSchemaName, defualtSchemaType, username, tenantName, models.SchemaCompatibilityNone)
 	if err != nil {
 		return _EMPTY_, err
 	}
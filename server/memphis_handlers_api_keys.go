@@ -0,0 +1,219 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/middlewares"
+	"github.com/memphisdev/memphis/models"
+	"github.com/memphisdev/memphis/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ApiKeysHandler struct{}
+
+// validateApiKeyRoles verifies the roles are management roles of the tenant,
+// a user restricted by roles can only create keys with its own roles
+func validateApiKeyRoles(roleIDs []int, user models.User) error {
+	if len(roleIDs) == 0 {
+		return nil
+	}
+	roles, err := db.GetRolesByIds(roleIDs, user.TenantName)
+	if err != nil {
+		return err
+	}
+	if len(roles) != len(roleIDs) {
+		return errors.New("one or more of the roles do not exist")
+	}
+	for _, role := range roles {
+		if role.Type != "management" {
+			return fmt.Errorf("role %v is not a management role", role.Name)
+		}
+	}
+	if len(user.Roles) > 0 {
+		remaining := removeRoleIds(roleIDs, user.Roles)
+		if len(remaining) > 0 {
+			return errors.New("an API key can only be assigned roles of the user creating it")
+		}
+	}
+	return nil
+}
+
+func canManageApiKey(user models.User, apiKey models.ApiKey) bool {
+	return user.UserType == "root" || len(user.Roles) == 0 || apiKey.UserID == user.ID
+}
+
+func (ah ApiKeysHandler) CreateApiKey(c *gin.Context) {
+	var body models.CreateApiKeySchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("CreateApiKey: API key %v: %v", body.Name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	name := strings.TrimSpace(body.Name)
+	scope := strings.ToLower(body.Scope)
+	if name == "" {
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "API key name can not be empty"})
+		return
+	}
+	if !middlewares.IsValidApiKeyScope(scope) {
+		serv.Warnf("[tenant: %v][user: %v]CreateApiKey: API key %v: invalid scope %v", user.TenantName, user.Username, name, scope)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "scope has to be one of the following read_only/station_admin/schema_admin"})
+		return
+	}
+	roleIDs := uniqueRoleIds(body.RoleIDs)
+	err = validateApiKeyRoles(roleIDs, user)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]CreateApiKey at validateApiKeyRoles: API key %v: %v", user.TenantName, user.Username, name, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+	var expiresAt *time.Time
+	if body.ExpiresInDays > 0 {
+		expiration := time.Now().AddDate(0, 0, body.ExpiresInDays)
+		expiresAt = &expiration
+	}
+
+	key, prefix, err := middlewares.GenerateApiKey()
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]CreateApiKey at GenerateApiKey: API key %v: %v", user.TenantName, user.Username, name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	apiKey, err := db.CreateApiKey(name, prefix, middlewares.HashApiKey(key), scope, roleIDs, user.ID, user.Username, user.TenantName, expiresAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			serv.Warnf("[tenant: %v][user: %v]CreateApiKey: API key %v already exists", user.TenantName, user.Username, name)
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "API key " + name + " already exists"})
+			return
+		}
+		serv.Errorf("[tenant: %v][user: %v]CreateApiKey at CreateApiKey: API key %v: %v", user.TenantName, user.Username, name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	serv.Noticef("[tenant: %v][user: %v]API key %v has been created", user.TenantName, user.Username, name)
+	c.IndentedJSON(200, models.CreateApiKeyRes{ApiKey: apiKey, Key: key})
+}
+
+func (ah ApiKeysHandler) GetApiKeys(c *gin.Context) {
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("GetApiKeys: %v", err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	apiKeys, err := db.GetApiKeysByTenant(user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]GetApiKeys at GetApiKeysByTenant: %v", user.TenantName, user.Username, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	apiKeysRes := []models.ApiKey{}
+	for _, apiKey := range apiKeys {
+		if canManageApiKey(user, apiKey) {
+			apiKeysRes = append(apiKeysRes, apiKey)
+		}
+	}
+	c.IndentedJSON(200, apiKeysRes)
+}
+
+func (ah ApiKeysHandler) RotateApiKey(c *gin.Context) {
+	var body models.RotateApiKeySchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("RotateApiKey: API key %v: %v", body.ID, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	exist, apiKey, err := db.GetApiKeyById(body.ID, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RotateApiKey at GetApiKeyById: API key %v: %v", user.TenantName, user.Username, body.ID, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist || !canManageApiKey(user, apiKey) {
+		serv.Warnf("[tenant: %v][user: %v]RotateApiKey: API key %v does not exist", user.TenantName, user.Username, body.ID)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "API key does not exist"})
+		return
+	}
+
+	key, prefix, err := middlewares.GenerateApiKey()
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RotateApiKey at GenerateApiKey: API key %v: %v", user.TenantName, user.Username, apiKey.Name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	// the previous key stops working immediately
+	apiKey, err = db.RotateApiKey(apiKey.ID, user.TenantName, prefix, middlewares.HashApiKey(key))
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RotateApiKey at RotateApiKey: API key %v: %v", user.TenantName, user.Username, body.ID, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	serv.Noticef("[tenant: %v][user: %v]API key %v has been rotated", user.TenantName, user.Username, apiKey.Name)
+	c.IndentedJSON(200, models.CreateApiKeyRes{ApiKey: apiKey, Key: key})
+}
+
+func (ah ApiKeysHandler) RemoveApiKey(c *gin.Context) {
+	var body models.RemoveApiKeySchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("RemoveApiKey: API key %v: %v", body.ID, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	exist, apiKey, err := db.GetApiKeyById(body.ID, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RemoveApiKey at GetApiKeyById: API key %v: %v", user.TenantName, user.Username, body.ID, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist || !canManageApiKey(user, apiKey) {
+		serv.Warnf("[tenant: %v][user: %v]RemoveApiKey: API key %v does not exist", user.TenantName, user.Username, body.ID)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "API key does not exist"})
+		return
+	}
+	_, err = db.RemoveApiKey(apiKey.ID, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RemoveApiKey at RemoveApiKey: API key %v: %v", user.TenantName, user.Username, apiKey.Name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	serv.Noticef("[tenant: %v][user: %v]API key %v has been removed", user.TenantName, user.Username, apiKey.Name)
+	c.IndentedJSON(200, gin.H{})
+}
@@ -119,6 +119,10 @@ func (ash AsyncTasksHandler) GetAllAsyncTasks(tenantName string) ([]models.Async
 		switch task.Name {
 		case "resend_all_dls_msgs":
 			task.Name = "Resend All DLS Messages"
+		case dlsExportTaskName:
+			task.Name = "Export DLS Messages"
+		case tieredRehydrateTaskName:
+			task.Name = "Rehydrate From Tiered Storage"
 		case "clone_repo":
 			task.Name = "Add GitHub Repo"
 		case "install_function":
@@ -0,0 +1,438 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/models"
+	"github.com/memphisdev/memphis/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	cgLagCheckInterval        = 30 * time.Second
+	cgLagDefaultSustainedSec  = 60
+	cgLagAlertTitle           = "Consumer group lag"
+	cgLagRecoveredAlertTitle  = "Consumer group lag recovered"
+	cgLagMaxSustainedForSec   = 24 * 60 * 60
+	cgLagThresholdStationWide = _EMPTY_
+)
+
+// cgLagAlertState tracks a single consumer group between lag checks
+type cgLagAlertState struct {
+	breachedSince time.Time
+	alerting      bool
+}
+
+// evaluate returns fire when the breach has lasted for the whole sustained window and recovered
+// when a group that was alerted on is back under its threshold
+func (st *cgLagAlertState) evaluate(breached bool, now time.Time, sustainedFor time.Duration) (fire bool, recovered bool) {
+	if !breached {
+		recovered = st.alerting
+		st.breachedSince = time.Time{}
+		st.alerting = false
+		return false, recovered
+	}
+	if st.breachedSince.IsZero() {
+		st.breachedSince = now
+	}
+	if !st.alerting && now.Sub(st.breachedSince) >= sustainedFor {
+		st.alerting = true
+		return true, false
+	}
+	return false, false
+}
+
+// cgLagBreached returns a description of the crossed limits, empty when the group is within its threshold
+func cgLagBreached(lag models.CgLag, threshold models.CgLagThreshold) string {
+	reasons := []string{}
+	if threshold.MaxPendingMsgs > 0 && lag.PendingMsgs+uint64(lag.AckPendingMsgs) >= uint64(threshold.MaxPendingMsgs) {
+		reasons = append(reasons, fmt.Sprintf("%v unprocessed messages (threshold %v)", lag.PendingMsgs+uint64(lag.AckPendingMsgs), threshold.MaxPendingMsgs))
+	}
+	if threshold.MaxOldestUnackedAgeMs > 0 && lag.OldestUnackedAgeMs >= threshold.MaxOldestUnackedAgeMs {
+		reasons = append(reasons, fmt.Sprintf("oldest unacked message is %v old (threshold %v)", time.Duration(lag.OldestUnackedAgeMs)*time.Millisecond, time.Duration(threshold.MaxOldestUnackedAgeMs)*time.Millisecond))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// cgLagThresholdFor prefers a threshold set for the consumer group over the station wide one
+func cgLagThresholdFor(cgName string, thresholds []models.CgLagThreshold) (models.CgLagThreshold, bool) {
+	var stationWide models.CgLagThreshold
+	found := false
+	for _, t := range thresholds {
+		if t.CgName == cgName {
+			return t, true
+		}
+		if t.CgName == cgLagThresholdStationWide {
+			stationWide = t
+			found = true
+		}
+	}
+	return stationWide, found
+}
+
+func validateCgLagThreshold(body models.UpdateCgLagThresholdSchema) error {
+	if body.MaxPendingMsgs < 0 || body.MaxOldestUnackedAgeMs < 0 || body.SustainedForSec < 0 {
+		return errors.New("threshold values can not be negative")
+	}
+	if body.MaxPendingMsgs == 0 && body.MaxOldestUnackedAgeMs == 0 {
+		return errors.New("at least one of max_pending_msgs or max_oldest_unacked_age_ms has to be set")
+	}
+	if body.SustainedForSec > cgLagMaxSustainedForSec {
+		return fmt.Errorf("sustained_for_sec can not be greater than %v", cgLagMaxSustainedForSec)
+	}
+	return nil
+}
+
+// getStationCgsLag collects the lag of every consumer group of the station per partition,
+// when cgName is not empty only that group is returned
+func (s *Server) getStationCgsLag(station models.Station, cgName string) ([]models.CgLag, error) {
+	stationName, err := StationNameFromStr(station.Name)
+	if err != nil {
+		return []models.CgLag{}, err
+	}
+	streams := map[int]string{}
+	if len(station.PartitionsList) == 0 {
+		streams[0] = stationName.Intern()
+	} else {
+		for _, p := range station.PartitionsList {
+			streams[p] = fmt.Sprintf("%v$%v", stationName.Intern(), p)
+		}
+	}
+	partitions := make([]int, 0, len(streams))
+	for p := range streams {
+		partitions = append(partitions, p)
+	}
+	sort.Ints(partitions)
+
+	now := time.Now()
+	lags := map[string]*models.CgLag{}
+	for _, partition := range partitions {
+		streamName := streams[partition]
+		consumers, err := s.memphisListConsumers(station.TenantName, streamName)
+		if err != nil {
+			if IsNatsErr(err, JSStreamNotFoundErr) {
+				continue
+			}
+			return []models.CgLag{}, err
+		}
+		for _, consumer := range consumers {
+			if consumer.Config == nil || consumer.Config.Durable == _EMPTY_ || strings.HasPrefix(consumer.Config.FilterSubject, MEMPHIS_GLOBAL_ACCOUNT) || !strings.HasSuffix(consumer.Config.FilterSubject, ".final") { // skip consumers that are not user consumers
+				continue
+			}
+			name := revertDelimiters(consumer.Name)
+			if cgName != _EMPTY_ && name != cgName {
+				continue
+			}
+			partitionLag := models.CgPartitionLag{
+				PartitionNumber: partition,
+				PendingMsgs:     consumer.NumPending,
+				AckPendingMsgs:  consumer.NumAckPending,
+				RedeliveredMsgs: consumer.NumRedelivered,
+			}
+			if consumer.NumPending > 0 || consumer.NumAckPending > 0 {
+				msg, err := s.memphisGetNextMessage(station.TenantName, streamName, consumer.Config.FilterSubject, consumer.AckFloor.Stream+1)
+				if err != nil && !IsNatsErr(err, JSNoMessageFoundErr) {
+					s.Warnf("[tenant: %v]getStationCgsLag at memphisGetNextMessage: station %v, consumer group %v: %v", station.TenantName, stationName.Ext(), name, err.Error())
+				} else if msg != nil && now.After(msg.Time) {
+					partitionLag.OldestUnackedAgeMs = now.Sub(msg.Time).Milliseconds()
+				}
+			}
+
+			lag, ok := lags[name]
+			if !ok {
+				lag = &models.CgLag{CgName: name, Partitions: []models.CgPartitionLag{}}
+				lags[name] = lag
+			}
+			lag.PendingMsgs += partitionLag.PendingMsgs
+			lag.AckPendingMsgs += partitionLag.AckPendingMsgs
+			lag.RedeliveredMsgs += partitionLag.RedeliveredMsgs
+			if partitionLag.OldestUnackedAgeMs > lag.OldestUnackedAgeMs {
+				lag.OldestUnackedAgeMs = partitionLag.OldestUnackedAgeMs
+			}
+			lag.Partitions = append(lag.Partitions, partitionLag)
+		}
+	}
+
+	result := make([]models.CgLag, 0, len(lags))
+	for _, lag := range lags {
+		result = append(result, *lag)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CgName < result[j].CgName
+	})
+	return result, nil
+}
+
+func (s *Server) MonitorCgLag() {
+	alerts := map[string]*cgLagAlertState{}
+	ticker := time.NewTicker(cgLagCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if s.JetStreamIsClustered() && !s.JetStreamIsLeader() { // logic happens once only on the leader
+			alerts = map[string]*cgLagAlertState{}
+			continue
+		}
+		s.checkCgLagThresholds(alerts, time.Now())
+	}
+}
+
+func (s *Server) checkCgLagThresholds(alerts map[string]*cgLagAlertState, now time.Time) {
+	thresholds, err := db.GetAllCgLagThresholds()
+	if err != nil {
+		s.Errorf("checkCgLagThresholds at GetAllCgLagThresholds: %v", err.Error())
+		return
+	}
+	thresholdsByStation := map[int][]models.CgLagThreshold{}
+	for _, t := range thresholds {
+		thresholdsByStation[t.StationId] = append(thresholdsByStation[t.StationId], t)
+	}
+
+	seen := map[string]bool{}
+	for stationId, stationThresholds := range thresholdsByStation {
+		tenantName := stationThresholds[0].TenantName
+		if !shouldSendNotification(tenantName, CgLagAlert) {
+			continue
+		}
+		exist, station, err := db.GetStationById(stationId, tenantName)
+		if err != nil {
+			s.Errorf("[tenant: %v]checkCgLagThresholds at GetStationById: %v", tenantName, err.Error())
+			continue
+		}
+		if !exist {
+			continue
+		}
+		lags, err := s.getStationCgsLag(station, _EMPTY_)
+		if err != nil {
+			s.Errorf("[tenant: %v]checkCgLagThresholds at getStationCgsLag: station %v: %v", tenantName, station.Name, err.Error())
+			continue
+		}
+		for _, lag := range lags {
+			threshold, ok := cgLagThresholdFor(lag.CgName, stationThresholds)
+			if !ok {
+				continue
+			}
+			key := fmt.Sprintf("%v:%v:%v", tenantName, stationId, lag.CgName)
+			seen[key] = true
+			state, ok := alerts[key]
+			if !ok {
+				state = &cgLagAlertState{}
+				alerts[key] = state
+			}
+			reason := cgLagBreached(lag, threshold)
+			fire, recovered := state.evaluate(reason != _EMPTY_, now, time.Duration(threshold.SustainedForSec)*time.Second)
+			if fire {
+				msg := fmt.Sprintf("Consumer group %v of station %v is lagging for more than %v: %v", lag.CgName, station.Name, time.Duration(threshold.SustainedForSec)*time.Second, reason)
+				err = s.SendNotification(tenantName, cgLagAlertTitle, msg, CgLagAlert)
+				if err != nil {
+					s.Errorf("[tenant: %v]checkCgLagThresholds at SendNotification: %v", tenantName, err.Error())
+				}
+			}
+			if recovered {
+				msg := fmt.Sprintf("Consumer group %v of station %v is back under its lag threshold", lag.CgName, station.Name)
+				err = s.SendNotification(tenantName, cgLagRecoveredAlertTitle, msg, CgLagAlert)
+				if err != nil {
+					s.Errorf("[tenant: %v]checkCgLagThresholds at SendNotification: %v", tenantName, err.Error())
+				}
+			}
+		}
+	}
+
+	for key := range alerts {
+		if !seen[key] {
+			delete(alerts, key)
+		}
+	}
+}
+
+func (ch ConsumersHandler) GetCgLag(c *gin.Context) {
+	var body models.GetCgLagSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("GetCgLag at getUserDetailsFromMiddleware: At station %v: %v", body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	station, ok := ch.getStationForCgRoute(c, user, body.StationName, "read", "GetCgLag")
+	if !ok {
+		return
+	}
+
+	lags, err := ch.S.getStationCgsLag(station, body.CgName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]GetCgLag at getStationCgsLag: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if body.CgName != _EMPTY_ && len(lags) == 0 {
+		errMsg := fmt.Sprintf("Consumer group %v does not exist in station %v", body.CgName, body.StationName)
+		serv.Warnf("[tenant: %v][user: %v]GetCgLag: %v", user.TenantName, user.Username, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
+	}
+
+	thresholds, err := db.GetCgLagThresholdsByStation(station.ID, station.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]GetCgLag at GetCgLagThresholdsByStation: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	c.IndentedJSON(200, models.GetCgLagResponse{Cgs: lags, Thresholds: thresholds})
+}
+
+func (ch ConsumersHandler) UpdateCgLagThreshold(c *gin.Context) {
+	var body models.UpdateCgLagThresholdSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("UpdateCgLagThreshold at getUserDetailsFromMiddleware: At station %v: %v", body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	err = validateCgLagThreshold(body)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]UpdateCgLagThreshold at validateCgLagThreshold: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+	if body.SustainedForSec == 0 {
+		body.SustainedForSec = cgLagDefaultSustainedSec
+	}
+
+	station, ok := ch.getStationForCgRoute(c, user, body.StationName, "write", "UpdateCgLagThreshold")
+	if !ok {
+		return
+	}
+
+	threshold, err := db.UpsertCgLagThreshold(models.CgLagThreshold{
+		StationId:             station.ID,
+		CgName:                body.CgName,
+		MaxPendingMsgs:        body.MaxPendingMsgs,
+		MaxOldestUnackedAgeMs: body.MaxOldestUnackedAgeMs,
+		SustainedForSec:       body.SustainedForSec,
+		TenantName:            station.TenantName,
+	})
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]UpdateCgLagThreshold at UpsertCgLagThreshold: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	target := "all consumer groups"
+	if body.CgName != _EMPTY_ {
+		target = "consumer group " + body.CgName
+	}
+	createCgAuditLog(user, station.Name, fmt.Sprintf("Lag threshold of %v has been updated by user %v", target, user.Username))
+	c.IndentedJSON(200, threshold)
+}
+
+func (ch ConsumersHandler) RemoveCgLagThreshold(c *gin.Context) {
+	var body models.RemoveCgLagThresholdSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("RemoveCgLagThreshold at getUserDetailsFromMiddleware: At station %v: %v", body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	station, ok := ch.getStationForCgRoute(c, user, body.StationName, "write", "RemoveCgLagThreshold")
+	if !ok {
+		return
+	}
+
+	removed, err := db.DeleteCgLagThreshold(station.ID, body.CgName, station.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RemoveCgLagThreshold at DeleteCgLagThreshold: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !removed {
+		errMsg := "Lag threshold does not exist"
+		serv.Warnf("[tenant: %v][user: %v]RemoveCgLagThreshold: At station %v: %v", user.TenantName, user.Username, body.StationName, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
+	}
+
+	target := "all consumer groups"
+	if body.CgName != _EMPTY_ {
+		target = "consumer group " + body.CgName
+	}
+	createCgAuditLog(user, station.Name, fmt.Sprintf("Lag threshold of %v has been removed by user %v", target, user.Username))
+	c.IndentedJSON(200, gin.H{})
+}
+
+// getStationForCgRoute resolves the station of a consumer group route and makes sure the user may access it for the operation
+func (ch ConsumersHandler) getStationForCgRoute(c *gin.Context, user models.User, name, operation, funcName string) (models.Station, bool) {
+	stationName, err := StationNameFromStr(name)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]%v at StationNameFromStr: At station %v: %v", user.TenantName, user.Username, funcName, name, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return models.Station{}, false
+	}
+	if !validateStationAccess(c, user, stationName.Ext(), operation, funcName) {
+		return models.Station{}, false
+	}
+	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]%v at GetStationByName: At station %v: %v", user.TenantName, user.Username, funcName, name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return models.Station{}, false
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("Station %v does not exist", name)
+		serv.Warnf("[tenant: %v][user: %v]%v: %v", user.TenantName, user.Username, funcName, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return models.Station{}, false
+	}
+	return station, true
+}
+
+func createCgAuditLog(user models.User, stationName, message string) {
+	var auditLogs []interface{}
+	newAuditLog := models.AuditLog{
+		StationName:       stationName,
+		Message:           message,
+		CreatedBy:         user.ID,
+		CreatedByUsername: user.Username,
+		CreatedAt:         time.Now(),
+		TenantName:        user.TenantName,
+	}
+	auditLogs = append(auditLogs, newAuditLog)
+	err := CreateAuditLogs(auditLogs)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]createCgAuditLog: Station %v - create audit logs error: %v", user.TenantName, user.Username, stationName, err.Error())
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/memphisdev/memphis/models"
+)
+
+func TestCgLagAlertStateEvaluate(t *testing.T) {
+	st := &cgLagAlertState{}
+	start := time.Now()
+	sustained := time.Minute
+
+	if fire, recovered := st.evaluate(true, start, sustained); fire || recovered {
+		t.Fatalf("alert fired before the sustained window")
+	}
+	if fire, _ := st.evaluate(true, start.Add(30*time.Second), sustained); fire {
+		t.Fatalf("alert fired before the sustained window")
+	}
+	if fire, _ := st.evaluate(true, start.Add(time.Minute), sustained); !fire {
+		t.Fatalf("expected alert after the sustained window")
+	}
+	if fire, _ := st.evaluate(true, start.Add(2*time.Minute), sustained); fire {
+		t.Fatalf("alert fired twice for the same breach")
+	}
+	if _, recovered := st.evaluate(false, start.Add(3*time.Minute), sustained); !recovered {
+		t.Fatalf("expected recovery once under the threshold")
+	}
+	if _, recovered := st.evaluate(false, start.Add(4*time.Minute), sustained); recovered {
+		t.Fatalf("recovery reported twice")
+	}
+
+	// a short breach that recovers before the window should not alert or recover
+	st.evaluate(true, start.Add(5*time.Minute), sustained)
+	if fire, recovered := st.evaluate(false, start.Add(5*time.Minute+10*time.Second), sustained); fire || recovered {
+		t.Fatalf("unexpected notification for a breach shorter than the window")
+	}
+}
+
+func TestCgLagThresholdFor(t *testing.T) {
+	thresholds := []models.CgLagThreshold{
+		{CgName: _EMPTY_, MaxPendingMsgs: 100},
+		{CgName: "cg1", MaxPendingMsgs: 10},
+	}
+	if th, ok := cgLagThresholdFor("cg1", thresholds); !ok || th.MaxPendingMsgs != 10 {
+		t.Fatalf("expected the consumer group threshold, got %+v", th)
+	}
+	if th, ok := cgLagThresholdFor("cg2", thresholds); !ok || th.MaxPendingMsgs != 100 {
+		t.Fatalf("expected the station wide threshold, got %+v", th)
+	}
+	if _, ok := cgLagThresholdFor("cg2", thresholds[1:]); ok {
+		t.Fatalf("expected no threshold")
+	}
+
+	lag := models.CgLag{PendingMsgs: 5, AckPendingMsgs: 5, OldestUnackedAgeMs: 1000}
+	if cgLagBreached(lag, models.CgLagThreshold{MaxPendingMsgs: 10}) == _EMPTY_ {
+		t.Fatalf("expected pending threshold to be breached")
+	}
+	if cgLagBreached(lag, models.CgLagThreshold{MaxPendingMsgs: 11, MaxOldestUnackedAgeMs: 2000}) != _EMPTY_ {
+		t.Fatalf("expected threshold not to be breached")
+	}
+}
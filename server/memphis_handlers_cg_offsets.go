@@ -0,0 +1,388 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/memphis_cache"
+	"github.com/memphisdev/memphis/models"
+	"github.com/memphisdev/memphis/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	cgOffsetEarliest  = "earliest"
+	cgOffsetLatest    = "latest"
+	cgOffsetSequence  = "sequence"
+	cgOffsetTimestamp = "timestamp"
+)
+
+func validateCgOffsetPosition(req models.ResetCgOffsetsSchema) error {
+	switch req.Position {
+	case cgOffsetEarliest, cgOffsetLatest:
+		return nil
+	case cgOffsetSequence:
+		if req.Sequence < 1 {
+			return errors.New("sequence must be greater than 0")
+		}
+		return nil
+	case cgOffsetTimestamp:
+		if req.Timestamp.IsZero() {
+			return errors.New("timestamp is required")
+		}
+		return nil
+	default:
+		return fmt.Errorf("position must be one of %v, %v, %v or %v", cgOffsetEarliest, cgOffsetLatest, cgOffsetSequence, cgOffsetTimestamp)
+	}
+}
+
+// cgOffsetConsumerConfig keeps the consumer group configuration and only replaces its starting point
+func cgOffsetConsumerConfig(cfg ConsumerConfig, req models.ResetCgOffsetsSchema) ConsumerConfig {
+	cfg.OptStartSeq = 0
+	cfg.OptStartTime = nil
+	switch req.Position {
+	case cgOffsetEarliest:
+		cfg.DeliverPolicy = DeliverAll
+	case cgOffsetLatest:
+		cfg.DeliverPolicy = DeliverNew
+	case cgOffsetSequence:
+		cfg.DeliverPolicy = DeliverByStartSequence
+		cfg.OptStartSeq = req.Sequence
+	case cgOffsetTimestamp:
+		cfg.DeliverPolicy = DeliverByStartTime
+		startTime := req.Timestamp
+		cfg.OptStartTime = &startTime
+	}
+	return cfg
+}
+
+// resetCgOffsets moves a consumer group of every requested partition to a new position by recreating its durable,
+// an ephemeral consumer with the same filter is used to find out where the group would start and how many
+// messages it would get, the returned bool tells whether the error can be shown to the user
+func (s *Server) resetCgOffsets(station models.Station, req models.ResetCgOffsetsSchema, user models.User) (models.ResetCgOffsetsResponse, bool, error) {
+	resp := models.ResetCgOffsetsResponse{DryRun: req.DryRun, Partitions: []models.CgPartitionOffsetReset{}}
+	stationName, err := StationNameFromStr(station.Name)
+	if err != nil {
+		return resp, true, err
+	}
+	err = validateCgOffsetPosition(req)
+	if err != nil {
+		return resp, true, err
+	}
+
+	streams := map[int]string{}
+	if len(station.PartitionsList) == 0 {
+		if len(req.Partitions) > 0 {
+			return resp, true, fmt.Errorf("station %v has no partitions", stationName.Ext())
+		}
+		streams[0] = stationName.Intern()
+	} else {
+		requested := req.Partitions
+		if len(requested) == 0 {
+			requested = station.PartitionsList
+		}
+		for _, p := range requested {
+			if !isPartitionOfStation(p, station.PartitionsList) {
+				return resp, true, fmt.Errorf("partition %v does not exist in station %v", p, stationName.Ext())
+			}
+			streams[p] = fmt.Sprintf("%v$%v", stationName.Intern(), p)
+		}
+	}
+	partitions := make([]int, 0, len(streams))
+	for p := range streams {
+		partitions = append(partitions, p)
+	}
+	sort.Ints(partitions)
+
+	cn := getInternalConsumerName(req.CgName)
+	newConfigs := map[int]ConsumerConfig{}
+	restoreConfigs := map[int]ConsumerConfig{}
+	for _, partition := range partitions {
+		streamName := streams[partition]
+		info, err := s.memphisConsumerInfo(station.TenantName, streamName, cn)
+		if err != nil {
+			if IsNatsErr(err, JSConsumerNotFoundErr) {
+				return resp, true, fmt.Errorf("consumer group %v does not exist in station %v", req.CgName, stationName.Ext())
+			}
+			return resp, false, err
+		}
+		newConfig := cgOffsetConsumerConfig(*info.Config, req)
+		newConfigs[partition] = newConfig
+		restoreConfigs[partition] = cgRestoreConsumerConfig(*info.Config, info.AckFloor.Stream)
+
+		probeConfig := newConfig
+		probeConfig.Durable = _EMPTY_
+		probeConfig.Name = _EMPTY_
+		probeConfig.InactiveThreshold = 30 * time.Second
+		probe, err := s.memphisAddEphemeralConsumer(station.TenantName, streamName, &probeConfig)
+		if err != nil {
+			return resp, false, err
+		}
+		err = s.memphisRemoveConsumer(station.TenantName, streamName, probe.Name)
+		if err != nil {
+			s.Warnf("[tenant: %v]resetCgOffsets at memphisRemoveConsumer: station %v: %v", station.TenantName, stationName.Ext(), err.Error())
+		}
+
+		result := models.CgPartitionOffsetReset{
+			PartitionNumber:     partition,
+			CurrentAckFloorSeq:  info.AckFloor.Stream,
+			CurrentDeliveredSeq: info.Delivered.Stream,
+			NewStartSeq:         probe.Delivered.Stream + 1,
+			PendingMessages:     probe.NumPending,
+		}
+		result.RedeliveredMessages, result.SkippedMessages = cgOffsetMessagesDelta(uint64(info.NumAckPending), info.NumPending, probe.NumPending)
+		resp.Partitions = append(resp.Partitions, result)
+	}
+	if req.DryRun {
+		return resp, false, nil
+	}
+
+	// every new configuration was already accepted by the server through its probe, a partition whose durable
+	// can not be recreated gets its original consumer back so the group never loses it
+	resetPartitions := []int{}
+	for _, partition := range partitions {
+		streamName := streams[partition]
+		newConfig := newConfigs[partition]
+		err = s.memphisRemoveConsumer(station.TenantName, streamName, cn)
+		if err != nil && !IsNatsErr(err, JSConsumerNotFoundErr) {
+			return resp, true, cgOffsetResetError(partition, resetPartitions, err)
+		}
+		err = s.memphisAddConsumer(station.TenantName, streamName, &newConfig)
+		if err != nil {
+			restoreConfig := restoreConfigs[partition]
+			restoreErr := s.memphisAddConsumer(station.TenantName, streamName, &restoreConfig)
+			if restoreErr != nil {
+				s.Errorf("[tenant: %v][user: %v]resetCgOffsets at memphisAddConsumer: failed restoring consumer group %v of station %v partition %v: %v", user.TenantName, user.Username, req.CgName, stationName.Ext(), partition, restoreErr.Error())
+			}
+			return resp, true, cgOffsetResetError(partition, resetPartitions, err)
+		}
+		resetPartitions = append(resetPartitions, partition)
+	}
+
+	target := req.Position
+	switch req.Position {
+	case cgOffsetSequence:
+		target = fmt.Sprintf("sequence %v", req.Sequence)
+	case cgOffsetTimestamp:
+		target = fmt.Sprintf("timestamp %v", req.Timestamp.Format(time.RFC3339))
+	}
+	message := fmt.Sprintf("Consumer group %v of station %v has been reset to %v by user %v", req.CgName, stationName.Ext(), target, user.Username)
+	s.Noticef("[tenant: %v][user: %v]: %v", user.TenantName, user.Username, message)
+	var auditLogs []interface{}
+	newAuditLog := models.AuditLog{
+		StationName:       stationName.Ext(),
+		Message:           message,
+		CreatedBy:         user.ID,
+		CreatedByUsername: user.Username,
+		CreatedAt:         time.Now(),
+		TenantName:        user.TenantName,
+	}
+	auditLogs = append(auditLogs, newAuditLog)
+	err = CreateAuditLogs(auditLogs)
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]resetCgOffsets: Station %v - create audit logs error: %v", user.TenantName, user.Username, stationName.Ext(), err.Error())
+	}
+	return resp, false, nil
+}
+
+// cgOffsetMessagesDelta compares the messages the group still has to process, the ones waiting for an ack and the
+// ones not delivered yet, with the messages it would get after the reset
+func cgOffsetMessagesDelta(ackPending, pending, newPending uint64) (uint64, uint64) {
+	outstanding := ackPending + pending
+	if newPending > outstanding {
+		return newPending - outstanding, 0
+	}
+	return 0, outstanding - newPending
+}
+
+// cgRestoreConsumerConfig returns the original consumer group configuration starting after its ack floor,
+// recreating the durable with it keeps the group where it was before the reset
+func cgRestoreConsumerConfig(cfg ConsumerConfig, ackFloor uint64) ConsumerConfig {
+	if ackFloor > 0 {
+		cfg.DeliverPolicy = DeliverByStartSequence
+		cfg.OptStartSeq = ackFloor + 1
+		cfg.OptStartTime = nil
+	}
+	return cfg
+}
+
+func cgOffsetResetError(partition int, resetPartitions []int, err error) error {
+	if len(resetPartitions) == 0 {
+		return fmt.Errorf("failed resetting partition %v, no partition has been changed: %v", partition, err)
+	}
+	return fmt.Errorf("failed resetting partition %v, partitions %v have already been reset: %v", partition, resetPartitions, err)
+}
+
+// only users rbac does not restrict can move consumer groups, the operation rewinds or skips messages for every consumer of the group
+func canResetCgOffsets(user models.User) bool {
+	return user.UserType == "root" || len(user.Roles) == 0
+}
+
+func isPartitionOfStation(partition int, partitionsList []int) bool {
+	for _, p := range partitionsList {
+		if p == partition {
+			return true
+		}
+	}
+	return false
+}
+
+func (ch ConsumersHandler) ResetCgOffsets(c *gin.Context) {
+	var body models.ResetCgOffsetsSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("ResetCgOffsets at getUserDetailsFromMiddleware: At station %v: %v", body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	stationName, err := StationNameFromStr(body.StationName)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]ResetCgOffsets at StationNameFromStr: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+
+	if !validateStationAccess(c, user, stationName.Ext(), "write", "ResetCgOffsets") {
+		return
+	}
+	if !canResetCgOffsets(user) {
+		serv.Warnf("[tenant: %v][user: %v]ResetCgOffsets: only admin users can reset consumer group offsets", user.TenantName, user.Username)
+		c.AbortWithStatusJSON(403, gin.H{"message": "Only admin users can reset consumer group offsets"})
+		return
+	}
+
+	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]ResetCgOffsets at GetStationByName: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("Station %v does not exist", body.StationName)
+		serv.Warnf("[tenant: %v][user: %v]ResetCgOffsets: %v", user.TenantName, user.Username, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
+	}
+
+	resp, showable, err := ch.S.resetCgOffsets(station, body, user)
+	if err != nil {
+		if showable {
+			serv.Warnf("[tenant: %v][user: %v]ResetCgOffsets at resetCgOffsets: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+			return
+		}
+		serv.Errorf("[tenant: %v][user: %v]ResetCgOffsets at resetCgOffsets: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	c.IndentedJSON(200, resp)
+}
+
+func (s *Server) resetCgOffsetsDirect(c *client, reply string, msg []byte) {
+	var rcr resetCgOffsetsRequest
+	var resp resetCgOffsetsResponse
+	tenantName, message, err := s.getTenantNameAndMessage(msg)
+	if err != nil {
+		s.Errorf("resetCgOffsetsDirect at getTenantNameAndMessage: %v", err.Error())
+		respondWithRespErr(s.MemphisGlobalAccountString(), s, reply, err, &resp)
+		return
+	}
+	if err := json.Unmarshal([]byte(message), &rcr); err != nil {
+		s.Errorf("[tenant: %v]resetCgOffsetsDirect at json.Unmarshal: %v", tenantName, err.Error())
+		respondWithRespErr(s.MemphisGlobalAccountString(), s, reply, err, &resp)
+		return
+	}
+	rcr.TenantName = tenantName
+
+	exist, user, err := memphis_cache.GetUser(rcr.Username, rcr.TenantName, false)
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]resetCgOffsetsDirect at memphis_cache.GetUser: Station %v: %v", rcr.TenantName, rcr.Username, rcr.StationName, err.Error())
+		respondWithRespErr(s.MemphisGlobalAccountString(), s, reply, err, &resp)
+		return
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("user %v does not exist", rcr.Username)
+		s.Warnf("[tenant: %v][user: %v]resetCgOffsetsDirect: %v", rcr.TenantName, rcr.Username, errMsg)
+		respondWithRespErr(s.MemphisGlobalAccountString(), s, reply, errors.New(errMsg), &resp)
+		return
+	}
+
+	stationName, err := StationNameFromStr(rcr.StationName)
+	if err != nil {
+		s.Warnf("[tenant: %v][user: %v]resetCgOffsetsDirect at StationNameFromStr: Station %v: %v", rcr.TenantName, rcr.Username, rcr.StationName, err.Error())
+		respondWithRespErr(s.MemphisGlobalAccountString(), s, reply, err, &resp)
+		return
+	}
+
+	allowed, _, err := ValidateStationPermissions(user.Roles, stationName.Ext(), user.TenantName, "write")
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]resetCgOffsetsDirect at ValidateStationPermissions: Station %v: %v", rcr.TenantName, rcr.Username, rcr.StationName, err.Error())
+		respondWithRespErr(s.MemphisGlobalAccountString(), s, reply, err, &resp)
+		return
+	}
+	if !allowed {
+		errMsg := fmt.Sprintf("user %v is not allowed to write to station %v", rcr.Username, stationName.Ext())
+		s.Warnf("[tenant: %v][user: %v]resetCgOffsetsDirect: %v", rcr.TenantName, rcr.Username, errMsg)
+		respondWithRespErr(s.MemphisGlobalAccountString(), s, reply, errors.New(errMsg), &resp)
+		return
+	}
+	if !canResetCgOffsets(user) {
+		errMsg := fmt.Sprintf("user %v is not allowed to reset consumer group offsets", rcr.Username)
+		s.Warnf("[tenant: %v][user: %v]resetCgOffsetsDirect: %v", rcr.TenantName, rcr.Username, errMsg)
+		respondWithRespErr(s.MemphisGlobalAccountString(), s, reply, errors.New(errMsg), &resp)
+		return
+	}
+
+	exist, station, err := db.GetStationByName(stationName.Ext(), rcr.TenantName)
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]resetCgOffsetsDirect at GetStationByName: Station %v: %v", rcr.TenantName, rcr.Username, rcr.StationName, err.Error())
+		respondWithRespErr(s.MemphisGlobalAccountString(), s, reply, err, &resp)
+		return
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("station %v does not exist", stationName.Ext())
+		s.Warnf("[tenant: %v][user: %v]resetCgOffsetsDirect: %v", rcr.TenantName, rcr.Username, errMsg)
+		respondWithRespErr(s.MemphisGlobalAccountString(), s, reply, errors.New(errMsg), &resp)
+		return
+	}
+
+	req := models.ResetCgOffsetsSchema{
+		StationName: rcr.StationName,
+		CgName:      rcr.CgName,
+		Position:    rcr.Position,
+		Sequence:    rcr.Sequence,
+		Timestamp:   rcr.Timestamp,
+		Partitions:  rcr.Partitions,
+		DryRun:      rcr.DryRun,
+	}
+	resp.ResetCgOffsetsResponse, _, err = s.resetCgOffsets(station, req, user)
+	if err != nil {
+		s.Warnf("[tenant: %v][user: %v]resetCgOffsetsDirect at resetCgOffsets: Station %v: %v", rcr.TenantName, rcr.Username, rcr.StationName, err.Error())
+		respondWithRespErr(s.MemphisGlobalAccountString(), s, reply, err, &resp)
+		return
+	}
+	respondWithResp(s.MemphisGlobalAccountString(), s, reply, &resp)
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/memphisdev/memphis/models"
+)
+
+func TestCgOffsetConsumerConfig(t *testing.T) {
+	cfg := ConsumerConfig{
+		Durable:       "cg",
+		DeliverPolicy: DeliverByStartSequence,
+		OptStartSeq:   10,
+		AckWait:       30 * time.Second,
+		FilterSubject: "station$1.final",
+	}
+	ts := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		req    models.ResetCgOffsetsSchema
+		policy DeliverPolicy
+		seq    uint64
+	}{
+		{req: models.ResetCgOffsetsSchema{Position: cgOffsetEarliest}, policy: DeliverAll},
+		{req: models.ResetCgOffsetsSchema{Position: cgOffsetLatest}, policy: DeliverNew},
+		{req: models.ResetCgOffsetsSchema{Position: cgOffsetSequence, Sequence: 42}, policy: DeliverByStartSequence, seq: 42},
+		{req: models.ResetCgOffsetsSchema{Position: cgOffsetTimestamp, Timestamp: ts}, policy: DeliverByStartTime},
+	}
+	for _, tc := range cases {
+		if err := validateCgOffsetPosition(tc.req); err != nil {
+			t.Fatalf("position %v: unexpected error: %v", tc.req.Position, err)
+		}
+		got := cgOffsetConsumerConfig(cfg, tc.req)
+		if got.DeliverPolicy != tc.policy || got.OptStartSeq != tc.seq {
+			t.Fatalf("position %v: unexpected config %+v", tc.req.Position, got)
+		}
+		if got.Durable != cfg.Durable || got.AckWait != cfg.AckWait || got.FilterSubject != cfg.FilterSubject {
+			t.Fatalf("position %v: consumer group settings were not kept", tc.req.Position)
+		}
+		if tc.req.Position == cgOffsetTimestamp && (got.OptStartTime == nil || !got.OptStartTime.Equal(ts)) {
+			t.Fatalf("unexpected start time %v", got.OptStartTime)
+		}
+	}
+
+	for _, req := range []models.ResetCgOffsetsSchema{{Position: "middle"}, {Position: cgOffsetSequence}, {Position: cgOffsetTimestamp}} {
+		if err := validateCgOffsetPosition(req); err == nil {
+			t.Fatalf("expected an error for %+v", req)
+		}
+	}
+}
+
+func TestCanResetCgOffsets(t *testing.T) {
+	if !canResetCgOffsets(models.User{UserType: "root"}) || !canResetCgOffsets(models.User{UserType: "management"}) || !canResetCgOffsets(models.User{UserType: "application"}) {
+		t.Fatalf("expected users rbac does not restrict to reset offsets")
+	}
+	if canResetCgOffsets(models.User{UserType: "management", Roles: []int{1}}) || canResetCgOffsets(models.User{UserType: "application", Roles: []int{1}}) {
+		t.Fatalf("expected users restricted by roles not to reset offsets")
+	}
+}
+
+func TestCgRestoreConsumerConfig(t *testing.T) {
+	start := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	cfg := ConsumerConfig{Durable: "cg", DeliverPolicy: DeliverByStartTime, OptStartTime: &start, FilterSubject: "station$1.final"}
+	restored := cgRestoreConsumerConfig(cfg, 41)
+	if restored.DeliverPolicy != DeliverByStartSequence || restored.OptStartSeq != 42 || restored.OptStartTime != nil {
+		t.Fatalf("expected the group to continue after its ack floor, got %+v", restored)
+	}
+	if restored.Durable != cfg.Durable || restored.FilterSubject != cfg.FilterSubject {
+		t.Fatalf("consumer group settings were not kept")
+	}
+	if restored = cgRestoreConsumerConfig(cfg, 0); restored.DeliverPolicy != DeliverByStartTime || restored.OptStartTime != &start {
+		t.Fatalf("expected a group without acks to keep its original start, got %+v", restored)
+	}
+
+	err := cgOffsetResetError(3, []int{1, 2}, errors.New("boom"))
+	if !strings.Contains(err.Error(), "partitions [1 2] have already been reset") {
+		t.Fatalf("expected the error to list the reset partitions, got %v", err)
+	}
+}
+
+func TestCgOffsetMessagesDelta(t *testing.T) {
+	cases := []struct {
+		ackPending, pending, newPending uint64
+		redelivered, skipped            uint64
+	}{
+		{ackPending: 0, pending: 10, newPending: 25, redelivered: 15},
+		{ackPending: 5, pending: 10, newPending: 25, redelivered: 10},
+		{ackPending: 5, pending: 10, newPending: 0, skipped: 15},
+		{ackPending: 5, pending: 10, newPending: 15},
+	}
+	for _, tc := range cases {
+		redelivered, skipped := cgOffsetMessagesDelta(tc.ackPending, tc.pending, tc.newPending)
+		if redelivered != tc.redelivered || skipped != tc.skipped {
+			t.Fatalf("%+v: expected %v redelivered and %v skipped, got %v and %v", tc, tc.redelivered, tc.skipped, redelivered, skipped)
+		}
+	}
+}
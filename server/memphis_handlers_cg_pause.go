@@ -0,0 +1,486 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/memphis_cache"
+	"github.com/memphisdev/memphis/models"
+	"github.com/memphisdev/memphis/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const pausedCgsRefreshInterval = 10 * time.Second
+
+// pausedCgs is keyed by tenant, internal station name and internal consumer name, every partition of a
+// group shares the key. The delivery loop only reads the memphisPaused flag of the consumer, the timers
+// clear that flag as soon as paused_until passes instead of waiting for the next refresh.
+var pausedCgs = struct {
+	sync.Mutex
+	m      map[string]models.PausedCg
+	timers map[string]*time.Timer
+}{m: map[string]models.PausedCg{}, timers: map[string]*time.Timer{}}
+
+type cgPauseUpdate struct {
+	models.PausedCg
+	IsPaused bool `json:"is_paused"`
+}
+
+func pausedCgKey(tenantName, stationIntern, cn string) string {
+	return brokerEnforcedStationKey(tenantName, stationIntern) + ":" + cn
+}
+
+func pausedCgKeyFromCg(cg models.PausedCg) (string, error) {
+	stationName, err := StationNameFromStr(cg.StationName)
+	if err != nil {
+		return _EMPTY_, err
+	}
+	return pausedCgKey(cg.TenantName, stationName.Intern(), getInternalConsumerName(cg.CgName)), nil
+}
+
+func isCgPauseActive(cg models.PausedCg, now time.Time) bool {
+	return cg.PausedUntil == nil || now.Before(*cg.PausedUntil)
+}
+
+// isPausedByMemphis is called from the delivery loop while the consumer lock is held
+func (o *consumer) isPausedByMemphis() bool {
+	return o.memphisPaused.Load()
+}
+
+// isConsumerInPausedCg sets the initial pause state of a consumer that is created while its group is paused
+func isConsumerInPausedCg(tenantName, streamName, cn string) bool {
+	stationIntern, _, _ := strings.Cut(streamName, "$")
+	pausedCgs.Lock()
+	cg, ok := pausedCgs.m[pausedCgKey(tenantName, stationIntern, cn)]
+	pausedCgs.Unlock()
+	return ok && isCgPauseActive(cg, time.Now())
+}
+
+// setCgPaused updates the local consumers of every partition of the group, resumed consumers are signaled
+// so waiting pull requests are served right away
+func (s *Server) setCgPaused(cg models.PausedCg, paused bool) {
+	acc, err := s.lookupAccount(cg.TenantName)
+	if err != nil {
+		return
+	}
+	stationName, err := StationNameFromStr(cg.StationName)
+	if err != nil {
+		return
+	}
+	streams := []string{stationName.Intern()}
+	for _, p := range cg.PartitionsList {
+		streams = append(streams, fmt.Sprintf("%v$%v", stationName.Intern(), p))
+	}
+	cn := getInternalConsumerName(cg.CgName)
+	for _, streamName := range streams {
+		mset, err := acc.lookupStream(streamName)
+		if err != nil {
+			continue
+		}
+		if o := mset.lookupConsumer(cn); o != nil {
+			o.memphisPaused.Store(paused)
+			if !paused {
+				o.signalNewMessages()
+			}
+		}
+	}
+}
+
+// pauseCgConsumers flags the consumers of the group and undoes it if the pause was removed meanwhile,
+// otherwise a consumer flagged after the group was resumed would stay paused
+func (s *Server) pauseCgConsumers(key string, cg models.PausedCg) {
+	s.setCgPaused(cg, true)
+	pausedCgs.Lock()
+	_, ok := pausedCgs.m[key]
+	pausedCgs.Unlock()
+	if !ok {
+		s.setCgPaused(cg, false)
+	}
+}
+
+func samePauseDeadline(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// storePausedCgLocked stores the pause of a group and arms the timer of paused_until, a timer that
+// already fires at the same deadline is kept. Lock should be held.
+func (s *Server) storePausedCgLocked(key string, cg models.PausedCg) {
+	previous, ok := pausedCgs.m[key]
+	pausedCgs.m[key] = cg
+	if ok && samePauseDeadline(previous.PausedUntil, cg.PausedUntil) && (cg.PausedUntil == nil || pausedCgs.timers[key] != nil) {
+		return
+	}
+	removePausedCgTimerLocked(key)
+	if cg.PausedUntil != nil {
+		pausedCgs.timers[key] = time.AfterFunc(time.Until(*cg.PausedUntil), func() {
+			s.expirePausedCg(key, cg)
+		})
+	}
+}
+
+// Lock should be held.
+func removePausedCgTimerLocked(key string) {
+	if t, ok := pausedCgs.timers[key]; ok {
+		t.Stop()
+		delete(pausedCgs.timers, key)
+	}
+}
+
+// expirePausedCg resumes the group locally once its pause expires, the DB record is cleared by the
+// leader in refreshPausedCgs
+func (s *Server) expirePausedCg(key string, cg models.PausedCg) {
+	pausedCgs.Lock()
+	current, ok := pausedCgs.m[key]
+	if !ok || !samePauseDeadline(current.PausedUntil, cg.PausedUntil) {
+		pausedCgs.Unlock()
+		return
+	}
+	delete(pausedCgs.m, key)
+	delete(pausedCgs.timers, key)
+	pausedCgs.Unlock()
+	s.setCgPaused(cg, false)
+}
+
+func (s *Server) applyCgPauseUpdate(update cgPauseUpdate) {
+	key, err := pausedCgKeyFromCg(update.PausedCg)
+	if err != nil {
+		return
+	}
+	paused := update.IsPaused && isCgPauseActive(update.PausedCg, time.Now())
+	pausedCgs.Lock()
+	if paused {
+		s.storePausedCgLocked(key, update.PausedCg)
+	} else {
+		delete(pausedCgs.m, key)
+		removePausedCgTimerLocked(key)
+	}
+	pausedCgs.Unlock()
+	if paused {
+		s.pauseCgConsumers(key, update.PausedCg)
+	} else {
+		s.setCgPaused(update.PausedCg, false)
+	}
+}
+
+func (s *Server) refreshPausedCgs() error {
+	if !s.JetStreamIsClustered() || s.JetStreamIsLeader() {
+		resumed, err := db.ResumeExpiredCgs()
+		if err != nil {
+			s.Errorf("refreshPausedCgs at ResumeExpiredCgs: %v", err.Error())
+		}
+		for _, cg := range resumed {
+			s.Noticef("[tenant: %v]Consumer group %v of station %v has been resumed, its pause has expired", cg.TenantName, cg.CgName, cg.StationName)
+		}
+	}
+
+	cgs, err := db.GetPausedCgs()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	paused := make(map[string]models.PausedCg, len(cgs))
+	for _, cg := range cgs {
+		if !isCgPauseActive(cg, now) {
+			continue
+		}
+		key, err := pausedCgKeyFromCg(cg)
+		if err != nil {
+			continue
+		}
+		paused[key] = cg
+	}
+	var resumed []models.PausedCg
+	pausedCgs.Lock()
+	for key, cg := range pausedCgs.m {
+		if _, ok := paused[key]; !ok {
+			delete(pausedCgs.m, key)
+			removePausedCgTimerLocked(key)
+			resumed = append(resumed, cg)
+		}
+	}
+	for key, cg := range paused {
+		s.storePausedCgLocked(key, cg)
+	}
+	pausedCgs.Unlock()
+
+	for _, cg := range resumed {
+		s.setCgPaused(cg, false)
+	}
+	// consumers created since the last refresh pick up the pause here as well
+	for key, cg := range paused {
+		s.pauseCgConsumers(key, cg)
+	}
+	return nil
+}
+
+func (s *Server) RefreshPausedCgs() {
+	ticker := time.NewTicker(pausedCgsRefreshInterval)
+	for ; true; <-ticker.C {
+		err := s.refreshPausedCgs()
+		if err != nil {
+			s.Errorf("RefreshPausedCgs at refreshPausedCgs: %v", err.Error())
+		}
+	}
+}
+
+func (s *Server) ListenForCgPauseUpdates() error {
+	_, err := s.subscribeOnAcc(s.MemphisGlobalAccount(), CG_PAUSE_UPDATES_SUBJ, CG_PAUSE_UPDATES_SUBJ+"_sid", func(_ *client, subject, reply string, msg []byte) {
+		go func(msg []byte) {
+			var update cgPauseUpdate
+			err := json.Unmarshal(msg, &update)
+			if err != nil {
+				s.Errorf("ListenForCgPauseUpdates at json.Unmarshal: %v", err.Error())
+				return
+			}
+			s.applyCgPauseUpdate(update)
+		}(copyBytes(msg))
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// setCgPauseState persists the state for every consumer of the group and lets all the brokers know about it,
+// the returned bool tells whether the error can be shown to the user
+func (s *Server) setCgPauseState(station models.Station, cgName string, isPaused bool, pausedUntil *time.Time, user models.User) (bool, error) {
+	stationName, err := StationNameFromStr(station.Name)
+	if err != nil {
+		return true, err
+	}
+	if isPaused && pausedUntil != nil && !pausedUntil.After(time.Now()) {
+		return true, errors.New("paused_until has to be in the future")
+	}
+	if !isPaused {
+		pausedUntil = nil
+	}
+
+	exist, err := db.UpdateCgPauseState(station.ID, cgName, isPaused, pausedUntil)
+	if err != nil {
+		return false, err
+	}
+	if !exist {
+		return true, fmt.Errorf("consumer group %v does not exist in station %v", cgName, stationName.Ext())
+	}
+
+	update := cgPauseUpdate{
+		PausedCg: models.PausedCg{
+			CgName:         cgName,
+			StationName:    stationName.Ext(),
+			TenantName:     station.TenantName,
+			PausedUntil:    pausedUntil,
+			PartitionsList: station.PartitionsList,
+		},
+		IsPaused: isPaused,
+	}
+	s.applyCgPauseUpdate(update)
+	err = s.sendInternalAccountMsgWithReply(s.MemphisGlobalAccount(), CG_PAUSE_UPDATES_SUBJ, _EMPTY_, nil, update, true)
+	if err != nil {
+		s.Warnf("[tenant: %v][user: %v]setCgPauseState at sendInternalAccountMsgWithReply: %v", user.TenantName, user.Username, err.Error())
+	}
+
+	var message string
+	if isPaused {
+		message = fmt.Sprintf("Consumer group %v of station %v has been paused by user %v", cgName, stationName.Ext(), user.Username)
+		if pausedUntil != nil {
+			message += " until " + pausedUntil.Format(time.RFC3339)
+		}
+	} else {
+		message = fmt.Sprintf("Consumer group %v of station %v has been resumed by user %v", cgName, stationName.Ext(), user.Username)
+	}
+	s.Noticef("[tenant: %v][user: %v]: %v", user.TenantName, user.Username, message)
+	createCgAuditLog(user, stationName.Ext(), message)
+	return false, nil
+}
+
+func (ch ConsumersHandler) PauseCg(c *gin.Context) {
+	var body models.PauseCgSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("PauseCg at getUserDetailsFromMiddleware: At station %v: %v", body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	station, ok := ch.getStationForCgRoute(c, user, body.StationName, "write", "PauseCg")
+	if !ok {
+		return
+	}
+
+	showable, err := ch.S.setCgPauseState(station, body.CgName, true, body.PausedUntil, user)
+	if err != nil {
+		if showable {
+			serv.Warnf("[tenant: %v][user: %v]PauseCg at setCgPauseState: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+			return
+		}
+		serv.Errorf("[tenant: %v][user: %v]PauseCg at setCgPauseState: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	c.IndentedJSON(200, gin.H{})
+}
+
+func (ch ConsumersHandler) ResumeCg(c *gin.Context) {
+	var body models.ResumeCgSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("ResumeCg at getUserDetailsFromMiddleware: At station %v: %v", body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	station, ok := ch.getStationForCgRoute(c, user, body.StationName, "write", "ResumeCg")
+	if !ok {
+		return
+	}
+
+	showable, err := ch.S.setCgPauseState(station, body.CgName, false, nil, user)
+	if err != nil {
+		if showable {
+			serv.Warnf("[tenant: %v][user: %v]ResumeCg at setCgPauseState: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+			return
+		}
+		serv.Errorf("[tenant: %v][user: %v]ResumeCg at setCgPauseState: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	c.IndentedJSON(200, gin.H{})
+}
+
+// getStationForSdkCgRequest resolves the user and station of a consumer group control request sent by an SDK
+func (s *Server) getStationForSdkCgRequest(tenantName, username, name, funcName string) (models.Station, models.User, error) {
+	exist, user, err := memphis_cache.GetUser(username, tenantName, false)
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]%v at memphis_cache.GetUser: Station %v: %v", tenantName, username, funcName, name, err.Error())
+		return models.Station{}, models.User{}, err
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("user %v does not exist", username)
+		s.Warnf("[tenant: %v][user: %v]%v: %v", tenantName, username, funcName, errMsg)
+		return models.Station{}, models.User{}, errors.New(errMsg)
+	}
+
+	stationName, err := StationNameFromStr(name)
+	if err != nil {
+		s.Warnf("[tenant: %v][user: %v]%v at StationNameFromStr: Station %v: %v", tenantName, username, funcName, name, err.Error())
+		return models.Station{}, models.User{}, err
+	}
+
+	// pausing and resuming changes the delivery of every consumer of the group
+	allowed, _, err := ValidateStationPermissions(user.Roles, stationName.Ext(), user.TenantName, "write")
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]%v at ValidateStationPermissions: Station %v: %v", tenantName, username, funcName, name, err.Error())
+		return models.Station{}, models.User{}, err
+	}
+	if !allowed {
+		errMsg := fmt.Sprintf("user %v is not allowed to write to station %v", username, stationName.Ext())
+		s.Warnf("[tenant: %v][user: %v]%v: %v", tenantName, username, funcName, errMsg)
+		return models.Station{}, models.User{}, errors.New(errMsg)
+	}
+
+	exist, station, err := db.GetStationByName(stationName.Ext(), tenantName)
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]%v at GetStationByName: Station %v: %v", tenantName, username, funcName, name, err.Error())
+		return models.Station{}, models.User{}, err
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("station %v does not exist", stationName.Ext())
+		s.Warnf("[tenant: %v][user: %v]%v: %v", tenantName, username, funcName, errMsg)
+		return models.Station{}, models.User{}, errors.New(errMsg)
+	}
+	return station, user, nil
+}
+
+func (s *Server) pauseCgDirect(c *client, reply string, msg []byte) {
+	var pcr pauseCgRequest
+	tenantName, message, err := s.getTenantNameAndMessage(msg)
+	if err != nil {
+		s.Errorf("pauseCgDirect at getTenantNameAndMessage: %v", err.Error())
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, err)
+		return
+	}
+	if err := json.Unmarshal([]byte(message), &pcr); err != nil {
+		s.Errorf("[tenant: %v]pauseCgDirect at json.Unmarshal: %v", tenantName, err.Error())
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, err)
+		return
+	}
+	pcr.TenantName = tenantName
+
+	station, user, err := s.getStationForSdkCgRequest(pcr.TenantName, pcr.Username, pcr.StationName, "pauseCgDirect")
+	if err != nil {
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, err)
+		return
+	}
+
+	_, err = s.setCgPauseState(station, pcr.CgName, true, pcr.PausedUntil, user)
+	if err != nil {
+		s.Warnf("[tenant: %v][user: %v]pauseCgDirect at setCgPauseState: Station %v: %v", pcr.TenantName, pcr.Username, pcr.StationName, err.Error())
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, err)
+		return
+	}
+	respondWithErr(s.MemphisGlobalAccountString(), s, reply, nil)
+}
+
+func (s *Server) resumeCgDirect(c *client, reply string, msg []byte) {
+	var rcr resumeCgRequest
+	tenantName, message, err := s.getTenantNameAndMessage(msg)
+	if err != nil {
+		s.Errorf("resumeCgDirect at getTenantNameAndMessage: %v", err.Error())
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, err)
+		return
+	}
+	if err := json.Unmarshal([]byte(message), &rcr); err != nil {
+		s.Errorf("[tenant: %v]resumeCgDirect at json.Unmarshal: %v", tenantName, err.Error())
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, err)
+		return
+	}
+	rcr.TenantName = tenantName
+
+	station, user, err := s.getStationForSdkCgRequest(rcr.TenantName, rcr.Username, rcr.StationName, "resumeCgDirect")
+	if err != nil {
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, err)
+		return
+	}
+
+	_, err = s.setCgPauseState(station, rcr.CgName, false, nil, user)
+	if err != nil {
+		s.Warnf("[tenant: %v][user: %v]resumeCgDirect at setCgPauseState: Station %v: %v", rcr.TenantName, rcr.Username, rcr.StationName, err.Error())
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, err)
+		return
+	}
+	respondWithErr(s.MemphisGlobalAccountString(), s, reply, nil)
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/memphisdev/memphis/models"
+)
+
+func resetPausedCgs() {
+	pausedCgs.Lock()
+	for key := range pausedCgs.timers {
+		removePausedCgTimerLocked(key)
+	}
+	pausedCgs.m = map[string]models.PausedCg{}
+	pausedCgs.Unlock()
+}
+
+func TestConsumerIsPausedByMemphis(t *testing.T) {
+	defer resetPausedCgs()
+
+	o := &consumer{acc: &Account{Name: "tenant"}, stream: "orders$2", name: "cg"}
+	if o.isPausedByMemphis() {
+		t.Fatalf("consumer should not be paused by default")
+	}
+	o.memphisPaused.Store(true)
+	if !o.isPausedByMemphis() {
+		t.Fatalf("expected the consumer flag to pause deliveries")
+	}
+
+	s := &Server{}
+	s.applyCgPauseUpdate(cgPauseUpdate{PausedCg: models.PausedCg{CgName: "cg", StationName: "orders", TenantName: "tenant"}, IsPaused: true})
+	if !isConsumerInPausedCg("tenant", "orders$2", "cg") {
+		t.Fatalf("expected every partition of the group to be paused")
+	}
+	if isConsumerInPausedCg("other", "orders", "cg") {
+		t.Fatalf("pause leaked to another tenant")
+	}
+
+	s.applyCgPauseUpdate(cgPauseUpdate{PausedCg: models.PausedCg{CgName: "cg", StationName: "orders", TenantName: "tenant"}, IsPaused: false})
+	if isConsumerInPausedCg("tenant", "orders", "cg") {
+		t.Fatalf("expected the group to be resumed")
+	}
+
+	past := time.Now().Add(-time.Minute)
+	s.applyCgPauseUpdate(cgPauseUpdate{PausedCg: models.PausedCg{CgName: "cg", StationName: "orders", TenantName: "tenant", PausedUntil: &past}, IsPaused: true})
+	if isConsumerInPausedCg("tenant", "orders", "cg") {
+		t.Fatalf("expired pause should not hold deliveries")
+	}
+}
+
+func TestPausedCgExpiresOnTimer(t *testing.T) {
+	defer resetPausedCgs()
+
+	s := &Server{}
+	until := time.Now().Add(50 * time.Millisecond)
+	s.applyCgPauseUpdate(cgPauseUpdate{PausedCg: models.PausedCg{CgName: "cg", StationName: "orders", TenantName: "tenant", PausedUntil: &until}, IsPaused: true})
+	if !isConsumerInPausedCg("tenant", "orders", "cg") {
+		t.Fatalf("expected the group to be paused until its deadline")
+	}
+
+	key := pausedCgKey("tenant", "orders", getInternalConsumerName("cg"))
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		pausedCgs.Lock()
+		_, paused := pausedCgs.m[key]
+		_, armed := pausedCgs.timers[key]
+		pausedCgs.Unlock()
+		if !paused && !armed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pause was not removed once paused_until passed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// extending the pause replaces the timer instead of letting the old deadline resume the group
+	first := time.Now().Add(30 * time.Millisecond)
+	later := time.Now().Add(time.Hour)
+	s.applyCgPauseUpdate(cgPauseUpdate{PausedCg: models.PausedCg{CgName: "cg", StationName: "orders", TenantName: "tenant", PausedUntil: &first}, IsPaused: true})
+	s.applyCgPauseUpdate(cgPauseUpdate{PausedCg: models.PausedCg{CgName: "cg", StationName: "orders", TenantName: "tenant", PausedUntil: &later}, IsPaused: true})
+	time.Sleep(100 * time.Millisecond)
+	if !isConsumerInPausedCg("tenant", "orders", "cg") {
+		t.Fatalf("extended pause was resumed by the previous deadline")
+	}
+}
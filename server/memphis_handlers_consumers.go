@@ -448,6 +448,8 @@ func (ch ConsumersHandler) GetCgsByStation(stationName StationName, station mode
 				LastStatusChangeDate:  consumer.UpdatedAt,
 				PartitionsList:        consumer.PartitionsList,
 				SdkLanguage:           consumers[0].Sdk,
+				IsPaused:              consumer.IsPaused,
+				PausedUntil:           consumer.PausedUntil,
 			}
 			m[consumer.ConsumersGroup] = cg
 		} else {
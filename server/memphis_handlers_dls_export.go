@@ -0,0 +1,493 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/models"
+	"github.com/memphisdev/memphis/utils"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	dlsExportTaskName       = "export_dls_msgs"
+	dlsExportBatchSize      = 500
+	dlsExportFilesRetention = 24 * time.Hour
+	dlsImportMaxLineSize    = 16 * 1024 * 1024
+)
+
+func getDlsExportsDir() string {
+	return filepath.Join(os.TempDir(), "memphis_dls_exports")
+}
+
+func removeOldDlsExports() {
+	entries, err := os.ReadDir(getDlsExportsDir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) > dlsExportFilesRetention {
+			os.Remove(filepath.Join(getDlsExportsDir(), entry.Name()))
+		}
+	}
+}
+
+func getDlsExportMetaData(task models.AsyncTask) (models.DlsExportMetaData, error) {
+	var metaData models.DlsExportMetaData
+	raw, err := json.Marshal(task.Data)
+	if err != nil {
+		return metaData, err
+	}
+	err = json.Unmarshal(raw, &metaData)
+	return metaData, err
+}
+
+func (sh StationsHandler) ExportDlsMessages(c *gin.Context) {
+	var body models.ExportDlsMessagesSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("ExportDlsMessages at getUserDetailsFromMiddleware: %v", err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	stationName, err := StationNameFromStr(body.StationName)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]ExportDlsMessages at StationNameFromStr: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+	if !validateStationAccess(c, user, stationName.Ext(), "read", "ExportDlsMessages") {
+		return
+	}
+	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]ExportDlsMessages at GetStationByName: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("Station %v does not exist", body.StationName)
+		serv.Warnf("[tenant: %v][user: %v]ExportDlsMessages: %v", user.TenantName, user.Username, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
+	}
+
+	switch body.Destination {
+	case _EMPTY_:
+		body.Destination = "file"
+	case "file":
+	case "s3":
+		if tenantIntegrations, ok := IntegrationsConcurrentCache.Load(user.TenantName); !ok {
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Exporting to S3 requires an S3 integration"})
+			return
+		} else if _, ok = tenantIntegrations["s3"].(models.Integration); !ok {
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Exporting to S3 requires an S3 integration"})
+			return
+		}
+	default:
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "destination has to be file or s3"})
+		return
+	}
+
+	filter, err := getDlsMessagesFilter(models.SearchDlsMessagesSchema{StationName: body.StationName, DlsType: body.DlsType, SortBy: "id", SortOrder: "asc", Limit: dlsExportBatchSize}, station.ID)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]ExportDlsMessages at getDlsMessagesFilter: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+
+	running, err := db.IsAsyncTaskRunning(dlsExportTaskName, user.TenantName, station.ID)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]ExportDlsMessages at IsAsyncTaskRunning: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if running {
+		errMsg := fmt.Sprintf("An export of station %v is already running", stationName.Ext())
+		serv.Warnf("[tenant: %v][user: %v]ExportDlsMessages: %v", user.TenantName, user.Username, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
+	}
+
+	task, err := db.UpsertAsyncTask(dlsExportTaskName, sh.S.opts.ServerName, time.Now(), user.TenantName, station.ID, user.Username)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]ExportDlsMessages at UpsertAsyncTask: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	go sh.S.exportDlsMessages(task, station, filter, body.Destination, user)
+
+	c.IndentedJSON(200, gin.H{"task_id": task.ID})
+}
+
+// exportDlsMessages writes the station dls messages as NDJSON into a local file which is either kept
+// for download from this broker or uploaded to the tenant's s3 bucket
+func (s *Server) exportDlsMessages(task models.AsyncTask, station models.Station, filter models.DlsMessagesFilter, destination string, user models.User) {
+	removeOldDlsExports()
+	err := os.MkdirAll(getDlsExportsDir(), 0755)
+	if err != nil {
+		s.handleDlsExportFailure(task, station, user, _EMPTY_, err)
+		return
+	}
+	fileName := fmt.Sprintf("%v_%v_%v.ndjson", task.TenantName, station.Name, task.ID)
+	filePath := filepath.Join(getDlsExportsDir(), filepath.Base(fileName))
+	file, err := os.Create(filePath)
+	if err != nil {
+		s.handleDlsExportFailure(task, station, user, _EMPTY_, err)
+		return
+	}
+
+	metaData := models.DlsExportMetaData{Destination: destination}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for {
+		dlsMsgs, err := db.SearchDlsMessages(filter)
+		if err != nil {
+			file.Close()
+			s.handleDlsExportFailure(task, station, user, filePath, err)
+			return
+		}
+		for _, dlsMsg := range dlsMsgs {
+			record := models.DlsExportRecord{
+				ID:              dlsMsg.ID,
+				StationName:     station.Name,
+				MessageType:     dlsMsg.MessageType,
+				MessageSeq:      dlsMsg.MessageSeq,
+				PartitionNumber: dlsMsg.PartitionNumber,
+				ProducerName:    dlsMsg.ProducerName,
+				PoisonedCgs:     dlsMsg.PoisonedCgs,
+				ValidationError: dlsMsg.ValidationError,
+				UpdatedAt:       dlsMsg.UpdatedAt,
+				TimeSent:        dlsMsg.MessageDetails.TimeSent,
+				Size:            dlsMsg.MessageDetails.Size,
+				Headers:         dlsMsg.MessageDetails.Headers,
+				Data:            dlsMsg.MessageDetails.Data,
+			}
+			err = encoder.Encode(record)
+			if err != nil {
+				file.Close()
+				s.handleDlsExportFailure(task, station, user, filePath, err)
+				return
+			}
+			metaData.Offset = dlsMsg.ID
+			metaData.Exported++
+		}
+
+		// keeps the task alive for RemoveInactiveAsyncTasks
+		err = db.UpdateAsyncTaskById(task.ID, time.Now(), metaData)
+		if err != nil {
+			s.Errorf("[tenant: %v][user: %v]exportDlsMessages at UpdateAsyncTaskById at station %v: %v", task.TenantName, user.Username, station.Name, err.Error())
+		}
+		if len(dlsMsgs) < filter.Limit {
+			break
+		}
+		filter.Cursor = &models.DlsMessagesCursor{ID: metaData.Offset}
+	}
+
+	err = writer.Flush()
+	if err == nil {
+		err = file.Close()
+	} else {
+		file.Close()
+	}
+	if err != nil {
+		s.handleDlsExportFailure(task, station, user, filePath, err)
+		return
+	}
+
+	metaData.Location = fileName
+	if destination == "s3" {
+		metaData.Location, err = uploadDlsExportToS3(task.TenantName, station.Name, task.ID, filePath)
+		os.Remove(filePath)
+		if err != nil {
+			s.handleDlsExportFailure(task, station, user, _EMPTY_, err)
+			return
+		}
+	}
+
+	err = db.UpdateAsyncTaskById(task.ID, time.Now(), metaData)
+	if err != nil {
+		s.handleDlsExportFailure(task, station, user, filePath, err)
+		return
+	}
+	err = db.UpdateStatusAsyncTaskById(task.ID, "completed", _EMPTY_)
+	if err != nil {
+		s.handleDlsExportFailure(task, station, user, filePath, err)
+		return
+	}
+
+	systemMessage := SystemMessage{
+		MessageType:    "info",
+		MessagePayload: fmt.Sprintf("Export of %v DLS messages at station %s, triggered by user %s has been completed successfully", metaData.Exported, station.Name, user.Username),
+	}
+	err = s.sendSystemMessageOnWS(user, systemMessage)
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]exportDlsMessages at sendSystemMessageOnWS at station %v: %v", task.TenantName, user.Username, station.Name, err.Error())
+	}
+}
+
+func uploadDlsExportToS3(tenantName, stationName string, taskId int, filePath string) (string, error) {
+	uploader, bucketName, err := getTenantS3Uploader(tenantName)
+	if err != nil {
+		return _EMPTY_, err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return _EMPTY_, err
+	}
+	defer file.Close()
+
+	if tenantName == serv.MemphisGlobalAccountString() {
+		tenantName = "global"
+	}
+	objectName := fmt.Sprintf("memphis/%v/dls_exports/%v/%v.ndjson", tenantName, stationName, taskId)
+	_, err = uploader.Upload(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+		Body:   file,
+	})
+	if err != nil {
+		return _EMPTY_, errors.New("failed to upload the dls export to S3: " + err.Error())
+	}
+	return fmt.Sprintf("s3://%v/%v", bucketName, objectName), nil
+}
+
+func (s *Server) handleDlsExportFailure(task models.AsyncTask, station models.Station, user models.User, filePath string, exportErr error) {
+	s.Errorf("[tenant: %v][user: %v]exportDlsMessages: at station %v: %v", task.TenantName, user.Username, station.Name, exportErr.Error())
+	if filePath != _EMPTY_ {
+		os.Remove(filePath)
+	}
+	err := db.UpdateStatusAsyncTaskById(task.ID, "failed", exportErr.Error())
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]handleDlsExportFailure at UpdateStatusAsyncTaskById at station %v: %v", task.TenantName, user.Username, station.Name, err.Error())
+	}
+	systemMessage := SystemMessage{
+		MessageType:    "error",
+		MessagePayload: fmt.Sprintf("Export of DLS messages at station %s, triggered by user %s has failed due to an internal error", station.Name, user.Username),
+	}
+	err = s.sendSystemMessageOnWS(user, systemMessage)
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]handleDlsExportFailure at sendSystemMessageOnWS at station %v: %v", task.TenantName, user.Username, station.Name, err.Error())
+	}
+}
+
+func (ash AsyncTasksHandler) DownloadDlsExport(c *gin.Context) {
+	var body models.DownloadDlsExportSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("DownloadDlsExport at getUserDetailsFromMiddleware: %v", err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	exist, task, err := db.GetAsyncTaskById(body.TaskId, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]DownloadDlsExport at GetAsyncTaskById: %v", user.TenantName, user.Username, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist || task.Name != dlsExportTaskName {
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Export task does not exist"})
+		return
+	}
+	if task.Status != "completed" {
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Export is not ready yet"})
+		return
+	}
+	metaData, err := getDlsExportMetaData(task)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]DownloadDlsExport at getDlsExportMetaData: %v", user.TenantName, user.Username, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if metaData.Destination != "file" {
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "The export has been uploaded to " + metaData.Location})
+		return
+	}
+	if task.BrokrInCharge != serv.opts.ServerName {
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "The export file is stored on broker " + task.BrokrInCharge})
+		return
+	}
+
+	fileName := filepath.Base(metaData.Location)
+	filePath := filepath.Join(getDlsExportsDir(), fileName)
+	if _, err := os.Stat(filePath); err != nil {
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "The export file does not exist anymore"})
+		return
+	}
+	c.FileAttachment(filePath, fileName)
+}
+
+func (sh StationsHandler) ImportDlsMessages(c *gin.Context) {
+	stationNameStr := c.PostForm("station_name")
+	if stationNameStr == _EMPTY_ {
+		c.AbortWithStatusJSON(400, gin.H{"message": "station_name is required"})
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Could not complete uploading your file, please check your file"})
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("ImportDlsMessages at getUserDetailsFromMiddleware: %v", err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if IsStorageLimitExceeded(user.TenantName) {
+		serv.Warnf("[tenant: %v][user: %v]ImportDlsMessages at IsStorageLimitExceeded: %s", user.TenantName, user.Username, ErrUpgradePlan.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": ErrUpgradePlan.Error()})
+		return
+	}
+
+	stationName, err := StationNameFromStr(stationNameStr)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]ImportDlsMessages at StationNameFromStr: At station %v: %v", user.TenantName, user.Username, stationNameStr, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+	if !validateStationAccess(c, user, stationName.Ext(), "write", "ImportDlsMessages") {
+		return
+	}
+	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]ImportDlsMessages at GetStationByName: At station %v: %v", user.TenantName, user.Username, stationNameStr, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("Station %v does not exist", stationNameStr)
+		serv.Warnf("[tenant: %v][user: %v]ImportDlsMessages: %v", user.TenantName, user.Username, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
+	}
+	account, err := sh.S.lookupAccount(user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]ImportDlsMessages at lookupAccount: %v", user.TenantName, user.Username, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]ImportDlsMessages at Open: %v", user.TenantName, user.Username, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	defer file.Close()
+
+	result := models.DlsImportResponse{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), dlsImportMaxLineSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var record models.DlsExportRecord
+		err = json.Unmarshal(line, &record)
+		if err == nil {
+			err = sh.S.replayDlsExportRecord(account, station, stationName, record)
+		}
+		if err != nil {
+			serv.Warnf("[tenant: %v][user: %v]ImportDlsMessages: At station %v: %v", user.TenantName, user.Username, stationNameStr, err.Error())
+			result.Failed++
+			continue
+		}
+		result.Imported++
+	}
+	if err := scanner.Err(); err != nil {
+		serv.Warnf("[tenant: %v][user: %v]ImportDlsMessages at scanner: At station %v: %v", user.TenantName, user.Username, stationNameStr, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": fmt.Sprintf("Failed reading the import file after %v messages: %v", result.Imported+result.Failed, err.Error())})
+		return
+	}
+
+	serv.Noticef("[tenant: %v][user: %v]ImportDlsMessages: %v messages have been replayed into station %v, %v failed", user.TenantName, user.Username, result.Imported, stationName.Ext(), result.Failed)
+	c.IndentedJSON(200, result)
+}
+
+// replayDlsExportRecord produces an exported dls message into the station, the original producer is kept
+// in $memphis_producedBy so poison messages of the replayed copy are attributed to it
+func (s *Server) replayDlsExportRecord(account *Account, station models.Station, stationName StationName, record models.DlsExportRecord) error {
+	data, err := hex.DecodeString(record.Data)
+	if err != nil {
+		return fmt.Errorf("message %v: invalid data: %v", record.ID, err.Error())
+	}
+
+	headers := make(map[string]string, len(record.Headers)+2)
+	for key, value := range record.Headers {
+		headers[key] = value
+	}
+	delete(headers, "$memphis_pm_id")
+	delete(headers, "$memphis_pm_cg_name")
+	producedBy := headers["$memphis_producedBy"]
+	// resent copies carry $memphis_dls which would hide them from the poison messages tracking
+	if producedBy == _EMPTY_ || producedBy == "$memphis_dls" {
+		producedBy = record.ProducerName
+	}
+	if producedBy == _EMPTY_ {
+		producedBy = "unknown"
+	}
+	headers["$memphis_producedBy"] = producedBy
+	if headers["$memphis_connectionId"] == _EMPTY_ {
+		headers["$memphis_connectionId"] = "$memphis_dls_import"
+	}
+
+	subject := fmt.Sprintf("%s.final", stationName.Intern())
+	if station.Version != 0 && len(station.PartitionsList) > 0 {
+		partition := station.PartitionsList[rand.Intn(len(station.PartitionsList))]
+		for _, p := range station.PartitionsList {
+			if p == record.PartitionNumber {
+				partition = p
+				break
+			}
+		}
+		subject = fmt.Sprintf("%s$%v.final", stationName.Intern(), partition)
+	}
+
+	enforced, partition, err := validateBrokerEnforcedMsg(station.TenantName, subject, data)
+	if err != nil {
+		if enforced.dlsEnabled {
+			s.sendBrokerEnforcedMsgToDls(account, stationName.Intern(), partition, headers, data, err)
+		}
+		return fmt.Errorf("message %v: %v", record.ID, err.Error())
+	}
+	return s.sendInternalAccountMsgWithHeadersWithEcho(account, subject, data, headers)
+}
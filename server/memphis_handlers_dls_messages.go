@@ -12,6 +12,7 @@
 package server
 
 import (
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -24,6 +25,7 @@ import (
 
 	"github.com/memphisdev/memphis/db"
 	"github.com/memphisdev/memphis/models"
+	"github.com/memphisdev/memphis/tracing"
 )
 
 const (
@@ -128,14 +130,21 @@ func (s *Server) handleNewUnackedMsg(msg []byte) error {
 		Headers:  headersJson,
 	}
 
+	span := startStationSpan("dls capture "+station.Name, tracing.SpanKindConsumer, headersJson, station.TenantName, station.Name)
+	span.SetAttribute("memphis.consumer_group", cgName)
+	span.SetAttribute("memphis.message.sequence", int64(messageSeq))
+	defer span.End()
+
 	dlsMsgId, updated, err := db.StorePoisonMsg(station.ID, int(messageSeq), cgName, producedByHeader, poisonedCgs, messageDetails, station.TenantName, partitionNumber, "")
 	if err != nil {
+		span.SetError(err)
 		serv.Errorf("[tenant: %v]handleNewUnackedMsg at StorePoisonMsg: Error while getting notified about a poison message: %v", station.TenantName, err.Error())
 		return err
 	}
 	if !updated {
-		err = s.sendToDlsStation(station, data, headersJson, "unacked", _EMPTY_)
+		err = s.sendToDlsStation(station, data, headersWithTraceContext(headersJson, span.Context()), "unacked", _EMPTY_)
 		if err != nil {
+			span.SetError(err)
 			serv.Errorf("[tenant: %v]handleNewUnackedMsg at sendToDlsStation: station: %v, Error while getting notified about a poison message: %v", station.TenantName, station.DlsStation, err.Error())
 			return err
 		}
@@ -145,13 +154,7 @@ func (s *Server) handleNewUnackedMsg(msg []byte) error {
 		return nil
 	}
 
-	idForUrl := strconv.Itoa(dlsMsgId)
-	var msgUrl = s.opts.UiHost + "/stations/" + stationName.Ext() + "/" + idForUrl
-	err = s.SendNotification(station.TenantName, PoisonMessageTitle, "Poison message has been identified, for more details head to: "+msgUrl, PoisonMAlert)
-	if err != nil {
-		serv.Warnf("[tenant: %v]handleNewUnackedMsg at SendNotification: Error while sending a poison message notification: %v", station.TenantName, err.Error())
-		return nil
-	}
+	s.scheduleDlsRetryOrNotify(station, dlsMsgId)
 	return nil
 }
 
@@ -178,6 +181,7 @@ func (s *Server) handleSchemaverseDlsMsg(msg []byte) error {
 		serv.Warnf("[tenant: %v]handleSchemaverseDlsMsg: station %v couldn't been found", tenantName, stationName.Ext())
 		return nil
 	}
+	recordSchemaValidationFailure(tenantName, stationName.Ext())
 
 	message.Message.TimeSent = time.Now()
 	_, err = db.InsertSchemaverseDlsMsg(station.ID, 0, message.Producer.Name, []string{}, models.MessagePayload(message.Message), message.ValidationError, tenantName, message.PartitionNumber)
@@ -287,14 +291,7 @@ func (s *Server) handleNackedDlsMsg(msg []byte) error {
 		return nil
 	}
 
-	idForUrl := strconv.Itoa(dlsMsgId)
-	var msgUrl = s.opts.UiHost + "/stations/" + stationName.Ext() + "/" + idForUrl
-	err = s.SendNotification(station.TenantName, PoisonMessageTitle, "Poison message has been identified, for more details head to: "+msgUrl, PoisonMAlert)
-	if err != nil {
-		serv.Warnf("[tenant: %v]handleNackedDlsMsg at SendNotification: Error while sending a poison message notification: %v", station.TenantName, err.Error())
-		return nil
-	}
-
+	s.scheduleDlsRetryOrNotify(station, dlsMsgId)
 	return nil
 }
 
@@ -374,6 +371,154 @@ func (pmh PoisonMessagesHandler) GetDlsMsgsByStationLight(station models.Station
 	return poisonMessages, schemaMessages, functionsMessages, totalDlsAmount, nil
 }
 
+const (
+	dlsSearchDefaultLimit = 50
+	dlsSearchMaxLimit     = 500
+)
+
+func encodeDlsMessagesCursor(cursor models.DlsMessagesCursor) (string, error) {
+	cursorJson, err := json.Marshal(cursor)
+	if err != nil {
+		return _EMPTY_, err
+	}
+	return base64.RawURLEncoding.EncodeToString(cursorJson), nil
+}
+
+func decodeDlsMessagesCursor(cursor string) (*models.DlsMessagesCursor, error) {
+	cursorJson, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var decoded models.DlsMessagesCursor
+	err = json.Unmarshal(cursorJson, &decoded)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &decoded, nil
+}
+
+// getDlsMessagesFilter validates the search request, the returned errors are meant to be shown to the user
+func getDlsMessagesFilter(body models.SearchDlsMessagesSchema, stationId int) (models.DlsMessagesFilter, error) {
+	filter := models.DlsMessagesFilter{
+		StationId:       stationId,
+		PartitionNumber: body.PartitionNumber,
+		CgName:          body.CgName,
+		ProducerName:    body.ProducerName,
+		ValidationError: body.ValidationError,
+		HeaderKey:       body.HeaderKey,
+		HeaderValue:     body.HeaderValue,
+		Limit:           body.Limit,
+	}
+	if filter.PartitionNumber <= 0 {
+		filter.PartitionNumber = -1
+	}
+
+	switch body.DlsType {
+	case _EMPTY_, "poison", "functions":
+		filter.MessageType = body.DlsType
+	case "schema", "schemaverse":
+		filter.MessageType = "schema"
+	default:
+		return models.DlsMessagesFilter{}, fmt.Errorf("dls_type has to be one of poison/schemaverse/functions")
+	}
+
+	switch body.SortBy {
+	case _EMPTY_:
+		filter.SortBy = "updated_at"
+	case "updated_at", "message_seq", "id":
+		filter.SortBy = body.SortBy
+	default:
+		return models.DlsMessagesFilter{}, fmt.Errorf("sort_by has to be one of updated_at/message_seq/id")
+	}
+	switch strings.ToLower(body.SortOrder) {
+	case _EMPTY_, "desc":
+		filter.SortDesc = true
+	case "asc":
+		filter.SortDesc = false
+	default:
+		return models.DlsMessagesFilter{}, fmt.Errorf("sort_order has to be asc or desc")
+	}
+
+	if !body.From.IsZero() {
+		from := body.From
+		filter.From = &from
+	}
+	if !body.To.IsZero() {
+		to := body.To
+		filter.To = &to
+	}
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		return models.DlsMessagesFilter{}, errors.New("from can not be later than to")
+	}
+	if filter.HeaderValue != _EMPTY_ && filter.HeaderKey == _EMPTY_ {
+		return models.DlsMessagesFilter{}, errors.New("header_value requires header_key")
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = dlsSearchDefaultLimit
+	} else if filter.Limit > dlsSearchMaxLimit {
+		filter.Limit = dlsSearchMaxLimit
+	}
+
+	if body.Cursor != _EMPTY_ {
+		cursor, err := decodeDlsMessagesCursor(body.Cursor)
+		if err != nil {
+			return models.DlsMessagesFilter{}, err
+		}
+		filter.Cursor = cursor
+	}
+	return filter, nil
+}
+
+func (pmh PoisonMessagesHandler) SearchDlsMessages(filter models.DlsMessagesFilter) (models.SearchDlsMessagesResponse, error) {
+	pageSize := filter.Limit
+	// fetching one extra row tells whether there is a next page
+	filter.Limit++
+	dlsMsgs, err := db.SearchDlsMessages(filter)
+	if err != nil {
+		return models.SearchDlsMessagesResponse{}, err
+	}
+
+	result := models.SearchDlsMessagesResponse{Messages: make([]models.SearchedDlsMessage, 0, len(dlsMsgs))}
+	if len(dlsMsgs) > pageSize {
+		dlsMsgs = dlsMsgs[:pageSize]
+		last := dlsMsgs[len(dlsMsgs)-1]
+		result.NextCursor, err = encodeDlsMessagesCursor(models.DlsMessagesCursor{UpdatedAt: last.UpdatedAt, MessageSeq: last.MessageSeq, ID: last.ID})
+		if err != nil {
+			return models.SearchDlsMessagesResponse{}, err
+		}
+	}
+
+	for _, v := range dlsMsgs {
+		data := v.MessageDetails.Data
+		if len(data) > 80 { // get the first chars for preview needs
+			data = data[0:80]
+		}
+		messageDetails := models.MessagePayload{
+			TimeSent: v.MessageDetails.TimeSent,
+			Size:     v.MessageDetails.Size,
+			Data:     data,
+			Headers:  v.MessageDetails.Headers,
+		}
+		if v.MessageType == "schema" {
+			messageDetails.Size = len(v.MessageDetails.Data) + len(v.MessageDetails.Headers)
+		}
+		result.Messages = append(result.Messages, models.SearchedDlsMessage{
+			ID:              v.ID,
+			MessageSeq:      v.MessageSeq,
+			MessageType:     v.MessageType,
+			PartitionNumber: v.PartitionNumber,
+			ProducerName:    v.ProducerName,
+			PoisonedCgs:     v.PoisonedCgs,
+			ValidationError: v.ValidationError,
+			UpdatedAt:       v.UpdatedAt,
+			RetryAttempts:   v.RetryAttempts,
+			Message:         messageDetails,
+		})
+	}
+	return result, nil
+}
+
 func (pmh PoisonMessagesHandler) GetDlsMessageDetailsById(messageId int, dlsType string, tenantName string) (models.DlsMessageResponse, error) {
 	exist, dlsMessage, err := db.GetDlsMessageById(messageId)
 	if err != nil {
@@ -415,6 +560,7 @@ func (pmh PoisonMessagesHandler) GetDlsMessageDetailsById(messageId int, dlsType
 		UpdatedAt:       dlsMessage.UpdatedAt,
 		MessageType:     dlsMessage.MessageType,
 		ValidationError: dlsMessage.ValidationError,
+		RetryAttempts:   dlsMessage.RetryAttempts,
 	}
 
 	if station.IsNative {
@@ -492,6 +638,7 @@ func (pmh PoisonMessagesHandler) GetDlsMessageDetailsById(messageId int, dlsType
 		UpdatedAt:       dlsMsg.UpdatedAt,
 		PoisonedCgs:     poisonedCgs,
 		ValidationError: dlsMsg.ValidationError,
+		RetryAttempts:   dlsMsg.RetryAttempts,
 	}
 
 	return result, nil
@@ -576,12 +723,17 @@ func (s *Server) sendToDlsStation(station models.Station, messagePayload []byte,
 			if err != nil {
 				return err
 			}
-			headers["station"] = station.Name
-			headers["type"] = dlsType
+			// copied so the caller's map is not modified, the trace context headers travel along with the rest
+			dlsHeaders := make(map[string]string, len(headers)+3)
+			for k, v := range headers {
+				dlsHeaders[k] = v
+			}
+			dlsHeaders["station"] = station.Name
+			dlsHeaders["type"] = dlsType
 			if dlsType == "functions" {
-				headers["function_name"] = functionName
+				dlsHeaders["function_name"] = functionName
 			}
-			s.sendInternalAccountMsgWithHeadersWithEcho(acc, subject, messagePayload, headers)
+			s.sendInternalAccountMsgWithHeadersWithEcho(acc, subject, messagePayload, dlsHeaders)
 		}
 	}
 	return nil
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/memphisdev/memphis/models"
+)
+
+func TestDlsMessagesFilter(t *testing.T) {
+	filter, err := getDlsMessagesFilter(models.SearchDlsMessagesSchema{StationName: "orders", DlsType: "schemaverse", Limit: 10000}, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filter.MessageType != "schema" || filter.PartitionNumber != -1 || filter.Limit != dlsSearchMaxLimit || filter.SortBy != "updated_at" || !filter.SortDesc {
+		t.Fatalf("unexpected filter defaults: %+v", filter)
+	}
+
+	invalid := []models.SearchDlsMessagesSchema{
+		{StationName: "orders", DlsType: "unknown"},
+		{StationName: "orders", SortBy: "producer_name"},
+		{StationName: "orders", SortOrder: "up"},
+		{StationName: "orders", HeaderValue: "x"},
+		{StationName: "orders", From: time.Now(), To: time.Now().Add(-time.Hour)},
+		{StationName: "orders", Cursor: "not a cursor"},
+	}
+	for _, body := range invalid {
+		if _, err := getDlsMessagesFilter(body, 3); err == nil {
+			t.Fatalf("expected %+v to be rejected", body)
+		}
+	}
+
+	cursor := models.DlsMessagesCursor{UpdatedAt: time.Now().UTC().Truncate(time.Microsecond), MessageSeq: 7, ID: 42}
+	encoded, err := encodeDlsMessagesCursor(cursor)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	filter, err = getDlsMessagesFilter(models.SearchDlsMessagesSchema{StationName: "orders", Cursor: encoded}, 3)
+	if err != nil || filter.Cursor == nil || *filter.Cursor != cursor {
+		t.Fatalf("cursor did not round trip: %+v, %v", filter.Cursor, err)
+	}
+}
@@ -33,9 +33,10 @@ const INTEGRATIONS_AUDIT_LOGS_CONSUMER = "$memphis_integrations_audit_logs_consu
 type IntegrationsHandler struct{ S *Server }
 
 var integrationsAuditLogLabelToSubjectMap = map[string]string{
-	"slack":  integrationsAuditLogsStream + ".%s.slack",
-	"s3":     integrationsAuditLogsStream + ".%s.s3",
-	"github": integrationsAuditLogsStream + ".%s.github",
+	"slack":   integrationsAuditLogsStream + ".%s.slack",
+	"s3":      integrationsAuditLogsStream + ".%s.s3",
+	"github":  integrationsAuditLogsStream + ".%s.github",
+	"webhook": integrationsAuditLogsStream + ".%s.webhook",
 }
 
 func (it IntegrationsHandler) CreateIntegration(c *gin.Context) {
@@ -109,6 +110,43 @@ func (it IntegrationsHandler) CreateIntegration(c *gin.Context) {
 			return
 		}
 		integration = s3Integration
+	case webhookIntegrationName:
+		webhookIntegration, errorCode, err := it.handleCreateWebhookIntegration(user.TenantName, body)
+		if err != nil {
+			if errorCode == 500 {
+				serv.Errorf("[tenant: %v][user: %v]CreateIntegration at handleCreateWebhookIntegration: %v", user.TenantName, user.Username, err.Error())
+				message = "Server error"
+			} else {
+				message = err.Error()
+				serv.Warnf("[tenant: %v][user: %v]CreateIntegration at handleCreateWebhookIntegration: %v", user.TenantName, user.Username, message)
+				auditLog := fmt.Sprintf("Error while trying to connect with the webhook: %v", message)
+				it.Errorf(integrationType, user.TenantName, auditLog)
+			}
+			c.AbortWithStatusJSON(errorCode, gin.H{"message": message})
+			return
+		}
+		integration = webhookIntegration
+	case "filesystem", "gcs", "azure_blob":
+		if !ValidataAccessToFeature(user.TenantName, "feature-storage-tiering") {
+			serv.Warnf("[tenant: %v][user: %v]CreateIntegration at ValidataAccessToFeature: %v", user.TenantName, user.Username, "feature-storage-tiering")
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "This feature is not available on your current pricing plan, in order to enjoy it you will have to upgrade your plan"})
+			return
+		}
+		storageIntegration, errorCode, err := it.handleCreateTieredStorageIntegration(user.TenantName, integrationType, body.Keys)
+		if err != nil {
+			if errorCode == 500 {
+				serv.Errorf("[tenant: %v][user: %v]CreateIntegration at handleCreateTieredStorageIntegration: %v", user.TenantName, user.Username, err.Error())
+				message = "Server error"
+			} else {
+				message = err.Error()
+				serv.Warnf("[tenant: %v][user: %v]CreateIntegration at handleCreateTieredStorageIntegration: %v", user.TenantName, user.Username, message)
+				auditLog := fmt.Sprintf("Error while trying to connect with %v: %v", tieredStorageBackendTypes[integrationType].displayName, message)
+				it.Errorf(integrationType, user.TenantName, auditLog)
+			}
+			c.AbortWithStatusJSON(errorCode, gin.H{"message": message})
+			return
+		}
+		integration = storageIntegration
 	case "github":
 		githubIntegration, errorCode, err := it.handleCreateGithubIntegration(user.TenantName, body.Keys)
 		if err != nil {
@@ -201,6 +239,38 @@ func (it IntegrationsHandler) UpdateIntegration(c *gin.Context) {
 			return
 		}
 		integration = s3Integration
+	case webhookIntegrationName:
+		webhookIntegration, errorCode, err := it.handleUpdateWebhookIntegration(user.TenantName, body)
+		if err != nil {
+			if errorCode == 500 {
+				serv.Errorf("[tenant: %v][user: %v]UpdateIntegration at handleUpdateWebhookIntegration: %v", user.TenantName, user.Username, err.Error())
+				message = "Server error"
+			} else {
+				message = err.Error()
+				serv.Warnf("[tenant: %v][user: %v]UpdateIntegration at handleUpdateWebhookIntegration: %v", user.TenantName, user.Username, message)
+				auditLog := fmt.Sprintf("Error while trying to connect with the webhook: %v", message)
+				it.Errorf(integrationType, user.TenantName, auditLog)
+			}
+			c.AbortWithStatusJSON(errorCode, gin.H{"message": message})
+			return
+		}
+		integration = webhookIntegration
+	case "filesystem", "gcs", "azure_blob":
+		storageIntegration, errorCode, err := it.handleUpdateTieredStorageIntegration(user.TenantName, integrationType, body)
+		if err != nil {
+			if errorCode == 500 {
+				serv.Errorf("[tenant: %v][user: %v]UpdateIntegration at handleUpdateTieredStorageIntegration: %v", user.TenantName, user.Username, err.Error())
+				message = "Server error"
+			} else {
+				message = err.Error()
+				serv.Warnf("[tenant: %v][user: %v]UpdateIntegration at handleUpdateTieredStorageIntegration: %v", user.TenantName, user.Username, message)
+				auditLog := fmt.Sprintf("Error while trying to connect with %v: %v", tieredStorageBackendTypes[integrationType].displayName, message)
+				it.Errorf(integrationType, user.TenantName, auditLog)
+			}
+			c.AbortWithStatusJSON(errorCode, gin.H{"message": message})
+			return
+		}
+		integration = storageIntegration
 	case "github":
 		_, locked, _, err := db.GetAndLockSharedLock("functions", user.TenantName)
 		if err != nil {
@@ -378,7 +448,13 @@ func (it IntegrationsHandler) DisconnectIntegration(c *gin.Context) {
 	case "slack":
 		update := models.SdkClientsUpdates{
 			Type:   sendNotificationType,
-			Update: false,
+			Update: integrationHasAlert(user.TenantName, webhookIntegrationName, SchemaVAlert),
+		}
+		serv.SendUpdateToClients(update)
+	case webhookIntegrationName:
+		update := models.SdkClientsUpdates{
+			Type:   sendNotificationType,
+			Update: integrationHasAlert(user.TenantName, slackIntegrationName, SchemaVAlert),
 		}
 		serv.SendUpdateToClients(update)
 	}
@@ -396,7 +472,7 @@ func (it IntegrationsHandler) DisconnectIntegration(c *gin.Context) {
 	c.IndentedJSON(200, gin.H{})
 }
 
-func createIntegrationsKeysAndProperties(integrationType, authToken string, channelID string, pmAlert bool, svfAlert bool, disconnectAlert bool, accessKey, secretKey, bucketName, region, url, forceS3PathStyle string, githubIntegrationDetails map[string]interface{}, repo, branch, repoType, repoOwner string) (map[string]interface{}, map[string]bool) {
+func createIntegrationsKeysAndProperties(integrationType, authToken string, channelID string, pmAlert bool, svfAlert bool, disconnectAlert bool, cgLagAlert bool, accessKey, secretKey, bucketName, region, url, forceS3PathStyle string, githubIntegrationDetails map[string]interface{}, repo, branch, repoType, repoOwner string) (map[string]interface{}, map[string]bool) {
 	keys := make(map[string]interface{})
 	properties := make(map[string]bool)
 	switch integrationType {
@@ -406,6 +482,7 @@ func createIntegrationsKeysAndProperties(integrationType, authToken string, chan
 		properties[PoisonMAlert] = pmAlert
 		properties[SchemaVAlert] = svfAlert
 		properties[DisconEAlert] = disconnectAlert
+		properties[CgLagAlert] = cgLagAlert
 	case "s3":
 		keys["access_key"] = accessKey
 		keys["secret_key"] = secretKey
@@ -466,6 +543,8 @@ func (it IntegrationsHandler) GetIntegrationDetails(c *gin.Context) {
 
 	if integration.Name == "s3" && integration.Keys["secret_key"] != _EMPTY_ {
 		integration.Keys["secret_key"] = hideIntegrationSecretKey(integration.Keys["secret_key"].(string))
+	} else if isTieredStorageIntegration(integration.Name) {
+		integration.Keys = hideIntegrationKeysSecret(integration.Keys)
 	}
 
 	sourceCodeIntegration, branchesMap, err := getSourceCodeDetails(user.TenantName, body, "get_all_repos")
@@ -525,6 +604,8 @@ func (it IntegrationsHandler) GetAllIntegrations(c *gin.Context) {
 		}
 		if integrations[i].Name == "s3" && integrations[i].Keys["secret_key"] != _EMPTY_ {
 			integrations[i].Keys["secret_key"] = hideIntegrationSecretKey(integrations[i].Keys["secret_key"].(string))
+		} else if isTieredStorageIntegration(integrations[i].Name) {
+			integrations[i].Keys = hideIntegrationKeysSecret(integrations[i].Keys)
 		}
 		if integrations[i].Name == "github" && integrations[i].Keys["installation_id"] != _EMPTY_ {
 			memphisFuncs, err := db.GetMemphisFunctionsByMemphis()
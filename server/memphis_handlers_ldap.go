@@ -0,0 +1,154 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/idp"
+	"github.com/memphisdev/memphis/ldap_auth"
+	"github.com/memphisdev/memphis/memphis_cache"
+	"github.com/memphisdev/memphis/models"
+)
+
+// authenticateLdapUser authenticates a management user without a local password against the directory
+func authenticateLdapUser(username, password string) (bool, models.User, error) {
+	authenticator, err := ldap_auth.GetAuthenticator()
+	if err != nil {
+		return false, models.User{}, err
+	}
+	identity, err := authenticator.Authenticate(username, password)
+	if errors.Is(err, ldap_auth.ErrInvalidCredentials) || errors.Is(err, idp.ErrUserNotMapped) {
+		return false, models.User{}, nil
+	} else if err != nil {
+		return false, models.User{}, err
+	}
+	if identity.UserType != "management" {
+		return false, models.User{}, nil
+	}
+
+	user, rolesChanged, err := authenticator.ProvisionUser(identity, MEMPHIS_GLOBAL_ACCOUNT)
+	if errors.Is(err, idp.ErrUserRejected) {
+		serv.Warnf("[tenant: %v][user: %v]authenticateLdapUser at ProvisionUser: %v", MEMPHIS_GLOBAL_ACCOUNT, username, err.Error())
+		return false, models.User{}, nil
+	} else if err != nil {
+		return false, models.User{}, err
+	}
+	if rolesChanged {
+		SendUserDeleteCacheUpdate([]string{user.Username}, user.TenantName)
+	}
+	return true, user, nil
+}
+
+// authenticateLdapClient authenticates an application user connecting with a username which is not
+// one of the broker's users, the user is provisioned on its first connection and gets the permissions of its roles
+func (s *Server) authenticateLdapClient(c *client) bool {
+	username, tenantId, err := getUserAndTenantIdFromString(strings.ToLower(c.opts.Username))
+	if err != nil {
+		return false
+	}
+	tenantName := MEMPHIS_GLOBAL_ACCOUNT
+	if tenantId != -1 {
+		exist, tenant, err := db.GetTenantById(tenantId)
+		if err != nil {
+			c.Errorf("[tenant id: %v][user: %v]authenticateLdapClient at GetTenantById: %v", tenantId, username, err.Error())
+			return false
+		}
+		if !exist {
+			return false
+		}
+		tenantName = tenant.Name
+	}
+
+	// local users and users of another identity provider never authenticate against the directory
+	exist, user, err := memphis_cache.GetUser(username, tenantName, false)
+	if err != nil {
+		c.Errorf("[tenant: %v][user: %v]authenticateLdapClient at GetUser: %v", tenantName, username, err.Error())
+		return false
+	}
+	if exist && !ldap_auth.ProvisionedUser(user) {
+		return false
+	}
+
+	authenticator, err := ldap_auth.GetAuthenticator()
+	if err != nil {
+		c.Errorf("[tenant: %v][user: %v]authenticateLdapClient at GetAuthenticator: %v", tenantName, username, err.Error())
+		return false
+	}
+	identity, err := authenticator.AuthenticateWithTimeout(username, c.opts.Password, ldapClientAuthTimeout(s.getOpts()))
+	if err != nil {
+		if !errors.Is(err, ldap_auth.ErrInvalidCredentials) && !errors.Is(err, idp.ErrUserNotMapped) {
+			c.Errorf("[tenant: %v][user: %v]authenticateLdapClient at Authenticate: %v", tenantName, username, err.Error())
+		}
+		return false
+	}
+	if identity.UserType != "application" {
+		return false
+	}
+	// the tenant suffix is chosen by the client, the directory decides which tenants the user belongs to
+	if !authenticator.TenantAllowed(identity, tenantName) {
+		c.Warnf("[tenant: %v][user: %v]authenticateLdapClient: the directory does not map the user to the tenant", tenantName, username)
+		return false
+	}
+
+	user, rolesChanged, err := authenticator.ProvisionUser(identity, tenantName)
+	if err != nil {
+		c.Warnf("[tenant: %v][user: %v]authenticateLdapClient at ProvisionUser: %v", tenantName, username, err.Error())
+		return false
+	}
+	if rolesChanged {
+		SendUserDeleteCacheUpdate([]string{user.Username}, user.TenantName)
+	}
+
+	acc, err := s.LookupAccount(user.TenantName)
+	if err != nil {
+		c.Errorf("[tenant: %v][user: %v]authenticateLdapClient at LookupAccount: %v", tenantName, username, err.Error())
+		return false
+	}
+	permissions, err := ldapClientPermissions(user)
+	if err != nil {
+		c.Errorf("[tenant: %v][user: %v]authenticateLdapClient at ldapClientPermissions: %v", tenantName, username, err.Error())
+		return false
+	}
+	c.RegisterUser(&User{Username: c.opts.Username, Account: acc, Permissions: permissions})
+	return true
+}
+
+// ldapClientAuthTimeout bounds the directory round trip with the client's authentication timeout
+func ldapClientAuthTimeout(opts *Options) time.Duration {
+	if opts.AuthTimeout > 0 {
+		return time.Duration(opts.AuthTimeout * float64(time.Second))
+	}
+	return AUTH_TIMEOUT
+}
+
+// ldapClientPermissions returns the permissions of a directory user's connection, a user without roles
+// gets nothing since permissions left empty would open the whole account including the internal subjects
+func ldapClientPermissions(user models.User) (*Permissions, error) {
+	if len(user.Roles) == 0 {
+		return &Permissions{
+			Publish:   &SubjectPermission{Deny: []string{fwcs}},
+			Subscribe: &SubjectPermission{Deny: []string{fwcs}},
+		}, nil
+	}
+	permissions, err := getRolesNatsPermissions(user.Roles, user.TenantName)
+	if err != nil {
+		return nil, err
+	}
+	return &Permissions{
+		Publish:   &SubjectPermission{Allow: permissions.Publish.Allow, Deny: permissions.Publish.Deny},
+		Subscribe: &SubjectPermission{Allow: permissions.Subscribe.Allow, Deny: permissions.Subscribe.Deny},
+	}, nil
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/memphisdev/memphis/models"
+)
+
+func TestLdapClientPermissionsWithoutRoles(t *testing.T) {
+	permissions, err := ldapClientPermissions(models.User{Username: "jdoe", UserType: "application", TenantName: "acme"})
+	if err != nil {
+		t.Fatalf("ldapClientPermissions: %v", err)
+	}
+	if permissions == nil || permissions.Publish == nil || permissions.Subscribe == nil {
+		t.Fatalf("expected a user without roles to get restricted permissions, got %+v", permissions)
+	}
+	if len(permissions.Publish.Allow) != 0 || !reflect.DeepEqual(permissions.Publish.Deny, []string{">"}) {
+		t.Fatalf("expected publishing to be denied, got %+v", permissions.Publish)
+	}
+	if len(permissions.Subscribe.Allow) != 0 || !reflect.DeepEqual(permissions.Subscribe.Deny, []string{">"}) {
+		t.Fatalf("expected subscribing to be denied, got %+v", permissions.Subscribe)
+	}
+}
+
+func TestLdapClientAuthTimeout(t *testing.T) {
+	if timeout := ldapClientAuthTimeout(&Options{AuthTimeout: 0.5}); timeout != 500*time.Millisecond {
+		t.Fatalf("expected the client's authentication timeout, got %v", timeout)
+	}
+	if timeout := ldapClientAuthTimeout(&Options{}); timeout != AUTH_TIMEOUT {
+		t.Fatalf("expected the default authentication timeout, got %v", timeout)
+	}
+}
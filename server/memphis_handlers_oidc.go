@@ -0,0 +1,171 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/memphisdev/memphis/models"
+	"github.com/memphisdev/memphis/oidc"
+	"github.com/memphisdev/memphis/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	oidcStateCookie           = "memphis-oidc-state"
+	oidcStateCookiePath       = "/api/usermgmt/oidc"
+	oidcStateExpiresInMinutes = 10
+)
+
+// the state token is signed with a key derived from the jwt secret so it can never pass as an access token
+func oidcStateKey() []byte {
+	return []byte(configuration.JWT_SECRET + "$oidc_state")
+}
+
+func createOidcStateToken(state, nonce, verifier string) (string, error) {
+	claims := jwt.MapClaims{
+		"state":         state,
+		"nonce":         nonce,
+		"code_verifier": verifier,
+		"exp":           time.Now().Add(time.Minute * time.Duration(oidcStateExpiresInMinutes)).Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(oidcStateKey())
+}
+
+func parseOidcStateToken(raw string) (string, string, string, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	token, err := parser.Parse(raw, func(token *jwt.Token) (interface{}, error) {
+		return oidcStateKey(), nil
+	})
+	if err != nil {
+		return _EMPTY_, _EMPTY_, _EMPTY_, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return _EMPTY_, _EMPTY_, _EMPTY_, errors.New("invalid login state")
+	}
+	state, _ := claims["state"].(string)
+	nonce, _ := claims["nonce"].(string)
+	verifier, _ := claims["code_verifier"].(string)
+	if state == _EMPTY_ || nonce == _EMPTY_ || verifier == _EMPTY_ {
+		return _EMPTY_, _EMPTY_, _EMPTY_, errors.New("invalid login state")
+	}
+	return state, nonce, verifier, nil
+}
+
+func (umh UserMgmtHandler) OidcLogin(c *gin.Context) {
+	if !oidc.Enabled() {
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "SSO login is not configured"})
+		return
+	}
+
+	state, nonce, err := oidc.NewState()
+	if err != nil {
+		serv.Errorf("OidcLogin at NewState: %v", err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		serv.Errorf("OidcLogin at NewPKCE: %v", err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	authURL, err := oidc.GetProvider().AuthCodeURL(c.Request.Context(), state, nonce, challenge)
+	if err != nil {
+		serv.Errorf("OidcLogin at AuthCodeURL: %v", err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	stateToken, err := createOidcStateToken(state, nonce, verifier)
+	if err != nil {
+		serv.Errorf("OidcLogin at createOidcStateToken: %v", err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	c.SetCookie(oidcStateCookie, stateToken, oidcStateExpiresInMinutes*60, oidcStateCookiePath, _EMPTY_, false, true)
+	c.IndentedJSON(200, gin.H{"authorization_url": authURL})
+}
+
+func (umh UserMgmtHandler) OidcCallback(c *gin.Context) {
+	var body models.OidcCallbackSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	if !oidc.Enabled() {
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "SSO login is not configured"})
+		return
+	}
+
+	stateToken, err := c.Cookie(oidcStateCookie)
+	if err != nil {
+		serv.Warnf("OidcCallback: login state cookie is missing")
+		c.AbortWithStatusJSON(401, gin.H{"message": "Unauthorized"})
+		return
+	}
+	// the state can be used only once
+	c.SetCookie(oidcStateCookie, _EMPTY_, -1, oidcStateCookiePath, _EMPTY_, false, true)
+	state, nonce, verifier, err := parseOidcStateToken(stateToken)
+	if err != nil {
+		serv.Warnf("OidcCallback at parseOidcStateToken: %v", err.Error())
+		c.AbortWithStatusJSON(401, gin.H{"message": "Unauthorized"})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(state), []byte(body.State)) != 1 {
+		serv.Warnf("OidcCallback: state does not match the login request")
+		c.AbortWithStatusJSON(401, gin.H{"message": "Unauthorized"})
+		return
+	}
+
+	provider := oidc.GetProvider()
+	idToken, err := provider.Exchange(c.Request.Context(), body.Code, verifier)
+	if err != nil {
+		serv.Warnf("OidcCallback at Exchange: %v", err.Error())
+		c.AbortWithStatusJSON(401, gin.H{"message": "Unauthorized"})
+		return
+	}
+	claims, err := provider.Verify(c.Request.Context(), idToken, nonce)
+	if err != nil {
+		serv.Warnf("OidcCallback at Verify: %v", err.Error())
+		c.AbortWithStatusJSON(401, gin.H{"message": "Unauthorized"})
+		return
+	}
+	identity, err := provider.Identity(claims)
+	if err != nil {
+		serv.Warnf("OidcCallback at Identity: %v", err.Error())
+		c.AbortWithStatusJSON(401, gin.H{"message": "Unauthorized"})
+		return
+	}
+	if identity.UserType == "application" {
+		serv.Warnf("OidcCallback: user %v is mapped to an application user and can not log in", identity.Username)
+		c.AbortWithStatusJSON(401, gin.H{"message": "Unauthorized"})
+		return
+	}
+
+	user, rolesChanged, err := provider.ProvisionUser(identity, MEMPHIS_GLOBAL_ACCOUNT)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]OidcCallback at ProvisionUser: %v", MEMPHIS_GLOBAL_ACCOUNT, identity.Username, err.Error())
+		c.AbortWithStatusJSON(401, gin.H{"message": "Unauthorized"})
+		return
+	}
+	if rolesChanged {
+		SendUserDeleteCacheUpdate([]string{user.Username}, user.TenantName)
+	}
+
+	loginUser(c, user)
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestOidcStateToken(t *testing.T) {
+	stateToken, err := createOidcStateToken("state", "nonce", "verifier")
+	if err != nil {
+		t.Fatalf("createOidcStateToken: %v", err)
+	}
+	state, nonce, verifier, err := parseOidcStateToken(stateToken)
+	if err != nil || state != "state" || nonce != "nonce" || verifier != "verifier" {
+		t.Fatalf("unexpected state %v %v %v: %v", state, nonce, verifier, err)
+	}
+
+	// a state token must not verify as a memphis access token
+	_, err = jwt.Parse(stateToken, func(token *jwt.Token) (interface{}, error) {
+		return []byte(configuration.JWT_SECRET), nil
+	})
+	if err == nil {
+		t.Fatalf("expected the state token to be rejected with the access token secret")
+	}
+
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"state": "state", "nonce": "nonce", "code_verifier": "verifier"}).SignedString([]byte(configuration.JWT_SECRET))
+	if _, _, _, err = parseOidcStateToken(forged); err == nil {
+		t.Fatalf("expected a state token signed with another key to be rejected")
+	}
+}
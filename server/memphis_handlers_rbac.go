@@ -1,13 +1,20 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/memphis_cache"
 	"github.com/memphisdev/memphis/models"
+	"github.com/memphisdev/memphis/utils"
+
+	"github.com/gin-gonic/gin"
 )
 
+type RbacHandler struct{}
+
 const (
 	inboxSubject   = "_INBOX.>"
 	wildCardSuffix = ".>"
@@ -16,7 +23,6 @@ const (
 // the function returns a bool for is allowd to create and a bool for if a reload is needed
 func ValidateStationPermissions(rolesId []int, stationName, tenantName, operation string) (bool, bool, error) {
 	// if the user dosent have a role len rolesId is 0 then he allowd to create
-	// TODO: add check if denied when we allow to deny
 	if len(rolesId) == 0 {
 		neededReload, err := checkTenantPermissionsUsage(tenantName)
 		if err != nil {
@@ -24,11 +30,11 @@ func ValidateStationPermissions(rolesId []int, stationName, tenantName, operatio
 		}
 		return true, neededReload, nil
 	} else {
-		allowd, err := db.CheckUserStationPermissions(rolesId, stationName, operation)
+		permissions, err := db.GetUserPermissions(rolesId, tenantName)
 		if err != nil {
 			return false, false, err
 		}
-		if !allowd {
+		if !isStationAllowed(permissions, stationName, operation) {
 			return false, false, nil
 		}
 		return true, true, nil
@@ -44,37 +50,104 @@ func checkTenantPermissionsUsage(tenantName string) (bool, error) {
 	return reloadNeeded, nil
 }
 
-func GetUserAllowedStations(userRoles []int, tenantName string) ([]models.Station, []models.Station, error) {
-	permissions, err := db.GetUserPermissions(userRoles, tenantName)
-	if err != nil {
-		return nil, nil, err
+// matchPermissionPattern reports whether a stored permission pattern covers the station name,
+// a * in the pattern matches any sequence of characters and every other character matches itself
+func matchPermissionPattern(pattern, stationName string) bool {
+	pattern = strings.ReplaceAll(pattern, "\\", "")
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == stationName
 	}
+	if !strings.HasPrefix(stationName, parts[0]) {
+		return false
+	}
+	rest := stationName[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	last := parts[len(parts)-1]
+	return len(rest) >= len(last) && strings.HasSuffix(rest, last)
+}
 
-	var permissionsAllowReadPattern []string
-	var permissionsAllowWritePattern []string
-
+// isStationAllowed requires a matching allow rule for the operation, a matching deny rule from any of the user's roles takes precedence over it
+func isStationAllowed(permissions []models.Permission, stationName, operation string) bool {
+	allowed := false
 	for _, permission := range permissions {
+		if permission.Type != operation || !matchPermissionPattern(permission.Pattern, stationName) {
+			continue
+		}
+		if permission.RestrictionType == "deny" {
+			return false
+		}
 		if permission.RestrictionType == "allow" {
-			if permission.Type == "read" {
-				permissionsAllowReadPattern = append(permissionsAllowReadPattern, permission.Pattern)
+			allowed = true
+		}
+	}
+	return allowed
+}
 
-			} else if permission.Type == "write" {
-				permissionsAllowWritePattern = append(permissionsAllowWritePattern, permission.Pattern)
-			}
+func filterAllowedStations(permissions []models.Permission, stations []models.Station, operation string) []models.Station {
+	allowedStations := []models.Station{}
+	for _, station := range stations {
+		if isStationAllowed(permissions, station.Name, operation) {
+			allowedStations = append(allowedStations, station)
 		}
 	}
+	return allowedStations
+}
 
-	allowReadStations, err := db.GetStationsByPattern(permissionsAllowReadPattern, tenantName)
+func GetUserAllowedStations(userRoles []int, tenantName string) ([]models.Station, []models.Station, error) {
+	permissions, err := db.GetUserPermissions(userRoles, tenantName)
 	if err != nil {
 		return nil, nil, err
 	}
+	if len(permissions) == 0 {
+		return []models.Station{}, []models.Station{}, nil
+	}
 
-	allowWriteStations, err := db.GetStationsByPattern(permissionsAllowWritePattern, tenantName)
+	stations, err := db.GetActiveStationsPerTenant(tenantName)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return allowReadStations, allowWriteStations, nil
+	return filterAllowedStations(permissions, stations, "read"), filterAllowedStations(permissions, stations, "write"), nil
+}
+
+// validateStationAccess aborts the request when the user's roles do not grant the operation on the station
+func validateStationAccess(c *gin.Context, user models.User, stationName, operation, funcName string) bool {
+	allowed, _, err := ValidateStationPermissions(user.Roles, stationName, user.TenantName, operation)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]%v at ValidateStationPermissions: Station %v: %v", user.TenantName, user.Username, funcName, stationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return false
+	}
+	if !allowed {
+		errMsg := fmt.Sprintf("user %v is not allowed to %v station %v", user.Username, operation, stationName)
+		serv.Warnf("[tenant: %v][user: %v]%v: %v", user.TenantName, user.Username, funcName, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return false
+	}
+	return true
+}
+
+// validateStationsAccess checks every station before a handler changes any of them
+func validateStationsAccess(c *gin.Context, user models.User, stationNames []string, operation, funcName string) bool {
+	for _, name := range stationNames {
+		stationName, err := StationNameFromStr(name)
+		if err != nil {
+			serv.Warnf("[tenant: %v][user: %v]%v at StationNameFromStr: Station %v: %v", user.TenantName, user.Username, funcName, name, err.Error())
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+			return false
+		}
+		if !validateStationAccess(c, user, stationName.Ext(), operation, funcName) {
+			return false
+		}
+	}
+	return true
 }
 
 func GetPatternWithDots(pattern string) string {
@@ -124,10 +197,75 @@ func GetAllowedSubjectsFromRoleIds(roleIds []int, tenantName string) ([]string,
 
 	allowReadSubjects = append(allowReadSubjects, GetAllMemphisAndNatsInternalSubjects()...)
 	allowWriteSubjects = append(allowWriteSubjects, GetAllMemphisAndNatsInternalSubjects()...)
+	if len(allowdWriteStations) > 0 {
+		allowWriteSubjects = append(allowWriteSubjects, GetAllMemphisStationManagementSubjects()...)
+	}
 
 	return allowReadSubjects, allowWriteSubjects, nil
 }
 
+// GetDeniedSubjectsFromRoleIds returns the subjects of the stations a deny rule matches, the deny lists keep them closed
+// even when an internal subject or a wider allow pattern would let the connection reach them
+func GetDeniedSubjectsFromRoleIds(roleIds []int, tenantName string, allowReadSubjects, allowWriteSubjects []string) ([]string, []string, error) {
+	permissions, err := db.GetUserPermissions(roleIds, tenantName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(permissions) == 0 {
+		return []string{}, []string{}, nil
+	}
+	stations, err := db.GetActiveStationsPerTenant(tenantName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var denyReadSubjects []string
+	var denyWriteSubjects []string
+	for _, station := range filterDeniedStations(permissions, stations, "read") {
+		for _, partition := range station.PartitionsList {
+			partitionStream := fmt.Sprintf("%v$%v.>", replaceDelimiters(station.Name), partition)
+			denyReadSubjects = append(denyReadSubjects, GetAllowReadSubscribeInternalSubjects(partitionStream)...)
+			denyWriteSubjects = append(denyWriteSubjects, GetAllowReadPublishInternalSbjects(partitionStream)...)
+		}
+	}
+	for _, station := range filterDeniedStations(permissions, stations, "write") {
+		for _, partition := range station.PartitionsList {
+			partitionStream := fmt.Sprintf("%v$%v.>", replaceDelimiters(station.Name), partition)
+			denyWriteSubjects = append(denyWriteSubjects, GetAllowWritePublishInternalSubjects(partitionStream)...)
+		}
+	}
+
+	// a subject the other operation still needs, like the stream info, stays open
+	return subtractSubjects(denyReadSubjects, allowReadSubjects), subtractSubjects(denyWriteSubjects, allowWriteSubjects), nil
+}
+
+func filterDeniedStations(permissions []models.Permission, stations []models.Station, operation string) []models.Station {
+	deniedStations := []models.Station{}
+	for _, station := range stations {
+		for _, permission := range permissions {
+			if permission.Type == operation && permission.RestrictionType == "deny" && matchPermissionPattern(permission.Pattern, station.Name) {
+				deniedStations = append(deniedStations, station)
+				break
+			}
+		}
+	}
+	return deniedStations
+}
+
+func subtractSubjects(subjects, remove []string) []string {
+	removed := map[string]bool{}
+	for _, subject := range remove {
+		removed[subject] = true
+	}
+	result := []string{}
+	for _, subject := range subjects {
+		if !removed[subject] {
+			result = append(result, subject)
+		}
+	}
+	return result
+}
+
 func GetAllMemphisAndNatsInternalSubjects() []string {
 	var subjects []string
 
@@ -154,6 +292,19 @@ func GetAllMemphisAndNatsInternalSubjects() []string {
 	return subjects
 }
 
+// GetAllMemphisStationManagementSubjects are the requests that change a station or its consumer groups,
+// only connections that may write to some station can publish them and the handlers check the station itself
+func GetAllMemphisStationManagementSubjects() []string {
+	var subjects []string
+
+	subjects = append(subjects, memphisStationPartitions)
+	subjects = append(subjects, memphisCgOffsetResets)
+	subjects = append(subjects, memphisCgPauses)
+	subjects = append(subjects, memphisCgResumes)
+
+	return subjects
+}
+
 func GetAllMemphisStationInternalSubjects(stationName string) []string {
 	var subjects []string
 
@@ -201,3 +352,591 @@ func GetAllowWritePublishInternalSubjects(partitionStream string) []string {
 
 	return subjects
 }
+
+func validateRoleType(roleType string) error {
+	if roleType != "management" && roleType != "application" {
+		return errors.New("role type has to be one of the following management/application")
+	}
+	return nil
+}
+
+func validateRoleName(name, tenantName string) error {
+	// a user created with permissions gets a role named after it, which is removed together with the user
+	exist, _, err := memphis_cache.GetUser(strings.ToLower(name), tenantName, false)
+	if err != nil {
+		return err
+	}
+	if exist {
+		return fmt.Errorf("role name %v is already used by a user", name)
+	}
+	return nil
+}
+
+func getRoleRes(role models.Role) (models.RoleRes, error) {
+	permissions, err := db.GetPermissionsByRoleIdAndTenant(role.ID, role.TenantName)
+	if err != nil {
+		return models.RoleRes{}, err
+	}
+	for i := range permissions {
+		permissions[i].Pattern = strings.ReplaceAll(permissions[i].Pattern, "\\", "")
+	}
+	users, err := db.GetUsersByRoleId(role.ID, role.TenantName)
+	if err != nil {
+		return models.RoleRes{}, err
+	}
+	usernames := []string{}
+	for _, u := range users {
+		usernames = append(usernames, u.Username)
+	}
+	return models.RoleRes{
+		ID:          role.ID,
+		Name:        role.Name,
+		Type:        role.Type,
+		Permissions: permissions,
+		Users:       usernames,
+	}, nil
+}
+
+// applyRolesChange drops the cached users holding a changed role and reloads the
+// broker accounts when application users are affected, so connected clients get the new permissions
+func applyRolesChange(users []models.User, tenantName string) error {
+	if len(users) == 0 {
+		return nil
+	}
+	var usernames []string
+	reloadNeeded := false
+	for _, u := range users {
+		usernames = append(usernames, u.Username)
+		if u.UserType == "application" {
+			reloadNeeded = true
+		}
+	}
+	SendUserDeleteCacheUpdate(usernames, tenantName)
+	if reloadNeeded && configuration.USER_PASS_BASED_AUTH {
+		return serv.SendReloadSignal()
+	}
+	return nil
+}
+
+// getUserOwnRoles returns the roles created implicitly for a user when it was added with permissions
+func getUserOwnRoles(user models.User) ([]int, error) {
+	if len(user.Roles) == 0 {
+		return []int{}, nil
+	}
+	roles, err := db.GetRolesByIds(user.Roles, user.TenantName)
+	if err != nil {
+		return []int{}, err
+	}
+	ownRoles := []int{}
+	for _, role := range roles {
+		if role.Name == user.Username {
+			ownRoles = append(ownRoles, role.ID)
+		}
+	}
+	return ownRoles, nil
+}
+
+// roles and permissions can only be managed by the root user and management users that no role restricts
+func isRbacAdmin(user models.User) bool {
+	return user.UserType == "root" || (user.UserType == "management" && len(user.Roles) == 0)
+}
+
+func rbacAdminOnly(c *gin.Context, user models.User, funcName string) bool {
+	if isRbacAdmin(user) {
+		return true
+	}
+	serv.Warnf("[tenant: %v][user: %v]%v: only admin users can manage roles and permissions", user.TenantName, user.Username, funcName)
+	c.AbortWithStatusJSON(403, gin.H{"message": "Only admin users can manage roles and permissions"})
+	return false
+}
+
+func (rh RbacHandler) CreateRole(c *gin.Context) {
+	var body models.CreateRoleSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("CreateRole: Role %v: %v", body.Name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !rbacAdminOnly(c, user, "CreateRole") {
+		return
+	}
+
+	roleName := strings.TrimSpace(body.Name)
+	roleType := strings.ToLower(body.Type)
+	err = validateRoleType(roleType)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]CreateRole: Role %v: %v", user.TenantName, user.Username, roleName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+	err = validateRoleName(roleName, user.TenantName)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]CreateRole at validateRoleName: Role %v: %v", user.TenantName, user.Username, roleName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+
+	internalPermissions, err := InternalPermissions(models.Permissions{
+		AllowReadPermissions:  body.AllowReadPermissions,
+		AllowWritePermissions: body.AllowWritePermissions,
+		DenyReadPermissions:   body.DenyReadPermissions,
+		DenyWritePermissions:  body.DenyWritePermissions,
+	})
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]CreateRole at InternalPermissions: Role %v: %v", user.TenantName, user.Username, roleName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+
+	role, _, err := db.CreateNewRole(roleName, strings.ToLower(user.TenantName), roleType, internalPermissions.AllowReadPermissions, internalPermissions.AllowWritePermissions, internalPermissions.DenyReadPermissions, internalPermissions.DenyWritePermissions)
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			serv.Warnf("[tenant: %v][user: %v]CreateRole: Role %v already exists", user.TenantName, user.Username, roleName)
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Role " + roleName + " already exists"})
+			return
+		}
+		serv.Errorf("[tenant: %v][user: %v]CreateRole at CreateNewRole: Role %v: %v", user.TenantName, user.Username, roleName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	roleRes, err := getRoleRes(role)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]CreateRole at getRoleRes: Role %v: %v", user.TenantName, user.Username, roleName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	serv.Noticef("[tenant: %v][user: %v]Role %v has been created", user.TenantName, user.Username, roleName)
+	c.IndentedJSON(200, roleRes)
+}
+
+func (rh RbacHandler) GetRoles(c *gin.Context) {
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("GetRoles: %v", err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	roles, err := db.GetRolesByTenant(user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]GetRoles at GetRolesByTenant: %v", user.TenantName, user.Username, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	rolesRes := []models.RoleRes{}
+	for _, role := range roles {
+		roleRes, err := getRoleRes(role)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]GetRoles at getRoleRes: Role %v: %v", user.TenantName, user.Username, role.Name, err.Error())
+			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+			return
+		}
+		rolesRes = append(rolesRes, roleRes)
+	}
+
+	c.IndentedJSON(200, rolesRes)
+}
+
+func (rh RbacHandler) GetRole(c *gin.Context) {
+	var body models.GetRoleSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("GetRole: Role %v: %v", body.RoleID, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	exist, role, err := db.GetRoleById(body.RoleID, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]GetRole at GetRoleById: Role %v: %v", user.TenantName, user.Username, body.RoleID, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		serv.Warnf("[tenant: %v][user: %v]GetRole: Role %v does not exist", user.TenantName, user.Username, body.RoleID)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Role does not exist"})
+		return
+	}
+
+	roleRes, err := getRoleRes(role)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]GetRole at getRoleRes: Role %v: %v", user.TenantName, user.Username, role.Name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	c.IndentedJSON(200, roleRes)
+}
+
+func (rh RbacHandler) UpdateRole(c *gin.Context) {
+	var body models.UpdateRoleSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("UpdateRole: Role %v: %v", body.RoleID, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !rbacAdminOnly(c, user, "UpdateRole") {
+		return
+	}
+
+	exist, role, err := db.GetRoleById(body.RoleID, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]UpdateRole at GetRoleById: Role %v: %v", user.TenantName, user.Username, body.RoleID, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		serv.Warnf("[tenant: %v][user: %v]UpdateRole: Role %v does not exist", user.TenantName, user.Username, body.RoleID)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Role does not exist"})
+		return
+	}
+
+	roleName := strings.TrimSpace(body.Name)
+	if roleName != role.Name {
+		err = validateRoleName(roleName, user.TenantName)
+		if err != nil {
+			serv.Warnf("[tenant: %v][user: %v]UpdateRole at validateRoleName: Role %v: %v", user.TenantName, user.Username, roleName, err.Error())
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+			return
+		}
+		err = db.UpdateRoleName(role.ID, user.TenantName, roleName)
+		if err != nil {
+			if strings.Contains(err.Error(), "already exists") {
+				serv.Warnf("[tenant: %v][user: %v]UpdateRole: %v", user.TenantName, user.Username, err.Error())
+				c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Role " + roleName + " already exists"})
+				return
+			}
+			serv.Errorf("[tenant: %v][user: %v]UpdateRole at UpdateRoleName: Role %v: %v", user.TenantName, user.Username, role.Name, err.Error())
+			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+			return
+		}
+		serv.Noticef("[tenant: %v][user: %v]Role %v has been renamed to %v", user.TenantName, user.Username, role.Name, roleName)
+		role.Name = roleName
+	}
+
+	roleRes, err := getRoleRes(role)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]UpdateRole at getRoleRes: Role %v: %v", user.TenantName, user.Username, role.Name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	c.IndentedJSON(200, roleRes)
+}
+
+func (rh RbacHandler) RemoveRole(c *gin.Context) {
+	var body models.RemoveRoleSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("RemoveRole: Role %v: %v", body.RoleID, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !rbacAdminOnly(c, user, "RemoveRole") {
+		return
+	}
+
+	exist, role, err := db.GetRoleById(body.RoleID, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RemoveRole at GetRoleById: Role %v: %v", user.TenantName, user.Username, body.RoleID, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		serv.Warnf("[tenant: %v][user: %v]RemoveRole: Role %v does not exist", user.TenantName, user.Username, body.RoleID)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Role does not exist"})
+		return
+	}
+
+	users, err := db.GetUsersByRoleId(role.ID, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RemoveRole at GetUsersByRoleId: Role %v: %v", user.TenantName, user.Username, role.Name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	err = db.RemoveRoleFromUsers(role.ID, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RemoveRole at RemoveRoleFromUsers: Role %v: %v", user.TenantName, user.Username, role.Name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	err = db.RemoveRoleAndPermissions([]int{role.ID}, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RemoveRole at RemoveRoleAndPermissions: Role %v: %v", user.TenantName, user.Username, role.Name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	err = applyRolesChange(users, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RemoveRole at applyRolesChange: Role %v: %v", user.TenantName, user.Username, role.Name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	serv.Noticef("[tenant: %v][user: %v]Role %v has been removed", user.TenantName, user.Username, role.Name)
+	c.IndentedJSON(200, gin.H{})
+}
+
+func (rh RbacHandler) AddPermissions(c *gin.Context) {
+	var body models.AddPermissionsSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("AddPermissions: Role %v: %v", body.RoleID, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !rbacAdminOnly(c, user, "AddPermissions") {
+		return
+	}
+
+	if len(body.AllowReadPermissions)+len(body.AllowWritePermissions)+len(body.DenyReadPermissions)+len(body.DenyWritePermissions) == 0 {
+		serv.Warnf("[tenant: %v][user: %v]AddPermissions: Role %v: no permissions were provided", user.TenantName, user.Username, body.RoleID)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "No permissions were provided"})
+		return
+	}
+	internalPermissions, err := InternalPermissions(models.Permissions{
+		AllowReadPermissions:  body.AllowReadPermissions,
+		AllowWritePermissions: body.AllowWritePermissions,
+		DenyReadPermissions:   body.DenyReadPermissions,
+		DenyWritePermissions:  body.DenyWritePermissions,
+	})
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]AddPermissions at InternalPermissions: Role %v: %v", user.TenantName, user.Username, body.RoleID, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+
+	exist, role, err := db.GetRoleById(body.RoleID, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]AddPermissions at GetRoleById: Role %v: %v", user.TenantName, user.Username, body.RoleID, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		serv.Warnf("[tenant: %v][user: %v]AddPermissions: Role %v does not exist", user.TenantName, user.Username, body.RoleID)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Role does not exist"})
+		return
+	}
+
+	err = db.InsertPermissions(internalPermissions.AllowReadPermissions, internalPermissions.AllowWritePermissions, internalPermissions.DenyReadPermissions, internalPermissions.DenyWritePermissions, role.ID, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]AddPermissions at InsertPermissions: Role %v: %v", user.TenantName, user.Username, role.Name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	roleRes, err := rh.applyPermissionsChange(role)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]AddPermissions at applyPermissionsChange: Role %v: %v", user.TenantName, user.Username, role.Name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	serv.Noticef("[tenant: %v][user: %v]Permissions have been added to role %v", user.TenantName, user.Username, role.Name)
+	c.IndentedJSON(200, roleRes)
+}
+
+func (rh RbacHandler) RemovePermissions(c *gin.Context) {
+	var body models.RemovePermissionsSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("RemovePermissions: Role %v: %v", body.RoleID, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !rbacAdminOnly(c, user, "RemovePermissions") {
+		return
+	}
+
+	exist, role, err := db.GetRoleById(body.RoleID, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RemovePermissions at GetRoleById: Role %v: %v", user.TenantName, user.Username, body.RoleID, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		serv.Warnf("[tenant: %v][user: %v]RemovePermissions: Role %v does not exist", user.TenantName, user.Username, body.RoleID)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Role does not exist"})
+		return
+	}
+
+	removed, err := db.RemovePermissionsByIds(role.ID, body.PermissionIDs, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RemovePermissions at RemovePermissionsByIds: Role %v: %v", user.TenantName, user.Username, role.Name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if removed == 0 {
+		serv.Warnf("[tenant: %v][user: %v]RemovePermissions: Role %v does not have the given permissions", user.TenantName, user.Username, role.Name)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Permissions do not exist in role " + role.Name})
+		return
+	}
+
+	roleRes, err := rh.applyPermissionsChange(role)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RemovePermissions at applyPermissionsChange: Role %v: %v", user.TenantName, user.Username, role.Name, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	serv.Noticef("[tenant: %v][user: %v]Permissions have been removed from role %v", user.TenantName, user.Username, role.Name)
+	c.IndentedJSON(200, roleRes)
+}
+
+func (rh RbacHandler) applyPermissionsChange(role models.Role) (models.RoleRes, error) {
+	users, err := db.GetUsersByRoleId(role.ID, role.TenantName)
+	if err != nil {
+		return models.RoleRes{}, err
+	}
+	err = applyRolesChange(users, role.TenantName)
+	if err != nil {
+		return models.RoleRes{}, err
+	}
+	return getRoleRes(role)
+}
+
+func (rh RbacHandler) AssignRoles(c *gin.Context) {
+	rh.updateUserRoles(c, true)
+}
+
+func (rh RbacHandler) UnassignRoles(c *gin.Context) {
+	rh.updateUserRoles(c, false)
+}
+
+func (rh RbacHandler) updateUserRoles(c *gin.Context, assign bool) {
+	var body models.AssignRolesSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	funcName := "UnassignRoles"
+	if assign {
+		funcName = "AssignRoles"
+	}
+	username := strings.ToLower(body.Username)
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("%v: User %v: %v", funcName, username, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !rbacAdminOnly(c, user, funcName) {
+		return
+	}
+
+	exist, userToUpdate, err := memphis_cache.GetUser(username, user.TenantName, true)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]%v at GetUser: User %v: %v", user.TenantName, user.Username, funcName, username, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		serv.Warnf("[tenant: %v][user: %v]%v: User %v does not exist", user.TenantName, user.Username, funcName, username)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "User does not exist"})
+		return
+	}
+	if userToUpdate.UserType == "root" {
+		serv.Warnf("[tenant: %v][user: %v]%v: roles can not be assigned to the root user", user.TenantName, user.Username, funcName)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Roles can not be assigned to the root user"})
+		return
+	}
+
+	roles, err := db.GetRolesByIds(body.RoleIDs, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]%v at GetRolesByIds: User %v: %v", user.TenantName, user.Username, funcName, username, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if len(roles) != len(uniqueRoleIds(body.RoleIDs)) {
+		serv.Warnf("[tenant: %v][user: %v]%v: User %v: some of the roles do not exist", user.TenantName, user.Username, funcName, username)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Some of the roles do not exist"})
+		return
+	}
+
+	var userRoles []int
+	if assign {
+		for _, role := range roles {
+			if role.Type != userToUpdate.UserType {
+				errMsg := fmt.Sprintf("role %v is a %v role and can not be assigned to a %v user", role.Name, role.Type, userToUpdate.UserType)
+				serv.Warnf("[tenant: %v][user: %v]%v: User %v: %v", user.TenantName, user.Username, funcName, username, errMsg)
+				c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+				return
+			}
+		}
+		userRoles = uniqueRoleIds(append(userToUpdate.Roles, body.RoleIDs...))
+	} else {
+		userRoles = removeRoleIds(userToUpdate.Roles, body.RoleIDs)
+	}
+
+	err = db.UpdateUserRole(user.TenantName, username, userRoles)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]%v at UpdateUserRole: User %v: %v", user.TenantName, user.Username, funcName, username, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	err = applyRolesChange([]models.User{userToUpdate}, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]%v at applyRolesChange: User %v: %v", user.TenantName, user.Username, funcName, username, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	serv.Noticef("[tenant: %v][user: %v]Roles of user %v have been updated", user.TenantName, user.Username, username)
+	c.IndentedJSON(200, gin.H{"username": username, "roles": userRoles})
+}
+
+func uniqueRoleIds(roleIDs []int) []int {
+	seen := map[int]bool{}
+	unique := []int{}
+	for _, id := range roleIDs {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+func removeRoleIds(roleIDs, toRemove []int) []int {
+	remove := map[int]bool{}
+	for _, id := range toRemove {
+		remove[id] = true
+	}
+	remaining := []int{}
+	for _, id := range roleIDs {
+		if !remove[id] {
+			remaining = append(remaining, id)
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/memphisdev/memphis/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpdateUserRoleIds(t *testing.T) {
+	assigned := uniqueRoleIds(append([]int{1, 2}, 2, 3, 1))
+	if !reflect.DeepEqual(assigned, []int{1, 2, 3}) {
+		t.Fatalf("expected [1 2 3], got %v", assigned)
+	}
+	remaining := removeRoleIds(assigned, []int{2, 4})
+	if !reflect.DeepEqual(remaining, []int{1, 3}) {
+		t.Fatalf("expected [1 3], got %v", remaining)
+	}
+	if remaining = removeRoleIds(remaining, []int{1, 3}); len(remaining) != 0 || remaining == nil {
+		t.Fatalf("expected an empty non nil roles list, got %v", remaining)
+	}
+}
+
+func rbacTestPermission(pattern, operation, restriction string) models.Permission {
+	// patterns are stored the way the handlers save them
+	return models.Permission{Pattern: GetPatternWithDots(pattern), Type: operation, RestrictionType: restriction}
+}
+
+func TestMatchPermissionPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		station string
+		match   bool
+	}{
+		{"orders", "orders", true},
+		{"orders", "orders.eu", false},
+		{"orders.*", "orders.eu", true},
+		{"orders.*", "orders.eu.paris", true},
+		{"orders.*", "orders", false},
+		{"orders.*", "my.orders.eu", false},
+		{"*.eu", "orders.eu", true},
+		{"*.eu", "orders.eu.paris", false},
+		{"*", "anything", true},
+		{"ord*s", "orders", true},
+		{"ord*s", "ordersx", false},
+		{"o*d*s", "orders", true},
+		{"o*s*s", "orders", false},
+		{"ord?rs", "orders", false},
+		{"ord?rs", "ord?rs", true},
+		{"[a-z]*", "orders", false},
+		{"orders[", "orders[", true},
+	}
+	for _, tc := range cases {
+		if got := matchPermissionPattern(GetPatternWithDots(tc.pattern), tc.station); got != tc.match {
+			t.Errorf("pattern %v on station %v: expected %v, got %v", tc.pattern, tc.station, tc.match, got)
+		}
+	}
+}
+
+func TestIsStationAllowedDenyPrecedence(t *testing.T) {
+	permissions := []models.Permission{
+		rbacTestPermission("orders.*", "read", "allow"),
+		rbacTestPermission("orders.eu.*", "read", "deny"),
+		rbacTestPermission("*", "write", "allow"),
+		rbacTestPermission("*.audit", "write", "deny"),
+		rbacTestPermission("payments", "read", "allow"),
+		// another role of the user denies what the first one allows
+		rbacTestPermission("payments", "read", "deny"),
+	}
+	cases := []struct {
+		station   string
+		operation string
+		allowed   bool
+	}{
+		{"orders.us", "read", true},
+		{"orders.eu.paris", "read", false},
+		{"orders.eu", "read", true},
+		{"inventory", "read", false},
+		{"payments", "read", false},
+		{"orders.eu.paris", "write", true},
+		{"orders.audit", "write", false},
+		{"payments", "write", true},
+	}
+	for _, tc := range cases {
+		if got := isStationAllowed(permissions, tc.station, tc.operation); got != tc.allowed {
+			t.Errorf("%v on station %v: expected %v, got %v", tc.operation, tc.station, tc.allowed, got)
+		}
+	}
+
+	// a deny only role does not grant anything by itself
+	denyOnly := []models.Permission{rbacTestPermission("orders.eu.*", "read", "deny")}
+	if isStationAllowed(denyOnly, "orders.us", "read") {
+		t.Fatalf("expected a deny only role to not allow reading")
+	}
+}
+
+func TestFilterAllowedStations(t *testing.T) {
+	permissions := []models.Permission{
+		rbacTestPermission("orders.*", "read", "allow"),
+		rbacTestPermission("orders.eu.*", "read", "deny"),
+		rbacTestPermission("orders.eu.*", "write", "allow"),
+	}
+	stations := []models.Station{{Name: "orders.us"}, {Name: "orders.eu.paris"}, {Name: "inventory"}}
+
+	read := filterAllowedStations(permissions, stations, "read")
+	if len(read) != 1 || read[0].Name != "orders.us" {
+		t.Fatalf("expected only orders.us to be readable, got %v", read)
+	}
+	write := filterAllowedStations(permissions, stations, "write")
+	if len(write) != 1 || write[0].Name != "orders.eu.paris" {
+		t.Fatalf("expected only orders.eu.paris to be writable, got %v", write)
+	}
+}
+
+func TestFilterDeniedStations(t *testing.T) {
+	permissions := []models.Permission{
+		rbacTestPermission("orders.*", "read", "allow"),
+		rbacTestPermission("orders.eu.*", "read", "deny"),
+		rbacTestPermission("inventory", "write", "deny"),
+	}
+	stations := []models.Station{{Name: "orders.us"}, {Name: "orders.eu.paris"}, {Name: "inventory"}}
+
+	read := filterDeniedStations(permissions, stations, "read")
+	if len(read) != 1 || read[0].Name != "orders.eu.paris" {
+		t.Fatalf("expected only orders.eu.paris to be denied for reading, got %v", read)
+	}
+	write := filterDeniedStations(permissions, stations, "write")
+	if len(write) != 1 || write[0].Name != "inventory" {
+		t.Fatalf("expected only inventory to be denied for writing, got %v", write)
+	}
+
+	subjects := subtractSubjects([]string{"a", "b", "c"}, []string{"b"})
+	if !reflect.DeepEqual(subjects, []string{"a", "c"}) {
+		t.Fatalf("expected subjects still allowed to be removed from the deny list, got %v", subjects)
+	}
+}
+
+func TestRbacHandlersRejectNonAdminUsers(t *testing.T) {
+	if serv == nil {
+		serv = &Server{}
+		defer func() { serv = nil }()
+	}
+	gin.SetMode(gin.TestMode)
+	rh := RbacHandler{}
+	cases := []struct {
+		name    string
+		handler gin.HandlerFunc
+		body    string
+	}{
+		{"CreateRole", rh.CreateRole, `{"name":"devs","type":"management"}`},
+		{"UpdateRole", rh.UpdateRole, `{"role_id":1,"name":"devs"}`},
+		{"RemoveRole", rh.RemoveRole, `{"role_id":1}`},
+		{"AddPermissions", rh.AddPermissions, `{"role_id":1}`},
+		{"RemovePermissions", rh.RemovePermissions, `{"role_id":1,"permission_ids":[1]}`},
+		{"AssignRoles", rh.AssignRoles, `{"username":"dev","role_ids":[1]}`},
+		{"UnassignRoles", rh.UnassignRoles, `{"username":"dev","role_ids":[1]}`},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set("user", models.User{Username: "dev", UserType: "management", Roles: []int{1}, TenantName: "$memphis"})
+		tc.handler(c)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("%v: expected a non admin user to get %v, got %v: %v", tc.name, http.StatusForbidden, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestIsRbacAdmin(t *testing.T) {
+	if !isRbacAdmin(models.User{UserType: "root"}) {
+		t.Fatalf("expected the root user to be an admin")
+	}
+	if !isRbacAdmin(models.User{UserType: "management"}) {
+		t.Fatalf("expected a management user without roles to be an admin")
+	}
+	if isRbacAdmin(models.User{UserType: "management", Roles: []int{1}}) {
+		t.Fatalf("expected a management user restricted by roles not to be an admin")
+	}
+	if isRbacAdmin(models.User{UserType: "application"}) {
+		t.Fatalf("expected an application user not to be an admin")
+	}
+}
+
+func TestStationManagementSubjectsNotGrantedByDefault(t *testing.T) {
+	defaults := GetAllMemphisAndNatsInternalSubjects()
+	for _, subject := range GetAllMemphisStationManagementSubjects() {
+		for _, d := range defaults {
+			if d == subject {
+				t.Fatalf("%v is granted to every restricted connection", subject)
+			}
+		}
+	}
+}
+
+func TestInternalPermissionsRejectsMalformedPatterns(t *testing.T) {
+	for _, pattern := range []string{"ord?rs", "[a-z]*", "orders[", "orders\\.eu", ""} {
+		if _, err := InternalPermissions(models.Permissions{AllowReadPermissions: []string{pattern}}); err == nil {
+			t.Errorf("expected pattern %q to be rejected", pattern)
+		}
+	}
+	if _, err := InternalPermissions(models.Permissions{DenyWritePermissions: []string{"orders.*"}}); err != nil {
+		t.Fatalf("expected a valid pattern to be accepted: %v", err)
+	}
+}
+
+func TestValidateStationsAccessRejectsInvalidNames(t *testing.T) {
+	if serv == nil {
+		serv = &Server{}
+		defer func() { serv = nil }()
+	}
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	user := models.User{Username: "dev", UserType: "management", Roles: []int{1}, TenantName: "$memphis"}
+	if validateStationsAccess(c, user, []string{"orders#eu"}, "write", "AttachDlsStation") {
+		t.Fatalf("expected an invalid station name to be rejected")
+	}
+	if w.Code != SHOWABLE_ERROR_STATUS_CODE {
+		t.Fatalf("expected %v, got %v", SHOWABLE_ERROR_STATUS_CODE, w.Code)
+	}
+}
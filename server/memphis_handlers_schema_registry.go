@@ -0,0 +1,693 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SchemaRegistryHandler exposes Schemaverse through the Confluent Schema Registry REST protocol,
+// subjects are mapped to schema names and global schema ids to schema version ids
+type SchemaRegistryHandler struct{ S *Server }
+
+const (
+	schemaRegistrySubjectNotFound      = 40401
+	schemaRegistryVersionNotFound      = 40402
+	schemaRegistrySchemaNotFound       = 40403
+	schemaRegistryIncompatibleSchema   = 409
+	schemaRegistryInvalidSchema        = 42201
+	schemaRegistryInvalidVersion       = 42202
+	schemaRegistryInvalidCompatibility = 42203
+	schemaRegistryReferenceExists      = 42206
+	schemaRegistryServerError          = 50001
+)
+
+// the tenant default compatibility, subjects created through the registry start with it
+const schemaRegistryCompatibilityConfigKey = "schema_registry_compatibility"
+
+func schemaRegistryError(c *gin.Context, errorCode int, message string) {
+	status := errorCode
+	for status >= 1000 {
+		status /= 10
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error_code": errorCode, "message": message})
+}
+
+func toSchemaRegistryType(schemaType string) string {
+	return strings.ToUpper(schemaType)
+}
+
+func fromSchemaRegistryType(schemaType string) string {
+	if schemaType == _EMPTY_ {
+		return "avro"
+	}
+	return strings.ToLower(schemaType)
+}
+
+func toSchemaRegistryReferences(references []models.SchemaReference) []models.SchemaRegistryReference {
+	registryReferences := []models.SchemaRegistryReference{}
+	for _, ref := range references {
+		registryReferences = append(registryReferences, models.SchemaRegistryReference{Name: ref.Name, Subject: ref.SchemaName, Version: ref.VersionNumber})
+	}
+	return registryReferences
+}
+
+func fromSchemaRegistryReferences(registryReferences []models.SchemaRegistryReference) []models.SchemaReference {
+	references := []models.SchemaReference{}
+	for _, ref := range registryReferences {
+		references = append(references, models.SchemaReference{Name: ref.Name, SchemaName: strings.ToLower(ref.Subject), VersionNumber: ref.Version})
+	}
+	return references
+}
+
+func getSortedSchemaVersions(schemaId int) ([]models.SchemaVersion, error) {
+	versions, err := getSchemaVersionsBySchemaId(schemaId)
+	if err != nil {
+		return []models.SchemaVersion{}, err
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].VersionNumber < versions[j].VersionNumber
+	})
+	return versions, nil
+}
+
+func findSchemaVersionByContent(versions []models.SchemaVersion, schemaContent string) (models.SchemaVersion, bool) {
+	for _, v := range versions {
+		if v.SchemaContent == schemaContent {
+			return v, true
+		}
+	}
+	return models.SchemaVersion{}, false
+}
+
+func getSchemaRegistryCompatibility(tenantName string) (string, error) {
+	exist, config, err := db.GetSystemKey(schemaRegistryCompatibilityConfigKey, tenantName)
+	if err != nil {
+		return _EMPTY_, err
+	}
+	if !exist {
+		return models.SchemaCompatibilityNone, nil
+	}
+	return getSchemaCompatibility(config.Value), nil
+}
+
+func (sh SchemaRegistryHandler) getSubject(c *gin.Context, user models.User) (models.Schema, bool) {
+	subject := strings.ToLower(c.Param("subject"))
+	exist, schema, err := db.GetSchemaByName(subject, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]SchemaRegistry at GetSchemaByName: Subject %v: %v", user.TenantName, user.Username, subject, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return models.Schema{}, false
+	}
+	if !exist {
+		schemaRegistryError(c, schemaRegistrySubjectNotFound, fmt.Sprintf("Subject '%v' not found.", subject))
+		return models.Schema{}, false
+	}
+	return schema, true
+}
+
+// getSubjectVersion resolves a version param which is either a positive version number, "latest" or -1
+func (sh SchemaRegistryHandler) getSubjectVersion(c *gin.Context, user models.User, schema models.Schema) (models.SchemaVersion, bool) {
+	versionParam := c.Param("version")
+	if versionParam == "latest" || versionParam == "-1" {
+		versions, err := getSortedSchemaVersions(schema.ID)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]SchemaRegistry at getSortedSchemaVersions: Subject %v: %v", user.TenantName, user.Username, schema.Name, err.Error())
+			schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+			return models.SchemaVersion{}, false
+		}
+		if len(versions) == 0 {
+			schemaRegistryError(c, schemaRegistryVersionNotFound, fmt.Sprintf("Version %v not found.", versionParam))
+			return models.SchemaVersion{}, false
+		}
+		return versions[len(versions)-1], true
+	}
+
+	versionNumber, err := strconv.Atoi(versionParam)
+	if err != nil || versionNumber <= 0 {
+		schemaRegistryError(c, schemaRegistryInvalidVersion, fmt.Sprintf("The specified version '%v' is not a valid version id. Allowed values are between [1, 2^31-1] and the string \"latest\"", versionParam))
+		return models.SchemaVersion{}, false
+	}
+	exist, version, err := db.GetSchemaVersionByNumberAndID(versionNumber, schema.ID)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]SchemaRegistry at GetSchemaVersionByNumberAndID: Subject %v: %v", user.TenantName, user.Username, schema.Name, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return models.SchemaVersion{}, false
+	}
+	if !exist {
+		schemaRegistryError(c, schemaRegistryVersionNotFound, fmt.Sprintf("Version %v not found.", versionNumber))
+		return models.SchemaVersion{}, false
+	}
+	version.References, err = db.GetSchemaVersionReferences(version.ID)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]SchemaRegistry at GetSchemaVersionReferences: Subject %v: %v", user.TenantName, user.Username, schema.Name, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return models.SchemaVersion{}, false
+	}
+	return version, true
+}
+
+// getCandidateVersion validates a schema sent by a registry client and builds a version out of it
+func getCandidateVersion(subject string, body models.SchemaRegistrySchema, schemaType string, tenantName string) (models.SchemaVersion, error) {
+	err := validateSchemaType(schemaType)
+	if err != nil {
+		return models.SchemaVersion{}, err
+	}
+	references := fromSchemaRegistryReferences(body.References)
+	resolvedReferences, err := validateSchemaReferences(subject, schemaType, references, tenantName)
+	if err != nil {
+		return models.SchemaVersion{}, err
+	}
+	err = validateSchemaContent(body.Schema, schemaType, resolvedReferences)
+	if err != nil {
+		return models.SchemaVersion{}, err
+	}
+	messageStructName := _EMPTY_
+	if schemaType == "protobuf" {
+		messageStructName, err = getProtoMessageStructName(body.Schema, resolvedReferences)
+		if err != nil {
+			return models.SchemaVersion{}, err
+		}
+	}
+	return models.SchemaVersion{SchemaContent: body.Schema, MessageStructName: messageStructName, TenantName: tenantName, References: references}, nil
+}
+
+func (sh SchemaRegistryHandler) GetSubjects(c *gin.Context) {
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("GetSubjects at getUserDetailsFromMiddleware: %v", err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	schemas, err := db.GetAllSchemasDetails(user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]GetSubjects at GetAllSchemasDetails: %v", user.TenantName, user.Username, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	subjects := []string{}
+	for _, schema := range schemas {
+		subjects = append(subjects, schema.Name)
+	}
+	sort.Strings(subjects)
+
+	c.IndentedJSON(200, subjects)
+}
+
+func (sh SchemaRegistryHandler) GetSubjectVersions(c *gin.Context) {
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("GetSubjectVersions at getUserDetailsFromMiddleware: %v", err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	schema, ok := sh.getSubject(c, user)
+	if !ok {
+		return
+	}
+	versions, err := getSortedSchemaVersions(schema.ID)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]GetSubjectVersions at getSortedSchemaVersions: Subject %v: %v", user.TenantName, user.Username, schema.Name, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	versionNumbers := []int{}
+	for _, v := range versions {
+		versionNumbers = append(versionNumbers, v.VersionNumber)
+	}
+
+	c.IndentedJSON(200, versionNumbers)
+}
+
+func (sh SchemaRegistryHandler) GetSubjectVersion(c *gin.Context) {
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("GetSubjectVersion at getUserDetailsFromMiddleware: %v", err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	schema, ok := sh.getSubject(c, user)
+	if !ok {
+		return
+	}
+	version, ok := sh.getSubjectVersion(c, user, schema)
+	if !ok {
+		return
+	}
+
+	c.IndentedJSON(200, models.SchemaRegistrySubjectVersion{
+		Subject:    schema.Name,
+		ID:         version.ID,
+		Version:    version.VersionNumber,
+		Schema:     version.SchemaContent,
+		SchemaType: toSchemaRegistryType(schema.Type),
+		References: toSchemaRegistryReferences(version.References),
+	})
+}
+
+func (sh SchemaRegistryHandler) GetSubjectVersionSchema(c *gin.Context) {
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("GetSubjectVersionSchema at getUserDetailsFromMiddleware: %v", err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	schema, ok := sh.getSubject(c, user)
+	if !ok {
+		return
+	}
+	version, ok := sh.getSubjectVersion(c, user, schema)
+	if !ok {
+		return
+	}
+
+	c.String(200, version.SchemaContent)
+}
+
+func (sh SchemaRegistryHandler) RegisterSchema(c *gin.Context) {
+	var body models.SchemaRegistrySchema
+	if err := c.ShouldBindJSON(&body); err != nil {
+		schemaRegistryError(c, schemaRegistryInvalidSchema, "Invalid schema: "+err.Error())
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("RegisterSchema at getUserDetailsFromMiddleware: %v", err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+
+	subject := strings.ToLower(c.Param("subject"))
+	err = validateSchemaName(subject)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]RegisterSchema at validateSchemaName: Subject %v: %v", user.TenantName, user.Username, subject, err.Error())
+		schemaRegistryError(c, schemaRegistryInvalidSchema, err.Error())
+		return
+	}
+	schemaType := fromSchemaRegistryType(body.SchemaType)
+	candidate, err := getCandidateVersion(subject, body, schemaType, user.TenantName)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]RegisterSchema at getCandidateVersion: Subject %v: %v", user.TenantName, user.Username, subject, err.Error())
+		schemaRegistryError(c, schemaRegistryInvalidSchema, "Invalid schema: "+err.Error())
+		return
+	}
+
+	exist, schema, err := db.GetSchemaByName(subject, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RegisterSchema at GetSchemaByName: Subject %v: %v", user.TenantName, user.Username, subject, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+
+	newSchemaReq := CreateSchemaReq{
+		Name:              subject,
+		Type:              schemaType,
+		CreatedByUsername: user.Username,
+		SchemaContent:     candidate.SchemaContent,
+		MessageStructName: candidate.MessageStructName,
+		References:        candidate.References,
+	}
+	if !exist {
+		newSchemaReq.Compatibility, err = getSchemaRegistryCompatibility(user.TenantName)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]RegisterSchema at getSchemaRegistryCompatibility: Subject %v: %v", user.TenantName, user.Username, subject, err.Error())
+			schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+			return
+		}
+		err = sh.S.createNewSchema(newSchemaReq, user.TenantName)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]RegisterSchema at createNewSchema: Subject %v: %v", user.TenantName, user.Username, subject, err.Error())
+			schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+			return
+		}
+		serv.Noticef("[tenant: %v][user: %v]Schema %v has been registered through the schema registry", user.TenantName, user.Username, subject)
+		exist, schema, err = db.GetSchemaByName(subject, user.TenantName)
+		if err != nil || !exist {
+			serv.Errorf("[tenant: %v][user: %v]RegisterSchema at GetSchemaByName: Subject %v: %v", user.TenantName, user.Username, subject, err)
+			schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+			return
+		}
+	} else {
+		if schema.Type != schemaType {
+			schemaRegistryError(c, schemaRegistryIncompatibleSchema, fmt.Sprintf("Schema being registered is incompatible with an earlier schema for subject \"%v\", the subject type is %v", subject, toSchemaRegistryType(schema.Type)))
+			return
+		}
+		versions, err := getSortedSchemaVersions(schema.ID)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]RegisterSchema at getSortedSchemaVersions: Subject %v: %v", user.TenantName, user.Username, subject, err.Error())
+			schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+			return
+		}
+		// registering an already known schema is idempotent and returns the existing id
+		if version, found := findSchemaVersionByContent(versions, candidate.SchemaContent); found {
+			c.IndentedJSON(200, gin.H{"id": version.ID})
+			return
+		}
+		err = sh.S.updateSchemaVersion(schema, user.TenantName, newSchemaReq)
+		if err != nil {
+			var compatibilityErr *schemaCompatibilityError
+			if errors.As(err, &compatibilityErr) {
+				schemaRegistryError(c, schemaRegistryIncompatibleSchema, "Schema being registered is incompatible with an earlier schema; "+err.Error())
+				return
+			}
+			serv.Errorf("[tenant: %v][user: %v]RegisterSchema at updateSchemaVersion: Subject %v: %v", user.TenantName, user.Username, subject, err.Error())
+			schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+			return
+		}
+	}
+
+	versions, err := getSortedSchemaVersions(schema.ID)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RegisterSchema at getSortedSchemaVersions: Subject %v: %v", user.TenantName, user.Username, subject, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	version, found := findSchemaVersionByContent(versions, candidate.SchemaContent)
+	if !found {
+		serv.Errorf("[tenant: %v][user: %v]RegisterSchema: Subject %v: the registered version could not be found", user.TenantName, user.Username, subject)
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+
+	c.IndentedJSON(200, gin.H{"id": version.ID})
+}
+
+func (sh SchemaRegistryHandler) LookupSchema(c *gin.Context) {
+	var body models.SchemaRegistrySchema
+	if err := c.ShouldBindJSON(&body); err != nil {
+		schemaRegistryError(c, schemaRegistryInvalidSchema, "Invalid schema: "+err.Error())
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("LookupSchema at getUserDetailsFromMiddleware: %v", err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	schema, ok := sh.getSubject(c, user)
+	if !ok {
+		return
+	}
+	versions, err := getSortedSchemaVersions(schema.ID)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]LookupSchema at getSortedSchemaVersions: Subject %v: %v", user.TenantName, user.Username, schema.Name, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	version, found := findSchemaVersionByContent(versions, body.Schema)
+	if !found || schema.Type != fromSchemaRegistryType(body.SchemaType) {
+		schemaRegistryError(c, schemaRegistrySchemaNotFound, "Schema not found")
+		return
+	}
+
+	c.IndentedJSON(200, models.SchemaRegistrySubjectVersion{
+		Subject:    schema.Name,
+		ID:         version.ID,
+		Version:    version.VersionNumber,
+		Schema:     version.SchemaContent,
+		SchemaType: toSchemaRegistryType(schema.Type),
+		References: toSchemaRegistryReferences(version.References),
+	})
+}
+
+func (sh SchemaRegistryHandler) DeleteSubject(c *gin.Context) {
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("DeleteSubject at getUserDetailsFromMiddleware: %v", err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	schema, ok := sh.getSubject(c, user)
+	if !ok {
+		return
+	}
+	referencedBy, err := getSchemaReferencedBy(schema, map[string]bool{})
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]DeleteSubject at getSchemaReferencedBy: Subject %v: %v", user.TenantName, user.Username, schema.Name, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	if len(referencedBy) > 0 {
+		schemaRegistryError(c, schemaRegistryReferenceExists, fmt.Sprintf("One or more references exist to the schema {subject=%v}, referenced by: %v", schema.Name, strings.Join(referencedBy, ", ")))
+		return
+	}
+	versions, err := getSortedSchemaVersions(schema.ID)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]DeleteSubject at getSortedSchemaVersions: Subject %v: %v", user.TenantName, user.Username, schema.Name, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+
+	DeleteTagsFromSchema(schema.ID)
+	err = deleteSchemaFromStations(sh.S, schema.Name, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]DeleteSubject at deleteSchemaFromStations: Subject %v: %v", user.TenantName, user.Username, schema.Name, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	err = db.FindAndDeleteSchema([]int{schema.ID})
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]DeleteSubject at FindAndDeleteSchema: Subject %v: %v", user.TenantName, user.Username, schema.Name, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	serv.Noticef("[tenant: %v][user: %v]Schema %v has been deleted through the schema registry", user.TenantName, user.Username, schema.Name)
+
+	versionNumbers := []int{}
+	for _, v := range versions {
+		versionNumbers = append(versionNumbers, v.VersionNumber)
+	}
+	c.IndentedJSON(200, versionNumbers)
+}
+
+func (sh SchemaRegistryHandler) getSchemaVersionById(c *gin.Context, user models.User) (models.Schema, models.SchemaVersion, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		schemaRegistryError(c, schemaRegistrySchemaNotFound, "Schema not found")
+		return models.Schema{}, models.SchemaVersion{}, false
+	}
+	exist, version, err := db.GetSchemaVersionByID(id, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]SchemaRegistry at GetSchemaVersionByID: Schema ID %v: %v", user.TenantName, user.Username, id, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return models.Schema{}, models.SchemaVersion{}, false
+	}
+	if !exist {
+		schemaRegistryError(c, schemaRegistrySchemaNotFound, "Schema not found")
+		return models.Schema{}, models.SchemaVersion{}, false
+	}
+	exist, schema, err := db.GetSchemaByID(version.SchemaId, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]SchemaRegistry at GetSchemaByID: Schema ID %v: %v", user.TenantName, user.Username, id, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return models.Schema{}, models.SchemaVersion{}, false
+	}
+	if !exist {
+		schemaRegistryError(c, schemaRegistrySchemaNotFound, "Schema not found")
+		return models.Schema{}, models.SchemaVersion{}, false
+	}
+	return schema, version, true
+}
+
+func (sh SchemaRegistryHandler) GetSchemaById(c *gin.Context) {
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("GetSchemaById at getUserDetailsFromMiddleware: %v", err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	schema, version, ok := sh.getSchemaVersionById(c, user)
+	if !ok {
+		return
+	}
+
+	c.IndentedJSON(200, gin.H{
+		"schema":     version.SchemaContent,
+		"schemaType": toSchemaRegistryType(schema.Type),
+	})
+}
+
+func (sh SchemaRegistryHandler) GetSchemaIdVersions(c *gin.Context) {
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("GetSchemaIdVersions at getUserDetailsFromMiddleware: %v", err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	schema, version, ok := sh.getSchemaVersionById(c, user)
+	if !ok {
+		return
+	}
+
+	c.IndentedJSON(200, []gin.H{{"subject": schema.Name, "version": version.VersionNumber}})
+}
+
+func (sh SchemaRegistryHandler) GetSchemaTypes(c *gin.Context) {
+	c.IndentedJSON(200, []string{"AVRO", "JSON", "PROTOBUF"})
+}
+
+func (sh SchemaRegistryHandler) TestCompatibility(c *gin.Context) {
+	var body models.SchemaRegistrySchema
+	if err := c.ShouldBindJSON(&body); err != nil {
+		schemaRegistryError(c, schemaRegistryInvalidSchema, "Invalid schema: "+err.Error())
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("TestCompatibility at getUserDetailsFromMiddleware: %v", err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	schema, ok := sh.getSubject(c, user)
+	if !ok {
+		return
+	}
+	target, ok := sh.getSubjectVersion(c, user, schema)
+	if !ok {
+		return
+	}
+	candidate, err := getCandidateVersion(schema.Name, body, fromSchemaRegistryType(body.SchemaType), user.TenantName)
+	if err != nil {
+		schemaRegistryError(c, schemaRegistryInvalidSchema, "Invalid schema: "+err.Error())
+		return
+	}
+	if schema.Type != fromSchemaRegistryType(body.SchemaType) {
+		c.IndentedJSON(200, gin.H{"is_compatible": false})
+		return
+	}
+
+	// the compatibility endpoint always tests against a single version, even for transitive modes
+	err = checkSchemaCompatibility(schema, candidate, target, []models.SchemaVersion{target})
+	if err != nil {
+		var compatibilityErr *schemaCompatibilityError
+		if !errors.As(err, &compatibilityErr) {
+			serv.Errorf("[tenant: %v][user: %v]TestCompatibility at checkSchemaCompatibility: Subject %v: %v", user.TenantName, user.Username, schema.Name, err.Error())
+			schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+			return
+		}
+		res := gin.H{"is_compatible": false}
+		if c.Query("verbose") == "true" {
+			messages := []string{}
+			for _, v := range compatibilityErr.violations {
+				messages = append(messages, fmt.Sprintf("%v: %v", v.Path, v.Description))
+			}
+			res["messages"] = messages
+		}
+		c.IndentedJSON(200, res)
+		return
+	}
+
+	c.IndentedJSON(200, gin.H{"is_compatible": true})
+}
+
+func (sh SchemaRegistryHandler) GetConfig(c *gin.Context) {
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("GetConfig at getUserDetailsFromMiddleware: %v", err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	compatibility, err := getSchemaRegistryCompatibility(user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]GetConfig at getSchemaRegistryCompatibility: %v", user.TenantName, user.Username, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+
+	c.IndentedJSON(200, gin.H{"compatibilityLevel": compatibility})
+}
+
+func (sh SchemaRegistryHandler) UpdateConfig(c *gin.Context) {
+	var body models.SchemaRegistryConfig
+	if err := c.ShouldBindJSON(&body); err != nil {
+		schemaRegistryError(c, schemaRegistryInvalidCompatibility, "Invalid compatibility level")
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("UpdateConfig at getUserDetailsFromMiddleware: %v", err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	compatibility := getSchemaCompatibility(body.Compatibility)
+	err = validateSchemaCompatibility(compatibility)
+	if err != nil {
+		schemaRegistryError(c, schemaRegistryInvalidCompatibility, "Invalid compatibility level. "+err.Error())
+		return
+	}
+	err = db.UpsertConfiguration(schemaRegistryCompatibilityConfigKey, compatibility, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]UpdateConfig at UpsertConfiguration: %v", user.TenantName, user.Username, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	serv.Noticef("[tenant: %v][user: %v]Schema registry default compatibility has been set to %v", user.TenantName, user.Username, compatibility)
+
+	c.IndentedJSON(200, gin.H{"compatibility": compatibility})
+}
+
+func (sh SchemaRegistryHandler) GetSubjectConfig(c *gin.Context) {
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("GetSubjectConfig at getUserDetailsFromMiddleware: %v", err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	schema, ok := sh.getSubject(c, user)
+	if !ok {
+		return
+	}
+
+	c.IndentedJSON(200, gin.H{"compatibilityLevel": getSchemaCompatibility(schema.Compatibility)})
+}
+
+func (sh SchemaRegistryHandler) UpdateSubjectConfig(c *gin.Context) {
+	var body models.SchemaRegistryConfig
+	if err := c.ShouldBindJSON(&body); err != nil {
+		schemaRegistryError(c, schemaRegistryInvalidCompatibility, "Invalid compatibility level")
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("UpdateSubjectConfig at getUserDetailsFromMiddleware: %v", err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	compatibility := getSchemaCompatibility(body.Compatibility)
+	err = validateSchemaCompatibility(compatibility)
+	if err != nil {
+		schemaRegistryError(c, schemaRegistryInvalidCompatibility, "Invalid compatibility level. "+err.Error())
+		return
+	}
+	schema, ok := sh.getSubject(c, user)
+	if !ok {
+		return
+	}
+	err = db.UpdateSchemaCompatibility(schema.ID, compatibility)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]UpdateSubjectConfig at UpdateSchemaCompatibility: Subject %v: %v", user.TenantName, user.Username, schema.Name, err.Error())
+		schemaRegistryError(c, schemaRegistryServerError, "Error in the backend data store")
+		return
+	}
+	serv.Noticef("[tenant: %v][user: %v]Schema %v compatibility has been set to %v", user.TenantName, user.Username, schema.Name, compatibility)
+
+	c.IndentedJSON(200, gin.H{"compatibility": compatibility})
+}
@@ -16,9 +16,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -32,7 +32,6 @@ import (
 	"github.com/graph-gophers/graphql-go"
 	"github.com/hamba/avro/v2"
 	"github.com/jhump/protoreflect/desc/protoparse"
-	"github.com/santhosh-tekuri/jsonschema/v5"
 )
 
 type SchemasHandler struct{ S *Server }
@@ -47,11 +46,9 @@ var (
 	ErrNoSchema = errors.New("no schemas found")
 )
 
-func validateProtobufContent(schemaContent string) error {
+func validateProtobufContent(schemaContent string, references map[string]string) error {
 	parser := protoparse.Parser{
-		Accessor: func(filename string) (io.ReadCloser, error) {
-			return io.NopCloser(strings.NewReader(schemaContent)), nil
-		},
+		Accessor: protoFileAccessor(schemaContent, references),
 	}
 	_, err := parser.ParseFiles(_EMPTY_)
 	if err != nil {
@@ -61,8 +58,8 @@ func validateProtobufContent(schemaContent string) error {
 	return nil
 }
 
-func validateJsonSchemaContent(schemaContent string) error {
-	_, err := jsonschema.CompileString("test", schemaContent)
+func validateJsonSchemaContent(schemaContent string, references map[string]string) error {
+	_, err := compileJsonSchema(schemaContent, references)
 	if err != nil {
 		return errors.New("your json schema is invalid")
 	}
@@ -86,7 +83,7 @@ func validateAvroSchemaContent(schemaContent string) error {
 	return nil
 }
 
-func generateProtobufDescriptor(schemaName string, schemaVersionNum int, schemaContent string) ([]byte, error) {
+func generateProtobufDescriptor(schemaName string, schemaVersionNum int, schemaContent string, references map[string]string) ([]byte, error) {
 	filename := fmt.Sprintf("%v_%v.proto", schemaName, schemaVersionNum)
 	descFilename := fmt.Sprintf("%v_%v_desc", schemaName, schemaVersionNum)
 	err := os.WriteFile(filename, []byte(schemaContent), 0644)
@@ -96,6 +93,26 @@ func generateProtobufDescriptor(schemaName string, schemaVersionNum int, schemaC
 
 	protoCmd := "protoc"
 	args := []string{"--descriptor_set_out=" + descFilename, filename}
+	if len(references) > 0 {
+		// the referenced files are written under their import paths and bundled into the descriptor set
+		importsDir, err := os.MkdirTemp(_EMPTY_, fmt.Sprintf("%v_%v_imports", schemaName, schemaVersionNum))
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(importsDir)
+		for name, content := range references {
+			importPath := filepath.Join(importsDir, filepath.FromSlash(name))
+			err = os.MkdirAll(filepath.Dir(importPath), 0755)
+			if err != nil {
+				return nil, err
+			}
+			err = os.WriteFile(importPath, []byte(content), 0644)
+			if err != nil {
+				return nil, err
+			}
+		}
+		args = []string{"--include_imports", "--proto_path=.", "--proto_path=" + importsDir, "--descriptor_set_out=" + descFilename, filename}
+	}
 	cmd := exec.Command(protoCmd, args...)
 	err = cmd.Run()
 	if err != nil {
@@ -132,19 +149,19 @@ func validateSchemaType(schemaType string) error {
 	}
 }
 
-func validateSchemaContent(schemaContent, schemaType string) error {
+func validateSchemaContent(schemaContent, schemaType string, references map[string]string) error {
 	if len(schemaContent) == 0 {
 		return errors.New("your schema content is invalid")
 	}
 
 	switch schemaType {
 	case "protobuf":
-		err := validateProtobufContent(schemaContent)
+		err := validateProtobufContent(schemaContent, references)
 		if err != nil {
 			return err
 		}
 	case "json":
-		err := validateJsonSchemaContent(schemaContent)
+		err := validateJsonSchemaContent(schemaContent, references)
 		if err != nil {
 			return err
 		}
@@ -162,7 +179,7 @@ func validateSchemaContent(schemaContent, schemaType string) error {
 	return nil
 }
 
-func generateSchemaDescriptor(schemaName string, schemaVersionNum int, schemaContent, schemaType string) (string, error) {
+func generateSchemaDescriptor(schemaName string, schemaVersionNum int, schemaContent, schemaType string, references map[string]string) (string, error) {
 	if len(schemaContent) == 0 {
 		return _EMPTY_, errors.New("attempt to generate schema descriptor with empty schema")
 	}
@@ -171,7 +188,7 @@ func generateSchemaDescriptor(schemaName string, schemaVersionNum int, schemaCon
 		return _EMPTY_, errors.New("descriptor generation with schema type: " + schemaType + ", while protobuf is expected")
 	}
 
-	descriptor, err := generateProtobufDescriptor(schemaName, schemaVersionNum, schemaContent)
+	descriptor, err := generateProtobufDescriptor(schemaName, schemaVersionNum, schemaContent, references)
 	if err != nil {
 		return _EMPTY_, err
 	}
@@ -190,6 +207,10 @@ func generateSchemaUpdateInit(schema models.Schema) (*models.SchemaUpdateInit, e
 	if err != nil {
 		return nil, err
 	}
+	references, err := resolveSchemaReferences(activeVersion.References, schema.TenantName)
+	if err != nil {
+		return nil, err
+	}
 
 	return &models.SchemaUpdateInit{
 		SchemaName: schema.Name,
@@ -198,6 +219,7 @@ func generateSchemaUpdateInit(schema models.Schema) (*models.SchemaUpdateInit, e
 			Descriptor:        activeVersion.Descriptor,
 			Content:           activeVersion.SchemaContent,
 			MessageStructName: activeVersion.MessageStructName,
+			References:        references,
 		},
 		SchemaType: schema.Type,
 	}, nil
@@ -233,6 +255,10 @@ func getSchemaVersionsBySchemaId(id int) ([]models.SchemaVersion, error) {
 	if err != nil {
 		return []models.SchemaVersion{}, err
 	}
+	err = attachSchemaReferences(id, schemaVersions)
+	if err != nil {
+		return []models.SchemaVersion{}, err
+	}
 	return schemaVersions, nil
 }
 
@@ -241,6 +267,10 @@ func getActiveVersionBySchemaId(id int) (models.SchemaVersion, error) {
 	if err != nil {
 		return models.SchemaVersion{}, err
 	}
+	schemaVersion.References, err = db.GetSchemaVersionReferences(schemaVersion.ID)
+	if err != nil {
+		return models.SchemaVersion{}, err
+	}
 	return schemaVersion, nil
 }
 
@@ -289,6 +319,10 @@ func (sh SchemasHandler) getExtendedSchemaDetailsUpdateAvailable(schemaVersion i
 	if !exist {
 		return models.ExtendedSchemaDetails{}, fmt.Errorf("schema version %v does not exist for schema %v", strconv.Itoa(schemaVersion), schema.Name)
 	}
+	usedSchemaVersion.References, err = db.GetSchemaVersionReferences(usedSchemaVersion.ID)
+	if err != nil {
+		return models.ExtendedSchemaDetails{}, err
+	}
 
 	if !usedSchemaVersion.Active {
 		activeSchemaVersion, err := getActiveVersionBySchemaId(schema.ID)
@@ -313,6 +347,11 @@ func (sh SchemasHandler) getExtendedSchemaDetailsUpdateAvailable(schemaVersion i
 		return models.ExtendedSchemaDetails{}, err
 	}
 
+	referencedBy, err := getSchemaReferencedBy(schema, map[string]bool{})
+	if err != nil {
+		return models.ExtendedSchemaDetails{}, err
+	}
+
 	extedndedSchemaDetails = models.ExtendedSchemaDetails{
 		ID:                schema.ID,
 		SchemaName:        schema.Name,
@@ -321,6 +360,8 @@ func (sh SchemasHandler) getExtendedSchemaDetailsUpdateAvailable(schemaVersion i
 		UsedStations:      stations,
 		Tags:              tags,
 		CreatedByUsername: schema.CreatedByUsername,
+		Compatibility:     getSchemaCompatibility(schema.Compatibility),
+		ReferencedBy:      referencedBy,
 	}
 
 	return extedndedSchemaDetails, nil
@@ -344,6 +385,11 @@ func (sh SchemasHandler) getExtendedSchemaDetails(schema models.Schema, tenantNa
 		return models.ExtendedSchemaDetails{}, err
 	}
 
+	referencedBy, err := getSchemaReferencedBy(schema, map[string]bool{})
+	if err != nil {
+		return models.ExtendedSchemaDetails{}, err
+	}
+
 	extedndedSchemaDetails = models.ExtendedSchemaDetails{
 		ID:                schema.ID,
 		SchemaName:        schema.Name,
@@ -352,6 +398,8 @@ func (sh SchemasHandler) getExtendedSchemaDetails(schema models.Schema, tenantNa
 		UsedStations:      stations,
 		Tags:              tags,
 		CreatedByUsername: schema.CreatedByUsername,
+		Compatibility:     getSchemaCompatibility(schema.Compatibility),
+		ReferencedBy:      referencedBy,
 	}
 
 	return extedndedSchemaDetails, nil
@@ -439,17 +487,30 @@ func (sh SchemasHandler) CreateNewSchema(c *gin.Context) {
 		}
 	}
 
+	references, err := validateSchemaReferences(schemaName, schemaType, body.References, tenantName)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]CreateNewSchema at validateSchemaReferences: Schema %v: %v", user.TenantName, user.Username, schemaName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
 	schemaContent := body.SchemaContent
-	err = validateSchemaContent(schemaContent, schemaType)
+	err = validateSchemaContent(schemaContent, schemaType, references)
 	if err != nil {
 		serv.Warnf("[tenant: %v][user: %v]CreateNewSchema at validateSchemaContent: Schema %v: %v", user.TenantName, user.Username, schemaName, err.Error())
 		c.AbortWithStatusJSON(SCHEMA_VALIDATION_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
 		return
 	}
+	compatibility := getSchemaCompatibility(body.Compatibility)
+	err = validateSchemaCompatibility(compatibility)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]CreateNewSchema at validateSchemaCompatibility: Schema %v: %v", user.TenantName, user.Username, schemaName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
 	schemaVersionNumber := 1
 	descriptor := _EMPTY_
 	if schemaType == "protobuf" {
-		descriptor, err = generateSchemaDescriptor(schemaName, schemaVersionNumber, schemaContent, schemaType)
+		descriptor, err = generateSchemaDescriptor(schemaName, schemaVersionNumber, schemaContent, schemaType, references)
 		if err != nil {
 			serv.Warnf("[tenant: %v][user: %v]CreateNewSchema at generateSchemaDescriptor: Schema %v: %v", user.TenantName, user.Username, schemaName, err.Error())
 			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
@@ -457,7 +518,7 @@ func (sh SchemasHandler) CreateNewSchema(c *gin.Context) {
 		}
 	}
 
-	newSchema, rowsUpdated, err := db.InsertNewSchema(schemaName, schemaType, user.Username, tenantName)
+	newSchema, rowsUpdated, err := db.InsertNewSchema(schemaName, schemaType, user.Username, tenantName, compatibility)
 	if err != nil {
 		serv.Errorf("[tenant: %v][user: %v]CreateNewSchema at InsertNewSchema: Schema %v: %v", user.TenantName, user.Username, schemaName, err.Error())
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
@@ -465,12 +526,18 @@ func (sh SchemasHandler) CreateNewSchema(c *gin.Context) {
 	}
 
 	if rowsUpdated == 1 {
-		_, _, err = db.InsertNewSchemaVersion(schemaVersionNumber, user.ID, user.Username, schemaContent, newSchema.ID, messageStructName, descriptor, true, tenantName)
+		newSchemaVersion, _, err := db.InsertNewSchemaVersion(schemaVersionNumber, user.ID, user.Username, schemaContent, newSchema.ID, messageStructName, descriptor, true, tenantName)
 		if err != nil {
 			serv.Errorf("[tenant: %v][user: %v]CreateNewSchema at InsertNewSchemaVersion: %v", user.TenantName, user.Username, err.Error())
 			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 			return
 		}
+		err = db.InsertSchemaReferences(newSchemaVersion.ID, body.References, tenantName)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]CreateNewSchema at InsertSchemaReferences: %v", user.TenantName, user.Username, err.Error())
+			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+			return
+		}
 		message := fmt.Sprintf("[tenant: %v][user: %v]Schema %v has been created by %v", user.TenantName, user.Username, schemaName, user.Username)
 		serv.Noticef(message)
 	} else {
@@ -608,6 +675,11 @@ func (sh SchemasHandler) RemoveSchema(c *gin.Context) {
 	}
 
 	tenantName := user.TenantName
+	removedSchemas := make(map[string]bool, len(body.SchemaNames))
+	for _, name := range body.SchemaNames {
+		removedSchemas[strings.ToLower(name)] = true
+	}
+	schemas := []models.Schema{}
 	for _, name := range body.SchemaNames {
 		schemaName := strings.ToLower(name)
 		exist, schema, err := db.GetSchemaByName(schemaName, tenantName)
@@ -616,17 +688,34 @@ func (sh SchemasHandler) RemoveSchema(c *gin.Context) {
 			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 			return
 		}
-		if exist {
-			DeleteTagsFromSchema(schema.ID)
-			err := deleteSchemaFromStations(sh.S, schema.Name, tenantName)
-			if err != nil {
-				serv.Errorf("[tenant: %v][user: %v]RemoveSchema at deleteSchemaFromStations: Schema %v: %v", user.TenantName, user.Username, schemaName, err.Error())
-				c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
-				return
-			}
+		if !exist {
+			continue
+		}
+		referencedBy, err := getSchemaReferencedBy(schema, removedSchemas)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]RemoveSchema at getSchemaReferencedBy: Schema %v: %v", user.TenantName, user.Username, schemaName, err.Error())
+			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+			return
+		}
+		if len(referencedBy) > 0 {
+			errMsg := fmt.Sprintf("Schema %v can not be removed since it is referenced by the following schemas: %v", schemaName, strings.Join(referencedBy, ", "))
+			serv.Warnf("[tenant: %v][user: %v]RemoveSchema: %v", user.TenantName, user.Username, errMsg)
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+			return
+		}
+		schemas = append(schemas, schema)
+	}
 
-			schemaIds = append(schemaIds, schema.ID)
+	for _, schema := range schemas {
+		DeleteTagsFromSchema(schema.ID)
+		err := deleteSchemaFromStations(sh.S, schema.Name, tenantName)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]RemoveSchema at deleteSchemaFromStations: Schema %v: %v", user.TenantName, user.Username, schema.Name, err.Error())
+			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+			return
 		}
+
+		schemaIds = append(schemaIds, schema.ID)
 	}
 
 	if len(schemaIds) > 0 {
@@ -686,8 +775,14 @@ func (sh SchemasHandler) CreateNewVersion(c *gin.Context) {
 			return
 		}
 	}
+	references, err := validateSchemaReferences(schemaName, schema.Type, body.References, user.TenantName)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]CreateNewVersion at validateSchemaReferences: Schema %v: %v", user.TenantName, user.Username, body.SchemaName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
 	schemaContent := body.SchemaContent
-	err = validateSchemaContent(schemaContent, schema.Type)
+	err = validateSchemaContent(schemaContent, schema.Type, references)
 	if err != nil {
 		serv.Warnf("[tenant: %v][user: %v]CreateNewVersion at validateSchemaContent: Schema %v: %v", user.TenantName, user.Username, body.SchemaName, err.Error())
 		c.AbortWithStatusJSON(SCHEMA_VALIDATION_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
@@ -702,9 +797,23 @@ func (sh SchemasHandler) CreateNewVersion(c *gin.Context) {
 	}
 
 	versionNumber := countVersions + 1
+	candidate := models.SchemaVersion{VersionNumber: versionNumber, SchemaContent: schemaContent, MessageStructName: messageStructName, TenantName: user.TenantName, References: body.References}
+	err = validateSchemaVersionCompatibility(schema, candidate)
+	if err != nil {
+		var compatibilityErr *schemaCompatibilityError
+		if errors.As(err, &compatibilityErr) {
+			serv.Warnf("[tenant: %v][user: %v]CreateNewVersion at validateSchemaVersionCompatibility: Schema %v: %v", user.TenantName, user.Username, body.SchemaName, err.Error())
+			c.AbortWithStatusJSON(SCHEMA_VALIDATION_ERROR_STATUS_CODE, gin.H{"message": err.Error(), "violations": compatibilityErr.violations})
+			return
+		}
+		serv.Errorf("[tenant: %v][user: %v]CreateNewVersion at validateSchemaVersionCompatibility: Schema %v: %v", user.TenantName, user.Username, body.SchemaName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
 	descriptor := _EMPTY_
 	if schema.Type == "protobuf" {
-		descriptor, err = generateSchemaDescriptor(schemaName, versionNumber, schemaContent, schema.Type)
+		descriptor, err = generateSchemaDescriptor(schemaName, versionNumber, schemaContent, schema.Type, references)
 		if err != nil {
 			serv.Warnf("[tenant: %v][user: %v]CreateNewVersion at generateSchemaDescriptor: Schema %v: %v", user.TenantName, user.Username, body.SchemaName, err.Error())
 			c.AbortWithStatusJSON(SCHEMA_VALIDATION_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
@@ -718,6 +827,12 @@ func (sh SchemasHandler) CreateNewVersion(c *gin.Context) {
 		return
 	}
 	if rowsUpdated == 1 {
+		err = db.InsertSchemaReferences(newSchemaVersion.ID, body.References, user.TenantName)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]CreateNewVersion at InsertSchemaReferences: %v", user.TenantName, user.Username, err.Error())
+			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+			return
+		}
 		serv.Noticef("[tenant: %v][user: %v]Schema Version %v has been created by %v", user.TenantName, user.Username, strconv.Itoa(newSchemaVersion.VersionNumber), user.Username)
 	} else {
 		serv.Warnf("[tenant: %v][user: %v]CreateNewVersion: Schema %v: Version %v already exists", user.TenantName, user.Username, body.SchemaName, strconv.Itoa(newSchemaVersion.VersionNumber))
@@ -771,7 +886,7 @@ func (sh SchemasHandler) RollBackVersion(c *gin.Context) {
 	}
 
 	schemaVersion := body.VersionNumber
-	exist, _, err = db.GetSchemaVersionByNumberAndID(schemaVersion, schema.ID)
+	exist, targetVersion, err := db.GetSchemaVersionByNumberAndID(schemaVersion, schema.ID)
 	if err != nil {
 		serv.Errorf("[tenant: %v][user: %v]RollBackVersion at GetSchemaVersionByNumberAndID: Schema %v version %v: %v", user.TenantName, user.Username, body.SchemaName, strconv.Itoa(schemaVersion), err.Error())
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
@@ -791,6 +906,19 @@ func (sh SchemasHandler) RollBackVersion(c *gin.Context) {
 		return
 	}
 	if countVersions > 1 {
+		err = validateSchemaVersionCompatibility(schema, targetVersion)
+		if err != nil {
+			var compatibilityErr *schemaCompatibilityError
+			if errors.As(err, &compatibilityErr) {
+				serv.Warnf("[tenant: %v][user: %v]RollBackVersion at validateSchemaVersionCompatibility: Schema %v: %v", user.TenantName, user.Username, body.SchemaName, err.Error())
+				c.AbortWithStatusJSON(SCHEMA_VALIDATION_ERROR_STATUS_CODE, gin.H{"message": err.Error(), "violations": compatibilityErr.violations})
+				return
+			}
+			serv.Errorf("[tenant: %v][user: %v]RollBackVersion at validateSchemaVersionCompatibility: Schema %v: %v", user.TenantName, user.Username, body.SchemaName, err.Error())
+			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+			return
+		}
+
 		err = db.UpdateSchemaActiveVersion(schema.ID, body.VersionNumber)
 		if err != nil {
 			serv.Errorf("[tenant: %v][user: %v]RollBackVersion at UpdateSchemaActiveVersion: Schema %v: %v", user.TenantName, user.Username, body.SchemaName, err.Error())
@@ -814,6 +942,61 @@ func (sh SchemasHandler) RollBackVersion(c *gin.Context) {
 	c.IndentedJSON(200, extedndedSchemaDetails)
 }
 
+func (sh SchemasHandler) UpdateCompatibility(c *gin.Context) {
+	var body models.UpdateSchemaCompatibility
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("UpdateCompatibility at getUserDetailsFromMiddleware: Schema %v: %v", body.SchemaName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	compatibility := getSchemaCompatibility(body.Compatibility)
+	err = validateSchemaCompatibility(compatibility)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]UpdateCompatibility at validateSchemaCompatibility: Schema %v: %v", user.TenantName, user.Username, body.SchemaName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+
+	schemaName := strings.ToLower(body.SchemaName)
+	exist, schema, err := db.GetSchemaByName(schemaName, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]UpdateCompatibility at GetSchemaByName: Schema %v: %v", user.TenantName, user.Username, body.SchemaName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("Schema %v does not exist", body.SchemaName)
+		serv.Warnf("[tenant: %v][user: %v]UpdateCompatibility: %v", user.TenantName, user.Username, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
+	}
+
+	err = db.UpdateSchemaCompatibility(schema.ID, compatibility)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]UpdateCompatibility at UpdateSchemaCompatibility: Schema %v: %v", user.TenantName, user.Username, body.SchemaName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	serv.Noticef("[tenant: %v][user: %v]Schema %v compatibility has been set to %v", user.TenantName, user.Username, schemaName, compatibility)
+
+	schema.Compatibility = compatibility
+	extedndedSchemaDetails, err := sh.getExtendedSchemaDetails(schema, user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]UpdateCompatibility at getExtendedSchemaDetails: Schema %v: %v", user.TenantName, user.Username, body.SchemaName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	c.IndentedJSON(200, extedndedSchemaDetails)
+}
+
 func (sh SchemasHandler) ValidateSchema(c *gin.Context) {
 	var body models.ValidateSchema
 	ok := utils.Validate(c, &body, false, nil)
@@ -830,7 +1013,7 @@ func (sh SchemasHandler) ValidateSchema(c *gin.Context) {
 	}
 
 	schemaContent := body.SchemaContent
-	err = validateSchemaContent(schemaContent, schemaType)
+	err = validateSchemaContent(schemaContent, schemaType, nil)
 	if err != nil {
 		serv.Warnf("ValidateSchema at validateSchemaContent: Schema type %v: %v", schemaType, err.Error())
 		c.AbortWithStatusJSON(SCHEMA_VALIDATION_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
@@ -865,7 +1048,14 @@ func (s *Server) createSchemaDirect(c *client, reply string, msg []byte) {
 		return
 	}
 
-	err = validateSchemaContent(csr.SchemaContent, csr.Type)
+	references, err := validateSchemaReferences(csr.Name, csr.Type, csr.References, tenantName)
+	if err != nil {
+		s.Warnf("[tenant: %v]createSchemaDirect at validateSchemaReferences- failed creating Schema: %v : %v", tenantName, csr.Name, err.Error())
+		respondWithRespErr(s.MemphisGlobalAccountString(), s, reply, err, &resp)
+		return
+	}
+
+	err = validateSchemaContent(csr.SchemaContent, csr.Type, references)
 	if err != nil {
 		s.Warnf("[tenant: %v]createSchemaDirect at validateSchemaContent- Schema is not in the right %v format, error: %v", tenantName, csr.Type, err.Error())
 		respondWithRespErr(s.MemphisGlobalAccountString(), s, reply, err, &resp)
@@ -873,7 +1063,7 @@ func (s *Server) createSchemaDirect(c *client, reply string, msg []byte) {
 	}
 
 	if csr.Type == "protobuf" {
-		csr.MessageStructName, err = getProtoMessageStructName(csr.SchemaContent)
+		csr.MessageStructName, err = getProtoMessageStructName(csr.SchemaContent, references)
 		if err != nil {
 			s.Errorf("[tenant: %v]createSchemaDirect at getProtoMessageStructName- failed creating Schema: %v : %v", tenantName, csr.Name, err.Error())
 			respondWithRespErr(s.MemphisGlobalAccountString(), s, reply, err, &resp)
@@ -889,6 +1079,14 @@ func (s *Server) createSchemaDirect(c *client, reply string, msg []byte) {
 		}
 	}
 
+	csr.Compatibility = getSchemaCompatibility(csr.Compatibility)
+	err = validateSchemaCompatibility(csr.Compatibility)
+	if err != nil {
+		s.Warnf("[tenant: %v]createSchemaDirect at validateSchemaCompatibility- failed creating Schema: %v : %v", tenantName, csr.Name, err.Error())
+		respondWithRespErr(s.MemphisGlobalAccountString(), s, reply, err, &resp)
+		return
+	}
+
 	exist, existedSchema, err := db.GetSchemaByName(csr.Name, tenantName)
 	if err != nil {
 		s.Errorf("[tenant: %v]createSchemaDirect at GetSchemaByName- failed creating Schema: %v : %v", tenantName, csr.Name, err.Error())
@@ -898,7 +1096,7 @@ func (s *Server) createSchemaDirect(c *client, reply string, msg []byte) {
 
 	if exist {
 		if existedSchema.Type == csr.Type {
-			err = s.updateSchemaVersion(existedSchema.ID, tenantName, csr)
+			err = s.updateSchemaVersion(existedSchema, tenantName, csr)
 			if err != nil {
 				if !strings.Contains(err.Error(), "already exist") {
 					s.Errorf("[tenant: %v]createSchemaDirect at updateSchemaVersion - failed creating Schema: %v : %v", tenantName, csr.Name, err.Error())
@@ -927,7 +1125,8 @@ func (s *Server) createSchemaDirect(c *client, reply string, msg []byte) {
 
 }
 
-func (s *Server) updateSchemaVersion(schemaID int, tenantName string, newSchemaReq CreateSchemaReq) error {
+func (s *Server) updateSchemaVersion(schema models.Schema, tenantName string, newSchemaReq CreateSchemaReq) error {
+	schemaID := schema.ID
 	_, user, err := memphis_cache.GetUser(newSchemaReq.CreatedByUsername, tenantName, false)
 	if err != nil {
 		s.Errorf("[tenant: %v]updateSchemaVersion at memphis_cache.GetUser: Schema %v: %v", tenantName, newSchemaReq.Name, err.Error())
@@ -952,10 +1151,21 @@ func (s *Server) updateSchemaVersion(schemaID int, tenantName string, newSchemaR
 	}
 
 	versionNumber := countVersions + 1
+	candidate := models.SchemaVersion{VersionNumber: versionNumber, SchemaContent: newSchemaReq.SchemaContent, MessageStructName: newSchemaReq.MessageStructName, TenantName: tenantName, References: newSchemaReq.References}
+	err = validateSchemaVersionCompatibility(schema, candidate)
+	if err != nil {
+		s.Warnf("[tenant: %v][user: %v]updateSchemaVersion at validateSchemaVersionCompatibility: Schema %v: %v", tenantName, user.Username, newSchemaReq.Name, err.Error())
+		return err
+	}
 
+	references, err := resolveSchemaReferences(newSchemaReq.References, tenantName)
+	if err != nil {
+		s.Warnf("[tenant: %v][user: %v]updateSchemaVersion at resolveSchemaReferences: Schema %v: %v", tenantName, user.Username, newSchemaReq.Name, err.Error())
+		return err
+	}
 	descriptor := _EMPTY_
 	if newSchemaReq.Type == "protobuf" {
-		descriptor, err = generateSchemaDescriptor(newSchemaReq.Name, 1, newSchemaReq.SchemaContent, newSchemaReq.Type)
+		descriptor, err = generateSchemaDescriptor(newSchemaReq.Name, 1, newSchemaReq.SchemaContent, newSchemaReq.Type, references)
 		if err != nil {
 			s.Errorf("[tenant: %v][user: %v]CreateNewSchemaDirectn: could not create proto descriptor for %v: %v", tenantName, user.Username, newSchemaReq.Name, err.Error())
 			return err
@@ -968,6 +1178,11 @@ func (s *Server) updateSchemaVersion(schemaID int, tenantName string, newSchemaR
 		return err
 	}
 	if rowsUpdated == 1 {
+		err = db.InsertSchemaReferences(newSchemaVersion.ID, newSchemaReq.References, tenantName)
+		if err != nil {
+			s.Errorf("[tenant: %v][user: %v]updateSchemaVersion at InsertSchemaReferences: %v", tenantName, user.Username, err.Error())
+			return err
+		}
 		message := fmt.Sprintf("[tenant: %v][user: %v]Schema Version %v has been created by %v", tenantName, user.Username, strconv.Itoa(newSchemaVersion.VersionNumber), user.Username)
 		s.Noticef(message)
 		return nil
@@ -987,27 +1202,37 @@ func (s *Server) createNewSchema(newSchemaReq CreateSchemaReq, tenantName string
 		return err
 	}
 
+	references, err := resolveSchemaReferences(newSchemaReq.References, tenantName)
+	if err != nil {
+		s.Warnf("[tenant: %v][user: %v]createNewSchema at resolveSchemaReferences: Schema %v: %v", tenantName, user.Username, newSchemaReq.Name, err.Error())
+		return err
+	}
 	descriptor := _EMPTY_
 	if newSchemaReq.Type == "protobuf" {
-		descriptor, err = generateSchemaDescriptor(newSchemaReq.Name, 1, newSchemaReq.SchemaContent, newSchemaReq.Type)
+		descriptor, err = generateSchemaDescriptor(newSchemaReq.Name, 1, newSchemaReq.SchemaContent, newSchemaReq.Type, references)
 		if err != nil {
 			s.Errorf("[tenant: %v][user: %v]CreateNewSchema at generateSchemaDescriptor: Schema %v: %v", tenantName, user.Username, newSchemaReq.Name, err.Error())
 			return err
 		}
 	}
 
-	newSchema, rowUpdated, err := db.InsertNewSchema(newSchemaReq.Name, newSchemaReq.Type, newSchemaReq.CreatedByUsername, tenantName)
+	newSchema, rowUpdated, err := db.InsertNewSchema(newSchemaReq.Name, newSchemaReq.Type, newSchemaReq.CreatedByUsername, tenantName, getSchemaCompatibility(newSchemaReq.Compatibility))
 	if err != nil {
 		s.Errorf("[tenant: %v][user: %v]createNewSchema at db.InsertNewSchema: %v", tenantName, user.Username, err.Error())
 		return err
 	}
 
 	if rowUpdated == 1 {
-		_, _, err := db.InsertNewSchemaVersion(schemaVersionNumber, user.ID, user.Username, newSchemaReq.SchemaContent, newSchema.ID, newSchemaReq.MessageStructName, descriptor, true, tenantName)
+		newSchemaVersion, _, err := db.InsertNewSchemaVersion(schemaVersionNumber, user.ID, user.Username, newSchemaReq.SchemaContent, newSchema.ID, newSchemaReq.MessageStructName, descriptor, true, tenantName)
 		if err != nil {
 			s.Errorf("[tenant: %v][user: %v]createNewSchema at db.InsertNewSchemaVersion: %v", tenantName, user.Username, err.Error())
 			return err
 		}
+		err = db.InsertSchemaReferences(newSchemaVersion.ID, newSchemaReq.References, tenantName)
+		if err != nil {
+			s.Errorf("[tenant: %v][user: %v]createNewSchema at db.InsertSchemaReferences: %v", tenantName, user.Username, err.Error())
+			return err
+		}
 	}
 
 	err = CreateDefaultTags("schema", newSchema.ID, tenantName)
@@ -1019,11 +1244,9 @@ func (s *Server) createNewSchema(newSchemaReq CreateSchemaReq, tenantName string
 	return nil
 }
 
-func getProtoMessageStructName(schema_content string) (string, error) {
+func getProtoMessageStructName(schema_content string, references map[string]string) (string, error) {
 	parser := protoparse.Parser{
-		Accessor: func(filename string) (io.ReadCloser, error) {
-			return io.NopCloser(strings.NewReader(string(schema_content))), nil
-		},
+		Accessor: protoFileAccessor(schema_content, references),
 	}
 	something, err := parser.ParseFiles(_EMPTY_)
 	if err != nil {
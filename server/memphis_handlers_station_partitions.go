@@ -0,0 +1,263 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/memphis_cache"
+	"github.com/memphisdev/memphis/models"
+	"github.com/memphisdev/memphis/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// addStationPartitions creates the streams of the new partitions and a consumer per existing consumer group
+// on each of them, the returned bool tells whether the error can be shown to the user
+func (s *Server) addStationPartitions(station models.Station, partitionsNumber int, user models.User) ([]int, bool, error) {
+	stationName, err := StationNameFromStr(station.Name)
+	if err != nil {
+		return nil, true, err
+	}
+	if station.Version == 0 || len(station.PartitionsList) == 0 {
+		return nil, true, fmt.Errorf("station %v was created without partitions support and can not be expanded", stationName.Ext())
+	}
+	if partitionsNumber <= len(station.PartitionsList) {
+		return nil, true, fmt.Errorf("station %v already has %v partitions, the number of partitions can only be increased", stationName.Ext(), len(station.PartitionsList))
+	}
+	canCreate, partitionLimit := ValidataUsageLimitOfFeature(station.TenantName, "feature-partitions-per-station", partitionsNumber)
+	if !canCreate || partitionsNumber > partitionLimit {
+		return nil, true, fmt.Errorf("cannot expand station to %v partitions (max:%v)", partitionsNumber, partitionLimit)
+	}
+
+	nextPartition := 0
+	for _, p := range station.PartitionsList {
+		if p > nextPartition {
+			nextPartition = p
+		}
+	}
+	newPartitions := make([]int, 0, partitionsNumber-len(station.PartitionsList))
+	for len(station.PartitionsList)+len(newPartitions) < partitionsNumber {
+		nextPartition++
+		err = s.CreateStream(station.TenantName, stationName, station.RetentionType, station.RetentionValue, station.StorageType, station.IdempotencyWindow, station.Replicas, station.TieredStorageEnabled, nextPartition, true)
+		if err != nil {
+			// remove all partitions that were created
+			for _, partition := range newPartitions {
+				streamName := fmt.Sprintf("%v$%v", stationName.Intern(), partition)
+				if err := s.RemoveStream(station.TenantName, streamName); err != nil {
+					s.Errorf("[tenant: %v][user: %v]addStationPartitions at RemoveStream: Station %v: %v", user.TenantName, user.Username, stationName.Ext(), err.Error())
+				}
+			}
+			if IsNatsErr(err, JSStreamReplicasNotSupportedErr) {
+				return nil, true, errors.New("partitions can not be created, probably since replicas count is larger than the cluster size")
+			}
+			return nil, false, err
+		}
+		newPartitions = append(newPartitions, nextPartition)
+	}
+
+	partitionsList := append(append([]int{}, station.PartitionsList...), newPartitions...)
+	version := station.Version
+	if version < 2 {
+		version = 2
+	}
+	err = db.UpdateStationPartitions(station.Name, partitionsList, version, station.TenantName)
+	if err != nil {
+		return nil, false, err
+	}
+	station.PartitionsList = partitionsList
+	station.Version = version
+	setPartitionedStation(station.TenantName, stationName.Intern(), partitionsList)
+
+	// existing consumer groups start consuming the new partitions from their beginning
+	consumers, err := db.GetAllConsumersByStation(station.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	handledCgs := make(map[string]bool)
+	for _, consumer := range consumers {
+		cgName := consumer.ConsumersGroup
+		if cgName == _EMPTY_ {
+			cgName = consumer.Name
+		}
+		if handledCgs[cgName] {
+			continue
+		}
+		handledCgs[cgName] = true
+		cg := models.Consumer{
+			Name:                consumer.Name,
+			ConsumersGroup:      consumer.ConsumersGroup,
+			MaxAckTimeMs:        consumer.MaxAckTimeMs,
+			MaxMsgDeliveries:    consumer.MaxMsgDeliveries,
+			StartConsumeFromSeq: 1,
+			LastMessages:        -1,
+		}
+		err = s.CreateConsumer(station.TenantName, cg, station, newPartitions)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	s.SendUpdateToClients(models.SdkClientsUpdates{
+		StationName: stationName.Intern(),
+		Type:        partitionsUpdateType,
+		Update:      models.PartitionsUpdate{PartitionsList: partitionsList},
+	})
+
+	message := fmt.Sprintf("Station %v partitions have been increased from %v to %v by user %v", stationName.Ext(), len(partitionsList)-len(newPartitions), len(partitionsList), user.Username)
+	s.Noticef("[tenant: %v][user: %v]: %v", user.TenantName, user.Username, message)
+	var auditLogs []interface{}
+	newAuditLog := models.AuditLog{
+		StationName:       stationName.Ext(),
+		Message:           message,
+		CreatedBy:         user.ID,
+		CreatedByUsername: user.Username,
+		CreatedAt:         time.Now(),
+		TenantName:        user.TenantName,
+	}
+	auditLogs = append(auditLogs, newAuditLog)
+	err = CreateAuditLogs(auditLogs)
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]addStationPartitions: Station %v - create audit logs error: %v", user.TenantName, user.Username, stationName.Ext(), err.Error())
+	}
+	return partitionsList, false, nil
+}
+
+func (sh StationsHandler) AddPartitions(c *gin.Context) {
+	var body models.AddStationPartitionsSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("AddPartitions at getUserDetailsFromMiddleware: At station %v: %v", body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	stationName, err := StationNameFromStr(body.StationName)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]AddPartitions at StationNameFromStr: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+	if !validateStationAccess(c, user, stationName.Ext(), "write", "AddPartitions") {
+		return
+	}
+
+	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]AddPartitions at GetStationByName: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("Station %v does not exist", body.StationName)
+		serv.Warnf("[tenant: %v][user: %v]AddPartitions: %v", user.TenantName, user.Username, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
+	}
+
+	partitionsList, showable, err := sh.S.addStationPartitions(station, body.PartitionsNumber, user)
+	if err != nil {
+		if showable {
+			serv.Warnf("[tenant: %v][user: %v]AddPartitions at addStationPartitions: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+			return
+		}
+		serv.Errorf("[tenant: %v][user: %v]AddPartitions at addStationPartitions: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	c.IndentedJSON(200, gin.H{"partitions_list": partitionsList})
+}
+
+func (s *Server) addStationPartitionsDirect(c *client, reply string, msg []byte) {
+	var apr addStationPartitionsRequest
+	tenantName, message, err := s.getTenantNameAndMessage(msg)
+	if err != nil {
+		s.Errorf("addStationPartitionsDirect at getTenantNameAndMessage: %v", err.Error())
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, err)
+		return
+	}
+	if err := json.Unmarshal([]byte(message), &apr); err != nil {
+		s.Errorf("[tenant: %v]addStationPartitionsDirect at json.Unmarshal: %v", tenantName, err.Error())
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, err)
+		return
+	}
+	apr.TenantName = tenantName
+
+	exist, user, err := memphis_cache.GetUser(apr.Username, apr.TenantName, false)
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]addStationPartitionsDirect at memphis_cache.GetUser: Station %v: %v", apr.TenantName, apr.Username, apr.StationName, err.Error())
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, err)
+		return
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("user %v does not exist", apr.Username)
+		s.Warnf("[tenant: %v][user: %v]addStationPartitionsDirect: %v", apr.TenantName, apr.Username, errMsg)
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, errors.New(errMsg))
+		return
+	}
+
+	stationName, err := StationNameFromStr(apr.StationName)
+	if err != nil {
+		s.Warnf("[tenant: %v][user: %v]addStationPartitionsDirect at StationNameFromStr: Station %v: %v", apr.TenantName, apr.Username, apr.StationName, err.Error())
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, err)
+		return
+	}
+
+	allowed, _, err := ValidateStationPermissions(user.Roles, stationName.Ext(), user.TenantName, "write")
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]addStationPartitionsDirect at ValidateStationPermissions: Station %v: %v", apr.TenantName, apr.Username, apr.StationName, err.Error())
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, err)
+		return
+	}
+	if !allowed {
+		errMsg := fmt.Sprintf("user %v is not allowed to update station %v", apr.Username, stationName.Ext())
+		s.Warnf("[tenant: %v][user: %v]addStationPartitionsDirect: %v", apr.TenantName, apr.Username, errMsg)
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, errors.New(errMsg))
+		return
+	}
+
+	exist, station, err := db.GetStationByName(stationName.Ext(), apr.TenantName)
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]addStationPartitionsDirect at GetStationByName: Station %v: %v", apr.TenantName, apr.Username, apr.StationName, err.Error())
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, err)
+		return
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("station %v does not exist", stationName.Ext())
+		s.Warnf("[tenant: %v][user: %v]addStationPartitionsDirect: %v", apr.TenantName, apr.Username, errMsg)
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, errors.New(errMsg))
+		return
+	}
+
+	_, showable, err := s.addStationPartitions(station, apr.PartitionsNumber, user)
+	if err != nil {
+		if showable {
+			s.Warnf("[tenant: %v][user: %v]addStationPartitionsDirect at addStationPartitions: Station %v: %v", apr.TenantName, apr.Username, apr.StationName, err.Error())
+		} else {
+			s.Errorf("[tenant: %v][user: %v]addStationPartitionsDirect at addStationPartitions: Station %v: %v", apr.TenantName, apr.Username, apr.StationName, err.Error())
+		}
+		respondWithErr(s.MemphisGlobalAccountString(), s, reply, err)
+		return
+	}
+	respondWithErr(s.MemphisGlobalAccountString(), s, reply, nil)
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/memphisdev/memphis/db"
 	"github.com/memphisdev/memphis/memphis_cache"
 	"github.com/memphisdev/memphis/models"
+	"github.com/memphisdev/memphis/tracing"
 	"github.com/memphisdev/memphis/utils"
 
 	"github.com/gin-gonic/gin"
@@ -36,6 +37,7 @@ const (
 	stationObjectName       = "Station"
 	schemaToDlsUpdateType   = "schemaverse_to_dls"
 	removeStationUpdateType = "remove_station"
+	partitionsUpdateType    = "partitions_update"
 )
 
 type StationName struct {
@@ -609,6 +611,9 @@ func (sh StationsHandler) GetStation(c *gin.Context) {
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 		return
 	}
+	if !validateStationAccess(c, user, stationName, "read", "GetStation") {
+		return
+	}
 	exist, station, err := db.GetStationByName(stationName, user.TenantName)
 	if err != nil {
 		serv.Errorf("[tenant: %v][user: %v]GetStation at GetStationByName: Station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
@@ -645,28 +650,31 @@ func (sh StationsHandler) GetStation(c *gin.Context) {
 	}
 
 	stationResponse := models.GetStationResponseSchema{
-		ID:                   station.ID,
-		Name:                 station.Name,
-		RetentionType:        station.RetentionType,
-		RetentionValue:       station.RetentionValue,
-		StorageType:          station.StorageType,
-		Replicas:             station.Replicas,
-		CreatedBy:            station.CreatedBy,
-		CreatedByUsername:    station.CreatedByUsername,
-		CreatedAt:            station.CreatedAt,
-		LastUpdate:           station.UpdatedAt,
-		IsDeleted:            station.IsDeleted,
-		IdempotencyWindow:    station.IdempotencyWindow,
-		IsNative:             station.IsNative,
-		DlsConfiguration:     models.DlsConfiguration{Poison: station.DlsConfigurationPoison, Schemaverse: station.DlsConfigurationSchemaverse},
-		TieredStorageEnabled: station.TieredStorageEnabled,
-		Tags:                 tags,
-		ResendDisabled:       station.ResendDisabled,
-		PartitionsList:       station.PartitionsList,
-		PartitionsNumber:     len(station.PartitionsList),
-		DlsStation:           station.DlsStation,
-		FunctionsLockHeld:    station.FunctionsLockHeld,
-		FunctionsLockedAt:    station.FunctionsLockedAt,
+		ID:                      station.ID,
+		Name:                    station.Name,
+		RetentionType:           station.RetentionType,
+		RetentionValue:          station.RetentionValue,
+		StorageType:             station.StorageType,
+		Replicas:                station.Replicas,
+		CreatedBy:               station.CreatedBy,
+		CreatedByUsername:       station.CreatedByUsername,
+		CreatedAt:               station.CreatedAt,
+		LastUpdate:              station.UpdatedAt,
+		IsDeleted:               station.IsDeleted,
+		IdempotencyWindow:       station.IdempotencyWindow,
+		IsNative:                station.IsNative,
+		DlsConfiguration:        models.DlsConfiguration{Poison: station.DlsConfigurationPoison, Schemaverse: station.DlsConfigurationSchemaverse},
+		TieredStorageEnabled:    station.TieredStorageEnabled,
+		Tags:                    tags,
+		ResendDisabled:          station.ResendDisabled,
+		PartitionsList:          station.PartitionsList,
+		PartitionsNumber:        len(station.PartitionsList),
+		DlsStation:              station.DlsStation,
+		FunctionsLockHeld:       station.FunctionsLockHeld,
+		FunctionsLockedAt:       station.FunctionsLockedAt,
+		BrokerSchemaEnforcement: station.BrokerSchemaEnforcement,
+		DlsRetryPolicy:          station.DlsRetryPolicy,
+		TieredStorageFormat:     station.TieredStorageFormat,
 	}
 
 	c.IndentedJSON(200, stationResponse)
@@ -949,6 +957,7 @@ func (sh StationsHandler) CreateStation(c *gin.Context) {
 		errMsg := fmt.Sprintf("user %v is not allowed to create station %v", user.Username, body.Name)
 		serv.Warnf("[tenant: %v][user: %v]CreateStation: %v", user.TenantName, user.Username, errMsg)
 		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
 	}
 	if ReloadNeeded {
 		defer func() {
@@ -1283,6 +1292,9 @@ func (sh StationsHandler) AttachDlsStation(c *gin.Context) {
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 		return
 	}
+	if !validateStationsAccess(c, user, append([]string{body.Name}, body.StationNames...), "write", "AttachDlsStation") {
+		return
+	}
 
 	exist, station, err := db.GetStationByName(body.Name, tenantName)
 	if err != nil {
@@ -1355,6 +1367,9 @@ func (sh StationsHandler) DetachDlsStation(c *gin.Context) {
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 		return
 	}
+	if !validateStationsAccess(c, user, body.StationNames, "write", "DetachDlsStation") {
+		return
+	}
 
 	err = db.UpdateStationsDls(body.StationNames, _EMPTY_, tenantName)
 	if err != nil {
@@ -1381,6 +1396,19 @@ func (sh StationsHandler) RemoveStation(c *gin.Context) {
 		return
 	}
 
+	// every station is checked before any of them is removed
+	for _, name := range body.StationNames {
+		stationName, err := StationNameFromStr(name)
+		if err != nil {
+			serv.Warnf("RemoveStation: Station %v: %v", name, err.Error())
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+			return
+		}
+		if !validateStationAccess(c, user, stationName.Ext(), "write", "RemoveStation") {
+			return
+		}
+	}
+
 	for _, name := range body.StationNames {
 		stationName, err := StationNameFromStr(name)
 		if err != nil {
@@ -1692,6 +1720,9 @@ func (sh StationsHandler) GetPoisonMessageJourney(c *gin.Context) {
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 		return
 	}
+	if !validateStationAccess(c, user, poisonMessage.StationName, "read", "GetPoisonMessageJourney") {
+		return
+	}
 
 	shouldSendAnalytics, _ := shouldSendAnalytics()
 	if shouldSendAnalytics {
@@ -1702,6 +1733,70 @@ func (sh StationsHandler) GetPoisonMessageJourney(c *gin.Context) {
 	c.IndentedJSON(200, poisonMessage)
 }
 
+func (sh StationsHandler) SearchDlsMessages(c *gin.Context) {
+	var body models.SearchDlsMessagesSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("SearchDlsMessages at getUserDetailsFromMiddleware: %v", err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	stationName, err := StationNameFromStr(body.StationName)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]SearchDlsMessages at StationNameFromStr: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+	if !validateStationAccess(c, user, stationName.Ext(), "read", "SearchDlsMessages") {
+		return
+	}
+
+	result, showable, err := searchDlsMessagesByStationName(sh.S, body, user.TenantName)
+	if err != nil {
+		if showable {
+			serv.Warnf("[tenant: %v][user: %v]SearchDlsMessages: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		} else {
+			serv.Errorf("[tenant: %v][user: %v]SearchDlsMessages: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		}
+		return
+	}
+
+	c.IndentedJSON(200, result)
+}
+
+// searchDlsMessagesByStationName is shared by the REST and websocket searches,
+// showable is set for errors caused by the request itself
+func searchDlsMessagesByStationName(s *Server, body models.SearchDlsMessagesSchema, tenantName string) (models.SearchDlsMessagesResponse, bool, error) {
+	stationName, err := StationNameFromStr(body.StationName)
+	if err != nil {
+		return models.SearchDlsMessagesResponse{}, true, err
+	}
+	exist, station, err := db.GetStationByName(stationName.Ext(), tenantName)
+	if err != nil {
+		return models.SearchDlsMessagesResponse{}, false, err
+	}
+	if !exist {
+		return models.SearchDlsMessagesResponse{}, true, fmt.Errorf("Station %v does not exist", body.StationName)
+	}
+
+	filter, err := getDlsMessagesFilter(body, station.ID)
+	if err != nil {
+		return models.SearchDlsMessagesResponse{}, true, err
+	}
+	poisonMsgsHandler := PoisonMessagesHandler{S: s}
+	result, err := poisonMsgsHandler.SearchDlsMessages(filter)
+	if err != nil {
+		return models.SearchDlsMessagesResponse{}, false, err
+	}
+	return result, false, nil
+}
+
 func (sh StationsHandler) DropDlsMessages(c *gin.Context) {
 	var body models.DropDlsMessagesSchema
 	ok := utils.Validate(c, &body, false, nil)
@@ -1709,16 +1804,43 @@ func (sh StationsHandler) DropDlsMessages(c *gin.Context) {
 		return
 	}
 
-	err := db.DropDlsMessages(body.DlsMessageIds)
+	user, err := getUserDetailsFromMiddleware(c)
 	if err != nil {
-		serv.Errorf("DropDlsMessages at db.DropDlsMessages: %v", err.Error())
+		serv.Errorf("DropDlsMessages at getUserDetailsFromMiddleware: At station %v: %v", body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	stationName, err := StationNameFromStr(body.StationName)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]DropDlsMessages at StationNameFromStr: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+	if !validateStationAccess(c, user, stationName.Ext(), "write", "DropDlsMessages") {
+		return
+	}
+	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]DropDlsMessages at GetStationByName: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("Station %v does not exist", body.StationName)
+		serv.Warnf("[tenant: %v][user: %v]DropDlsMessages: %v", user.TenantName, user.Username, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
+	}
+
+	err = db.DropDlsMessages(station.ID, body.DlsMessageIds)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]DropDlsMessages at db.DropDlsMessages: %v", user.TenantName, user.Username, err.Error())
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 		return
 	}
 
 	shouldSendAnalytics, _ := shouldSendAnalytics()
 	if shouldSendAnalytics {
-		user, _ := getUserDetailsFromMiddleware(c)
 		analyticsParams := make(map[string]interface{})
 		analytics.SendEvent(user.TenantName, user.Username, analyticsParams, "user-ack-poison-message")
 	}
@@ -1729,33 +1851,8 @@ func (sh StationsHandler) DropDlsMessages(c *gin.Context) {
 func (s *Server) ResendUnackedMsg(dlsMsg models.DlsMessage, user models.User, stationName string) (string, error) {
 	size := int64(0)
 	for _, cgName := range dlsMsg.PoisonedCgs {
-		headersJson := map[string]string{}
-		for key, value := range dlsMsg.MessageDetails.Headers {
-			headersJson[key] = value
-		}
-		headersJson["$memphis_pm_id"] = strconv.Itoa(dlsMsg.ID)
-		headersJson["$memphis_pm_cg_name"] = cgName
-
-		headers, err := json.Marshal(headersJson)
+		err := s.resendUnackedMsgToCg(dlsMsg, user.TenantName, stationName, cgName)
 		if err != nil {
-			err = fmt.Errorf("Failed ResendUnackedMsg at json.Marshal: Poisoned consumer group: %v: %v", cgName, err.Error())
-			return cgName, err
-		}
-
-		data, err := hex.DecodeString(dlsMsg.MessageDetails.Data)
-		if err != nil {
-			err = fmt.Errorf("Failed ResendUnackedMsg at DecodeString: Poisoned consumer group: %v: %v", cgName, err.Error())
-			return cgName, err
-		}
-		//resend to both old and new subject convention
-		err = s.ResendPoisonMessage(user.TenantName, fmt.Sprintf(dlsResendMessagesStreamNew, replaceDelimiters(stationName), replaceDelimiters(cgName)), []byte(data), headers)
-		if err != nil {
-			err = fmt.Errorf("Failed ResendUnackedMsg at ResendPoisonMessage: Poisoned consumer group: %v: %v", cgName, err.Error())
-			return cgName, err
-		}
-		err = s.ResendPoisonMessage(user.TenantName, fmt.Sprintf(dlsResendMessagesStreamOld, replaceDelimiters(stationName), replaceDelimiters(cgName)), []byte(data), headers)
-		if err != nil {
-			err = fmt.Errorf("Failed ResendUnackedMsg at ResendPoisonMessage: Poisoned consumer group: %v: %v", cgName, err.Error())
 			return cgName, err
 		}
 		size += int64(dlsMsg.MessageDetails.Size)
@@ -1764,6 +1861,39 @@ func (s *Server) ResendUnackedMsg(dlsMsg models.DlsMessage, user models.User, st
 	return _EMPTY_, nil
 }
 
+func (s *Server) resendUnackedMsgToCg(dlsMsg models.DlsMessage, tenantName, stationName, cgName string) (err error) {
+	span := startStationSpan("dls resend "+stationName, tracing.SpanKindProducer, dlsMsg.MessageDetails.Headers, tenantName, stationName)
+	span.SetAttribute("memphis.consumer_group", cgName)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	headersJson := headersWithTraceContext(dlsMsg.MessageDetails.Headers, span.Context())
+	headersJson["$memphis_pm_id"] = strconv.Itoa(dlsMsg.ID)
+	headersJson["$memphis_pm_cg_name"] = cgName
+
+	headers, err := json.Marshal(headersJson)
+	if err != nil {
+		return fmt.Errorf("Failed ResendUnackedMsg at json.Marshal: Poisoned consumer group: %v: %v", cgName, err.Error())
+	}
+
+	data, err := hex.DecodeString(dlsMsg.MessageDetails.Data)
+	if err != nil {
+		return fmt.Errorf("Failed ResendUnackedMsg at DecodeString: Poisoned consumer group: %v: %v", cgName, err.Error())
+	}
+	//resend to both old and new subject convention
+	err = s.ResendPoisonMessage(tenantName, fmt.Sprintf(dlsResendMessagesStreamNew, replaceDelimiters(stationName), replaceDelimiters(cgName)), []byte(data), headers)
+	if err != nil {
+		return fmt.Errorf("Failed ResendUnackedMsg at ResendPoisonMessage: Poisoned consumer group: %v: %v", cgName, err.Error())
+	}
+	err = s.ResendPoisonMessage(tenantName, fmt.Sprintf(dlsResendMessagesStreamOld, replaceDelimiters(stationName), replaceDelimiters(cgName)), []byte(data), headers)
+	if err != nil {
+		return fmt.Errorf("Failed ResendUnackedMsg at ResendPoisonMessage: Poisoned consumer group: %v: %v", cgName, err.Error())
+	}
+	return nil
+}
+
 func (sh StationsHandler) ResendPoisonMessages(c *gin.Context) {
 	var body models.ResendPoisonMessagesSchema
 	ok := utils.Validate(c, &body, false, nil)
@@ -1785,6 +1915,9 @@ func (sh StationsHandler) ResendPoisonMessages(c *gin.Context) {
 	}
 
 	stationName := strings.ToLower(body.StationName)
+	if !validateStationAccess(c, user, stationName, "write", "ResendPoisonMessages") {
+		return
+	}
 	exist, station, err := db.GetStationByName(stationName, user.TenantName)
 	if err != nil {
 		serv.Errorf("[tenant: %v][user: %v]ResendPoisonMessages at GetStationByName: %v", user.TenantName, user.Username, err.Error())
@@ -1809,6 +1942,12 @@ func (sh StationsHandler) ResendPoisonMessages(c *gin.Context) {
 				c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 				return
 			}
+			if dlsMsg.StationId != station.ID {
+				errMsg := fmt.Sprintf("Message %v does not belong to station %v", id, stationName)
+				serv.Warnf("[tenant: %v][user: %v]ResendPoisonMessages: %v", user.TenantName, user.Username, errMsg)
+				c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+				return
+			}
 			cgName, err := sh.S.ResendUnackedMsg(dlsMsg, user, stationName)
 			if err != nil {
 				serv.Errorf("[tenant: %v][user: %v]ResendPoisonMessages at ResendUnackedMsg: Poisoned consumer group: %v: %v", user.TenantName, user.Username, cgName, err.Error())
@@ -1842,6 +1981,16 @@ func (sh StationsHandler) GetMessageDetails(c *gin.Context) {
 		return
 	}
 
+	stationName, err := StationNameFromStr(body.StationName)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]GetMessageDetails at StationNameFromStr: Message ID: %v: %v", user.TenantName, user.Username, strconv.Itoa(msgId), err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+	if !validateStationAccess(c, user, stationName.Ext(), "read", "GetMessageDetails") {
+		return
+	}
+
 	poisonMsgsHandler := PoisonMessagesHandler{S: sh.S}
 	if body.IsDls {
 		dlsMessage, err := poisonMsgsHandler.GetDlsMessageDetails(body.MessageId, body.DlsType, user.TenantName)
@@ -1855,13 +2004,6 @@ func (sh StationsHandler) GetMessageDetails(c *gin.Context) {
 		return
 	}
 
-	stationName, err := StationNameFromStr(body.StationName)
-	if err != nil {
-		serv.Warnf("[tenant: %v][user: %v]GetMessageDetails at StationNameFromStr: Message ID: %v: %v", user.TenantName, user.Username, strconv.Itoa(msgId), err.Error())
-		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
-		return
-	}
-
 	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
 	if err != nil {
 		serv.Errorf("[tenant: %v][user: %v]GetMessageDetails at GetStationByName: Message ID: %v: %v", user.TenantName, user.Username, strconv.Itoa(msgId), err.Error())
@@ -2011,6 +2153,10 @@ func (sh StationsHandler) UseSchema(c *gin.Context) {
 		SchemaType:       schema.Type,
 	}
 
+	if !validateStationsAccess(c, user, body.StationNames, "write", "UseSchema") {
+		return
+	}
+
 	shouldSendAnalytics, _ := shouldSendAnalytics()
 	for _, stationName := range body.StationNames {
 		stationName, err := StationNameFromStr(stationName)
@@ -2268,6 +2414,9 @@ func (sh StationsHandler) RemoveSchemaFromStation(c *gin.Context) {
 		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
 		return
 	}
+	if !validateStationAccess(c, user, stationName.Ext(), "write", "RemoveSchemaFromStation") {
+		return
+	}
 	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
 	if err != nil {
 		serv.Errorf("[tenant: %v][user: %v]RemoveSchemaFromStation at GetStationByName: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
@@ -2335,6 +2484,9 @@ func (sh StationsHandler) GetUpdatesForSchemaByStation(c *gin.Context) {
 		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
 		return
 	}
+	if !validateStationAccess(c, user, stationName.Ext(), "read", "GetUpdatesForSchemaByStation") {
+		return
+	}
 
 	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
 	if err != nil {
@@ -2401,6 +2553,9 @@ func (sh StationsHandler) UpdateDlsConfig(c *gin.Context) {
 		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
 		return
 	}
+	if !validateStationAccess(c, user, stationName.Ext(), "write", "UpdateDlsConfig") {
+		return
+	}
 
 	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
 	if err != nil {
@@ -2435,6 +2590,293 @@ func (sh StationsHandler) UpdateDlsConfig(c *gin.Context) {
 	c.IndentedJSON(200, gin.H{"poison": body.Poison, "schemaverse": body.Schemaverse})
 }
 
+func (sh StationsHandler) UpdateSchemaEnforcement(c *gin.Context) {
+	var body models.UpdateSchemaEnforcementSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("UpdateSchemaEnforcement at getUserDetailsFromMiddleware: At station %v: %v", body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	stationName, err := StationNameFromStr(body.StationName)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]UpdateSchemaEnforcement at StationNameFromStr: At station, %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+	if !validateStationAccess(c, user, stationName.Ext(), "write", "UpdateSchemaEnforcement") {
+		return
+	}
+
+	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]UpdateSchemaEnforcement at GetStationByName: At station, %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("Station %v does not exist", body.StationName)
+		serv.Warnf("[tenant: %v][user: %v]UpdateSchemaEnforcement: %v", user.TenantName, user.Username, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
+	}
+
+	if body.Enforced {
+		if station.SchemaName == _EMPTY_ {
+			errMsg := fmt.Sprintf("Station %v has no schema attached", body.StationName)
+			serv.Warnf("[tenant: %v][user: %v]UpdateSchemaEnforcement: %v", user.TenantName, user.Username, errMsg)
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+			return
+		}
+		if !ValidataAccessToFeature(user.TenantName, "feature-schemaverse-enforcement") {
+			errMsg := "broker schema enforcement is not supported in your pricing plan, please upgrade your plan to enjoy this feature"
+			serv.Warnf("[tenant: %v][user: %v]UpdateSchemaEnforcement: %v", user.TenantName, user.Username, errMsg)
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+			return
+		}
+	}
+
+	if station.BrokerSchemaEnforcement != body.Enforced {
+		err = db.UpdateStationBrokerSchemaEnforcement(station.Name, body.Enforced, station.TenantName)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]UpdateSchemaEnforcement at db.UpdateStationBrokerSchemaEnforcement: At station, %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+			return
+		}
+
+		err = sh.S.refreshBrokerSchemaEnforcement()
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]UpdateSchemaEnforcement at refreshBrokerSchemaEnforcement: At station, %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		}
+
+		message := fmt.Sprintf("Broker schema enforcement has been %v for station %v by user %v", map[bool]string{true: "enabled", false: "disabled"}[body.Enforced], stationName.Ext(), user.Username)
+		serv.Noticef("[tenant: %v][user: %v]: %v", user.TenantName, user.Username, message)
+		var auditLogs []interface{}
+		newAuditLog := models.AuditLog{
+			StationName:       stationName.Ext(),
+			Message:           message,
+			CreatedBy:         user.ID,
+			CreatedByUsername: user.Username,
+			CreatedAt:         time.Now(),
+			TenantName:        user.TenantName,
+		}
+		auditLogs = append(auditLogs, newAuditLog)
+		err = CreateAuditLogs(auditLogs)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]UpdateSchemaEnforcement: Station %v - create audit logs error: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		}
+	}
+
+	c.IndentedJSON(200, gin.H{"broker_schema_enforcement": body.Enforced})
+}
+
+func (sh StationsHandler) UpdateDlsRetryPolicy(c *gin.Context) {
+	var body models.UpdateDlsRetryPolicySchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("UpdateDlsRetryPolicy at getUserDetailsFromMiddleware: At station %v: %v", body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	stationName, err := StationNameFromStr(body.StationName)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]UpdateDlsRetryPolicy at StationNameFromStr: At station, %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+	if !validateStationAccess(c, user, stationName.Ext(), "write", "UpdateDlsRetryPolicy") {
+		return
+	}
+
+	err = validateDlsRetryPolicy(body.DlsRetryPolicy)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]UpdateDlsRetryPolicy at validateDlsRetryPolicy: At station, %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+
+	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]UpdateDlsRetryPolicy at GetStationByName: At station, %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("Station %v does not exist", body.StationName)
+		serv.Warnf("[tenant: %v][user: %v]UpdateDlsRetryPolicy: %v", user.TenantName, user.Username, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
+	}
+	if body.DlsRetryPolicy.MaxRetries > 0 && !station.IsNative {
+		errMsg := "Automatic DLS retries are supported only for stations created by Memphis SDKs"
+		serv.Warnf("[tenant: %v][user: %v]UpdateDlsRetryPolicy: %v", user.TenantName, user.Username, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
+	}
+
+	if station.DlsRetryPolicy != body.DlsRetryPolicy {
+		err = db.UpdateStationDlsRetryPolicy(station.Name, body.DlsRetryPolicy, station.TenantName)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]UpdateDlsRetryPolicy at db.UpdateStationDlsRetryPolicy: At station, %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+			return
+		}
+
+		message := fmt.Sprintf("DLS retry policy has been updated to %v retries for station %v by user %v", body.DlsRetryPolicy.MaxRetries, stationName.Ext(), user.Username)
+		serv.Noticef("[tenant: %v][user: %v]: %v", user.TenantName, user.Username, message)
+		var auditLogs []interface{}
+		newAuditLog := models.AuditLog{
+			StationName:       stationName.Ext(),
+			Message:           message,
+			CreatedBy:         user.ID,
+			CreatedByUsername: user.Username,
+			CreatedAt:         time.Now(),
+			TenantName:        user.TenantName,
+		}
+		auditLogs = append(auditLogs, newAuditLog)
+		err = CreateAuditLogs(auditLogs)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]UpdateDlsRetryPolicy: Station %v - create audit logs error: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		}
+	}
+
+	c.IndentedJSON(200, gin.H{"dls_retry_policy": body.DlsRetryPolicy})
+}
+
+func (sh StationsHandler) UpdateTieredStorageFormat(c *gin.Context) {
+	var body models.UpdateTieredStorageFormatSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("UpdateTieredStorageFormat at getUserDetailsFromMiddleware: At station %v: %v", body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	stationName, err := StationNameFromStr(body.StationName)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]UpdateTieredStorageFormat at StationNameFromStr: At station, %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+	if !validateStationAccess(c, user, stationName.Ext(), "write", "UpdateTieredStorageFormat") {
+		return
+	}
+
+	format := strings.ToLower(body.Format)
+	err = validateTieredStorageFormat(format)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]UpdateTieredStorageFormat at validateTieredStorageFormat: At station, %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+
+	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]UpdateTieredStorageFormat at GetStationByName: At station, %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("Station %v does not exist", body.StationName)
+		serv.Warnf("[tenant: %v][user: %v]UpdateTieredStorageFormat: %v", user.TenantName, user.Username, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
+	}
+
+	if station.TieredStorageFormat != format {
+		err = db.UpdateStationTieredStorageFormat(station.Name, format, station.TenantName)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]UpdateTieredStorageFormat at db.UpdateStationTieredStorageFormat: At station, %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+			return
+		}
+
+		message := fmt.Sprintf("Tiered storage format has been changed to %v for station %v by user %v", format, stationName.Ext(), user.Username)
+		serv.Noticef("[tenant: %v][user: %v]: %v", user.TenantName, user.Username, message)
+		var auditLogs []interface{}
+		newAuditLog := models.AuditLog{
+			StationName:       stationName.Ext(),
+			Message:           message,
+			CreatedBy:         user.ID,
+			CreatedByUsername: user.Username,
+			CreatedAt:         time.Now(),
+			TenantName:        user.TenantName,
+		}
+		auditLogs = append(auditLogs, newAuditLog)
+		err = CreateAuditLogs(auditLogs)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]UpdateTieredStorageFormat: Station %v - create audit logs error: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		}
+	}
+
+	c.IndentedJSON(200, gin.H{"tiered_storage_format": format})
+}
+
+func (sh StationsHandler) GetTieredStorageReconciliation(c *gin.Context) {
+	var body models.GetTieredStorageReconciliationSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("GetTieredStorageReconciliation at getUserDetailsFromMiddleware: At station %v: %v", body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	stationName, err := StationNameFromStr(body.StationName)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]GetTieredStorageReconciliation at StationNameFromStr: At station, %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+	if !validateStationAccess(c, user, stationName.Ext(), "read", "GetTieredStorageReconciliation") {
+		return
+	}
+
+	integrationType, err := resolveTieredStorageIntegration(user.TenantName, body.IntegrationType)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]GetTieredStorageReconciliation at resolveTieredStorageIntegration: %v", user.TenantName, user.Username, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+	backend, err := getTenantTieredStorageBackend(user.TenantName, integrationType)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]GetTieredStorageReconciliation at getTenantTieredStorageBackend: At station, %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	report, err := reconcileTieredStorage(backend, user.TenantName, stationName.Ext())
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]GetTieredStorageReconciliation at reconcileTieredStorage: At station, %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": fmt.Sprintf("Failed listing the objects of %v: %v", tieredStorageBackendTypes[integrationType].displayName, err.Error())})
+		return
+	}
+	report.Integration = integrationType
+
+	c.IndentedJSON(200, report)
+}
+
 func (sh StationsHandler) PurgeStation(c *gin.Context) {
 	var body models.PurgeStationSchema
 	ok := utils.Validate(c, &body, false, nil)
@@ -2456,6 +2898,10 @@ func (sh StationsHandler) PurgeStation(c *gin.Context) {
 		return
 	}
 
+	if !validateStationAccess(c, user, stationName.Ext(), "write", "PurgeStation") {
+		return
+	}
+
 	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
 	if err != nil {
 		serv.Errorf("[tenant: %v][user: %v]PurgeStation at GetStationByName: %v", user.TenantName, user.Username, err.Error())
@@ -2547,6 +2993,10 @@ func (sh StationsHandler) RemoveMessages(c *gin.Context) {
 		return
 	}
 
+	if !validateStationAccess(c, user, stationName.Ext(), "write", "RemoveMessages") {
+		return
+	}
+
 	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
 	if err != nil {
 		serv.Errorf("[tenant: %v][user: %v]RemoveMessages at GetStationByName: %v", user.TenantName, user.Username, err.Error())
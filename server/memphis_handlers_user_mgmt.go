@@ -27,6 +27,7 @@ import (
 
 	"github.com/memphisdev/memphis/analytics"
 	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/ldap_auth"
 	"github.com/memphisdev/memphis/models"
 	"github.com/memphisdev/memphis/utils"
 
@@ -63,7 +64,12 @@ func authenticateUser(username string, password string) (bool, models.User, erro
 	exist, user, err := db.GetUserForLogin(username)
 	if err != nil {
 		return false, models.User{}, err
-	} else if !exist {
+	}
+	// unknown users and users the directory provisioned are authenticated by the directory
+	if ldap_auth.Enabled() && (!exist || ldap_auth.ProvisionedUser(user)) {
+		return authenticateLdapUser(username, password)
+	}
+	if !exist {
 		return false, models.User{}, nil
 	}
 
@@ -176,6 +182,11 @@ func removeTenantResources(tenantName string, user models.User) error {
 		return err
 	}
 
+	err = db.RemoveApiKeysByTenant(tenantName)
+	if err != nil {
+		return err
+	}
+
 	err = db.RemovePermissionsByTenant(tenantName)
 	if err != nil {
 		return err
@@ -12,6 +12,7 @@
 package server
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -43,6 +44,7 @@ const (
 	memphisWS_subj_GetAllFunctions      = "get_all_functions"
 	memphisWS_subj_GetGraphOverview     = "get_graph_overview"
 	memphisWS_subj_GetFunctionsOverview = "get_functions_overview"
+	memphisWS_Subj_SearchDlsMessages    = "search_dls_messages"
 )
 
 type memphisWSReqFiller func(tenantName string) (any, error)
@@ -280,6 +282,21 @@ func memphisWSGetReqFillerFromSubj(s *Server, h *Handlers, subj string, tenantNa
 			}
 			return h.Monitoring.GetFunctionsOverview(stationName, tenantName, partitionInt)
 		}, nil
+	case memphisWS_Subj_SearchDlsMessages:
+		// the search params are passed as a base64url encoded json since their values may contain dots
+		searchJson, err := base64.RawURLEncoding.DecodeString(tokenAt(subj, 2))
+		if err != nil {
+			return nil, errors.New("invalid dls messages search params")
+		}
+		var body models.SearchDlsMessagesSchema
+		err = json.Unmarshal(searchJson, &body)
+		if err != nil || body.StationName == _EMPTY_ {
+			return nil, errors.New("invalid dls messages search params")
+		}
+		return func(string) (any, error) {
+			result, _, err := searchDlsMessagesByStationName(s, body, tenantName)
+			return result, err
+		}, nil
 	default:
 		return nil, errors.New("invalid subject")
 	}
@@ -59,6 +59,7 @@ const (
 	dlsResendMessagesStreamNew  = "$memphis_dls_%v.%v"
 	dlsResendMessagesStreamOld  = "$memphis_dls_%v_%v"
 	tieredStorageStream         = "$memphis_tiered_storage"
+	scheduledMsgsStream         = "$memphis_scheduled_msgs"
 	throughputStreamName        = "$memphis-throughput"
 	throughputStreamNameV1      = "$memphis-throughput-v1"
 	MEMPHIS_GLOBAL_ACCOUNT      = "$memphis"
@@ -75,6 +76,10 @@ const (
 	memphisSchemaCreations      = "$memphis_schema_creations"
 	memphisStationCreations     = "$memphis_station_creations"
 	memphisStationDestructions  = "$memphis_station_destructions"
+	memphisStationPartitions    = "$memphis_station_partitions_updates"
+	memphisCgOffsetResets       = "$memphis_cg_offset_resets"
+	memphisCgPauses             = "$memphis_cg_pauses"
+	memphisCgResumes            = "$memphis_cg_resumes"
 )
 
 var noLimit = -1
@@ -163,6 +168,8 @@ var (
 	SYSTEM_TASKS_STREAM_CREATED            bool
 	FUNCTIONS_TASKS_CONSUMER_CREATED       bool
 	CONNECTORS_LOGS_STREAM_CREATED         bool
+	SCHEDULED_MSGS_STREAM_CREATED          bool
+	SCHEDULED_MSGS_CONSUMER_CREATED        bool
 )
 
 type Messages []models.MessageDetails
@@ -378,6 +385,49 @@ func tryCreateInternalJetStreamResources(s *Server, retentionDur time.Duration,
 		TIERED_STORAGE_CONSUMER_CREATED = true
 	}
 
+	// scheduled messages stream
+	if !SCHEDULED_MSGS_STREAM_CREATED {
+		err = s.memphisAddStream(s.MemphisGlobalAccountString(), &StreamConfig{
+			Name:         scheduledMsgsStream,
+			Subjects:     []string{scheduledMsgsStream + ".>"},
+			Retention:    WorkQueuePolicy,
+			MaxAge:       scheduledMsgsMaxDelay + time.Hour*24,
+			MaxConsumers: -1,
+			Discard:      DiscardOld,
+			Storage:      FileStorage,
+			Replicas:     replicas,
+		})
+		if err != nil && IsNatsErr(err, JSClusterNoPeersErrF) {
+			time.Sleep(1 * time.Second)
+			tryCreateInternalJetStreamResources(s, retentionDur, successCh, isCluster)
+			return
+		}
+		if err != nil && !IsNatsErr(err, JSStreamNameExistErr) {
+			successCh <- err
+			return
+		}
+		SCHEDULED_MSGS_STREAM_CREATED = true
+	}
+
+	// create scheduled messages consumer
+	if !SCHEDULED_MSGS_CONSUMER_CREATED {
+		cc := ConsumerConfig{
+			DeliverPolicy: DeliverAll,
+			AckPolicy:     AckExplicit,
+			Durable:       SCHEDULED_MSGS_CONSUMER,
+			FilterSubject: scheduledMsgsStream + ".>",
+			AckWait:       time.Duration(30) * time.Second,
+			MaxAckPending: -1,
+			MaxDeliver:    -1,
+		}
+		err = serv.memphisAddConsumer(s.MemphisGlobalAccountString(), scheduledMsgsStream, &cc)
+		if err != nil {
+			successCh <- err
+			return
+		}
+		SCHEDULED_MSGS_CONSUMER_CREATED = true
+	}
+
 	// dls unacked messages stream
 	if !DLS_UNACKED_STREAM_CREATED {
 		err = s.memphisAddStream(s.MemphisGlobalAccountString(), &StreamConfig{
@@ -938,6 +988,67 @@ func (s *Server) memphisRemoveConsumer(tenantName, streamName, cn string) error
 	return resp.ToError()
 }
 
+// low level call, call only with internal station name (i.e stream name)!
+func (s *Server) memphisConsumerInfo(tenantName, streamName, cn string) (*ConsumerInfo, error) {
+	requestSubject := fmt.Sprintf(JSApiConsumerInfoT, streamName, cn)
+	var resp JSApiConsumerInfoResponse
+	err := jsApiRequest(tenantName, s, requestSubject, kindConsumerInfo, []byte(_EMPTY_), &resp)
+	if err != nil {
+		return nil, err
+	}
+	err = resp.ToError()
+	if err != nil {
+		return nil, err
+	}
+	return resp.ConsumerInfo, nil
+}
+
+func (s *Server) memphisListConsumers(tenantName, streamName string) ([]*ConsumerInfo, error) {
+	requestSubject := fmt.Sprintf(JSApiConsumerListT, streamName)
+	consumers := []*ConsumerInfo{}
+	for {
+		request := JSApiConsumersRequest{ApiPagedRequest: ApiPagedRequest{Offset: len(consumers)}}
+		rawRequest, err := json.Marshal(request)
+		if err != nil {
+			return nil, err
+		}
+		var resp JSApiConsumerListResponse
+		err = jsApiRequest(tenantName, s, requestSubject, kindConsumerInfo, []byte(rawRequest), &resp)
+		if err != nil {
+			return nil, err
+		}
+		err = resp.ToError()
+		if err != nil {
+			return nil, err
+		}
+		consumers = append(consumers, resp.Consumers...)
+		if len(resp.Consumers) == 0 || len(consumers) >= resp.Total {
+			break
+		}
+	}
+	return consumers, nil
+}
+
+// memphisAddEphemeralConsumer creates a consumer named by the server and returns its info
+func (s *Server) memphisAddEphemeralConsumer(tenantName, streamName string, cc *ConsumerConfig) (*ConsumerInfo, error) {
+	requestSubject := fmt.Sprintf(JSApiConsumerCreateT, streamName)
+	request := CreateConsumerRequest{Stream: streamName, Config: *cc}
+	rawRequest, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	var resp JSApiConsumerCreateResponse
+	err = jsApiRequest(tenantName, s, requestSubject, kindCreateConsumer, []byte(rawRequest), &resp)
+	if err != nil {
+		return nil, err
+	}
+	err = resp.ToError()
+	if err != nil {
+		return nil, err
+	}
+	return resp.ConsumerInfo, nil
+}
+
 func (s *Server) GetCgInfo(tenantName string, stationName StationName, cgName string, partitionsList []int) (ConsumerInfo, error) {
 	var resp JSApiConsumerInfoResponse
 	cgName = replaceDelimiters(cgName)
@@ -1489,6 +1600,29 @@ func (s *Server) memphisGetMessage(tenantName, streamName string, msgSeq uint64)
 	return resp.Message, nil
 }
 
+// memphisGetNextMessage returns the first message stored on the given subject starting at msgSeq
+func (s *Server) memphisGetNextMessage(tenantName, streamName, subject string, msgSeq uint64) (*StoredMsg, error) {
+	requestSubject := fmt.Sprintf(JSApiMsgGetT, streamName)
+	request := JSApiMsgGetRequest{Seq: msgSeq, NextFor: subject}
+	rawRequest, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp JSApiMsgGetResponse
+	err = jsApiRequest(tenantName, s, requestSubject, kindGetMsg, rawRequest, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	err = resp.ToError()
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Message, nil
+}
+
 func (s *Server) queueSubscribe(tenantName string, subj, queueGroupName string, cb simplifiedMsgHandler) error {
 	acc, err := s.lookupAccount(tenantName)
 	if err != nil {
@@ -1765,6 +1899,23 @@ func generateJSONString(accounts map[string]AccountConfig) (string, error) {
 	return newStr, nil
 }
 
+// getRolesNatsPermissions returns the connection permissions of a user with roles, the subjects of the stations
+// its roles allow and a deny list for the stations a deny rule matches
+func getRolesNatsPermissions(roleIds []int, tenantName string) (NatsPermissions, error) {
+	allowReadSubjects, allowWriteSubjects, err := GetAllowedSubjectsFromRoleIds(roleIds, tenantName)
+	if err != nil {
+		return NatsPermissions{}, err
+	}
+	denyReadSubjects, denyWriteSubjects, err := GetDeniedSubjectsFromRoleIds(roleIds, tenantName, allowReadSubjects, allowWriteSubjects)
+	if err != nil {
+		return NatsPermissions{}, err
+	}
+	return NatsPermissions{
+		Publish:   NatsAuthorization{Allow: allowWriteSubjects, Deny: denyWriteSubjects},
+		Subscribe: NatsAuthorization{Allow: allowReadSubjects, Deny: denyReadSubjects},
+	}, nil
+}
+
 func getAccountsAndUsersString() (string, error) {
 	decriptionKey := getAESKey()
 	users, err := db.GetAllUsersByType([]string{"application"})
@@ -1798,6 +1949,10 @@ func getAccountsAndUsersString() (string, error) {
 	}
 	tenantsToUsers := map[string][]UserConfig{}
 	for _, user := range users {
+		if user.Password == _EMPTY_ {
+			// provisioned from the directory, authenticated on connect by authenticateLdapClient
+			continue
+		}
 		tName := user.TenantName
 		decryptedUserPassword, err := DecryptAES(decriptionKey, user.Password)
 		if err != nil {
@@ -1805,19 +1960,16 @@ func getAccountsAndUsersString() (string, error) {
 		}
 		if tName == MEMPHIS_GLOBAL_ACCOUNT {
 			if len(user.Roles) > 0 {
-				allowReadSubjects, allowWriteSubjects, err := GetAllowedSubjectsFromRoleIds(user.Roles, tName)
+				permissions, err := getRolesNatsPermissions(user.Roles, tName)
 				if err != nil {
 					//return _EMPTY_, err
 					fmt.Printf("user: %v, err: %v\n", user.Username, err)
 				}
 
 				globalUsers = append(globalUsers, UserConfig{
-					User:     user.Username + "$1",
-					Password: decryptedUserPassword,
-					Permissions: NatsPermissions{
-						Publish:   NatsAuthorization{Allow: allowWriteSubjects},
-						Subscribe: NatsAuthorization{Allow: allowReadSubjects},
-					},
+					User:        user.Username + "$1",
+					Password:    decryptedUserPassword,
+					Permissions: permissions,
 				})
 			} else {
 				globalUsers = append(globalUsers, UserConfig{User: user.Username + "$1", Password: decryptedUserPassword})
@@ -1825,7 +1977,7 @@ func getAccountsAndUsersString() (string, error) {
 			continue
 		}
 		if len(user.Roles) > 0 {
-			allowReadSubjects, allowWriteSubjects, err := GetAllowedSubjectsFromRoleIds(user.Roles, tName)
+			permissions, err := getRolesNatsPermissions(user.Roles, tName)
 			if err != nil {
 				//return _EMPTY_, err
 				fmt.Printf("user: %v, err: %v\n", user.Username, err)
@@ -1833,21 +1985,15 @@ func getAccountsAndUsersString() (string, error) {
 
 			if usrMap, ok := tenantsToUsers[tName]; !ok {
 				tenantsToUsers[tName] = []UserConfig{{
-					User:     user.Username,
-					Password: decryptedUserPassword,
-					Permissions: NatsPermissions{
-						Publish:   NatsAuthorization{Allow: allowWriteSubjects},
-						Subscribe: NatsAuthorization{Allow: allowReadSubjects},
-					},
+					User:        user.Username,
+					Password:    decryptedUserPassword,
+					Permissions: permissions,
 				}}
 			} else {
 				tenantsToUsers[tName] = append(usrMap, UserConfig{
-					User:     user.Username,
-					Password: decryptedUserPassword,
-					Permissions: NatsPermissions{
-						Publish:   NatsAuthorization{Allow: allowWriteSubjects},
-						Subscribe: NatsAuthorization{Allow: allowReadSubjects},
-					},
+					User:        user.Username,
+					Password:    decryptedUserPassword,
+					Permissions: permissions,
 				})
 			}
 		} else {
@@ -0,0 +1,315 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/models"
+)
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// labeledCounter is a process lifetime counter kept per label set, new label sets are dropped
+// once the exporter series limit is reached so a misbehaving tenant can't grow it without bound.
+type labeledCounter struct {
+	sync.Mutex
+	m       map[string]float64
+	labels  map[string][]string
+	dropped uint64
+}
+
+func newLabeledCounter() *labeledCounter {
+	return &labeledCounter{m: map[string]float64{}, labels: map[string][]string{}}
+}
+
+func (lc *labeledCounter) add(value float64, labels ...string) {
+	key := strings.Join(labels, "\x00")
+	lc.Lock()
+	defer lc.Unlock()
+	if _, ok := lc.m[key]; !ok {
+		if len(lc.m) >= configuration.EXPORTER_MAX_SERIES {
+			lc.dropped++
+			return
+		}
+		lc.labels[key] = labels
+	}
+	lc.m[key] += value
+}
+
+func (lc *labeledCounter) write(w *metricsWriter, name string, labelNames ...string) {
+	lc.Lock()
+	keys := make([]string, 0, len(lc.m))
+	for k := range lc.m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		pairs := make([]string, 0, 2*len(labelNames))
+		for i, l := range lc.labels[k] {
+			pairs = append(pairs, labelNames[i], l)
+		}
+		w.sample(name, lc.m[k], pairs...)
+	}
+	w.dropped[name] += int(lc.dropped)
+	lc.Unlock()
+}
+
+var (
+	tieredStorageUploadedBytes  = newLabeledCounter()
+	tieredStorageUploadFailures = newLabeledCounter()
+	schemaValidationFailures    = newLabeledCounter()
+	lastSelfThroughput          atomic.Pointer[models.BrokerThroughput]
+)
+
+func recordTieredStorageUpload(tenantName string, bytes int64) {
+	tieredStorageUploadedBytes.add(float64(bytes), tenantName)
+}
+
+func recordTieredStorageUploadFailure(tenantName string) {
+	tieredStorageUploadFailures.add(1, tenantName)
+}
+
+func recordSchemaValidationFailure(tenantName, stationName string) {
+	schemaValidationFailures.add(1, tenantName, stationName)
+}
+
+// metricsWriter renders the Prometheus text exposition format and enforces the per family series limit
+type metricsWriter struct {
+	buf     bytes.Buffer
+	max     int
+	family  string
+	count   int
+	dropped map[string]int
+}
+
+func newMetricsWriter(maxSeries int) *metricsWriter {
+	return &metricsWriter{max: maxSeries, dropped: map[string]int{}}
+}
+
+func (w *metricsWriter) header(name, help, metricType string) {
+	w.family = name
+	w.count = 0
+	fmt.Fprintf(&w.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// sample writes a single series, labels are given as name/value pairs
+func (w *metricsWriter) sample(name string, value float64, labels ...string) {
+	if name == w.family {
+		if w.count >= w.max {
+			w.dropped[name]++
+			return
+		}
+		w.count++
+	}
+	w.buf.WriteString(name)
+	if len(labels) > 0 {
+		w.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.buf.WriteString(labels[i])
+			w.buf.WriteString(`="`)
+			w.buf.WriteString(escapeMetricLabel(labels[i+1]))
+			w.buf.WriteByte('"')
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	w.buf.WriteByte('\n')
+}
+
+func escapeMetricLabel(value string) string {
+	if !strings.ContainsAny(value, "\\\"\n") {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func (w *metricsWriter) writeDropped() {
+	w.header("memphis_exporter_dropped_series", "Series left out because of the exporter cardinality limit", "gauge")
+	names := make([]string, 0, len(w.dropped))
+	for name := range w.dropped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w.buf.WriteString(fmt.Sprintf("memphis_exporter_dropped_series{metric=\"%s\"} %d\n", name, w.dropped[name]))
+	}
+}
+
+// stationPartitionFromStream splits <station>$<partition> stream names, un-partitioned stations report partition 0
+func stationPartitionFromStream(streamName string) (string, string) {
+	intern, partition, found := strings.Cut(streamName, "$")
+	if !found {
+		partition = "0"
+	}
+	return StationNameFromStreamName(intern).Ext(), partition
+}
+
+type streamMetrics struct {
+	tenant    string
+	station   string
+	partition string
+	info      *StreamInfo
+	consumers []*ConsumerInfo
+}
+
+func (s *Server) collectStreamMetrics() ([]streamMetrics, error) {
+	tenants, err := db.GetAllTenants()
+	if err != nil {
+		return nil, err
+	}
+	result := []streamMetrics{}
+	for _, tenant := range tenants {
+		streams, err := s.memphisAllStreamsInfo(tenant.Name)
+		if err != nil {
+			s.Warnf("[tenant: %v]collectStreamMetrics at memphisAllStreamsInfo: %v", tenant.Name, err.Error())
+			continue
+		}
+		for _, stream := range streams {
+			if strings.HasPrefix(stream.Config.Name, MEMPHIS_GLOBAL_ACCOUNT) {
+				continue
+			}
+			station, partition := stationPartitionFromStream(stream.Config.Name)
+			sm := streamMetrics{tenant: tenant.Name, station: station, partition: partition, info: stream}
+			consumers, err := s.memphisListConsumers(tenant.Name, stream.Config.Name)
+			if err != nil {
+				s.Warnf("[tenant: %v]collectStreamMetrics at memphisListConsumers: station %v: %v", tenant.Name, station, err.Error())
+			}
+			for _, consumer := range consumers {
+				if consumer.Config == nil || consumer.Config.Durable == _EMPTY_ || strings.HasPrefix(consumer.Config.FilterSubject, MEMPHIS_GLOBAL_ACCOUNT) || !strings.HasSuffix(consumer.Config.FilterSubject, ".final") { // skip consumers that are not user consumers
+					continue
+				}
+				sm.consumers = append(sm.consumers, consumer)
+			}
+			result = append(result, sm)
+		}
+	}
+	return result, nil
+}
+
+func (s *Server) renderMetrics() []byte {
+	w := newMetricsWriter(configuration.EXPORTER_MAX_SERIES)
+
+	streams, err := s.collectStreamMetrics()
+	if err != nil {
+		s.Errorf("renderMetrics at collectStreamMetrics: %v", err.Error())
+	}
+	w.header("memphis_station_messages", "Messages stored in a station partition", "gauge")
+	for _, sm := range streams {
+		w.sample("memphis_station_messages", float64(sm.info.State.Msgs), "tenant", sm.tenant, "station", sm.station, "partition", sm.partition)
+	}
+	w.header("memphis_station_bytes", "Bytes stored in a station partition", "gauge")
+	for _, sm := range streams {
+		w.sample("memphis_station_bytes", float64(sm.info.State.Bytes), "tenant", sm.tenant, "station", sm.station, "partition", sm.partition)
+	}
+	w.header("memphis_cg_pending_messages", "Messages not yet delivered to a consumer group", "gauge")
+	for _, sm := range streams {
+		for _, consumer := range sm.consumers {
+			w.sample("memphis_cg_pending_messages", float64(consumer.NumPending), "tenant", sm.tenant, "station", sm.station, "partition", sm.partition, "cg", revertDelimiters(consumer.Name))
+		}
+	}
+	w.header("memphis_cg_ack_pending_messages", "Messages delivered to a consumer group and waiting for an ack", "gauge")
+	for _, sm := range streams {
+		for _, consumer := range sm.consumers {
+			w.sample("memphis_cg_ack_pending_messages", float64(consumer.NumAckPending), "tenant", sm.tenant, "station", sm.station, "partition", sm.partition, "cg", revertDelimiters(consumer.Name))
+		}
+	}
+
+	clients, err := db.GetStationsClientsCount()
+	if err != nil {
+		s.Errorf("renderMetrics at GetStationsClientsCount: %v", err.Error())
+	}
+	w.header("memphis_station_producers", "Connected producers of a station", "gauge")
+	for _, c := range clients {
+		w.sample("memphis_station_producers", float64(c.ProducersCount), "tenant", c.TenantName, "station", c.StationName)
+	}
+	w.header("memphis_station_consumers", "Connected consumers of a station", "gauge")
+	for _, c := range clients {
+		w.sample("memphis_station_consumers", float64(c.ConsumersCount), "tenant", c.TenantName, "station", c.StationName)
+	}
+
+	poisonCounts, err := db.GetPoisonMsgsCountPerCg()
+	if err != nil {
+		s.Errorf("renderMetrics at GetPoisonMsgsCountPerCg: %v", err.Error())
+	}
+	w.header("memphis_cg_poison_messages", "Poison messages of a consumer group waiting in the dead-letter station", "gauge")
+	for _, p := range poisonCounts {
+		w.sample("memphis_cg_poison_messages", float64(p.Count), "tenant", p.TenantName, "station", p.StationName, "cg", p.CgName)
+	}
+
+	dlsCounts, err := db.GetDlsMsgsCountByType()
+	if err != nil {
+		s.Errorf("renderMetrics at GetDlsMsgsCountByType: %v", err.Error())
+	}
+	w.header("memphis_dls_messages", "Messages in the dead-letter station by type", "gauge")
+	for _, d := range dlsCounts {
+		w.sample("memphis_dls_messages", float64(d.Count), "tenant", d.TenantName, "station", d.StationName, "type", d.MessageType)
+	}
+
+	w.header("memphis_tiered_storage_uploaded_bytes_total", "Bytes uploaded to tiered storage by this broker", "counter")
+	tieredStorageUploadedBytes.write(w, "memphis_tiered_storage_uploaded_bytes_total", "tenant")
+	w.header("memphis_tiered_storage_upload_failures_total", "Failed tiered storage uploads of this broker", "counter")
+	tieredStorageUploadFailures.write(w, "memphis_tiered_storage_upload_failures_total", "tenant")
+	w.header("memphis_schema_validation_failures_total", "Messages rejected by schemaverse validation", "counter")
+	schemaValidationFailures.write(w, "memphis_schema_validation_failures_total", "tenant", "station")
+
+	if tp := lastSelfThroughput.Load(); tp != nil {
+		w.header("memphis_throughput_read_bytes_per_second", "Bytes per second read from this broker", "gauge")
+		for _, tenant := range sortedKeys(tp.ReadMap) {
+			w.sample("memphis_throughput_read_bytes_per_second", float64(tp.ReadMap[tenant]), "tenant", tenant, "broker", tp.Name)
+		}
+		w.header("memphis_throughput_write_bytes_per_second", "Bytes per second written to this broker", "gauge")
+		for _, tenant := range sortedKeys(tp.WriteMap) {
+			w.sample("memphis_throughput_write_bytes_per_second", float64(tp.WriteMap[tenant]), "tenant", tenant, "broker", tp.Name)
+		}
+	}
+
+	w.writeDropped()
+	return w.buf.Bytes()
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// HandleMetrics exports Memphis level series in the Prometheus text format when the exporter is enabled
+func (s *Server) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.httpReqStats[MetricsPath]++
+	s.mu.Unlock()
+
+	if !configuration.EXPORTER {
+		http.Error(w, "exporter is disabled", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", metricsContentType)
+	w.Write(s.renderMetrics())
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMetricsWriterSeriesLimit(t *testing.T) {
+	w := newMetricsWriter(2)
+	w.header("memphis_station_messages", "help", "gauge")
+	w.sample("memphis_station_messages", 1, "tenant", "t", "station", "a")
+	w.sample("memphis_station_messages", 2, "tenant", "t", "station", "b")
+	w.sample("memphis_station_messages", 3, "tenant", "t", "station", "c")
+	w.header("memphis_station_bytes", "help", "gauge")
+	w.sample("memphis_station_bytes", 10, "tenant", "t", "station", `quo"te`)
+	w.writeDropped()
+
+	out := w.buf.String()
+	if strings.Contains(out, `station="c"`) {
+		t.Fatalf("series over the limit was exported:\n%v", out)
+	}
+	if !strings.Contains(out, `memphis_station_bytes{tenant="t",station="quo\"te"} 10`) {
+		t.Fatalf("expected an escaped label value:\n%v", out)
+	}
+	if !strings.Contains(out, `memphis_exporter_dropped_series{metric="memphis_station_messages"} 1`) {
+		t.Fatalf("expected the dropped series to be reported:\n%v", out)
+	}
+}
+
+func TestStationPartitionFromStream(t *testing.T) {
+	if station, partition := stationPartitionFromStream("orders$3"); station != "orders" || partition != "3" {
+		t.Fatalf("got %v %v", station, partition)
+	}
+	if station, partition := stationPartitionFromStream("orders"); station != "orders" || partition != "0" {
+		t.Fatalf("got %v %v", station, partition)
+	}
+}
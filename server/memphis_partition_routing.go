@@ -0,0 +1,142 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/memphisdev/memphis/db"
+)
+
+const (
+	partitionKeyHeader                 = "$memphis_partition_key"
+	partitionedStationsRefreshInterval = 10 * time.Second
+)
+
+// partitionedStations holds the partitions list of every partitioned station keyed the same way as
+// brokerEnforcedStations, so key based routing on the ingress path never touches the DB.
+var partitionedStations = struct {
+	sync.RWMutex
+	m map[string][]int
+}{m: map[string][]int{}}
+
+var hasPartitionedStations atomic.Bool
+
+// murmur2 is the hash used by the Kafka default partitioner, keeping it identical means
+// keys keep landing on the same partition index after migrating producers from Kafka.
+func murmur2(data []byte) int32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+	length := len(data)
+	h := seed ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16 | uint32(data[i+3])<<24
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	tail := length &^ 3
+	switch length % 4 {
+	case 3:
+		h ^= uint32(data[tail+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[tail+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[tail])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+func partitionForKey(key []byte, partitionsList []int) int {
+	idx := int(murmur2(key)&0x7fffffff) % len(partitionsList)
+	return partitionsList[idx]
+}
+
+func (s *Server) refreshPartitionedStations() error {
+	stations, err := db.GetPartitionedStations()
+	if err != nil {
+		return err
+	}
+
+	partitioned := make(map[string][]int, len(stations))
+	for _, station := range stations {
+		stationName, err := StationNameFromStr(station.StationName)
+		if err != nil {
+			continue
+		}
+		partitioned[brokerEnforcedStationKey(station.TenantName, stationName.Intern())] = station.PartitionsList
+	}
+
+	partitionedStations.Lock()
+	partitionedStations.m = partitioned
+	partitionedStations.Unlock()
+	hasPartitionedStations.Store(len(partitioned) > 0)
+	return nil
+}
+
+func setPartitionedStation(tenantName, stationIntern string, partitionsList []int) {
+	partitionedStations.Lock()
+	partitionedStations.m[brokerEnforcedStationKey(tenantName, stationIntern)] = partitionsList
+	partitionedStations.Unlock()
+	hasPartitionedStations.Store(true)
+}
+
+func (s *Server) RefreshPartitionedStations() {
+	ticker := time.NewTicker(partitionedStationsRefreshInterval)
+	for ; true; <-ticker.C {
+		err := s.refreshPartitionedStations()
+		if err != nil {
+			s.Errorf("RefreshPartitionedStations at refreshPartitionedStations: %v", err.Error())
+		}
+	}
+}
+
+// routeByPartitionKey rewrites a publish on the un-partitioned <station>.final subject of a partitioned
+// station into <station>$<partition>.final according to the $memphis_partition_key header.
+func (c *client) routeByPartitionKey(hdr []byte) {
+	acc := c.acc
+	if acc == nil {
+		return
+	}
+	stationIntern, partition, ok := stationFromFinalSubject(string(c.pa.subject))
+	if !ok || partition > 0 {
+		return
+	}
+	key := getHeader(partitionKeyHeader, hdr)
+	if len(key) == 0 {
+		return
+	}
+
+	partitionedStations.RLock()
+	partitionsList, ok := partitionedStations.m[brokerEnforcedStationKey(acc.GetName(), stationIntern)]
+	partitionedStations.RUnlock()
+	if !ok || len(partitionsList) == 0 {
+		return
+	}
+	c.pa.subject = []byte(fmt.Sprintf("%s$%v.final", stationIntern, partitionForKey(key, partitionsList)))
+}
@@ -0,0 +1,35 @@
+package server
+
+import "testing"
+
+func TestMurmur2(t *testing.T) {
+	// values taken from the Kafka client test suite
+	cases := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for key, expected := range cases {
+		if got := murmur2([]byte(key)); got != expected {
+			t.Fatalf("key %v: expected %v, got %v", key, expected, got)
+		}
+	}
+}
+
+func TestPartitionForKey(t *testing.T) {
+	partitionsList := []int{1, 2, 3, 4}
+	for _, key := range []string{"a", "user-1", "order-42"} {
+		first := partitionForKey([]byte(key), partitionsList)
+		for i := 0; i < 10; i++ {
+			if got := partitionForKey([]byte(key), partitionsList); got != first {
+				t.Fatalf("key %v: partition changed from %v to %v", key, first, got)
+			}
+		}
+		if first < 1 || first > 4 {
+			t.Fatalf("key %v: unexpected partition %v", key, first)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	scheduledMsgsDeliverAtHeader = "$memphis_deliver_at"
+	scheduledMsgsDelayMsHeader   = "$memphis_delay_ms"
+	scheduledMsgsSubject         = scheduledMsgsStream + ".msgs"
+	scheduledMsgsMaxDelay        = time.Hour * 24 * 30
+)
+
+type ScheduledMsg struct {
+	TenantName string            `json:"tenant_name"`
+	Subject    string            `json:"subject"`
+	DeliverAt  time.Time         `json:"deliver_at"`
+	Headers    map[string]string `json:"headers"`
+	Data       []byte            `json:"data"`
+}
+
+// getScheduledDeliveryTime returns the time a message should become visible to consumers,
+// $memphis_deliver_at (RFC3339 or unix milliseconds) takes precedence over $memphis_delay_ms
+func getScheduledDeliveryTime(hdr []byte, now time.Time) (time.Time, bool, error) {
+	if deliverAt := getHeader(scheduledMsgsDeliverAtHeader, hdr); len(deliverAt) > 0 {
+		value := strings.TrimSpace(string(deliverAt))
+		if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.UnixMilli(ms), true, nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, true, fmt.Errorf("%v header must be an RFC3339 timestamp or unix time in milliseconds", scheduledMsgsDeliverAtHeader)
+		}
+		return t, true, nil
+	}
+	if delay := getHeader(scheduledMsgsDelayMsHeader, hdr); len(delay) > 0 {
+		ms, err := strconv.ParseInt(strings.TrimSpace(string(delay)), 10, 64)
+		if err != nil || ms < 0 {
+			return time.Time{}, true, fmt.Errorf("%v header must be a non negative integer", scheduledMsgsDelayMsHeader)
+		}
+		return now.Add(time.Duration(ms) * time.Millisecond), true, nil
+	}
+	return time.Time{}, false, nil
+}
+
+// scheduleDelayedMsg parks a message published with a delivery time in the scheduled messages stream,
+// it returns true when the message was handled and should not be delivered now, a message that can not
+// be scheduled is rejected through its reply subject or delivered now when it has none
+func (c *client) scheduleDelayedMsg(subject, reply string, hdr, msg []byte) bool {
+	if !bytes.Contains(hdr, []byte(scheduledMsgsDeliverAtHeader)) && !bytes.Contains(hdr, []byte(scheduledMsgsDelayMsHeader)) {
+		return false
+	}
+	acc := c.acc
+	if acc == nil {
+		return false
+	}
+	stationIntern, partition, ok := stationFromFinalSubject(subject)
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	deliverAt, found, err := getScheduledDeliveryTime(hdr, now)
+	if !found {
+		return false
+	}
+	if err == nil && !deliverAt.After(now) {
+		return false
+	}
+	if err == nil && deliverAt.Sub(now) > scheduledMsgsMaxDelay {
+		err = fmt.Errorf("scheduled delivery time can not be more than %v days ahead", int(scheduledMsgsMaxDelay.Hours()/24))
+	}
+	if err == nil && !SCHEDULED_MSGS_STREAM_CREATED {
+		err = errors.New("scheduled delivery is not available yet")
+	}
+
+	s := c.srv
+	if err == nil {
+		var headers map[string]string
+		headers, err = DecodeHeader(hdr)
+		if err == nil {
+			delete(headers, scheduledMsgsDeliverAtHeader)
+			delete(headers, scheduledMsgsDelayMsHeader)
+			scheduledMsg := ScheduledMsg{
+				TenantName: acc.GetName(),
+				Subject:    subject,
+				DeliverAt:  deliverAt,
+				Headers:    headers,
+				Data:       copyBytes(msg),
+			}
+			err = s.sendInternalAccountMsg(s.MemphisGlobalAccount(), scheduledMsgsSubject, scheduledMsg)
+		}
+	}
+	if err != nil {
+		// a publisher that does not wait for an ack can not be told the message was rejected,
+		// so instead of dropping it the message is delivered right away
+		if reply == _EMPTY_ {
+			s.Warnf("[tenant: %v]scheduleDelayedMsg: station %v: delivering the message without a delay: %v", acc.GetName(), stationIntern, err.Error())
+			return false
+		}
+		s.Warnf("[tenant: %v]scheduleDelayedMsg: station %v: %v", acc.GetName(), stationIntern, err.Error())
+	}
+
+	if reply != _EMPTY_ {
+		streamName := stationIntern
+		if partition > 0 {
+			streamName = fmt.Sprintf("%v$%v", stationIntern, partition)
+		}
+		resp := JSPubAckResponse{PubAck: &PubAck{Stream: streamName}}
+		if err != nil {
+			resp.Error = NewJSStreamGeneralError(fmt.Errorf("failed scheduling message: %v", err.Error()))
+		}
+		s.sendInternalAccountMsg(acc, reply, resp)
+	}
+	return true
+}
+
+func (s *Server) handleScheduledMsg(msg []byte, reply string) {
+	if bytes.HasPrefix(msg, []byte(hdrLine[:hdrPreEnd])) {
+		if idx := bytes.Index(msg, []byte(CR_LF+CR_LF)); idx >= 0 {
+			msg = msg[idx+len(CR_LF+CR_LF):]
+		}
+	}
+	var scheduledMsg ScheduledMsg
+	err := json.Unmarshal(msg, &scheduledMsg)
+	if err != nil {
+		s.Errorf("handleScheduledMsg: failed unmarshalling scheduled message: %v", err.Error())
+		s.sendInternalAccountMsgWithEcho(s.MemphisGlobalAccount(), reply, []byte(_EMPTY_))
+		return
+	}
+
+	wait := time.Until(scheduledMsg.DeliverAt)
+	if wait > 0 {
+		// the message will be redelivered by the consumer once it is due
+		nak := []byte(fmt.Sprintf("%s %v", AckNak, wait))
+		s.sendInternalAccountMsgWithEcho(s.MemphisGlobalAccount(), reply, nak)
+		return
+	}
+
+	acc, err := s.lookupAccount(scheduledMsg.TenantName)
+	if err != nil {
+		s.Errorf("[tenant: %v]handleScheduledMsg at lookupAccount: subject %v: %v", scheduledMsg.TenantName, scheduledMsg.Subject, err.Error())
+		if errors.Is(err, ErrMissingAccount) {
+			s.sendInternalAccountMsgWithEcho(s.MemphisGlobalAccount(), reply, []byte(_EMPTY_))
+		}
+		return
+	}
+	err = s.sendInternalAccountMsgWithHeadersWithEcho(acc, scheduledMsg.Subject, scheduledMsg.Data, scheduledMsg.Headers)
+	if err != nil {
+		s.Errorf("[tenant: %v]handleScheduledMsg at sendInternalAccountMsgWithHeadersWithEcho: subject %v: %v", scheduledMsg.TenantName, scheduledMsg.Subject, err.Error())
+		return
+	}
+	s.sendInternalAccountMsgWithEcho(s.MemphisGlobalAccount(), reply, []byte(_EMPTY_))
+}
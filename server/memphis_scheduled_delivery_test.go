@@ -0,0 +1,58 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetScheduledDeliveryTime(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	hdr := func(kv ...string) []byte {
+		h := hdrLine
+		for i := 0; i < len(kv); i += 2 {
+			h += kv[i] + ": " + kv[i+1] + CR_LF
+		}
+		return []byte(h + CR_LF)
+	}
+
+	cases := []struct {
+		hdr      []byte
+		expected time.Time
+		found    bool
+		err      bool
+	}{
+		{hdr: hdr("$memphis_producedBy", "p1"), found: false},
+		{hdr: hdr(scheduledMsgsDelayMsHeader, "1500"), expected: now.Add(1500 * time.Millisecond), found: true},
+		{hdr: hdr(scheduledMsgsDeliverAtHeader, "2023-06-01T13:00:00Z"), expected: now.Add(time.Hour), found: true},
+		{hdr: hdr(scheduledMsgsDeliverAtHeader, "1685624400000"), expected: now.Add(time.Hour), found: true},
+		{hdr: hdr(scheduledMsgsDelayMsHeader, "100", scheduledMsgsDeliverAtHeader, "2023-06-01T13:00:00Z"), expected: now.Add(time.Hour), found: true},
+		{hdr: hdr(scheduledMsgsDelayMsHeader, "-1"), found: true, err: true},
+		{hdr: hdr(scheduledMsgsDeliverAtHeader, "tomorrow"), found: true, err: true},
+	}
+	for i, tc := range cases {
+		got, found, err := getScheduledDeliveryTime(tc.hdr, now)
+		if found != tc.found || (err != nil) != tc.err {
+			t.Fatalf("case %v: unexpected result found=%v err=%v", i, found, err)
+		}
+		if err == nil && found && !got.Equal(tc.expected) {
+			t.Fatalf("case %v: expected %v, got %v", i, tc.expected, got)
+		}
+	}
+}
+
+func TestScheduleDelayedMsgWithoutReply(t *testing.T) {
+	c := &client{acc: NewAccount("tenant"), srv: &Server{}}
+	hdr := func(key, value string) []byte {
+		return []byte(hdrLine + key + ": " + value + CR_LF + CR_LF)
+	}
+	created := SCHEDULED_MSGS_STREAM_CREATED
+	SCHEDULED_MSGS_STREAM_CREATED = false
+	defer func() { SCHEDULED_MSGS_STREAM_CREATED = created }()
+
+	// none of these can be scheduled, without a reply subject they have to be delivered instead of dropped
+	for _, h := range [][]byte{hdr(scheduledMsgsDelayMsHeader, "-1"), hdr(scheduledMsgsDeliverAtHeader, "tomorrow"), hdr(scheduledMsgsDelayMsHeader, "60000")} {
+		if c.scheduleDelayedMsg("orders.final", _EMPTY_, h, []byte("msg")) {
+			t.Fatalf("expected the message with headers %q to be delivered now", h)
+		}
+	}
+}
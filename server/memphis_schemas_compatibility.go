@@ -0,0 +1,474 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/memphisdev/memphis/models"
+
+	"github.com/hamba/avro/v2"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+)
+
+type schemaCompatibilityError struct {
+	violations []models.SchemaCompatibilityViolation
+}
+
+func (e *schemaCompatibilityError) Error() string {
+	msgs := make([]string, 0, len(e.violations))
+	for _, v := range e.violations {
+		msgs = append(msgs, fmt.Sprintf("version %v: %v: %v", v.VersionNumber, v.Path, v.Description))
+	}
+	return "the schema is not compatible with the existing versions: " + strings.Join(msgs, "; ")
+}
+
+func validateSchemaCompatibility(compatibility string) error {
+	switch compatibility {
+	case models.SchemaCompatibilityNone,
+		models.SchemaCompatibilityBackward,
+		models.SchemaCompatibilityBackwardTransitive,
+		models.SchemaCompatibilityForward,
+		models.SchemaCompatibilityForwardTransitive,
+		models.SchemaCompatibilityFull,
+		models.SchemaCompatibilityFullTransitive:
+		return nil
+	default:
+		return errors.New("compatibility can be one of the following NONE/BACKWARD/BACKWARD_TRANSITIVE/FORWARD/FORWARD_TRANSITIVE/FULL/FULL_TRANSITIVE")
+	}
+}
+
+func getSchemaCompatibility(compatibility string) string {
+	if compatibility == _EMPTY_ {
+		return models.SchemaCompatibilityNone
+	}
+	return strings.ToUpper(compatibility)
+}
+
+// checkSchemaCompatibility compares a candidate version against the active version, or against all
+// the given versions in case of a transitive mode, and returns every rule the candidate breaks.
+func checkSchemaCompatibility(schema models.Schema, candidate models.SchemaVersion, active models.SchemaVersion, versions []models.SchemaVersion) error {
+	mode := getSchemaCompatibility(schema.Compatibility)
+	if mode == models.SchemaCompatibilityNone || schema.Type == "graphql" {
+		return nil
+	}
+
+	targets := []models.SchemaVersion{active}
+	if strings.HasSuffix(mode, "_TRANSITIVE") {
+		targets = versions
+	}
+	backward := strings.HasPrefix(mode, models.SchemaCompatibilityBackward) || strings.HasPrefix(mode, models.SchemaCompatibilityFull)
+	forward := strings.HasPrefix(mode, models.SchemaCompatibilityForward) || strings.HasPrefix(mode, models.SchemaCompatibilityFull)
+
+	violations := []models.SchemaCompatibilityViolation{}
+	for _, target := range targets {
+		if target.SchemaContent == _EMPTY_ || target.VersionNumber == candidate.VersionNumber {
+			continue
+		}
+		if backward {
+			vs, err := compareSchemas(schema.Type, candidate, target)
+			if err != nil {
+				return err
+			}
+			violations = append(violations, markViolations(vs, target.VersionNumber, "backward")...)
+		}
+		if forward {
+			vs, err := compareSchemas(schema.Type, target, candidate)
+			if err != nil {
+				return err
+			}
+			violations = append(violations, markViolations(vs, target.VersionNumber, "forward")...)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &schemaCompatibilityError{violations: violations}
+	}
+	return nil
+}
+
+func validateSchemaVersionCompatibility(schema models.Schema, candidate models.SchemaVersion) error {
+	if getSchemaCompatibility(schema.Compatibility) == models.SchemaCompatibilityNone {
+		return nil
+	}
+	versions, err := getSchemaVersionsBySchemaId(schema.ID)
+	if err != nil {
+		return err
+	}
+	var active models.SchemaVersion
+	for _, v := range versions {
+		if v.Active {
+			active = v
+			break
+		}
+	}
+	return checkSchemaCompatibility(schema, candidate, active, versions)
+}
+
+func markViolations(violations []models.SchemaCompatibilityViolation, versionNumber int, direction string) []models.SchemaCompatibilityViolation {
+	for i := range violations {
+		violations[i].VersionNumber = versionNumber
+		violations[i].Description = fmt.Sprintf("%v (%v)", violations[i].Description, direction)
+	}
+	return violations
+}
+
+// compareSchemas returns the reasons data written with the writer schema can not be read with the reader schema
+func compareSchemas(schemaType string, reader, writer models.SchemaVersion) ([]models.SchemaCompatibilityViolation, error) {
+	switch schemaType {
+	case "protobuf":
+		return compareProtobufSchemas(reader, writer)
+	case "json":
+		return compareJsonSchemas(reader.SchemaContent, writer.SchemaContent)
+	case "avro":
+		return compareAvroSchemas(reader.SchemaContent, writer.SchemaContent)
+	}
+	return nil, nil
+}
+
+// parseProtoMessage returns the descriptor of a message of a proto schema,
+// the first message of the file when messageStructName is empty
+func parseProtoMessage(schemaContent, messageStructName string, references map[string]string) (*desc.MessageDescriptor, error) {
+	parser := protoparse.Parser{
+		Accessor: protoFileAccessor(schemaContent, references),
+	}
+	fds, err := parser.ParseFiles(_EMPTY_)
+	if err != nil {
+		return nil, fmt.Errorf("your Proto file is invalid: %v", err.Error())
+	}
+	if len(fds) == 0 {
+		return nil, errors.New("proto file contains no descriptors")
+	}
+	for _, m := range fds[0].GetMessageTypes() {
+		if messageStructName == _EMPTY_ || m.GetName() == messageStructName || m.GetFullyQualifiedName() == messageStructName {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("message %v is not defined in the proto file", messageStructName)
+}
+
+func compareProtobufSchemas(reader, writer models.SchemaVersion) ([]models.SchemaCompatibilityViolation, error) {
+	readerReferences, err := resolveSchemaReferences(reader.References, reader.TenantName)
+	if err != nil {
+		return nil, err
+	}
+	writerReferences, err := resolveSchemaReferences(writer.References, writer.TenantName)
+	if err != nil {
+		return nil, err
+	}
+	readerMsg, err := parseProtoMessage(reader.SchemaContent, reader.MessageStructName, readerReferences)
+	if err != nil {
+		return nil, err
+	}
+	writerMsg, err := parseProtoMessage(writer.SchemaContent, writer.MessageStructName, writerReferences)
+	if err != nil {
+		return nil, err
+	}
+	return compareProtoMessages(readerMsg, writerMsg, readerMsg.GetName(), map[string]bool{}), nil
+}
+
+// protobuf types sharing the same wire encoding can be read interchangeably
+var protoWireGroups = map[string]string{
+	"TYPE_INT32":    "varint",
+	"TYPE_INT64":    "varint",
+	"TYPE_UINT32":   "varint",
+	"TYPE_UINT64":   "varint",
+	"TYPE_BOOL":     "varint",
+	"TYPE_ENUM":     "varint",
+	"TYPE_SINT32":   "zigzag",
+	"TYPE_SINT64":   "zigzag",
+	"TYPE_FIXED32":  "fixed32",
+	"TYPE_SFIXED32": "fixed32",
+	"TYPE_FIXED64":  "fixed64",
+	"TYPE_SFIXED64": "fixed64",
+	"TYPE_STRING":   "bytes",
+	"TYPE_BYTES":    "bytes",
+}
+
+func compareProtoMessages(reader, writer *desc.MessageDescriptor, path string, visited map[string]bool) []models.SchemaCompatibilityViolation {
+	key := reader.GetFullyQualifiedName() + "|" + writer.GetFullyQualifiedName()
+	if visited[key] {
+		return nil
+	}
+	visited[key] = true
+
+	violations := []models.SchemaCompatibilityViolation{}
+	for _, wf := range writer.GetFields() {
+		fieldPath := fmt.Sprintf("%v.%v", path, wf.GetName())
+		rf := reader.FindFieldByNumber(wf.GetNumber())
+		if rf == nil {
+			continue
+		}
+		readerType, writerType := rf.GetType().String(), wf.GetType().String()
+		if readerType != writerType && (protoWireGroups[readerType] == _EMPTY_ || protoWireGroups[readerType] != protoWireGroups[writerType]) {
+			violations = append(violations, models.SchemaCompatibilityViolation{Path: fieldPath, Description: fmt.Sprintf("field number %v changed type from %v to %v", wf.GetNumber(), strings.ToLower(strings.TrimPrefix(writerType, "TYPE_")), strings.ToLower(strings.TrimPrefix(readerType, "TYPE_")))})
+			continue
+		}
+		if rf.IsRepeated() != wf.IsRepeated() {
+			violations = append(violations, models.SchemaCompatibilityViolation{Path: fieldPath, Description: fmt.Sprintf("field number %v changed between repeated and singular", wf.GetNumber())})
+			continue
+		}
+		if rf.GetMessageType() != nil && wf.GetMessageType() != nil {
+			violations = append(violations, compareProtoMessages(rf.GetMessageType(), wf.GetMessageType(), fieldPath, visited)...)
+		}
+	}
+	for _, rf := range reader.GetFields() {
+		if rf.IsRequired() && writer.FindFieldByNumber(rf.GetNumber()) == nil {
+			violations = append(violations, models.SchemaCompatibilityViolation{Path: fmt.Sprintf("%v.%v", path, rf.GetName()), Description: fmt.Sprintf("required field number %v is missing in the other version", rf.GetNumber())})
+		}
+	}
+	return violations
+}
+
+func compareJsonSchemas(readerContent, writerContent string) ([]models.SchemaCompatibilityViolation, error) {
+	var reader, writer map[string]interface{}
+	if err := json.Unmarshal([]byte(readerContent), &reader); err != nil {
+		return nil, errors.New("your json schema is invalid")
+	}
+	if err := json.Unmarshal([]byte(writerContent), &writer); err != nil {
+		return nil, errors.New("your json schema is invalid")
+	}
+	return compareJsonSchemaNodes(reader, writer, "$"), nil
+}
+
+func jsonSchemaTypes(node map[string]interface{}) []string {
+	switch t := node["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := []string{}
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func jsonSchemaRequired(node map[string]interface{}) map[string]bool {
+	required := map[string]bool{}
+	if arr, ok := node["required"].([]interface{}); ok {
+		for _, v := range arr {
+			if s, ok := v.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+	return required
+}
+
+func compareJsonSchemaNodes(reader, writer map[string]interface{}, path string) []models.SchemaCompatibilityViolation {
+	violations := []models.SchemaCompatibilityViolation{}
+
+	readerTypes, writerTypes := jsonSchemaTypes(reader), jsonSchemaTypes(writer)
+	if len(readerTypes) > 0 {
+		accepted := map[string]bool{}
+		for _, t := range readerTypes {
+			accepted[t] = true
+		}
+		for _, t := range writerTypes {
+			if !accepted[t] && !(t == "integer" && accepted["number"]) {
+				violations = append(violations, models.SchemaCompatibilityViolation{Path: path, Description: fmt.Sprintf("type %v is no longer accepted", t)})
+			}
+		}
+		if len(writerTypes) == 0 {
+			violations = append(violations, models.SchemaCompatibilityViolation{Path: path, Description: "type has been narrowed"})
+		}
+	}
+
+	readerProps, _ := reader["properties"].(map[string]interface{})
+	writerProps, _ := writer["properties"].(map[string]interface{})
+	readerRequired, writerRequired := jsonSchemaRequired(reader), jsonSchemaRequired(writer)
+	for name := range readerRequired {
+		if !writerRequired[name] {
+			violations = append(violations, models.SchemaCompatibilityViolation{Path: path + "." + name, Description: "property became required"})
+		}
+	}
+	closedReader := reader["additionalProperties"] == false
+	for name, wp := range writerProps {
+		rp, exist := readerProps[name]
+		if !exist {
+			if closedReader {
+				violations = append(violations, models.SchemaCompatibilityViolation{Path: path + "." + name, Description: "property has been removed while additional properties are not allowed"})
+			}
+			continue
+		}
+		rNode, rOk := rp.(map[string]interface{})
+		wNode, wOk := wp.(map[string]interface{})
+		if rOk && wOk {
+			violations = append(violations, compareJsonSchemaNodes(rNode, wNode, path+"."+name)...)
+		}
+	}
+
+	rItems, rOk := reader["items"].(map[string]interface{})
+	wItems, wOk := writer["items"].(map[string]interface{})
+	if rOk && wOk {
+		violations = append(violations, compareJsonSchemaNodes(rItems, wItems, path+"[]")...)
+	}
+
+	if rEnum, ok := reader["enum"].([]interface{}); ok {
+		wEnum, _ := writer["enum"].([]interface{})
+		if len(wEnum) == 0 {
+			violations = append(violations, models.SchemaCompatibilityViolation{Path: path, Description: "enum has been added"})
+		}
+		for _, wv := range wEnum {
+			found := false
+			for _, rv := range rEnum {
+				if fmt.Sprint(rv) == fmt.Sprint(wv) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				violations = append(violations, models.SchemaCompatibilityViolation{Path: path, Description: fmt.Sprintf("enum value %v has been removed", wv)})
+			}
+		}
+	}
+	return violations
+}
+
+func compareAvroSchemas(readerContent, writerContent string) ([]models.SchemaCompatibilityViolation, error) {
+	reader, err := avro.Parse(readerContent)
+	if err != nil {
+		return nil, fmt.Errorf("your Avro file is invalid: %v", err.Error())
+	}
+	writer, err := avro.Parse(writerContent)
+	if err != nil {
+		return nil, fmt.Errorf("your Avro file is invalid: %v", err.Error())
+	}
+	return compareAvroNodes(reader, writer, "$", map[string]bool{}), nil
+}
+
+// avro promotions allowed by the schema resolution rules, writer type -> reader types
+var avroPromotions = map[avro.Type][]avro.Type{
+	avro.Int:    {avro.Long, avro.Float, avro.Double},
+	avro.Long:   {avro.Float, avro.Double},
+	avro.Float:  {avro.Double},
+	avro.String: {avro.Bytes},
+	avro.Bytes:  {avro.String},
+}
+
+func derefAvro(sch avro.Schema) avro.Schema {
+	if ref, ok := sch.(*avro.RefSchema); ok {
+		return ref.Schema()
+	}
+	return sch
+}
+
+func compareAvroNodes(reader, writer avro.Schema, path string, visited map[string]bool) []models.SchemaCompatibilityViolation {
+	reader, writer = derefAvro(reader), derefAvro(writer)
+
+	if wu, ok := writer.(*avro.UnionSchema); ok {
+		violations := []models.SchemaCompatibilityViolation{}
+		for _, t := range wu.Types() {
+			violations = append(violations, compareAvroNodes(reader, t, path, visited)...)
+		}
+		return violations
+	}
+	if ru, ok := reader.(*avro.UnionSchema); ok {
+		for _, t := range ru.Types() {
+			if len(compareAvroNodes(t, writer, path, visited)) == 0 {
+				return nil
+			}
+		}
+		return []models.SchemaCompatibilityViolation{{Path: path, Description: fmt.Sprintf("type %v does not match any of the union types", writer.Type())}}
+	}
+
+	if reader.Type() != writer.Type() {
+		for _, t := range avroPromotions[writer.Type()] {
+			if t == reader.Type() {
+				return nil
+			}
+		}
+		return []models.SchemaCompatibilityViolation{{Path: path, Description: fmt.Sprintf("type changed from %v to %v", writer.Type(), reader.Type())}}
+	}
+
+	violations := []models.SchemaCompatibilityViolation{}
+	switch r := reader.(type) {
+	case *avro.RecordSchema:
+		w := writer.(*avro.RecordSchema)
+		// visited only holds the record pairs on the current path to stop on recursive records,
+		// a pair reached again through another field or union branch is compared again
+		key := r.FullName() + "|" + w.FullName()
+		if visited[key] {
+			return nil
+		}
+		visited[key] = true
+		defer delete(visited, key)
+		if !avroNamesMatch(r.FullName(), r.Aliases(), w.FullName()) {
+			violations = append(violations, models.SchemaCompatibilityViolation{Path: path, Description: fmt.Sprintf("record name changed from %v to %v", w.FullName(), r.FullName())})
+		}
+		writerFields := map[string]*avro.Field{}
+		for _, f := range w.Fields() {
+			writerFields[f.Name()] = f
+		}
+		for _, rf := range r.Fields() {
+			wf, exist := writerFields[rf.Name()]
+			if !exist {
+				for _, alias := range rf.Aliases() {
+					if wf, exist = writerFields[alias]; exist {
+						break
+					}
+				}
+			}
+			if !exist {
+				if !rf.HasDefault() {
+					violations = append(violations, models.SchemaCompatibilityViolation{Path: path + "." + rf.Name(), Description: "field has been added without a default value"})
+				}
+				continue
+			}
+			violations = append(violations, compareAvroNodes(rf.Type(), wf.Type(), path+"."+rf.Name(), visited)...)
+		}
+	case *avro.EnumSchema:
+		w := writer.(*avro.EnumSchema)
+		if r.Default() != _EMPTY_ {
+			break
+		}
+		symbols := map[string]bool{}
+		for _, s := range r.Symbols() {
+			symbols[s] = true
+		}
+		for _, s := range w.Symbols() {
+			if !symbols[s] {
+				violations = append(violations, models.SchemaCompatibilityViolation{Path: path, Description: fmt.Sprintf("enum symbol %v has been removed", s)})
+			}
+		}
+	case *avro.ArraySchema:
+		violations = append(violations, compareAvroNodes(r.Items(), writer.(*avro.ArraySchema).Items(), path+"[]", visited)...)
+	case *avro.MapSchema:
+		violations = append(violations, compareAvroNodes(r.Values(), writer.(*avro.MapSchema).Values(), path+"{}", visited)...)
+	case *avro.FixedSchema:
+		w := writer.(*avro.FixedSchema)
+		if r.Size() != w.Size() {
+			violations = append(violations, models.SchemaCompatibilityViolation{Path: path, Description: fmt.Sprintf("fixed size changed from %v to %v", w.Size(), r.Size())})
+		}
+	}
+	return violations
+}
+
+func avroNamesMatch(readerName string, readerAliases []string, writerName string) bool {
+	if readerName == writerName {
+		return true
+	}
+	for _, alias := range readerAliases {
+		if alias == writerName {
+			return true
+		}
+	}
+	return false
+}
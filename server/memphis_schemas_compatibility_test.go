@@ -0,0 +1,99 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/hamba/avro/v2"
+	"github.com/memphisdev/memphis/models"
+)
+
+func TestSchemaCompatibilityAvro(t *testing.T) {
+	v1 := models.SchemaVersion{VersionNumber: 1, Active: true, SchemaContent: `{"type":"record","name":"order","fields":[{"name":"id","type":"int"}]}`}
+	withDefault := models.SchemaVersion{VersionNumber: 2, SchemaContent: `{"type":"record","name":"order","fields":[{"name":"id","type":"long"},{"name":"note","type":"string","default":""}]}`}
+	noDefault := models.SchemaVersion{VersionNumber: 2, SchemaContent: `{"type":"record","name":"order","fields":[{"name":"id","type":"int"},{"name":"note","type":"string"}]}`}
+
+	schema := models.Schema{Type: "avro", Compatibility: models.SchemaCompatibilityBackward}
+	if err := checkSchemaCompatibility(schema, withDefault, v1, []models.SchemaVersion{v1}); err != nil {
+		t.Fatalf("expected backward compatible schema, got: %v", err)
+	}
+	if err := checkSchemaCompatibility(schema, noDefault, v1, []models.SchemaVersion{v1}); err == nil {
+		t.Fatalf("expected a violation for a field added without a default value")
+	}
+
+	// long can not be read as int, so the promotion only works in one direction
+	schema.Compatibility = models.SchemaCompatibilityFull
+	if err := checkSchemaCompatibility(schema, withDefault, v1, []models.SchemaVersion{v1}); err == nil {
+		t.Fatalf("expected a forward violation for int to long")
+	}
+}
+
+func TestSchemaCompatibilityAvroUnionReusingRecord(t *testing.T) {
+	// built by hand since the parser resolves a named reference to the last alias it registered
+	record := func(name string, aliases []string, fields ...string) *avro.RecordSchema {
+		recordFields := []*avro.Field{}
+		for _, field := range fields {
+			f, err := avro.NewField(field, avro.NewPrimitiveSchema(avro.String, nil))
+			if err != nil {
+				t.Fatalf("failed creating field %v: %v", field, err)
+			}
+			recordFields = append(recordFields, f)
+		}
+		r, err := avro.NewRecordSchema(name, _EMPTY_, recordFields, avro.WithAliases(aliases))
+		if err != nil {
+			t.Fatalf("failed creating record %v: %v", name, err)
+		}
+		return r
+	}
+	address := record("address", nil, "street", "zip")
+	billingTypes, err := avro.NewUnionSchema([]avro.Schema{address, record("legacy_address", []string{"address"}, "street")})
+	if err != nil {
+		t.Fatalf("failed creating union: %v", err)
+	}
+	billing, _ := avro.NewField("billing", billingTypes)
+	shipping, _ := avro.NewField("shipping", address)
+	reader, err := avro.NewRecordSchema("order", _EMPTY_, []*avro.Field{billing, shipping})
+	if err != nil {
+		t.Fatalf("failed creating record order: %v", err)
+	}
+	writer, err := avro.Parse(`{"type":"record","name":"order","fields":[
+		{"name":"billing","type":{"type":"record","name":"address","fields":[{"name":"street","type":"string"}]}},
+		{"name":"shipping","type":"address"}]}`)
+	if err != nil {
+		t.Fatalf("failed parsing writer schema: %v", err)
+	}
+
+	// billing still matches the legacy_address branch after the address branch failed,
+	// shipping reads the same address record and has to report the missing zip default
+	violations := compareAvroNodes(reader, writer, "$", map[string]bool{})
+	if len(violations) != 1 || violations[0].Path != "$.shipping.zip" {
+		t.Fatalf("expected a single violation for the shipping address, got: %+v", violations)
+	}
+}
+
+func TestSchemaCompatibilityJson(t *testing.T) {
+	v1 := models.SchemaVersion{VersionNumber: 1, Active: true, SchemaContent: `{"type":"object","properties":{"id":{"type":"integer"}},"required":["id"]}`}
+	v2 := models.SchemaVersion{VersionNumber: 2, SchemaContent: `{"type":"object","properties":{"id":{"type":"integer"},"name":{"type":"string"}},"required":["id","name"]}`}
+
+	schema := models.Schema{Type: "json", Compatibility: models.SchemaCompatibilityForward}
+	if err := checkSchemaCompatibility(schema, v2, v1, []models.SchemaVersion{v1}); err != nil {
+		t.Fatalf("expected forward compatible schema, got: %v", err)
+	}
+	schema.Compatibility = models.SchemaCompatibilityBackward
+	err := checkSchemaCompatibility(schema, v2, v1, []models.SchemaVersion{v1})
+	compatibilityErr, ok := err.(*schemaCompatibilityError)
+	if !ok || len(compatibilityErr.violations) != 1 || compatibilityErr.violations[0].Path != "$.name" {
+		t.Fatalf("expected a single violation for the new required property, got: %v", err)
+	}
+}
+
+func TestSchemaCompatibilityProtobuf(t *testing.T) {
+	v1 := models.SchemaVersion{VersionNumber: 1, Active: true, MessageStructName: "Order", SchemaContent: `syntax = "proto3"; message Order { int32 id = 1; string name = 2; }`}
+	v2 := models.SchemaVersion{VersionNumber: 2, MessageStructName: "Order", SchemaContent: `syntax = "proto3"; message Order { int64 id = 1; double name = 2; }`}
+
+	schema := models.Schema{Type: "protobuf", Compatibility: models.SchemaCompatibilityBackwardTransitive}
+	err := checkSchemaCompatibility(schema, v2, v1, []models.SchemaVersion{v1})
+	compatibilityErr, ok := err.(*schemaCompatibilityError)
+	if !ok || len(compatibilityErr.violations) != 1 || compatibilityErr.violations[0].Path != "Order.name" {
+		t.Fatalf("expected a single violation for the changed field type, got: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/models"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// protoFileAccessor serves the schema content as the root file and the resolved references by their import paths,
+// anything else falls back to the well-known imports bundled with protoparse
+func protoFileAccessor(schemaContent string, references map[string]string) protoparse.FileAccessor {
+	return func(filename string) (io.ReadCloser, error) {
+		if filename == _EMPTY_ {
+			return io.NopCloser(strings.NewReader(schemaContent)), nil
+		}
+		if content, ok := references[filename]; ok {
+			return io.NopCloser(strings.NewReader(content)), nil
+		}
+		return nil, fmt.Errorf("%v is not declared as a schema reference", filename)
+	}
+}
+
+func compileJsonSchema(schemaContent string, references map[string]string) (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	for name, content := range references {
+		err := compiler.AddResource(name, strings.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := compiler.AddResource("test", strings.NewReader(schemaContent))
+	if err != nil {
+		return nil, err
+	}
+	return compiler.Compile("test")
+}
+
+func validateSchemaReferenceName(name string) error {
+	if name == _EMPTY_ {
+		return errors.New("schema reference name can not be empty")
+	}
+	if path.IsAbs(name) || path.Clean(name) != name || strings.HasPrefix(name, "..") {
+		return fmt.Errorf("schema reference name %v has to be a clean relative path", name)
+	}
+	return nil
+}
+
+// validateSchemaReferences makes sure every reference points to an existing version of another schema
+// of the same type and returns the resolved contents of all the references, including the nested ones
+func validateSchemaReferences(schemaName, schemaType string, references []models.SchemaReference, tenantName string) (map[string]string, error) {
+	if len(references) == 0 {
+		return map[string]string{}, nil
+	}
+	if schemaType != "protobuf" && schemaType != "json" {
+		return nil, fmt.Errorf("schema references are not supported for %v schemas", schemaType)
+	}
+
+	names := make(map[string]bool, len(references))
+	for _, ref := range references {
+		err := validateSchemaReferenceName(ref.Name)
+		if err != nil {
+			return nil, err
+		}
+		if names[ref.Name] {
+			return nil, fmt.Errorf("schema reference %v is declared more than once", ref.Name)
+		}
+		names[ref.Name] = true
+		if strings.ToLower(ref.SchemaName) == schemaName {
+			return nil, errors.New("a schema can not reference itself")
+		}
+		exist, referenced, err := db.GetSchemaByName(strings.ToLower(ref.SchemaName), tenantName)
+		if err != nil {
+			return nil, err
+		}
+		if !exist {
+			return nil, fmt.Errorf("referenced schema %v does not exist", ref.SchemaName)
+		}
+		if referenced.Type != schemaType {
+			return nil, fmt.Errorf("referenced schema %v is of type %v, expected %v", ref.SchemaName, referenced.Type, schemaType)
+		}
+	}
+
+	return resolveSchemaReferences(references, tenantName)
+}
+
+func resolveSchemaReferences(references []models.SchemaReference, tenantName string) (map[string]string, error) {
+	resolved := map[string]string{}
+	err := resolveSchemaReferencesInto(references, tenantName, resolved)
+	if err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+func resolveSchemaReferencesInto(references []models.SchemaReference, tenantName string, resolved map[string]string) error {
+	for _, ref := range references {
+		exist, schema, err := db.GetSchemaByName(strings.ToLower(ref.SchemaName), tenantName)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return fmt.Errorf("referenced schema %v does not exist", ref.SchemaName)
+		}
+		exist, version, err := db.GetSchemaVersionByNumberAndID(ref.VersionNumber, schema.ID)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return fmt.Errorf("version %v of the referenced schema %v does not exist", ref.VersionNumber, ref.SchemaName)
+		}
+
+		if content, ok := resolved[ref.Name]; ok {
+			if content != version.SchemaContent {
+				return fmt.Errorf("schema reference %v resolves to different contents", ref.Name)
+			}
+			continue
+		}
+		resolved[ref.Name] = version.SchemaContent
+
+		// versions are immutable and can only reference versions that existed before them, so there are no cycles
+		nested, err := db.GetSchemaVersionReferences(version.ID)
+		if err != nil {
+			return err
+		}
+		err = resolveSchemaReferencesInto(nested, tenantName, resolved)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func attachSchemaReferences(schemaId int, versions []models.SchemaVersion) error {
+	references, err := db.GetSchemaReferencesBySchemaID(schemaId)
+	if err != nil {
+		return err
+	}
+	for i := range versions {
+		versions[i].References = references[versions[i].ID]
+		if versions[i].References == nil {
+			versions[i].References = []models.SchemaReference{}
+		}
+	}
+	return nil
+}
+
+// getSchemaReferencedBy returns the names of the schemas other than the removed ones that still reference the schema
+func getSchemaReferencedBy(schema models.Schema, removedSchemas map[string]bool) ([]string, error) {
+	names, err := db.GetSchemaNamesReferencingSchema(schema.ID)
+	if err != nil {
+		return []string{}, err
+	}
+	referencedBy := []string{}
+	for _, name := range names {
+		if !removedSchemas[name] {
+			referencedBy = append(referencedBy, name)
+		}
+	}
+	return referencedBy, nil
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestSchemaReferencesResolution(t *testing.T) {
+	references := map[string]string{"common/address.proto": `syntax = "proto3"; package common; message Address { string city = 1; }`}
+
+	order := `syntax = "proto3"; import "common/address.proto"; message Order { int64 id = 1; common.Address address = 2; }`
+	if err := validateProtobufContent(order, references); err != nil {
+		t.Fatalf("expected the import to be resolved, got: %v", err)
+	}
+	if err := validateProtobufContent(order, nil); err == nil {
+		t.Fatalf("expected an undeclared import to be rejected")
+	}
+	structName, err := getProtoMessageStructName(order, references)
+	if err != nil || structName != "Order" {
+		t.Fatalf("expected the root message to be Order, got: %v, %v", structName, err)
+	}
+
+	jsonReferences := map[string]string{"address.json": `{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`}
+	jsonValidator, err := compileSchemaValidator("json", `{"type":"object","properties":{"address":{"$ref":"address.json"}}}`, _EMPTY_, jsonReferences)
+	if err != nil {
+		t.Fatalf("json compile failed: %v", err)
+	}
+	if jsonValidator([]byte(`{"address":{"city":"tlv"}}`)) != nil {
+		t.Fatalf("valid json message was rejected")
+	}
+	if jsonValidator([]byte(`{"address":{}}`)) == nil {
+		t.Fatalf("the referenced schema was not enforced")
+	}
+
+	for _, name := range []string{"", "/etc/passwd", "../address.proto", "a/../../b.proto"} {
+		if validateSchemaReferenceName(name) == nil {
+			t.Fatalf("expected reference name %q to be rejected", name)
+		}
+	}
+}
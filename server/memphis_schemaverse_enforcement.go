@@ -0,0 +1,415 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/models"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/hamba/avro/v2"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+const brokerSchemaEnforcementRefreshInterval = 10 * time.Second
+
+type schemaValidator func(data []byte) error
+
+type enforcedStation struct {
+	schemaType      string
+	schemaVersionId int
+	dlsEnabled      bool
+}
+
+// brokerEnforcedStations holds the stations whose publishes are validated by the broker itself,
+// keyed by tenant name and internal station name, so the ingress path never touches the DB.
+var brokerEnforcedStations = struct {
+	sync.RWMutex
+	m map[string]enforcedStation
+}{m: map[string]enforcedStation{}}
+
+var hasBrokerEnforcedStations atomic.Bool
+
+// compiled validators are immutable per schema version, hence cached by version id
+var schemaValidatorsCache = NewConcurrentMap[schemaValidator]()
+
+func brokerEnforcedStationKey(tenantName, stationIntern string) string {
+	return tenantName + ":" + stationIntern
+}
+
+// stationFromFinalSubject extracts the internal station name and the partition out of
+// <station>.final / <station>$<partition>.final subjects.
+func stationFromFinalSubject(subject string) (string, int, bool) {
+	if !strings.HasSuffix(subject, ".final") {
+		return _EMPTY_, 0, false
+	}
+	stationIntern := strings.TrimSuffix(subject, ".final")
+	partition := 0
+	if idx := strings.LastIndex(stationIntern, "$"); idx > 0 {
+		p, err := strconv.Atoi(stationIntern[idx+1:])
+		if err != nil {
+			return _EMPTY_, 0, false
+		}
+		stationIntern = stationIntern[:idx]
+		partition = p
+	}
+	if stationIntern == _EMPTY_ || strings.Contains(stationIntern, ".") {
+		return _EMPTY_, 0, false
+	}
+	return stationIntern, partition, true
+}
+
+func compileSchemaValidator(schemaType, schemaContent, messageStructName string, references map[string]string) (schemaValidator, error) {
+	switch schemaType {
+	case "protobuf":
+		return compileProtobufValidator(schemaContent, messageStructName, references)
+	case "json":
+		return compileJsonSchemaValidator(schemaContent, references)
+	case "graphql":
+		return compileGraphqlValidator(schemaContent)
+	case "avro":
+		return compileAvroValidator(schemaContent)
+	default:
+		return nil, fmt.Errorf("unsupported schema type %v", schemaType)
+	}
+}
+
+func compileProtobufValidator(schemaContent, messageStructName string, references map[string]string) (schemaValidator, error) {
+	md, err := parseProtoMessage(schemaContent, messageStructName, references)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(data []byte) error {
+		msg := dynamic.NewMessage(md)
+		if err := msg.Unmarshal(data); err != nil {
+			return fmt.Errorf("invalid message format, expecting protobuf: %v", err.Error())
+		}
+		return nil
+	}, nil
+}
+
+func compileJsonSchemaValidator(schemaContent string, references map[string]string) (schemaValidator, error) {
+	sch, err := compileJsonSchema(schemaContent, references)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(data []byte) error {
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return errors.New("invalid message format, expecting json")
+		}
+		if err := sch.Validate(v); err != nil {
+			return err
+		}
+		return nil
+	}, nil
+}
+
+func compileGraphqlValidator(schemaContent string) (schemaValidator, error) {
+	sch, err := graphql.ParseSchema(schemaContent, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(data []byte) error {
+		errs := sch.Validate(string(data))
+		if len(errs) > 0 {
+			return errs[0]
+		}
+		return nil
+	}, nil
+}
+
+// SDKs produce avro stations as json documents, so messages are validated the same way
+func compileAvroValidator(schemaContent string) (schemaValidator, error) {
+	sch, err := avro.Parse(schemaContent)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(data []byte) error {
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return errors.New("invalid message format, expecting json")
+		}
+		return validateAvroValue(sch, v, "$")
+	}, nil
+}
+
+func validateAvroValue(sch avro.Schema, v interface{}, path string) error {
+	switch s := sch.(type) {
+	case *avro.RefSchema:
+		return validateAvroValue(s.Schema(), v, path)
+	case *avro.RecordSchema:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%v: expected record %v", path, s.FullName())
+		}
+		for _, f := range s.Fields() {
+			fv, exist := obj[f.Name()]
+			if !exist {
+				if f.HasDefault() {
+					continue
+				}
+				if u, ok := f.Type().(*avro.UnionSchema); ok && u.Nullable() {
+					continue
+				}
+				return fmt.Errorf("%v: missing required field %v", path, f.Name())
+			}
+			if err := validateAvroValue(f.Type(), fv, path+"."+f.Name()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *avro.ArraySchema:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%v: expected array", path)
+		}
+		for i, item := range arr {
+			if err := validateAvroValue(s.Items(), item, fmt.Sprintf("%v[%v]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *avro.MapSchema:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%v: expected map", path)
+		}
+		for k, item := range obj {
+			if err := validateAvroValue(s.Values(), item, path+"."+k); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *avro.UnionSchema:
+		for _, t := range s.Types() {
+			if validateAvroValue(t, v, path) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%v: value does not match any of the union types", path)
+	case *avro.EnumSchema:
+		str, ok := v.(string)
+		if ok {
+			for _, symbol := range s.Symbols() {
+				if symbol == str {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("%v: expected one of the enum %v symbols", path, s.FullName())
+	case *avro.FixedSchema:
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%v: expected fixed %v", path, s.FullName())
+		}
+		return nil
+	}
+
+	var valid bool
+	switch sch.Type() {
+	case avro.Null:
+		valid = v == nil
+	case avro.Boolean:
+		_, valid = v.(bool)
+	case avro.Int, avro.Long:
+		n, ok := v.(float64)
+		valid = ok && n == float64(int64(n))
+	case avro.Float, avro.Double:
+		_, valid = v.(float64)
+	case avro.String, avro.Bytes:
+		_, valid = v.(string)
+	}
+	if !valid {
+		return fmt.Errorf("%v: expected %v", path, sch.Type())
+	}
+	return nil
+}
+
+func getSchemaValidator(station models.SchemaEnforcedStation) (schemaValidator, error) {
+	key := strconv.Itoa(station.SchemaVersionId)
+	if validator, ok := schemaValidatorsCache.Load(key); ok {
+		return validator, nil
+	}
+	references, err := db.GetSchemaVersionReferences(station.SchemaVersionId)
+	if err != nil {
+		return nil, err
+	}
+	resolvedReferences, err := resolveSchemaReferences(references, station.TenantName)
+	if err != nil {
+		return nil, err
+	}
+	validator, err := compileSchemaValidator(station.SchemaType, station.SchemaContent, station.MessageStructName, resolvedReferences)
+	if err != nil {
+		return nil, err
+	}
+	schemaValidatorsCache.Add(key, validator)
+	return validator, nil
+}
+
+func (s *Server) refreshBrokerSchemaEnforcement() error {
+	stations, err := db.GetBrokerSchemaEnforcedStations()
+	if err != nil {
+		return err
+	}
+
+	enforced := make(map[string]enforcedStation, len(stations))
+	usedVersions := make(map[string]bool, len(stations))
+	for _, station := range stations {
+		_, err := getSchemaValidator(station)
+		if err != nil {
+			s.Warnf("[tenant: %v]refreshBrokerSchemaEnforcement at getSchemaValidator: station %v: %v", station.TenantName, station.StationName, err.Error())
+			continue
+		}
+		stationName, err := StationNameFromStr(station.StationName)
+		if err != nil {
+			continue
+		}
+		enforced[brokerEnforcedStationKey(station.TenantName, stationName.Intern())] = enforcedStation{
+			schemaType:      station.SchemaType,
+			schemaVersionId: station.SchemaVersionId,
+			dlsEnabled:      station.DlsConfigurationSchemaverse,
+		}
+		usedVersions[strconv.Itoa(station.SchemaVersionId)] = true
+	}
+
+	brokerEnforcedStations.Lock()
+	brokerEnforcedStations.m = enforced
+	brokerEnforcedStations.Unlock()
+	hasBrokerEnforcedStations.Store(len(enforced) > 0)
+
+	versions, _ := schemaValidatorsCache.Array()
+	for _, version := range versions {
+		if !usedVersions[version] {
+			schemaValidatorsCache.Delete(version)
+		}
+	}
+	return nil
+}
+
+func (s *Server) RefreshBrokerSchemaEnforcement() {
+	ticker := time.NewTicker(brokerSchemaEnforcementRefreshInterval)
+	for ; true; <-ticker.C {
+		err := s.refreshBrokerSchemaEnforcement()
+		if err != nil {
+			s.Errorf("RefreshBrokerSchemaEnforcement at refreshBrokerSchemaEnforcement: %v", err.Error())
+		}
+	}
+}
+
+// validateBrokerEnforcedMsg returns the station the message was published to and a validation
+// error in case the station is broker enforced and the message does not match its active schema.
+func validateBrokerEnforcedMsg(tenantName, subject string, msg []byte) (enforcedStation, int, error) {
+	if !hasBrokerEnforcedStations.Load() {
+		return enforcedStation{}, 0, nil
+	}
+	stationIntern, partition, ok := stationFromFinalSubject(subject)
+	if !ok {
+		return enforcedStation{}, 0, nil
+	}
+
+	brokerEnforcedStations.RLock()
+	station, ok := brokerEnforcedStations.m[brokerEnforcedStationKey(tenantName, stationIntern)]
+	brokerEnforcedStations.RUnlock()
+	if !ok {
+		return enforcedStation{}, 0, nil
+	}
+
+	validator, ok := schemaValidatorsCache.Load(strconv.Itoa(station.schemaVersionId))
+	if !ok {
+		return enforcedStation{}, 0, nil
+	}
+	return station, partition, validator(msg)
+}
+
+// enforceStationSchema is called on the client ingress path, it returns false in case the message
+// has been rejected and should not be stored in the station.
+func (c *client) enforceStationSchema(subject, reply string, hdr, msg []byte) bool {
+	acc := c.acc
+	if acc == nil {
+		return true
+	}
+	station, partition, err := validateBrokerEnforcedMsg(acc.GetName(), subject, msg)
+	if err == nil {
+		return true
+	}
+
+	s := c.srv
+	stationIntern, _, _ := stationFromFinalSubject(subject)
+	if reply != _EMPTY_ {
+		streamName := stationIntern
+		if partition > 0 {
+			streamName = fmt.Sprintf("%v$%v", stationIntern, partition)
+		}
+		resp := JSPubAckResponse{PubAck: &PubAck{Stream: streamName}, Error: NewJSStreamGeneralError(fmt.Errorf("schema validation has failed: %v", err.Error()))}
+		s.sendInternalAccountMsg(acc, reply, resp)
+	}
+
+	// with dls enabled the failure is counted once the dls message is handled
+	if !station.dlsEnabled {
+		recordSchemaValidationFailure(acc.GetName(), StationNameFromStreamName(stationIntern).Ext())
+	} else {
+		// the client's read buffer is reused once we return
+		go func(hdr, msg []byte, validationErr error) {
+			headers := map[string]string{}
+			if len(hdr) > 0 {
+				var err error
+				headers, err = DecodeHeader(hdr)
+				if err != nil {
+					s.Errorf("[tenant: %v]enforceStationSchema at DecodeHeader: station %v: %v", acc.GetName(), stationIntern, err.Error())
+					return
+				}
+			}
+			s.sendBrokerEnforcedMsgToDls(acc, stationIntern, partition, headers, msg, validationErr)
+		}(copyBytes(hdr), copyBytes(msg), err)
+	}
+	return false
+}
+
+func (s *Server) sendBrokerEnforcedMsgToDls(acc *Account, stationIntern string, partition int, headers map[string]string, msg []byte, validationErr error) {
+	size := len(msg)
+	for k, v := range headers {
+		size += len(k) + len(v)
+	}
+	dlsMsg := models.SchemaVerseDlsMessageSdk{
+		StationName: stationIntern,
+		Producer: models.ProducerDetails{
+			Name:         headers["$memphis_producedBy"],
+			ConnectionId: headers["$memphis_connectionId"],
+		},
+		Message: models.MessagePayload{
+			TimeSent: time.Now(),
+			Size:     size,
+			Data:     hex.EncodeToString(msg),
+			Headers:  headers,
+		},
+		ValidationError: validationErr.Error(),
+		PartitionNumber: partition,
+	}
+	err := s.sendInternalAccountMsg(acc, SCHEMAVERSE_DLS_SUBJ, dlsMsg)
+	if err != nil {
+		s.Errorf("[tenant: %v]sendBrokerEnforcedMsgToDls at sendInternalAccountMsg: station %v: %v", acc.GetName(), stationIntern, err.Error())
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestStationFromFinalSubject(t *testing.T) {
+	cases := []struct {
+		subject   string
+		station   string
+		partition int
+		ok        bool
+	}{
+		{subject: "orders.final", station: "orders", partition: 0, ok: true},
+		{subject: "orders$3.final", station: "orders", partition: 3, ok: true},
+		{subject: "orders$x.final", ok: false},
+		{subject: "orders.other", ok: false},
+		{subject: "a.b.final", ok: false},
+	}
+
+	for _, c := range cases {
+		station, partition, ok := stationFromFinalSubject(c.subject)
+		if ok != c.ok || station != c.station || partition != c.partition {
+			t.Fatalf("%v: got (%v, %v, %v)", c.subject, station, partition, ok)
+		}
+	}
+}
+
+func TestCompileSchemaValidators(t *testing.T) {
+	jsonValidator, err := compileSchemaValidator("json", `{"type":"object","properties":{"id":{"type":"integer"}},"required":["id"]}`, _EMPTY_, nil)
+	if err != nil {
+		t.Fatalf("json compile failed: %v", err)
+	}
+	if jsonValidator([]byte(`{"id":1}`)) != nil {
+		t.Fatalf("valid json message was rejected")
+	}
+	if jsonValidator([]byte(`{"name":"a"}`)) == nil {
+		t.Fatalf("invalid json message was accepted")
+	}
+
+	avroValidator, err := compileSchemaValidator("avro", `{"type":"record","name":"order","fields":[{"name":"id","type":"long"},{"name":"note","type":["null","string"]}]}`, _EMPTY_, nil)
+	if err != nil {
+		t.Fatalf("avro compile failed: %v", err)
+	}
+	if avroValidator([]byte(`{"id":7,"note":"x"}`)) != nil {
+		t.Fatalf("valid avro message was rejected")
+	}
+	if avroValidator([]byte(`{"id":"7"}`)) == nil {
+		t.Fatalf("invalid avro message was accepted")
+	}
+
+	protoValidator, err := compileSchemaValidator("protobuf", `syntax = "proto3"; message Order { int64 id = 1; }`, "Order", nil)
+	if err != nil {
+		t.Fatalf("protobuf compile failed: %v", err)
+	}
+	if protoValidator([]byte{0x08, 0x01}) != nil {
+		t.Fatalf("valid protobuf message was rejected")
+	}
+	if protoValidator([]byte{0x0a, 0x05}) == nil {
+		t.Fatalf("invalid protobuf message was accepted")
+	}
+}
@@ -13,6 +13,7 @@ package server
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/memphisdev/memphis/models"
 )
@@ -41,6 +42,13 @@ type createStationRequest struct {
 	DlsStation           string                  `json:"dls_station"`
 }
 
+type addStationPartitionsRequest struct {
+	StationName      string `json:"station_name"`
+	PartitionsNumber int    `json:"partitions_number"`
+	Username         string `json:"username"`
+	TenantName       string `json:"tenant_name"`
+}
+
 type destroyStationRequest struct {
 	StationName string `json:"station_name"`
 	Username    string `json:"username"`
@@ -98,6 +106,38 @@ type createConsumerResponseV1 struct {
 	Err              string                  `json:"error"`
 }
 
+type resetCgOffsetsRequest struct {
+	StationName string    `json:"station_name"`
+	CgName      string    `json:"cg_name"`
+	Position    string    `json:"position"`
+	Sequence    uint64    `json:"sequence"`
+	Timestamp   time.Time `json:"timestamp"`
+	Partitions  []int     `json:"partitions"`
+	DryRun      bool      `json:"dry_run"`
+	Username    string    `json:"username"`
+	TenantName  string    `json:"tenant_name"`
+}
+
+type pauseCgRequest struct {
+	StationName string     `json:"station_name"`
+	CgName      string     `json:"cg_name"`
+	PausedUntil *time.Time `json:"paused_until"`
+	Username    string     `json:"username"`
+	TenantName  string     `json:"tenant_name"`
+}
+
+type resumeCgRequest struct {
+	StationName string `json:"station_name"`
+	CgName      string `json:"cg_name"`
+	Username    string `json:"username"`
+	TenantName  string `json:"tenant_name"`
+}
+
+type resetCgOffsetsResponse struct {
+	models.ResetCgOffsetsResponse
+	Err string `json:"error"`
+}
+
 type createProducerResponse struct {
 	SchemaUpdate                    models.SchemaUpdateInit `json:"schema_update"`
 	PartitionsUpdate                models.PartitionsUpdate `json:"partitions_update"`
@@ -213,11 +253,13 @@ type destroyConsumerRequestV1 struct {
 }
 
 type CreateSchemaReq struct {
-	Name              string `json:"name"`
-	Type              string `json:"type"`
-	CreatedByUsername string `json:"created_by_username"`
-	SchemaContent     string `json:"schema_content"`
-	MessageStructName string `json:"message_struct_name"`
+	Name              string                   `json:"name"`
+	Type              string                   `json:"type"`
+	CreatedByUsername string                   `json:"created_by_username"`
+	SchemaContent     string                   `json:"schema_content"`
+	MessageStructName string                   `json:"message_struct_name"`
+	Compatibility     string                   `json:"compatibility"`
+	References        []models.SchemaReference `json:"references"`
 }
 
 type SchemaResponse struct {
@@ -228,6 +270,10 @@ func (cpr *createProducerResponse) SetError(err error) {
 	cpr.Err = err.Error()
 }
 
+func (rcr *resetCgOffsetsResponse) SetError(err error) {
+	rcr.Err = err.Error()
+}
+
 func (ccr *createConsumerResponse) SetError(err error) {
 	ccr.Err = err.Error()
 }
@@ -252,6 +298,9 @@ func (s *Server) initializeSDKHandlers() {
 	s.queueSubscribe(s.MemphisGlobalAccountString(), "$memphis_station_destructions",
 		"memphis_station_destructions_listeners_group",
 		destroyStationHandler(s))
+	s.queueSubscribe(s.MemphisGlobalAccountString(), memphisStationPartitions,
+		"memphis_station_partitions_updates_listeners_group",
+		addStationPartitionsHandler(s))
 
 	// producers
 	s.queueSubscribe(s.MemphisGlobalAccountString(), "$memphis_producer_creations",
@@ -268,6 +317,15 @@ func (s *Server) initializeSDKHandlers() {
 	s.queueSubscribe(s.MemphisGlobalAccountString(), "$memphis_consumer_destructions",
 		"memphis_consumer_destructions_listeners_group",
 		destroyConsumerHandler(s))
+	s.queueSubscribe(s.MemphisGlobalAccountString(), memphisCgOffsetResets,
+		"memphis_cg_offset_resets_listeners_group",
+		resetCgOffsetsHandler(s))
+	s.queueSubscribe(s.MemphisGlobalAccountString(), memphisCgPauses,
+		"memphis_cg_pauses_listeners_group",
+		pauseCgHandler(s))
+	s.queueSubscribe(s.MemphisGlobalAccountString(), memphisCgResumes,
+		"memphis_cg_resumes_listeners_group",
+		resumeCgHandler(s))
 
 	// schemas
 	s.queueSubscribe(s.MemphisGlobalAccountString(), "$memphis_schema_attachments",
@@ -300,6 +358,12 @@ func destroyStationHandler(s *Server) simplifiedMsgHandler {
 	}
 }
 
+func addStationPartitionsHandler(s *Server) simplifiedMsgHandler {
+	return func(c *client, subject, reply string, msg []byte) {
+		go s.addStationPartitionsDirect(c, reply, copyBytes(msg))
+	}
+}
+
 func createProducerHandler(s *Server) simplifiedMsgHandler {
 	return func(c *client, subject, reply string, msg []byte) {
 		go s.createProducerDirect(c, reply, copyBytes(msg))
@@ -324,6 +388,24 @@ func destroyConsumerHandler(s *Server) simplifiedMsgHandler {
 	}
 }
 
+func resetCgOffsetsHandler(s *Server) simplifiedMsgHandler {
+	return func(c *client, subject, reply string, msg []byte) {
+		go s.resetCgOffsetsDirect(c, reply, copyBytes(msg))
+	}
+}
+
+func pauseCgHandler(s *Server) simplifiedMsgHandler {
+	return func(c *client, subject, reply string, msg []byte) {
+		go s.pauseCgDirect(c, reply, copyBytes(msg))
+	}
+}
+
+func resumeCgHandler(s *Server) simplifiedMsgHandler {
+	return func(c *client, subject, reply string, msg []byte) {
+		go s.resumeCgDirect(c, reply, copyBytes(msg))
+	}
+}
+
 func attachSchemaHandler(s *Server) simplifiedMsgHandler {
 	return func(c *client, subject, reply string, msg []byte) {
 		go s.useSchemaDirect(c, reply, copyBytes(msg))
@@ -0,0 +1,459 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/models"
+	"github.com/memphisdev/memphis/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	tieredRehydrateTaskName = "rehydrate_tiered_msgs"
+	rehydratedHeader        = "$memphis_rehydrated"
+)
+
+// rehydrateRange is the part of the archive a rehydrate task replays, zero values are unbounded
+type rehydrateRange struct {
+	fromTime time.Time
+	toTime   time.Time
+	fromSeq  uint64
+	toSeq    uint64
+}
+
+func (r rehydrateRange) hasTime() bool {
+	return !r.fromTime.IsZero() || !r.toTime.IsZero()
+}
+
+func (r rehydrateRange) hasSeq() bool {
+	return r.fromSeq > 0 || r.toSeq > 0
+}
+
+// includesHour reports whether an object written for the hour starting at hour may hold messages of the range
+func (r rehydrateRange) includesHour(hour time.Time) bool {
+	if !r.fromTime.IsZero() && !hour.Add(time.Hour).After(r.fromTime) {
+		return false
+	}
+	if !r.toTime.IsZero() && hour.After(r.toTime) {
+		return false
+	}
+	return true
+}
+
+func (r rehydrateRange) includes(msg rehydratedMsg) bool {
+	if r.hasTime() {
+		if msg.time.IsZero() {
+			return false
+		}
+		if !r.fromTime.IsZero() && msg.time.Before(r.fromTime) {
+			return false
+		}
+		if !r.toTime.IsZero() && msg.time.After(r.toTime) {
+			return false
+		}
+	}
+	if r.hasSeq() {
+		if msg.seq == 0 {
+			return false
+		}
+		if r.fromSeq > 0 && msg.seq < r.fromSeq {
+			return false
+		}
+		if r.toSeq > 0 && msg.seq > r.toSeq {
+			return false
+		}
+	}
+	return true
+}
+
+// rehydratedMsg is a message read back from tier 2 storage, legacy json objects carry no sequence, time or partition
+type rehydratedMsg struct {
+	seq       uint64
+	time      time.Time
+	partition int
+	headers   map[string]string
+	payload   []byte
+}
+
+// tieredStorageObjectHour extracts the hour of the dt/hour partition an object was written to
+func tieredStorageObjectHour(objectName string) (time.Time, bool) {
+	var dt, hour string
+	for _, part := range strings.Split(objectName, "/") {
+		if strings.HasPrefix(part, "dt=") {
+			dt = strings.TrimPrefix(part, "dt=")
+		} else if strings.HasPrefix(part, "hour=") {
+			hour = strings.TrimPrefix(part, "hour=")
+		}
+	}
+	if dt == _EMPTY_ || hour == _EMPTY_ {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("2006-01-02T15", dt+"T"+hour)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// tieredStorageObjectFormat returns the format of an object by its extension
+func tieredStorageObjectFormat(objectName string) (string, bool) {
+	// .json is a suffix of none of the others so the order of the checks doesn't matter
+	for format, ext := range tieredStorageFormatExtensions {
+		if strings.HasSuffix(objectName, ext) {
+			return format, true
+		}
+	}
+	return _EMPTY_, false
+}
+
+func decodeTieredStorageObject(format string, data []byte) ([]rehydratedMsg, error) {
+	switch format {
+	case tieredStorageFormatJson:
+		var messages []Msg
+		err := json.Unmarshal(data, &messages)
+		if err != nil {
+			return nil, err
+		}
+		msgs := make([]rehydratedMsg, 0, len(messages))
+		for _, message := range messages {
+			payload, err := hex.DecodeString(message.Payload)
+			if err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, rehydratedMsg{headers: message.Headers, payload: payload})
+		}
+		return msgs, nil
+	case tieredStorageFormatNdjsonGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return readNdjsonRecords(zr)
+	case tieredStorageFormatNdjsonZstd:
+		zr, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return readNdjsonRecords(zr)
+	default:
+		return nil, fmt.Errorf("rehydrating %v objects is not supported", format)
+	}
+}
+
+func readNdjsonRecords(r io.Reader) ([]rehydratedMsg, error) {
+	var msgs []rehydratedMsg
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var record tieredRecord
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return msgs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, rehydratedMsg{
+			seq:       record.Sequence,
+			time:      record.Timestamp,
+			partition: record.Partition,
+			headers:   record.Headers,
+			payload:   record.Payload,
+		})
+	}
+}
+
+// listRehydrateObjects returns the station's objects that may hold messages of the range, oldest first as far as the keys tell
+func listRehydrateObjects(backend TieredStorageBackend, tenantName, stationName string, rng rehydrateRange) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tieredStorageBackendTimeout)
+	defer cancel()
+	names, err := backend.ListObjects(ctx, tieredStorageObjectPrefix(tenantName, stationName))
+	if err != nil {
+		return nil, err
+	}
+	var objects []string
+	for _, name := range names {
+		hour, ok := tieredStorageObjectHour(name)
+		if rng.hasTime() && (!ok || !rng.includesHour(hour)) {
+			// objects without a dt/hour key predate them and hold no timestamps
+			continue
+		}
+		objects = append(objects, name)
+	}
+	sort.Strings(objects)
+	return objects, nil
+}
+
+func (sh StationsHandler) RehydrateFromTieredStorage(c *gin.Context) {
+	var body models.RehydrateFromTieredStorageSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("RehydrateFromTieredStorage at getUserDetailsFromMiddleware: %v", err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	if !body.FromTime.IsZero() && !body.ToTime.IsZero() && body.FromTime.After(body.ToTime) {
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "from_time has to be before to_time"})
+		return
+	}
+	if body.FromSeq > 0 && body.ToSeq > 0 && body.FromSeq > body.ToSeq {
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "from_seq has to be lower than to_seq"})
+		return
+	}
+
+	stationName, err := StationNameFromStr(body.StationName)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]RehydrateFromTieredStorage at StationNameFromStr: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+	if !validateStationAccess(c, user, stationName.Ext(), "read", "RehydrateFromTieredStorage") {
+		return
+	}
+	exist, station, err := db.GetStationByName(stationName.Ext(), user.TenantName)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RehydrateFromTieredStorage at GetStationByName: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if !exist {
+		errMsg := fmt.Sprintf("Station %v does not exist", body.StationName)
+		serv.Warnf("[tenant: %v][user: %v]RehydrateFromTieredStorage: %v", user.TenantName, user.Username, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
+	}
+
+	target := station
+	if body.TargetStationName != _EMPTY_ {
+		targetName, err := StationNameFromStr(body.TargetStationName)
+		if err != nil {
+			serv.Warnf("[tenant: %v][user: %v]RehydrateFromTieredStorage at StationNameFromStr: At station %v: %v", user.TenantName, user.Username, body.TargetStationName, err.Error())
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+			return
+		}
+		exist, target, err = db.GetStationByName(targetName.Ext(), user.TenantName)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]RehydrateFromTieredStorage at GetStationByName: At station %v: %v", user.TenantName, user.Username, body.TargetStationName, err.Error())
+			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+			return
+		}
+		if !exist {
+			errMsg := fmt.Sprintf("Station %v does not exist", body.TargetStationName)
+			serv.Warnf("[tenant: %v][user: %v]RehydrateFromTieredStorage: %v", user.TenantName, user.Username, errMsg)
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+			return
+		}
+	}
+	if !validateStationAccess(c, user, target.Name, "write", "RehydrateFromTieredStorage") {
+		return
+	}
+
+	integrationType, err := resolveTieredStorageIntegration(user.TenantName, body.IntegrationType)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]RehydrateFromTieredStorage at resolveTieredStorageIntegration: %v", user.TenantName, user.Username, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": err.Error()})
+		return
+	}
+
+	running, err := db.IsAsyncTaskRunning(tieredRehydrateTaskName, user.TenantName, station.ID)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RehydrateFromTieredStorage at IsAsyncTaskRunning: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	if running {
+		errMsg := fmt.Sprintf("A rehydration of station %v is already running", stationName.Ext())
+		serv.Warnf("[tenant: %v][user: %v]RehydrateFromTieredStorage: %v", user.TenantName, user.Username, errMsg)
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": errMsg})
+		return
+	}
+
+	task, err := db.UpsertAsyncTask(tieredRehydrateTaskName, sh.S.opts.ServerName, time.Now(), user.TenantName, station.ID, user.Username)
+	if err != nil {
+		serv.Errorf("[tenant: %v][user: %v]RehydrateFromTieredStorage at UpsertAsyncTask: At station %v: %v", user.TenantName, user.Username, body.StationName, err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+	rng := rehydrateRange{fromTime: body.FromTime, toTime: body.ToTime, fromSeq: body.FromSeq, toSeq: body.ToSeq}
+	go sh.S.rehydrateFromTieredStorage(task, station, target, integrationType, rng, user)
+
+	c.IndentedJSON(200, gin.H{"task_id": task.ID})
+}
+
+// rehydrateFromTieredStorage reads the station's archived objects back from the integration and republishes
+// the messages of the range into the target station
+func (s *Server) rehydrateFromTieredStorage(task models.AsyncTask, station, target models.Station, integrationType string, rng rehydrateRange, user models.User) {
+	metaData := models.TieredRehydrateMetaData{Integration: integrationType, TargetStation: target.Name}
+	backend, err := getTenantTieredStorageBackend(task.TenantName, integrationType)
+	if err != nil {
+		s.handleRehydrateFailure(task, station, user, err)
+		return
+	}
+	objects, err := listRehydrateObjects(backend, task.TenantName, station.Name, rng)
+	if err != nil {
+		s.handleRehydrateFailure(task, station, user, err)
+		return
+	}
+	metaData.TotalObjects = len(objects)
+	err = db.UpdateAsyncTaskById(task.ID, time.Now(), metaData)
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]rehydrateFromTieredStorage at UpdateAsyncTaskById at station %v: %v", task.TenantName, user.Username, station.Name, err.Error())
+	}
+
+	account, err := s.lookupAccount(task.TenantName)
+	if err != nil {
+		s.handleRehydrateFailure(task, station, user, err)
+		return
+	}
+	targetName, err := StationNameFromStr(target.Name)
+	if err != nil {
+		s.handleRehydrateFailure(task, station, user, err)
+		return
+	}
+
+	for _, objectName := range objects {
+		format, ok := tieredStorageObjectFormat(objectName)
+		if !ok || format == tieredStorageFormatParquet {
+			// parquet objects are meant for query engines and are not read back by the broker
+			metaData.SkippedObjects++
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), tieredStorageBackendTimeout)
+		data, err := backend.ReadObject(ctx, objectName)
+		cancel()
+		if err != nil {
+			s.handleRehydrateFailure(task, station, user, fmt.Errorf("failed reading %v: %v", objectName, err.Error()))
+			return
+		}
+		msgs, err := decodeTieredStorageObject(format, data)
+		if err != nil {
+			s.handleRehydrateFailure(task, station, user, fmt.Errorf("failed decoding %v: %v", objectName, err.Error()))
+			return
+		}
+		for _, msg := range msgs {
+			if !rng.includes(msg) {
+				continue
+			}
+			if s.republishRehydratedMsg(account, station, target, targetName, msg) {
+				metaData.Republished++
+			} else {
+				metaData.Rejected++
+			}
+		}
+		metaData.ReadObjects++
+
+		// keeps the task alive for RemoveInactiveAsyncTasks
+		err = db.UpdateAsyncTaskById(task.ID, time.Now(), metaData)
+		if err != nil {
+			s.Errorf("[tenant: %v][user: %v]rehydrateFromTieredStorage at UpdateAsyncTaskById at station %v: %v", task.TenantName, user.Username, station.Name, err.Error())
+		}
+	}
+
+	err = db.UpdateAsyncTaskById(task.ID, time.Now(), metaData)
+	if err != nil {
+		s.handleRehydrateFailure(task, station, user, err)
+		return
+	}
+	err = db.UpdateStatusAsyncTaskById(task.ID, "completed", _EMPTY_)
+	if err != nil {
+		s.handleRehydrateFailure(task, station, user, err)
+		return
+	}
+
+	systemMessage := SystemMessage{
+		MessageType:    "info",
+		MessagePayload: fmt.Sprintf("Rehydration of %v messages from station %s into station %s, triggered by user %s has been completed successfully", metaData.Republished, station.Name, target.Name, user.Username),
+	}
+	err = s.sendSystemMessageOnWS(user, systemMessage)
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]rehydrateFromTieredStorage at sendSystemMessageOnWS at station %v: %v", task.TenantName, user.Username, station.Name, err.Error())
+	}
+}
+
+// republishRehydratedMsg returns false when the target station's enforced schema rejected the message
+func (s *Server) republishRehydratedMsg(account *Account, station, target models.Station, targetName StationName, msg rehydratedMsg) bool {
+	headers := make(map[string]string, len(msg.headers)+1)
+	for k, v := range msg.headers {
+		headers[k] = v
+	}
+	headers[rehydratedHeader] = station.Name
+
+	subject := fmt.Sprintf("%s.final", targetName.Intern())
+	if target.Version > 0 && len(target.PartitionsList) > 0 {
+		partition := 0
+		if station.ID == target.ID && msg.partition > 0 {
+			// replaying into the original station keeps the partition the message was produced to
+			for _, p := range target.PartitionsList {
+				if p == msg.partition {
+					partition = p
+					break
+				}
+			}
+		}
+		if partition == 0 {
+			if key := headers[partitionKeyHeader]; key != _EMPTY_ {
+				partition = partitionForKey([]byte(key), target.PartitionsList)
+			} else {
+				partition = target.PartitionsList[rand.Intn(len(target.PartitionsList))]
+			}
+		}
+		subject = fmt.Sprintf("%s$%v.final", targetName.Intern(), partition)
+	}
+
+	enforced, partition, err := validateBrokerEnforcedMsg(target.TenantName, subject, msg.payload)
+	if err != nil {
+		if enforced.dlsEnabled {
+			s.sendBrokerEnforcedMsgToDls(account, targetName.Intern(), partition, headers, msg.payload, err)
+		}
+		return false
+	}
+	err = s.sendInternalAccountMsgWithHeadersWithEcho(account, subject, msg.payload, headers)
+	return err == nil
+}
+
+func (s *Server) handleRehydrateFailure(task models.AsyncTask, station models.Station, user models.User, rehydrateErr error) {
+	s.Errorf("[tenant: %v][user: %v]rehydrateFromTieredStorage: at station %v: %v", task.TenantName, user.Username, station.Name, rehydrateErr.Error())
+	err := db.UpdateStatusAsyncTaskById(task.ID, "failed", rehydrateErr.Error())
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]handleRehydrateFailure at UpdateStatusAsyncTaskById at station %v: %v", task.TenantName, user.Username, station.Name, err.Error())
+	}
+	systemMessage := SystemMessage{
+		MessageType:    "error",
+		MessagePayload: fmt.Sprintf("Rehydration of station %s from tiered storage, triggered by user %s has failed", station.Name, user.Username),
+	}
+	err = s.sendSystemMessageOnWS(user, systemMessage)
+	if err != nil {
+		s.Errorf("[tenant: %v][user: %v]handleRehydrateFailure at sendSystemMessageOnWS at station %v: %v", task.TenantName, user.Username, station.Name, err.Error())
+	}
+}
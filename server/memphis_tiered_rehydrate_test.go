@@ -0,0 +1,78 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTieredStorageObjectHour(t *testing.T) {
+	hour, ok := tieredStorageObjectHour("memphis/tenant/orders/dt=2026-10-17/hour=09/uid(3).ndjson.gz")
+	if !ok || !hour.Equal(time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected hour %v %v", hour, ok)
+	}
+	if _, ok := tieredStorageObjectHour("memphis/tenant/orders/uid(3).json"); ok {
+		t.Fatalf("expected legacy key to have no hour")
+	}
+	format, ok := tieredStorageObjectFormat("memphis/tenant/orders/dt=2026-10-17/hour=09/uid(3).ndjson.zst")
+	if !ok || format != tieredStorageFormatNdjsonZstd {
+		t.Fatalf("unexpected format %v", format)
+	}
+}
+
+func TestDecodeTieredStorageObject(t *testing.T) {
+	ts := time.Date(2026, 10, 17, 13, 5, 0, 0, time.UTC)
+	legacy, _, err := buildTieredStorageObject([]StoredMsg{{Data: []byte("hello"), Header: []byte("NATS/1.0\r\nKey: v\r\n\r\n"), Sequence: 7, Time: ts}})
+	if err != nil {
+		t.Fatalf("buildTieredStorageObject: %v", err)
+	}
+	msgs, err := decodeTieredStorageObject(tieredStorageFormatJson, legacy)
+	if err != nil {
+		t.Fatalf("decode json: %v", err)
+	}
+	if len(msgs) != 1 || string(msgs[0].payload) != "hello" || msgs[0].headers["key"] != "v" || msgs[0].seq != 0 {
+		t.Fatalf("unexpected legacy msgs %+v", msgs)
+	}
+
+	records := testTieredRecords()
+	for _, format := range []string{tieredStorageFormatNdjsonGzip, tieredStorageFormatNdjsonZstd} {
+		data, err := encodeTieredRecords(format, records, nil)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		msgs, err := decodeTieredStorageObject(format, data)
+		if err != nil {
+			t.Fatalf("decode %v: %v", format, err)
+		}
+		if len(msgs) != len(records) {
+			t.Fatalf("%v: expected %v msgs, got %v", format, len(records), len(msgs))
+		}
+		for i, msg := range msgs {
+			if msg.seq != records[i].Sequence || !msg.time.Equal(records[i].Timestamp) || msg.partition != 1 || string(msg.payload) != string(records[i].Payload) {
+				t.Fatalf("%v: unexpected msg %+v", format, msg)
+			}
+		}
+	}
+	if _, err := decodeTieredStorageObject(tieredStorageFormatParquet, nil); err == nil {
+		t.Fatalf("expected parquet to be unsupported")
+	}
+}
+
+func TestRehydrateRange(t *testing.T) {
+	ts := time.Date(2026, 10, 17, 13, 5, 0, 0, time.UTC)
+	rng := rehydrateRange{fromTime: ts, toTime: ts.Add(time.Minute), fromSeq: 10, toSeq: 20}
+	if !rng.includes(rehydratedMsg{seq: 10, time: ts}) {
+		t.Fatalf("expected msg at the lower bounds to be included")
+	}
+	if rng.includes(rehydratedMsg{seq: 21, time: ts}) || rng.includes(rehydratedMsg{seq: 10, time: ts.Add(2 * time.Minute)}) {
+		t.Fatalf("expected msgs out of range to be excluded")
+	}
+	if rng.includes(rehydratedMsg{}) {
+		t.Fatalf("expected legacy msgs to be excluded from a bounded range")
+	}
+	if !(rehydrateRange{}).includes(rehydratedMsg{}) {
+		t.Fatalf("expected legacy msgs to be included in an unbounded range")
+	}
+	if !rng.includesHour(ts.Truncate(time.Hour)) || rng.includesHour(ts.Truncate(time.Hour).Add(-time.Hour)) || rng.includesHour(ts.Add(time.Hour)) {
+		t.Fatalf("unexpected hour pruning")
+	}
+}
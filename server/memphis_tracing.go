@@ -0,0 +1,58 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"github.com/memphisdev/memphis/tracing"
+)
+
+const tracingMessagingSystem = "memphis"
+
+func (s *Server) InitializeTracing() {
+	cfg := tracing.Config{
+		Endpoint:       configuration.OTEL_EXPORTER_OTLP_ENDPOINT,
+		Headers:        tracing.ParseHeaders(configuration.OTEL_EXPORTER_OTLP_HEADERS),
+		ServiceName:    configuration.OTEL_SERVICE_NAME,
+		ServiceVersion: s.MemphisVersion(),
+		InstanceID:     s.Name(),
+	}
+	err := tracing.Initialize(cfg, func(err error) {
+		s.Warnf("[tenant: %v]Tracing exporter: %v", s.MemphisGlobalAccountString(), err.Error())
+	})
+	if err != nil {
+		s.Errorf("[tenant: %v]InitializeTracing: %v", s.MemphisGlobalAccountString(), err.Error())
+		return
+	}
+	if tracing.Enabled() {
+		s.Noticef("[tenant: %v]Exporting traces to %v", s.MemphisGlobalAccountString(), configuration.OTEL_EXPORTER_OTLP_ENDPOINT)
+	}
+}
+
+// startStationSpan starts a span for a hop owned by the broker, parented on the trace context the message carries
+func startStationSpan(name string, kind tracing.SpanKind, headers map[string]string, tenantName, stationName string) *tracing.Span {
+	parent, _ := tracing.FromHeaders(headers)
+	span := tracing.Start(name, kind, parent)
+	span.SetAttribute("messaging.system", tracingMessagingSystem)
+	span.SetAttribute("messaging.destination.name", stationName)
+	span.SetAttribute("memphis.tenant", tenantName)
+	return span
+}
+
+// headersWithTraceContext returns a copy of headers that points at sc, the original map is left untouched
+func headersWithTraceContext(headers map[string]string, sc tracing.SpanContext) map[string]string {
+	res := make(map[string]string, len(headers)+2)
+	for k, v := range headers {
+		res[k] = v
+	}
+	sc.Inject(res)
+	return res
+}
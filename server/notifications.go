@@ -29,6 +29,8 @@ type NotificationMsg struct {
 	Message    string    `json:"message"`
 	MsgType    string    `json:"msgType"`
 	Time       time.Time `json:"time"`
+	// Integration is empty on notifications queued by older brokers, those are slack ones
+	Integration string `json:"integration,omitempty"`
 }
 
 type NotificationMsgWithReply struct {
@@ -39,33 +41,32 @@ type NotificationMsgWithReply struct {
 func (s *Server) SendNotification(tenantName string, title string, message string, msgType string) error {
 	for k := range NotificationFunctionsMap {
 		switch k {
-		case slackIntegrationName:
-			if tenantInetgrations, ok := IntegrationsConcurrentCache.Load(tenantName); ok {
-				if slackIntegration, ok := tenantInetgrations[slackIntegrationName].(models.SlackIntegration); ok {
-					if slackIntegration.Properties[msgType] {
-						// TODO: if the stream doesn't exist save the messages in buffer
-						if !NOTIFICATIONS_BUFFER_STREAM_CREATED {
-							return nil
-						}
+		case slackIntegrationName, webhookIntegrationName:
+			if !integrationHasAlert(tenantName, k, msgType) {
+				continue
+			}
+			// TODO: if the stream doesn't exist save the messages in buffer
+			if !NOTIFICATIONS_BUFFER_STREAM_CREATED {
+				return nil
+			}
 
-						// TODO: do we need msg-id here? if yes - what's the best way to generate it? hash title?
-						if tenantName == "" {
-							tenantName = serv.MemphisGlobalAccountString()
-						}
-						notificationMsg := NotificationMsg{
-							TenantName: tenantName,
-							Title:      title,
-							Message:    message,
-							MsgType:    msgType,
-							Time:       time.Now(),
-						}
+			// TODO: do we need msg-id here? if yes - what's the best way to generate it? hash title?
+			queuedTenantName := tenantName
+			if queuedTenantName == "" {
+				queuedTenantName = serv.MemphisGlobalAccountString()
+			}
+			notificationMsg := NotificationMsg{
+				TenantName:  queuedTenantName,
+				Title:       title,
+				Message:     message,
+				MsgType:     msgType,
+				Time:        time.Now(),
+				Integration: k,
+			}
 
-						err := saveSlackNotificationToQueue(s, notificationsStreamName+".user_notifications", tenantName, &notificationMsg)
-						if err != nil {
-							return err
-						}
-					}
-				}
+			err := saveSlackNotificationToQueue(s, notificationsStreamName+".user_notifications", queuedTenantName, &notificationMsg)
+			if err != nil {
+				return err
 			}
 		default:
 			return errors.New("failed sending notification: unsupported integration")
@@ -88,12 +89,41 @@ func saveSlackNotificationToQueue(s *Server, subject, tenantName string, notific
 }
 
 func shouldSendNotification(tenantName string, alertType string) bool {
-	if tenantInetgrations, ok := IntegrationsConcurrentCache.Load(tenantName); ok {
-		if slackIntegration, ok := tenantInetgrations["slack"].(models.SlackIntegration); ok {
-			if slackIntegration.Properties[alertType] {
-				return true
+	return integrationHasAlert(tenantName, slackIntegrationName, alertType) || integrationHasAlert(tenantName, webhookIntegrationName, alertType)
+}
+
+func integrationHasAlert(tenantName, integrationName, alertType string) bool {
+	tenantInetgrations, ok := IntegrationsConcurrentCache.Load(tenantName)
+	if !ok {
+		return false
+	}
+	switch integration := tenantInetgrations[integrationName].(type) {
+	case models.SlackIntegration:
+		return integration.Properties[alertType]
+	case models.WebhookIntegration:
+		return integration.Properties[alertType]
+	}
+	return false
+}
+
+// sendBufferedNotifications hands every buffered notification to the integration it was queued for,
+// notifications queued before they carried the integration are slack ones
+func sendBufferedNotifications(s *Server, msgs []slackMsg) {
+	tenantMsgs := groupMessagesByTenant(msgs, s)
+	for tenantName, tMsgs := range tenantMsgs {
+		var slackMsgs, webhookMsgs []NotificationMsgWithReply
+		for _, m := range tMsgs {
+			if m.NotificationMsg.Integration == webhookIntegrationName {
+				webhookMsgs = append(webhookMsgs, m)
+			} else {
+				slackMsgs = append(slackMsgs, m)
 			}
 		}
+		if len(slackMsgs) > 0 {
+			sendTenantSlackNotifications(s, tenantName, slackMsgs)
+		}
+		if len(webhookMsgs) > 0 {
+			sendTenantWebhookNotifications(s, tenantName, webhookMsgs)
+		}
 	}
-	return false
 }
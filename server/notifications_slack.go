@@ -56,7 +56,7 @@ func sendMessageToSlackChannel(integration models.SlackIntegration, title string
 
 func cacheDetailsSlack(keys map[string]interface{}, properties map[string]bool, tenantName string) {
 	var authToken, channelID string
-	var poisonMessageAlert, schemaValidationFailAlert, disconnectionEventsAlert bool
+	var poisonMessageAlert, schemaValidationFailAlert, disconnectionEventsAlert, cgLagAlert bool
 	slackIntegration := models.SlackIntegration{}
 	slackIntegration.Keys = make(map[string]string)
 	slackIntegration.Properties = make(map[string]bool)
@@ -68,6 +68,7 @@ func cacheDetailsSlack(keys map[string]interface{}, properties map[string]bool,
 		poisonMessageAlert = false
 		schemaValidationFailAlert = false
 		disconnectionEventsAlert = false
+		cgLagAlert = false
 	}
 	authToken, ok := keys["auth_token"].(string)
 	if !ok {
@@ -91,6 +92,10 @@ func cacheDetailsSlack(keys map[string]interface{}, properties map[string]bool,
 	if !ok {
 		disconnectionEventsAlert = false
 	}
+	cgLagAlert, ok = properties[CgLagAlert]
+	if !ok {
+		cgLagAlert = false
+	}
 	if slackIntegration.Keys["auth_token"] != authToken {
 		slackIntegration.Keys["auth_token"] = authToken
 		if authToken != _EMPTY_ {
@@ -102,6 +107,7 @@ func cacheDetailsSlack(keys map[string]interface{}, properties map[string]bool,
 	slackIntegration.Properties[PoisonMAlert] = poisonMessageAlert
 	slackIntegration.Properties[SchemaVAlert] = schemaValidationFailAlert
 	slackIntegration.Properties[DisconEAlert] = disconnectionEventsAlert
+	slackIntegration.Properties[CgLagAlert] = cgLagAlert
 	slackIntegration.Name = "slack"
 	if _, ok := IntegrationsConcurrentCache.Load(tenantName); !ok {
 		IntegrationsConcurrentCache.Add(tenantName, map[string]interface{}{"slack": slackIntegration})
@@ -116,7 +122,7 @@ func cacheDetailsSlack(keys map[string]interface{}, properties map[string]bool,
 
 func (it IntegrationsHandler) getSlackIntegrationDetails(body models.CreateIntegrationSchema) (map[string]interface{}, map[string]bool, int, error) {
 	var authToken, channelID, uiUrl string
-	var pmAlert, svfAlert, disconnectAlert bool
+	var pmAlert, svfAlert, disconnectAlert, cgLagAlert bool
 	authToken, ok := body.Keys["auth_token"].(string)
 	if !ok {
 		return map[string]interface{}{}, map[string]bool{}, SHOWABLE_ERROR_STATUS_CODE, errors.New("must provide auth token for slack integration")
@@ -144,8 +150,12 @@ func (it IntegrationsHandler) getSlackIntegrationDetails(body models.CreateInteg
 	if !ok {
 		disconnectAlert = false
 	}
+	cgLagAlert, ok = body.Properties[CgLagAlert]
+	if !ok {
+		cgLagAlert = false
+	}
 
-	keys, properties := createIntegrationsKeysAndProperties("slack", authToken, channelID, pmAlert, svfAlert, disconnectAlert, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_, map[string]interface{}{}, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_)
+	keys, properties := createIntegrationsKeysAndProperties("slack", authToken, channelID, pmAlert, svfAlert, disconnectAlert, cgLagAlert, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_, map[string]interface{}{}, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_)
 	return keys, properties, 0, nil
 }
 
@@ -174,7 +184,7 @@ func (it IntegrationsHandler) handleUpdateSlackIntegration(tenantName, integrati
 	if err != nil {
 		return models.Integration{}, errorCode, err
 	}
-	slackIntegration, err := updateSlackIntegration(tenantName, keys["auth_token"].(string), keys["channel_id"].(string), properties[PoisonMAlert], properties[SchemaVAlert], properties[DisconEAlert], body.UIUrl)
+	slackIntegration, err := updateSlackIntegration(tenantName, keys["auth_token"].(string), keys["channel_id"].(string), properties[PoisonMAlert], properties[SchemaVAlert], properties[DisconEAlert], properties[CgLagAlert], body.UIUrl)
 	if err != nil {
 		errMsg := strings.ToLower(err.Error())
 		if strings.Contains(errMsg, "invalid auth token") || strings.Contains(errMsg, "invalid channel") {
@@ -236,7 +246,7 @@ func createSlackIntegration(tenantName string, keys map[string]interface{}, prop
 	return slackIntegration, errors.New("slack integration already exists")
 }
 
-func updateSlackIntegration(tenantName string, authToken string, channelID string, pmAlert bool, svfAlert bool, disconnectAlert bool, uiUrl string) (models.Integration, error) {
+func updateSlackIntegration(tenantName string, authToken string, channelID string, pmAlert bool, svfAlert bool, disconnectAlert bool, cgLagAlert bool, uiUrl string) (models.Integration, error) {
 	var slackIntegration models.Integration
 	if authToken == _EMPTY_ {
 		exist, integrationFromDb, err := db.GetIntegration("slack", tenantName)
@@ -257,7 +267,7 @@ func updateSlackIntegration(tenantName string, authToken string, channelID strin
 	if err != nil {
 		return slackIntegration, err
 	}
-	keys, properties := createIntegrationsKeysAndProperties("slack", authToken, channelID, pmAlert, svfAlert, disconnectAlert, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_, map[string]interface{}{}, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_)
+	keys, properties := createIntegrationsKeysAndProperties("slack", authToken, channelID, pmAlert, svfAlert, disconnectAlert, cgLagAlert, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_, map[string]interface{}{}, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_)
 	stringMapKeys := GetKeysAsStringMap(keys)
 	cloneKeys := copyMaps(stringMapKeys)
 	encryptedValue, err := EncryptAES([]byte(authToken))
@@ -321,13 +331,6 @@ type slackMsg struct {
 	ReplySubject string
 }
 
-func sendSlackNotifications(s *Server, msgs []slackMsg) {
-	tenantMsgs := groupMessagesByTenant(msgs, s)
-	for tenantName, tMsgs := range tenantMsgs {
-		sendTenantSlackNotifications(s, tenantName, tMsgs)
-	}
-}
-
 func sendTenantSlackNotifications(s *Server, tenantName string, msgs []NotificationMsgWithReply) {
 	var ok bool
 	if _, ok := NotificationFunctionsMap[slackIntegrationName]; !ok {
@@ -0,0 +1,515 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/models"
+	"github.com/memphisdev/memphis/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	webhookIntegrationName   = "webhook"
+	webhookTestAlert         = "test"
+	webhookSignatureHeader   = "X-Memphis-Signature"
+	webhookTimestampHeader   = "X-Memphis-Timestamp"
+	webhookEventHeader       = "X-Memphis-Event"
+	webhookRequestTimeout    = 10 * time.Second
+	webhookRetryBaseDelay    = 5 * time.Second
+	webhookRetryMaxDelay     = 5 * time.Minute
+	webhookMaxDeliveries     = 10 // MaxDeliver of the notifications buffer consumer
+	webhookMaxResponseToRead = 1024
+)
+
+var webhookAlertTypes = []string{PoisonMAlert, SchemaVAlert, DisconEAlert, CgLagAlert}
+
+var errWebhookTargetNotAllowed = errors.New("private, loopback and link-local addresses are not allowed as webhook targets")
+
+// webhookHttpClient dials without a proxy so the address check below sees the webhook target itself
+var webhookHttpClient = &http.Client{
+	Timeout: webhookRequestTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: webhookRequestTimeout,
+			Control: webhookDialControl,
+		}).DialContext,
+		TLSHandshakeTimeout: webhookRequestTimeout,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+var webhookCgnatRange = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// webhookDialControl runs once the target was resolved, so a hostname can not point a webhook at the
+// broker's own network, WEBHOOK_ALLOW_PRIVATE_HOSTS opts in to such targets
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	if configuration.WEBHOOK_ALLOW_PRIVATE_HOSTS {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isWebhookBlockedIP(ip) {
+		return fmt.Errorf("%w: %v", errWebhookTargetNotAllowed, host)
+	}
+	return nil
+}
+
+func isWebhookBlockedIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || webhookCgnatRange.Contains(ip)
+}
+
+// webhookPayload is what the payload templates are rendered with
+type webhookPayload struct {
+	Title      string `json:"title"`
+	Message    string `json:"message"`
+	Type       string `json:"type"`
+	TenantName string `json:"tenant_name"`
+	Time       string `json:"time"`
+}
+
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// webhookDeliveryError tells whether a failed delivery is worth retrying
+type webhookDeliveryError struct {
+	retryable bool
+	err       error
+}
+
+func (e webhookDeliveryError) Error() string {
+	return e.err.Error()
+}
+
+func (e webhookDeliveryError) Unwrap() error {
+	return e.err
+}
+
+// stringMapKey reads a nested object key of an integration, it is a map[string]interface{} after a json round trip
+func stringMapKey(keys map[string]interface{}, key string) map[string]string {
+	res := map[string]string{}
+	switch v := keys[key].(type) {
+	case map[string]string:
+		for k, val := range v {
+			res[k] = val
+		}
+	case map[string]interface{}:
+		for k, val := range v {
+			if str, ok := val.(string); ok {
+				res[k] = str
+			}
+		}
+	}
+	return res
+}
+
+func renderWebhookPayload(tmpl string, msg NotificationMsg) ([]byte, error) {
+	payload := webhookPayload{
+		Title:      msg.Title,
+		Message:    msg.Message,
+		Type:       msg.MsgType,
+		TenantName: msg.TenantName,
+		Time:       msg.Time.UTC().Format(time.RFC3339),
+	}
+	if tmpl == _EMPTY_ {
+		return json.Marshal(payload)
+	}
+	t, err := template.New("payload").Funcs(webhookTemplateFuncs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = t.Execute(&buf, payload)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, errors.New("the rendered payload is not a valid json")
+	}
+	return buf.Bytes(), nil
+}
+
+// signWebhookPayload signs the timestamp and the body so a captured request can't be replayed with another timestamp
+func signWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func sendWebhookNotification(integration models.WebhookIntegration, msg NotificationMsg) error {
+	body, err := renderWebhookPayload(integration.Templates[msg.MsgType], msg)
+	if err != nil {
+		return webhookDeliveryError{retryable: false, err: fmt.Errorf("failed rendering the %v payload: %v", msg.MsgType, err.Error())}
+	}
+	req, err := http.NewRequest(http.MethodPost, integration.Keys["url"], bytes.NewReader(body))
+	if err != nil {
+		return webhookDeliveryError{retryable: false, err: err}
+	}
+	for k, v := range integration.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Memphis")
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(webhookEventHeader, msg.MsgType)
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	if secret := integration.Keys["secret_key"]; secret != _EMPTY_ {
+		req.Header.Set(webhookSignatureHeader, signWebhookPayload(secret, timestamp, body))
+	}
+
+	resp, err := webhookHttpClient.Do(req)
+	if err != nil {
+		return webhookDeliveryError{retryable: !errors.Is(err, errWebhookTargetNotAllowed), err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxResponseToRead))
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500
+	return webhookDeliveryError{retryable: retryable, err: fmt.Errorf("webhook responded with %v: %v", resp.StatusCode, strings.TrimSpace(string(respBody)))}
+}
+
+// webhookRetryDelay backs off exponentially by the number of times the notification was delivered
+func webhookRetryDelay(deliveries uint64) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := uint64(1); i < deliveries && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMaxDelay {
+		delay = webhookRetryMaxDelay
+	}
+	return delay
+}
+
+func sendTenantWebhookNotifications(s *Server, tenantName string, msgs []NotificationMsgWithReply) {
+	tenantIntegrations, ok := IntegrationsConcurrentCache.Load(tenantName)
+	if !ok {
+		// the webhook is either not enabled or have been disabled - just ack these messages
+		ackMsgs(s, msgs)
+		return
+	}
+	webhookIntegration, ok := tenantIntegrations[webhookIntegrationName].(models.WebhookIntegration)
+	if !ok {
+		ackMsgs(s, msgs)
+		return
+	}
+
+	for _, m := range msgs {
+		err := sendWebhookNotification(webhookIntegration, *m.NotificationMsg)
+		if err != nil {
+			var deliveryErr webhookDeliveryError
+			_, _, deliveries := ackReplyInfo(m.ReplySubject)
+			if errors.As(err, &deliveryErr) && deliveryErr.retryable && deliveries < webhookMaxDeliveries {
+				s.Warnf("[tenant: %v]failed to send webhook notification, retrying: %v", tenantName, err.Error())
+				err = nackMsgs(s, []NotificationMsgWithReply{m}, webhookRetryDelay(deliveries))
+				if err != nil {
+					s.Errorf("[tenant: %v]failed to send NACK for webhook notification: %v", tenantName, err.Error())
+				}
+				continue
+			}
+			s.Errorf("[tenant: %v]failed to send webhook notification: %v", tenantName, err.Error())
+		}
+
+		err = s.sendInternalAccountMsg(s.MemphisGlobalAccount(), m.ReplySubject, []byte(_EMPTY_))
+		if err != nil {
+			s.Errorf("[tenant: %v]failed to send ACK for webhook notification: %v", tenantName, err.Error())
+		}
+	}
+}
+
+func cacheDetailsWebhook(keys map[string]interface{}, properties map[string]bool, tenantName string) {
+	if keys == nil {
+		deleteIntegrationFromTenant(tenantName, webhookIntegrationName, IntegrationsConcurrentCache)
+		return
+	}
+	webhookUrl, ok := keys["url"].(string)
+	if !ok {
+		deleteIntegrationFromTenant(tenantName, webhookIntegrationName, IntegrationsConcurrentCache)
+		return
+	}
+	secret, _ := keys["secret_key"].(string)
+	webhookIntegration := models.WebhookIntegration{
+		Name:       webhookIntegrationName,
+		Keys:       map[string]string{"url": webhookUrl, "secret_key": secret},
+		Headers:    stringMapKey(keys, "headers"),
+		Templates:  stringMapKey(keys, "templates"),
+		Properties: make(map[string]bool),
+	}
+	for _, alertType := range webhookAlertTypes {
+		webhookIntegration.Properties[alertType] = properties[alertType]
+	}
+	if _, ok := IntegrationsConcurrentCache.Load(tenantName); !ok {
+		IntegrationsConcurrentCache.Add(tenantName, map[string]interface{}{webhookIntegrationName: webhookIntegration})
+	} else {
+		err := addIntegrationToTenant(tenantName, webhookIntegrationName, IntegrationsConcurrentCache, webhookIntegration)
+		if err != nil {
+			serv.Errorf("cacheDetailsWebhook: " + err.Error())
+			return
+		}
+	}
+}
+
+// getWebhookIntegrationDetails validates the webhook keys, an empty secret_key keeps the one already stored
+func getWebhookIntegrationDetails(tenantName string, bodyKeys map[string]interface{}, bodyProperties map[string]bool) (map[string]interface{}, map[string]bool, int, error) {
+	webhookUrl := strings.TrimSpace(integrationKeyString(bodyKeys, "url"))
+	if webhookUrl == _EMPTY_ {
+		return nil, nil, SHOWABLE_ERROR_STATUS_CODE, errors.New("must provide a url for the webhook integration")
+	}
+	parsedUrl, err := url.Parse(webhookUrl)
+	if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == _EMPTY_ {
+		return nil, nil, SHOWABLE_ERROR_STATUS_CODE, errors.New("the webhook url has to be an absolute http or https url")
+	}
+
+	secret := integrationKeyString(bodyKeys, "secret_key")
+	if secret == _EMPTY_ {
+		exist, integrationFromDb, err := db.GetIntegration(webhookIntegrationName, tenantName)
+		if err != nil {
+			return nil, nil, 500, err
+		}
+		if exist {
+			if value, ok := integrationFromDb.Keys["secret_key"].(string); ok && value != _EMPTY_ {
+				secret, err = DecryptAES(getAESKey(), value)
+				if err != nil {
+					return nil, nil, 500, err
+				}
+			}
+		}
+	}
+
+	headers := map[string]interface{}{}
+	for k, v := range stringMapKey(bodyKeys, "headers") {
+		canonical := http.CanonicalHeaderKey(strings.TrimSpace(k))
+		switch canonical {
+		case _EMPTY_:
+			continue
+		case "Content-Type", webhookSignatureHeader, webhookTimestampHeader, webhookEventHeader:
+			return nil, nil, SHOWABLE_ERROR_STATUS_CODE, fmt.Errorf("the %v header is set by Memphis", canonical)
+		}
+		headers[canonical] = v
+	}
+
+	templates := map[string]interface{}{}
+	sample := NotificationMsg{TenantName: tenantName, Title: "title", Message: "message", Time: time.Now()}
+	for alertType, tmpl := range stringMapKey(bodyKeys, "templates") {
+		if !webhookAlertType(alertType) {
+			return nil, nil, SHOWABLE_ERROR_STATUS_CODE, fmt.Errorf("unknown alert type %v in the payload templates", alertType)
+		}
+		if strings.TrimSpace(tmpl) == _EMPTY_ {
+			continue
+		}
+		sample.MsgType = alertType
+		if _, err := renderWebhookPayload(tmpl, sample); err != nil {
+			return nil, nil, SHOWABLE_ERROR_STATUS_CODE, fmt.Errorf("invalid payload template for %v: %v", alertType, err.Error())
+		}
+		templates[alertType] = tmpl
+	}
+
+	keys := map[string]interface{}{
+		"url":        webhookUrl,
+		"secret_key": secret,
+		"headers":    headers,
+		"templates":  templates,
+	}
+	properties := make(map[string]bool, len(webhookAlertTypes))
+	for _, alertType := range webhookAlertTypes {
+		properties[alertType] = bodyProperties[alertType]
+	}
+	return keys, properties, 0, nil
+}
+
+func webhookAlertType(alertType string) bool {
+	for _, t := range webhookAlertTypes {
+		if t == alertType {
+			return true
+		}
+	}
+	return false
+}
+
+func (it IntegrationsHandler) handleCreateWebhookIntegration(tenantName string, body models.CreateIntegrationSchema) (models.Integration, int, error) {
+	exist, _, err := db.GetIntegration(webhookIntegrationName, tenantName)
+	if err != nil {
+		return models.Integration{}, 500, err
+	}
+	if exist {
+		return models.Integration{}, SHOWABLE_ERROR_STATUS_CODE, errors.New("webhook integration already exists")
+	}
+	keys, properties, errorCode, err := getWebhookIntegrationDetails(tenantName, body.Keys, body.Properties)
+	if err != nil {
+		return models.Integration{}, errorCode, err
+	}
+	encryptedKeys, err := encryptIntegrationSecretKey(keys)
+	if err != nil {
+		return models.Integration{}, 500, err
+	}
+	integration, err := db.InsertNewIntegration(tenantName, webhookIntegrationName, encryptedKeys, properties)
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			return models.Integration{}, SHOWABLE_ERROR_STATUS_CODE, err
+		}
+		return models.Integration{}, 500, err
+	}
+	err = broadcastWebhookIntegration(tenantName, keys, properties, integration.IsValid)
+	if err != nil {
+		return models.Integration{}, 500, err
+	}
+	integration.Keys = hideIntegrationKeysSecret(keys)
+	return integration, 0, nil
+}
+
+func (it IntegrationsHandler) handleUpdateWebhookIntegration(tenantName string, body models.CreateIntegrationSchema) (models.Integration, int, error) {
+	keys, properties, errorCode, err := getWebhookIntegrationDetails(tenantName, body.Keys, body.Properties)
+	if err != nil {
+		return models.Integration{}, errorCode, err
+	}
+	encryptedKeys, err := encryptIntegrationSecretKey(keys)
+	if err != nil {
+		return models.Integration{}, 500, err
+	}
+	integration, err := db.UpdateIntegration(tenantName, webhookIntegrationName, encryptedKeys, properties)
+	if err != nil {
+		return models.Integration{}, 500, err
+	}
+	err = broadcastWebhookIntegration(tenantName, keys, properties, integration.IsValid)
+	if err != nil {
+		return models.Integration{}, 500, err
+	}
+	integration.Keys = hideIntegrationKeysSecret(keys)
+	integration.Properties = properties
+	return integration, 0, nil
+}
+
+func broadcastWebhookIntegration(tenantName string, keys map[string]interface{}, properties map[string]bool, isValid bool) error {
+	integrationToUpdate := models.CreateIntegration{
+		Name:       webhookIntegrationName,
+		Keys:       keys,
+		Properties: properties,
+		TenantName: tenantName,
+		IsValid:    isValid,
+	}
+	msg, err := json.Marshal(integrationToUpdate)
+	if err != nil {
+		return err
+	}
+	err = serv.sendInternalAccountMsgWithReply(serv.MemphisGlobalAccount(), INTEGRATIONS_UPDATES_SUBJ, _EMPTY_, nil, msg, true)
+	if err != nil {
+		return err
+	}
+	update := models.SdkClientsUpdates{
+		Type:   sendNotificationType,
+		Update: properties[SchemaVAlert] || integrationHasAlert(tenantName, slackIntegrationName, SchemaVAlert),
+	}
+	serv.SendUpdateToClients(update)
+	return nil
+}
+
+// testWebhookIntegration delivers a test notification right away, bypassing the notifications buffer
+func testWebhookIntegration(tenantName string, keys map[string]interface{}) error {
+	integration := models.WebhookIntegration{
+		Name:      webhookIntegrationName,
+		Keys:      map[string]string{"url": integrationKeyString(keys, "url"), "secret_key": integrationKeyString(keys, "secret_key")},
+		Headers:   stringMapKey(keys, "headers"),
+		Templates: stringMapKey(keys, "templates"),
+	}
+	msg := NotificationMsg{
+		TenantName: tenantName,
+		Title:      "Memphis webhook test",
+		Message:    "This is a test notification sent from Memphis",
+		MsgType:    webhookTestAlert,
+		Time:       time.Now(),
+	}
+	return sendWebhookNotification(integration, msg)
+}
+
+func (it IntegrationsHandler) TestWebhookIntegration(c *gin.Context) {
+	var body models.TestWebhookIntegrationSchema
+	ok := utils.Validate(c, &body, false, nil)
+	if !ok {
+		return
+	}
+	user, err := getUserDetailsFromMiddleware(c)
+	if err != nil {
+		serv.Errorf("TestWebhookIntegration at getUserDetailsFromMiddleware: %v", err.Error())
+		c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+		return
+	}
+
+	keys := body.Keys
+	if integrationKeyString(keys, "url") == _EMPTY_ {
+		// testing the connected webhook
+		exist, integration, err := db.GetIntegration(webhookIntegrationName, user.TenantName)
+		if err != nil {
+			serv.Errorf("[tenant: %v][user: %v]TestWebhookIntegration at GetIntegration: %v", user.TenantName, user.Username, err.Error())
+			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+			return
+		}
+		if !exist {
+			c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Webhook integration does not exist"})
+			return
+		}
+		keys = make(map[string]interface{}, len(integration.Keys))
+		for k, v := range integration.Keys {
+			keys[k] = v
+		}
+		// the stored secret is encrypted, getWebhookIntegrationDetails loads it when it is missing
+		delete(keys, "secret_key")
+	}
+	keys, _, errorCode, err := getWebhookIntegrationDetails(user.TenantName, keys, nil)
+	if err != nil {
+		if errorCode == 500 {
+			serv.Errorf("[tenant: %v][user: %v]TestWebhookIntegration at getWebhookIntegrationDetails: %v", user.TenantName, user.Username, err.Error())
+			c.AbortWithStatusJSON(500, gin.H{"message": "Server error"})
+			return
+		}
+		c.AbortWithStatusJSON(errorCode, gin.H{"message": err.Error()})
+		return
+	}
+
+	err = testWebhookIntegration(user.TenantName, keys)
+	if err != nil {
+		serv.Warnf("[tenant: %v][user: %v]TestWebhookIntegration at testWebhookIntegration: %v", user.TenantName, user.Username, err.Error())
+		c.AbortWithStatusJSON(SHOWABLE_ERROR_STATUS_CODE, gin.H{"message": "Failed sending the test notification: " + err.Error()})
+		return
+	}
+	c.IndentedJSON(200, gin.H{})
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/memphisdev/memphis/models"
+)
+
+func allowPrivateWebhookTargets(t *testing.T, allow bool) {
+	t.Helper()
+	previous := configuration.WEBHOOK_ALLOW_PRIVATE_HOSTS
+	configuration.WEBHOOK_ALLOW_PRIVATE_HOSTS = allow
+	t.Cleanup(func() { configuration.WEBHOOK_ALLOW_PRIVATE_HOSTS = previous })
+}
+
+func TestSendWebhookNotification(t *testing.T) {
+	allowPrivateWebhookTargets(t, true)
+	var gotBody []byte
+	var gotHeaders http.Header
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeaders = r.Header.Clone()
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	integration := models.WebhookIntegration{
+		Name:      webhookIntegrationName,
+		Keys:      map[string]string{"url": srv.URL, "secret_key": "shh"},
+		Headers:   map[string]string{"Authorization": "GenieKey 123"},
+		Templates: map[string]string{PoisonMAlert: `{"message": {{json .Message}}, "priority": "P1"}`},
+	}
+	msg := NotificationMsg{TenantName: "tenant", Title: "Poison message", Message: `quote " inside`, MsgType: PoisonMAlert, Time: time.Now()}
+	if err := sendWebhookNotification(integration, msg); err != nil {
+		t.Fatalf("sendWebhookNotification: %v", err)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal(gotBody, &payload); err != nil || payload["message"] != msg.Message || payload["priority"] != "P1" {
+		t.Fatalf("unexpected payload %s: %v", gotBody, err)
+	}
+	if gotHeaders.Get("Authorization") != "GenieKey 123" || gotHeaders.Get(webhookEventHeader) != PoisonMAlert {
+		t.Fatalf("unexpected headers %v", gotHeaders)
+	}
+	if gotHeaders.Get(webhookSignatureHeader) != signWebhookPayload("shh", gotHeaders.Get(webhookTimestampHeader), gotBody) {
+		t.Fatalf("unexpected signature %v", gotHeaders.Get(webhookSignatureHeader))
+	}
+
+	// alert types without a template get the default payload
+	msg.MsgType = DisconEAlert
+	if err := sendWebhookNotification(integration, msg); err != nil {
+		t.Fatalf("sendWebhookNotification: %v", err)
+	}
+	var defaultPayload webhookPayload
+	if err := json.Unmarshal(gotBody, &defaultPayload); err != nil || defaultPayload.Type != DisconEAlert || defaultPayload.TenantName != "tenant" {
+		t.Fatalf("unexpected default payload %s: %v", gotBody, err)
+	}
+
+	for code, retryable := range map[int]bool{http.StatusServiceUnavailable: true, http.StatusTooManyRequests: true, http.StatusBadRequest: false} {
+		status = code
+		err := sendWebhookNotification(integration, msg)
+		deliveryErr, ok := err.(webhookDeliveryError)
+		if !ok || deliveryErr.retryable != retryable {
+			t.Fatalf("status %v: expected retryable %v, got %v", code, retryable, err)
+		}
+	}
+}
+
+func TestWebhookRejectsPrivateTargets(t *testing.T) {
+	allowPrivateWebhookTargets(t, false)
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	integration := models.WebhookIntegration{Name: webhookIntegrationName, Keys: map[string]string{"url": srv.URL}}
+	err := sendWebhookNotification(integration, NotificationMsg{TenantName: "tenant", MsgType: PoisonMAlert, Time: time.Now()})
+	deliveryErr, ok := err.(webhookDeliveryError)
+	if !ok || deliveryErr.retryable || !errors.Is(err, errWebhookTargetNotAllowed) || called {
+		t.Fatalf("expected a loopback target to be rejected without a retry, got %v", err)
+	}
+
+	for addr, blocked := range map[string]bool{
+		"127.0.0.1": true, "10.1.2.3": true, "172.16.0.1": true, "192.168.1.1": true, "169.254.169.254": true,
+		"100.64.0.1": true, "0.0.0.0": true, "::1": true, "fe80::1": true, "fd00::1": true, "::ffff:127.0.0.1": true,
+		"8.8.8.8": false, "2606:4700:4700::1111": false,
+	} {
+		if got := isWebhookBlockedIP(net.ParseIP(addr)); got != blocked {
+			t.Errorf("address %v: expected blocked %v, got %v", addr, blocked, got)
+		}
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	if webhookRetryDelay(1) != webhookRetryBaseDelay || webhookRetryDelay(3) != 4*webhookRetryBaseDelay {
+		t.Fatalf("unexpected backoff %v %v", webhookRetryDelay(1), webhookRetryDelay(3))
+	}
+	if webhookRetryDelay(50) != webhookRetryMaxDelay {
+		t.Fatalf("expected the backoff to be capped, got %v", webhookRetryDelay(50))
+	}
+}
+
+func TestGetWebhookIntegrationDetails(t *testing.T) {
+	keys := map[string]interface{}{
+		"url":        "https://alerts.example.com/hook",
+		"secret_key": "shh",
+		"headers":    map[string]interface{}{"x-team": "data"},
+		"templates":  map[string]interface{}{CgLagAlert: `{"text": {{json .Title}}}`},
+	}
+	res, properties, _, err := getWebhookIntegrationDetails("tenant", keys, map[string]bool{CgLagAlert: true})
+	if err != nil {
+		t.Fatalf("getWebhookIntegrationDetails: %v", err)
+	}
+	if stringMapKey(res, "headers")["X-Team"] != "data" || !properties[CgLagAlert] || properties[PoisonMAlert] {
+		t.Fatalf("unexpected details %v %v", res, properties)
+	}
+
+	invalid := []map[string]interface{}{
+		{"url": "ftp://alerts.example.com", "secret_key": "shh"},
+		{"url": "https://alerts.example.com", "secret_key": "shh", "headers": map[string]interface{}{"x-memphis-signature": "forged"}},
+		{"url": "https://alerts.example.com", "secret_key": "shh", "templates": map[string]interface{}{"unknown_alert": "{}"}},
+		{"url": "https://alerts.example.com", "secret_key": "shh", "templates": map[string]interface{}{PoisonMAlert: `{"text": {{.Message}}}`}},
+	}
+	for _, keys := range invalid {
+		if _, _, _, err := getWebhookIntegrationDetails("tenant", keys, nil); err == nil {
+			t.Fatalf("expected %v to be rejected", keys)
+		}
+	}
+}
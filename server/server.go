@@ -2863,6 +2863,7 @@ const (
 	JszPath          = "/jsz"
 	HealthzPath      = "/healthz"
 	IPQueuesPath     = "/ipqueuesz"
+	MetricsPath      = "/metrics" // ** added by Memphis
 )
 
 func (s *Server) basePath(p string) string {
@@ -2977,6 +2978,10 @@ func (s *Server) startMonitoring(secure bool) error {
 	mux.HandleFunc(s.basePath(HealthzPath), s.HandleHealthz)
 	// IPQueuesz
 	mux.HandleFunc(s.basePath(IPQueuesPath), s.HandleIPQueuesz)
+	// ** added by Memphis
+	// Metrics
+	mux.HandleFunc(s.basePath(MetricsPath), s.HandleMetrics)
+	// added by Memphis **
 
 	// Do not set a WriteTimeout because it could cause cURL/browser
 	// to return empty response or unable to display page if the
@@ -4531,6 +4536,10 @@ func (s *Server) initializeMemphis() {
 	if err != nil {
 		s.Errorf("Failed to initialize user cache %v", err.Error())
 	}
+	err = memphis_cache.InitializeLdapCache(configuration.LDAP_CACHE_TTL_SECONDS)
+	if err != nil {
+		s.Errorf("Failed to initialize ldap cache %v", err.Error())
+	}
 	err = s.InitializeEventCounter()
 	if err != nil {
 		s.Errorf("Failed initializing event counter: " + err.Error())
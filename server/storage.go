@@ -20,45 +20,86 @@ import (
 	"strings"
 	"time"
 
-	"github.com/memphisdev/memphis/models"
+	"github.com/memphisdev/memphis/tracing"
 )
 
+// flushMapToTier2Storage uploads the batch of every station to each of the tenant's tiered storage integrations,
+// a station's messages are acked once all of them have it while the stations that failed stay in the map for the next round
 func flushMapToTier2Storage() error {
 	it := IntegrationsHandler{S: serv}
+	var errs []error
 	for t, tenant := range tieredStorageMsgsMap.m {
 		if IsStorageLimitExceeded(t) {
 			serv.Warnf("[tenant:%s]flushMapToTier2Storage: %s", t, ErrUpgradePlan.Error())
 			it.Warnf("s3", t, "Can't upload messages to tiered storage: you've reached your storage limit for this month")
+			ackTieredStorageTenant(t, tenant)
 			continue
 		}
-		if ValidataAccessToFeature(t, "feature-storage-tiering") {
-			for k, f := range StorageFunctionsMap {
-				switch k {
-				case "s3":
-					if tenantIntegrations, ok := IntegrationsConcurrentCache.Load(t); !ok {
-						continue
-					} else {
-						if _, ok = tenantIntegrations["s3"].(models.Integration); ok {
-							err := f.(func(string, map[string][]StoredMsg) error)(t, tenant)
-							if err != nil {
-								return err
-							}
-							it.Noticef(k, t, "Uploaded a batch of messages to S3 successfully")
-						}
-					}
-				default:
+		if !ValidataAccessToFeature(t, "feature-storage-tiering") {
+			serv.Warnf("[tenant: %v]flushMapToTier2Storage: Has no access to feature-storage-tiering in its Plan", t)
+			ackTieredStorageTenant(t, tenant)
+			continue
+		}
+		uploadedTo := map[string]bool{}
+		for streamName, msgs := range tenant {
+			failed := false
+			for _, k := range tenantTieredStorageIntegrations(t) {
+				f, ok := StorageFunctionsMap[k]
+				if !ok {
 					return errors.New("failed uploading to tiered storage : unsupported integration")
 				}
+				span := tracing.Start("tiered storage upload", tracing.SpanKindClient, tracing.SpanContext{})
+				span.SetAttribute("memphis.tenant", t)
+				span.SetAttribute("memphis.station", streamName)
+				span.SetAttribute("memphis.tiered_storage.type", k)
+				span.SetAttribute("memphis.tiered_storage.messages", len(msgs))
+				size, err := f.(func(string, string, []StoredMsg) (int64, error))(t, streamName, msgs)
+				span.SetAttribute("memphis.tiered_storage.bytes", size)
+				span.SetError(err)
+				span.End()
+				if err != nil {
+					recordTieredStorageUploadFailure(t)
+					errs = append(errs, fmt.Errorf("[tenant: %v]station %v: %v", t, streamName, err.Error()))
+					failed = true
+					continue
+				}
+				if size > 0 {
+					recordTieredStorageUpload(t, size)
+					uploadedTo[k] = true
+				}
 			}
-		} else {
-			serv.Warnf("[tenant: %v]flushMapToTier2Storage: Has no access to feature-storage-tiering in its Plan", t)
+			if !failed {
+				ackTieredStorageMsgs(msgs)
+				delete(tenant, streamName)
+			}
+		}
+		for k := range uploadedTo {
+			it.Noticef(k, t, fmt.Sprintf("Uploaded a batch of messages to %v successfully", tieredStorageBackendTypes[k].displayName))
+		}
+		if len(tenant) == 0 {
+			tieredStorageMsgsMap.Delete(t)
 		}
 	}
-	return nil
+	return errors.Join(errs...)
+}
+
+// ackTieredStorageMsgs acks every delivery of the messages, redeliveries of the same sequence included
+func ackTieredStorageMsgs(msgs []StoredMsg) {
+	for _, msg := range msgs {
+		serv.sendInternalAccountMsg(serv.MemphisGlobalAccount(), msg.ReplySubject, []byte(_EMPTY_))
+	}
+}
 
+// ackTieredStorageTenant drops the batches of a tenant that can't upload to tiered storage
+func ackTieredStorageTenant(tenantName string, tenant map[string][]StoredMsg) {
+	for streamName, msgs := range tenant {
+		ackTieredStorageMsgs(msgs)
+		delete(tenant, streamName)
+	}
+	tieredStorageMsgsMap.Delete(tenantName)
 }
 
-func (s *Server) sendToTier2Storage(storageType interface{}, buf []byte, seq uint64, tierStorageType string) error {
+func (s *Server) sendToTier2Storage(storageType interface{}, buf []byte, seq uint64, ts int64, tierStorageType string) error {
 	storedType := reflect.TypeOf(storageType).Elem().Name()
 	var streamName, tenantName string
 	switch storedType {
@@ -77,41 +118,33 @@ func (s *Server) sendToTier2Storage(storageType interface{}, buf []byte, seq uin
 		return nil
 	}
 
-	for k := range StorageFunctionsMap {
-		if ValidataAccessToFeature(tenantName, "feature-storage-tiering") {
-			switch k {
-			case "s3":
-				if tenantIntegrations, ok := IntegrationsConcurrentCache.Load(tenantName); !ok {
-					continue
-				} else {
-					if _, ok = tenantIntegrations["s3"].(models.Integration); ok {
-						msgId := map[string]string{}
-						seqNumber := strconv.Itoa(int(seq))
-						msgId["msg-id"] = streamName + seqNumber
-						if tenantName == _EMPTY_ {
-							tenantName = serv.MemphisGlobalAccountString()
-						}
-						subject := fmt.Sprintf("%s.%s.%s", tieredStorageStream, streamName, tenantName)
-						// TODO: if the stream is not exists save the messages in buffer
-						if TIERED_STORAGE_STREAM_CREATED {
-							tierStorageMsg := TieredStorageMsg{
-								Buf:         buf,
-								StationName: streamName,
-								TenantName:  tenantName,
-							}
+	// the message is queued once no matter how many tiered storage integrations the tenant has,
+	// flushMapToTier2Storage uploads the batch to each of them
+	if !ValidataAccessToFeature(tenantName, "feature-storage-tiering") || len(tenantTieredStorageIntegrations(tenantName)) == 0 {
+		return nil
+	}
+	msgId := map[string]string{}
+	seqNumber := strconv.Itoa(int(seq))
+	msgId["msg-id"] = streamName + seqNumber
+	if tenantName == _EMPTY_ {
+		tenantName = serv.MemphisGlobalAccountString()
+	}
+	subject := fmt.Sprintf("%s.%s.%s", tieredStorageStream, streamName, tenantName)
+	// TODO: if the stream is not exists save the messages in buffer
+	if TIERED_STORAGE_STREAM_CREATED {
+		tierStorageMsg := TieredStorageMsg{
+			Buf:         buf,
+			StationName: streamName,
+			TenantName:  tenantName,
+			Sequence:    seq,
+			Time:        ts,
+		}
 
-							msg, err := json.Marshal(tierStorageMsg)
-							if err != nil {
-								return err
-							}
-							s.sendInternalAccountMsgWithHeadersWithEcho(s.MemphisGlobalAccount(), subject, msg, msgId)
-						}
-					}
-				}
-			default:
-				return errors.New("failed send to tiered storage : unsupported integration")
-			}
+		msg, err := json.Marshal(tierStorageMsg)
+		if err != nil {
+			return err
 		}
+		s.sendInternalAccountMsgWithHeadersWithEcho(s.MemphisGlobalAccount(), subject, msg, msgId)
 	}
 	return nil
 }
@@ -163,6 +196,13 @@ func (s *Server) handleNewTieredStorageMsg(msg []byte, reply string) {
 	dataLen := len(payload) - dataFirstIdx
 	header := payload[:dataFirstIdx]
 	data := payload[dataFirstIdx : dataFirstIdx+dataLen]
+	// messages queued by older brokers don't carry their original sequence and time
+	if tieredStorageMsg.Sequence != 0 {
+		seq = tieredStorageMsg.Sequence
+	}
+	if tieredStorageMsg.Time != 0 {
+		intTs = int(tieredStorageMsg.Time)
+	}
 	message := StoredMsg{
 		Subject:      tieredStorageMsg.StationName,
 		Sequence:     uint64(seq),
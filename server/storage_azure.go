@@ -0,0 +1,237 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const azureStorageApiVersion = "2021-08-06"
+
+// azureBlobBackend talks to the Blob service REST API with Shared Key auth, secret_key holds the account key.
+// url overrides the account endpoint, e.g. http://127.0.0.1:10000/devstoreaccount1 for Azurite.
+type azureBlobBackend struct {
+	endpoint      *url.URL
+	accountName   string
+	accountKey    []byte
+	containerName string
+	client        *http.Client
+}
+
+func newAzureBlobBackend(keys map[string]string) (TieredStorageBackend, error) {
+	accountKey, err := base64.StdEncoding.DecodeString(keys["secret_key"])
+	if err != nil {
+		return nil, errors.New("account key has to be base64 encoded")
+	}
+	endpoint := strings.TrimSuffix(keys["url"], "/")
+	if endpoint == _EMPTY_ {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", keys["account_name"])
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == _EMPTY_ {
+		return nil, fmt.Errorf("invalid url %v", keys["url"])
+	}
+	return &azureBlobBackend{
+		endpoint:      u,
+		accountName:   keys["account_name"],
+		accountKey:    accountKey,
+		containerName: keys["container_name"],
+		client:        &http.Client{Timeout: tieredStorageBackendTimeout},
+	}, nil
+}
+
+func (b *azureBlobBackend) blobUrl(blobName string, query url.Values) *url.URL {
+	u := *b.endpoint
+	u.Path = u.Path + "/" + b.containerName
+	if blobName != _EMPTY_ {
+		u.Path += "/" + blobName
+	}
+	u.RawPath = _EMPTY_
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// sign adds the Shared Key authorization header, see
+// https://learn.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key
+func (b *azureBlobBackend) sign(req *http.Request) {
+	contentLength := _EMPTY_
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	var msHeaders []string
+	for k := range req.Header {
+		if lk := strings.ToLower(k); strings.HasPrefix(lk, "x-ms-") {
+			msHeaders = append(msHeaders, lk)
+		}
+	}
+	sort.Strings(msHeaders)
+	var canonicalizedHeaders strings.Builder
+	for _, k := range msHeaders {
+		canonicalizedHeaders.WriteString(k + ":" + strings.TrimSpace(req.Header.Get(k)) + "\n")
+	}
+
+	canonicalizedResource := "/" + b.accountName + req.URL.EscapedPath()
+	query := req.URL.Query()
+	params := make([]string, 0, len(query))
+	for k := range query {
+		params = append(params, k)
+	}
+	sort.Strings(params)
+	for _, k := range params {
+		values := query[k]
+		sort.Strings(values)
+		canonicalizedResource += "\n" + strings.ToLower(k) + ":" + strings.Join(values, ",")
+	}
+
+	stringToSign := strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		_EMPTY_, // Date, x-ms-date is used instead
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	}, "\n") + "\n" + canonicalizedHeaders.String() + canonicalizedResource
+
+	mac := hmac.New(sha256.New, b.accountKey)
+	mac.Write([]byte(stringToSign))
+	req.Header.Set("Authorization", "SharedKey "+b.accountName+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+func (b *azureBlobBackend) do(ctx context.Context, method string, u *url.URL, body []byte, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureStorageApiVersion)
+	b.sign(req)
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var errResp struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if xml.Unmarshal(respBody, &errResp) == nil && errResp.Code != _EMPTY_ {
+			return nil, fmt.Errorf("%v: %v", resp.Status, errResp.Code)
+		}
+		return nil, fmt.Errorf("%v", resp.Status)
+	}
+	return resp, nil
+}
+
+func (b *azureBlobBackend) UploadObject(ctx context.Context, objectName string, data []byte) error {
+	resp, err := b.do(ctx, http.MethodPut, b.blobUrl(objectName, nil), data, map[string]string{
+		"x-ms-blob-type": "BlockBlob",
+		"Content-Type":   "application/octet-stream",
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (b *azureBlobBackend) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	var objects []string
+	marker := _EMPTY_
+	for {
+		query := url.Values{}
+		query.Set("restype", "container")
+		query.Set("comp", "list")
+		query.Set("prefix", prefix)
+		if marker != _EMPTY_ {
+			query.Set("marker", marker)
+		}
+		resp, err := b.do(ctx, http.MethodGet, b.blobUrl(_EMPTY_, query), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Blobs struct {
+				Blob []struct {
+					Name string `xml:"Name"`
+				} `xml:"Blob"`
+			} `xml:"Blobs"`
+			NextMarker string `xml:"NextMarker"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, blob := range page.Blobs.Blob {
+			objects = append(objects, blob.Name)
+		}
+		if page.NextMarker == _EMPTY_ {
+			return objects, nil
+		}
+		marker = page.NextMarker
+	}
+}
+
+func (b *azureBlobBackend) ReadObject(ctx context.Context, objectName string) ([]byte, error) {
+	resp, err := b.do(ctx, http.MethodGet, b.blobUrl(objectName, nil), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (b *azureBlobBackend) Test(ctx context.Context) error {
+	query := url.Values{}
+	query.Set("restype", "container")
+	resp, err := b.do(ctx, http.MethodGet, b.blobUrl(_EMPTY_, query), nil, nil)
+	if err != nil {
+		return fmt.Errorf("could not access container %v: %v", b.containerName, err.Error())
+	}
+	resp.Body.Close()
+	err = b.UploadObject(ctx, "memphis", []byte("test"))
+	if err != nil {
+		return fmt.Errorf("could not upload objects - %v", err.Error())
+	}
+	resp, err = b.do(ctx, http.MethodDelete, b.blobUrl("memphis", nil), nil, nil)
+	if err != nil {
+		return fmt.Errorf("could not delete objects - %v", err.Error())
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,561 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/models"
+)
+
+const tieredStorageBackendTimeout = 2 * time.Minute
+
+// TieredStorageBackend is implemented by every tier 2 storage integration
+type TieredStorageBackend interface {
+	// UploadObject stores data under objectName, an existing object with the same name is overwritten
+	UploadObject(ctx context.Context, objectName string, data []byte) error
+	ListObjects(ctx context.Context, prefix string) ([]string, error)
+	ReadObject(ctx context.Context, objectName string) ([]byte, error)
+	// Test verifies the destination is reachable and writable with the configured credentials
+	Test(ctx context.Context) error
+}
+
+type tieredStorageBackendType struct {
+	displayName string
+	// keys lists the integration keys of the backend, secret_key is encrypted at rest like the other integrations secrets
+	keys         []string
+	requiredKeys []string
+	build        func(keys map[string]string) (TieredStorageBackend, error)
+}
+
+var tieredStorageBackendTypes = map[string]tieredStorageBackendType{
+	"s3": {
+		displayName:  "S3",
+		keys:         []string{"access_key", "secret_key", "bucket_name", "region", "url", "s3_path_style"},
+		requiredKeys: []string{"access_key", "secret_key", "bucket_name", "region"},
+		build:        newS3Backend,
+	},
+	"filesystem": {
+		displayName:  "filesystem",
+		keys:         []string{"path"},
+		requiredKeys: []string{"path"},
+		build:        newFsBackend,
+	},
+	"gcs": {
+		displayName:  "GCS",
+		keys:         []string{"bucket_name", "secret_key", "url"},
+		requiredKeys: []string{"bucket_name"},
+		build:        newGcsBackend,
+	},
+	"azure_blob": {
+		displayName:  "Azure Blob",
+		keys:         []string{"account_name", "secret_key", "container_name", "url"},
+		requiredKeys: []string{"account_name", "secret_key", "container_name"},
+		build:        newAzureBlobBackend,
+	},
+}
+
+func isTieredStorageIntegration(integrationType string) bool {
+	_, ok := tieredStorageBackendTypes[integrationType]
+	return ok
+}
+
+func integrationKeyString(keys map[string]interface{}, key string) string {
+	value, _ := keys[key].(string)
+	return value
+}
+
+// tenantTieredStorageIntegrations returns the tiered storage integrations the tenant has connected, sorted by name
+func tenantTieredStorageIntegrations(tenantName string) []string {
+	tenantIntegrations, ok := IntegrationsConcurrentCache.Load(tenantName)
+	if !ok {
+		return nil
+	}
+	var res []string
+	for name, integration := range tenantIntegrations {
+		if _, ok := integration.(models.Integration); ok && isTieredStorageIntegration(name) {
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func getTenantTieredStorageBackend(tenantName, integrationType string) (TieredStorageBackend, error) {
+	backendType, ok := tieredStorageBackendTypes[integrationType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported tiered storage integration %v", integrationType)
+	}
+	var integration models.Integration
+	if tenantIntegrations, ok := IntegrationsConcurrentCache.Load(tenantName); !ok {
+		return nil, fmt.Errorf("%v integration does not exist", integrationType)
+	} else if integration, ok = tenantIntegrations[integrationType].(models.Integration); !ok {
+		return nil, fmt.Errorf("%v integration does not exist", integrationType)
+	}
+	keys := make(map[string]string, len(backendType.keys))
+	for _, key := range backendType.keys {
+		keys[key] = integrationKeyString(integration.Keys, key)
+	}
+	return backendType.build(keys)
+}
+
+// resolveTieredStorageIntegration defaults to the first connected tiered storage integration of the tenant
+func resolveTieredStorageIntegration(tenantName, integrationType string) (string, error) {
+	integrations := tenantTieredStorageIntegrations(tenantName)
+	if integrationType == _EMPTY_ {
+		if len(integrations) == 0 {
+			return _EMPTY_, errors.New("there is no connected tiered storage integration")
+		}
+		return integrations[0], nil
+	}
+	for _, integration := range integrations {
+		if integration == integrationType {
+			return integrationType, nil
+		}
+	}
+	return _EMPTY_, fmt.Errorf("tiered storage integration %v is not connected", integrationType)
+}
+
+func tieredStorageObjectPrefix(tenantName, stationName string) string {
+	if tenantName == serv.MemphisGlobalAccountString() {
+		tenantName = "global"
+	}
+	return "memphis/" + tenantName + "/" + stationName + "/"
+}
+
+func buildTieredStorageObject(msgs []StoredMsg) ([]byte, int64, error) {
+	messages := make([]Msg, 0, len(msgs))
+	size := int64(0)
+	for _, msg := range msgs {
+		hdrs := map[string]string{}
+		if len(msg.Header) > 0 {
+			headersSplit := strings.Split(strings.ToLower(string(msg.Header)), CR_LF)
+			for _, header := range headersSplit {
+				if header != _EMPTY_ && !strings.Contains(header, "nats") {
+					key, value, _ := strings.Cut(header, ":")
+					hdrs[strings.TrimSpace(key)] = strings.TrimSpace(value)
+				}
+			}
+		}
+		messages = append(messages, Msg{Payload: hex.EncodeToString(msg.Data), Headers: hdrs})
+		size += int64(len(msg.Data)) + int64(len(msg.Header))
+	}
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(messages)
+	if err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), size, nil
+}
+
+// tieredStorageStationFormat returns the object format of a station and the decoder of its schema for parquet objects
+func tieredStorageStationFormat(tenantName, stationName string) (string, *schemaDecoder) {
+	exist, station, err := db.GetStationByName(stationName, tenantName)
+	if err != nil || !exist || station.TieredStorageFormat == _EMPTY_ {
+		return tieredStorageFormatJson, nil
+	}
+	if station.TieredStorageFormat != tieredStorageFormatParquet {
+		return station.TieredStorageFormat, nil
+	}
+	decoder, err := getStationSchemaDecoder(station)
+	if err != nil {
+		serv.Warnf("[tenant: %v]tieredStorageStationFormat at getStationSchemaDecoder: station %v: %v", tenantName, stationName, err.Error())
+	}
+	return station.TieredStorageFormat, decoder
+}
+
+// pendingTieredMsgs sorts a batch by sequence and drops redeliveries and messages at or below the high water mark
+func pendingTieredMsgs(msgs []StoredMsg, highWaterMark uint64) []StoredMsg {
+	sorted := make([]StoredMsg, len(msgs))
+	copy(sorted, msgs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Sequence < sorted[j].Sequence })
+	pending := make([]StoredMsg, 0, len(sorted))
+	for _, msg := range sorted {
+		if msg.Sequence <= highWaterMark {
+			continue
+		}
+		if len(pending) > 0 && pending[len(pending)-1].Sequence == msg.Sequence {
+			continue
+		}
+		pending = append(pending, msg)
+	}
+	return pending
+}
+
+// splitTieredMsgsByHour cuts a sequence ordered batch into runs of messages produced at the same hour,
+// each run becomes an object covering a contiguous part of the batch
+func splitTieredMsgsByHour(msgs []StoredMsg) [][]StoredMsg {
+	var runs [][]StoredMsg
+	for i, msg := range msgs {
+		if i == 0 || !msg.Time.UTC().Truncate(time.Hour).Equal(msgs[i-1].Time.UTC().Truncate(time.Hour)) {
+			runs = append(runs, nil)
+		}
+		runs[len(runs)-1] = append(runs[len(runs)-1], msg)
+	}
+	return runs
+}
+
+// uploadedTieredRangeEnd returns the last sequence of an object that already starts at the run's first sequence,
+// it is there when a previous upload made it to the bucket but the broker went down before checkpointing it
+func uploadedTieredRangeEnd(backend TieredStorageBackend, rangePrefix string) (uint64, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tieredStorageBackendTimeout)
+	defer cancel()
+	names, err := backend.ListObjects(ctx, rangePrefix)
+	if err != nil {
+		return 0, false, err
+	}
+	found := false
+	end := uint64(0)
+	for _, name := range names {
+		if _, _, lastSeq, ok := parseTieredStorageObjectRange(name); ok && lastSeq > end {
+			end = lastSeq
+			found = true
+		}
+	}
+	return end, found, nil
+}
+
+// tieredStorageUploader returns the StorageFunctionsMap entry of a tiered storage integration,
+// it uploads the batch of a single stream and returns the size of the messages that were uploaded
+func (s *Server) tieredStorageUploader(integrationType string) func(string, string, []StoredMsg) (int64, error) {
+	return func(tenantName, streamName string, msgs []StoredMsg) (int64, error) {
+		displayName := tieredStorageBackendTypes[integrationType].displayName
+		backend, err := getTenantTieredStorageBackend(tenantName, integrationType)
+		if err != nil {
+			return 0, fmt.Errorf("failed uploading to %v: %v", displayName, err.Error())
+		}
+		highWaterMark, err := db.GetTieredStorageCheckpoint(tenantName, integrationType, streamName)
+		if err != nil {
+			return 0, fmt.Errorf("failed getting the %v checkpoint: %v", displayName, err.Error())
+		}
+		stationName, partitionStr := stationPartitionFromStream(streamName)
+		partition, _ := strconv.Atoi(partitionStr)
+		format, decoder := tieredStorageStationFormat(tenantName, stationName)
+		uploadedSize := int64(0)
+		for _, run := range splitTieredMsgsByHour(pendingTieredMsgs(msgs, highWaterMark)) {
+			hour := run[0].Time.UTC().Truncate(time.Hour)
+			for len(run) > 0 {
+				rangePrefix := tieredStorageObjectRangePrefix(tenantName, stationName, hour, partition, run[0].Sequence)
+				end, found, err := uploadedTieredRangeEnd(backend, rangePrefix)
+				if err != nil {
+					return uploadedSize, fmt.Errorf("failed listing objects of %v: %v", displayName, err.Error())
+				}
+				if !found {
+					break
+				}
+				i := 0
+				for i < len(run) && run[i].Sequence <= end {
+					i++
+				}
+				err = db.AdvanceTieredStorageCheckpoint(tenantName, integrationType, streamName, run[0].Sequence, run[i-1].Sequence)
+				if err != nil {
+					return uploadedSize, fmt.Errorf("failed updating the %v checkpoint: %v", displayName, err.Error())
+				}
+				run = run[i:]
+			}
+			if len(run) == 0 {
+				continue
+			}
+
+			var data []byte
+			size := int64(0)
+			if format == tieredStorageFormatJson {
+				data, size, err = buildTieredStorageObject(run)
+			} else {
+				for _, msg := range run {
+					size += int64(len(msg.Data)) + int64(len(msg.Header))
+				}
+				data, err = encodeTieredRecords(format, tieredRecordsFromMsgs(stationName, partition, run, decoder), decoder.decodedFields())
+			}
+			if err != nil {
+				return uploadedSize, err
+			}
+			firstSeq, lastSeq := run[0].Sequence, run[len(run)-1].Sequence
+			objectName := tieredStorageObjectKey(tenantName, stationName, hour, partition, firstSeq, lastSeq, format)
+			ctx, cancel := context.WithTimeout(context.Background(), tieredStorageBackendTimeout)
+			err = backend.UploadObject(ctx, objectName, data)
+			cancel()
+			if err != nil {
+				return uploadedSize, fmt.Errorf("failed uploading object to %v: %v", displayName, err.Error())
+			}
+			uploadedSize += size
+			IncrementEventCounter(tenantName, "tiered", size, int64(len(run)), _EMPTY_, []byte{}, []byte{})
+			s.Noticef("[tenant: %v]new file has been uploaded to %v: %s", tenantName, displayName, objectName)
+			err = db.AdvanceTieredStorageCheckpoint(tenantName, integrationType, streamName, firstSeq, lastSeq)
+			if err != nil {
+				return uploadedSize, fmt.Errorf("failed updating the %v checkpoint: %v", displayName, err.Error())
+			}
+		}
+		return uploadedSize, nil
+	}
+}
+
+// reconcileTieredStorage walks the sequence ranges of a station's objects per partition and reports the
+// sequences missing from the bucket and the ones that were uploaded more than once
+func reconcileTieredStorage(backend TieredStorageBackend, tenantName, stationName string) (models.TieredStorageReconciliation, error) {
+	var report models.TieredStorageReconciliation
+	ctx, cancel := context.WithTimeout(context.Background(), tieredStorageBackendTimeout)
+	defer cancel()
+	names, err := backend.ListObjects(ctx, tieredStorageObjectPrefix(tenantName, stationName))
+	if err != nil {
+		return report, err
+	}
+	report.Gaps = []models.TieredStorageSeqRange{}
+	report.Duplicates = []models.TieredStorageSeqRange{}
+	ranges := map[int][]models.TieredStorageSeqRange{}
+	for _, name := range names {
+		report.Objects++
+		partition, firstSeq, lastSeq, ok := parseTieredStorageObjectRange(name)
+		if !ok {
+			report.LegacyObjects++
+			continue
+		}
+		ranges[partition] = append(ranges[partition], models.TieredStorageSeqRange{Partition: partition, From: firstSeq, To: lastSeq})
+	}
+	partitions := make([]int, 0, len(ranges))
+	for partition := range ranges {
+		partitions = append(partitions, partition)
+	}
+	sort.Ints(partitions)
+	for _, partition := range partitions {
+		partitionRanges := ranges[partition]
+		sort.Slice(partitionRanges, func(i, j int) bool {
+			if partitionRanges[i].From == partitionRanges[j].From {
+				return partitionRanges[i].To < partitionRanges[j].To
+			}
+			return partitionRanges[i].From < partitionRanges[j].From
+		})
+		covered := partitionRanges[0].To
+		for _, r := range partitionRanges[1:] {
+			if r.From > covered+1 {
+				report.Gaps = append(report.Gaps, models.TieredStorageSeqRange{Partition: partition, From: covered + 1, To: r.From - 1})
+			} else if r.From <= covered {
+				to := r.To
+				if covered < to {
+					to = covered
+				}
+				report.Duplicates = append(report.Duplicates, models.TieredStorageSeqRange{Partition: partition, From: r.From, To: to})
+			}
+			if r.To > covered {
+				covered = r.To
+			}
+		}
+	}
+	return report, nil
+}
+
+func cacheDetailsTieredStorage(integrationType string, keys map[string]interface{}, properties map[string]bool, tenantName string) {
+	if keys == nil {
+		deleteIntegrationFromTenant(tenantName, integrationType, IntegrationsConcurrentCache)
+		return
+	}
+	integration := models.Integration{
+		Name:       integrationType,
+		Keys:       make(map[string]interface{}),
+		Properties: make(map[string]bool),
+	}
+	for _, key := range tieredStorageBackendTypes[integrationType].keys {
+		integration.Keys[key] = integrationKeyString(keys, key)
+	}
+	if _, ok := IntegrationsConcurrentCache.Load(tenantName); !ok {
+		IntegrationsConcurrentCache.Add(tenantName, map[string]interface{}{integrationType: integration})
+	} else {
+		err := addIntegrationToTenant(tenantName, integrationType, IntegrationsConcurrentCache, integration)
+		if err != nil {
+			serv.Errorf("cacheDetailsTieredStorage: %s ", err.Error())
+			return
+		}
+	}
+}
+
+// handleTieredStorageIntegration validates the keys of a non S3 tiered storage integration and tests the destination,
+// an empty secret_key keeps the one already stored
+func (it IntegrationsHandler) handleTieredStorageIntegration(tenantName, integrationType string, bodyKeys map[string]interface{}) (int, map[string]interface{}, error) {
+	backendType := tieredStorageBackendTypes[integrationType]
+	keys := make(map[string]interface{}, len(backendType.keys))
+	for _, key := range backendType.keys {
+		keys[key] = strings.TrimSpace(integrationKeyString(bodyKeys, key))
+	}
+
+	if _, ok := keys["secret_key"]; ok && keys["secret_key"] == _EMPTY_ {
+		exist, integrationFromDb, err := db.GetIntegration(integrationType, tenantName)
+		if err != nil {
+			return 500, map[string]interface{}{}, err
+		}
+		if exist {
+			if value, ok := integrationFromDb.Keys["secret_key"].(string); ok && value != _EMPTY_ {
+				decryptedValue, err := DecryptAES(getAESKey(), value)
+				if err != nil {
+					return 500, map[string]interface{}{}, err
+				}
+				keys["secret_key"] = decryptedValue
+			}
+		}
+	}
+
+	for _, key := range backendType.requiredKeys {
+		if keys[key] == _EMPTY_ {
+			return SHOWABLE_ERROR_STATUS_CODE, map[string]interface{}{}, fmt.Errorf("%v is required", key)
+		}
+	}
+
+	stringKeys := make(map[string]string, len(keys))
+	for k, v := range keys {
+		stringKeys[k] = v.(string)
+	}
+	backend, err := backendType.build(stringKeys)
+	if err != nil {
+		return SHOWABLE_ERROR_STATUS_CODE, map[string]interface{}{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), tieredStorageBackendTimeout)
+	defer cancel()
+	err = backend.Test(ctx)
+	if err != nil {
+		return SHOWABLE_ERROR_STATUS_CODE, map[string]interface{}{}, err
+	}
+	return 200, keys, nil
+}
+
+func (it IntegrationsHandler) handleCreateTieredStorageIntegration(tenantName, integrationType string, bodyKeys map[string]interface{}) (models.Integration, int, error) {
+	statusCode, keys, err := it.handleTieredStorageIntegration(tenantName, integrationType, bodyKeys)
+	if err != nil {
+		return models.Integration{}, statusCode, err
+	}
+	exist, _, err := db.GetIntegration(integrationType, tenantName)
+	if err != nil {
+		return models.Integration{}, 500, err
+	}
+	if exist {
+		return models.Integration{}, SHOWABLE_ERROR_STATUS_CODE, fmt.Errorf("%v integration already exists", integrationType)
+	}
+	encryptedKeys, err := encryptIntegrationSecretKey(keys)
+	if err != nil {
+		return models.Integration{}, 500, err
+	}
+	integration, err := db.InsertNewIntegration(tenantName, integrationType, encryptedKeys, map[string]bool{})
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			return models.Integration{}, SHOWABLE_ERROR_STATUS_CODE, err
+		}
+		return models.Integration{}, 500, err
+	}
+	err = broadcastTieredStorageIntegration(tenantName, integrationType, keys, integration.IsValid)
+	if err != nil {
+		return models.Integration{}, 500, err
+	}
+	integration.Keys = hideIntegrationKeysSecret(keys)
+	return integration, statusCode, nil
+}
+
+func (it IntegrationsHandler) handleUpdateTieredStorageIntegration(tenantName, integrationType string, body models.CreateIntegrationSchema) (models.Integration, int, error) {
+	statusCode, keys, err := it.handleTieredStorageIntegration(tenantName, integrationType, body.Keys)
+	if err != nil {
+		return models.Integration{}, statusCode, err
+	}
+	encryptedKeys, err := encryptIntegrationSecretKey(keys)
+	if err != nil {
+		return models.Integration{}, 500, err
+	}
+	integration, err := db.UpdateIntegration(tenantName, integrationType, encryptedKeys, map[string]bool{})
+	if err != nil {
+		return models.Integration{}, 500, err
+	}
+	err = broadcastTieredStorageIntegration(tenantName, integrationType, keys, integration.IsValid)
+	if err != nil {
+		return models.Integration{}, 500, err
+	}
+	integration.Keys = hideIntegrationKeysSecret(keys)
+	integration.Properties = map[string]bool{}
+	return integration, statusCode, nil
+}
+
+func encryptIntegrationSecretKey(keys map[string]interface{}) (map[string]interface{}, error) {
+	res := make(map[string]interface{}, len(keys))
+	for k, v := range keys {
+		res[k] = v
+	}
+	if secret, ok := keys["secret_key"].(string); ok {
+		// empty secrets are not stored, the connections loader decrypts every stored secret_key
+		if secret == _EMPTY_ {
+			delete(res, "secret_key")
+			return res, nil
+		}
+		encryptedValue, err := EncryptAES([]byte(secret))
+		if err != nil {
+			return nil, err
+		}
+		res["secret_key"] = encryptedValue
+	}
+	return res, nil
+}
+
+func hideIntegrationKeysSecret(keys map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(keys))
+	for k, v := range keys {
+		res[k] = v
+	}
+	if secret, ok := keys["secret_key"].(string); ok && len(secret) >= 4 {
+		res["secret_key"] = hideIntegrationSecretKey(secret)
+	}
+	return res
+}
+
+func broadcastTieredStorageIntegration(tenantName, integrationType string, keys map[string]interface{}, isValid bool) error {
+	integrationToUpdate := models.CreateIntegration{
+		Name:       integrationType,
+		Keys:       keys,
+		Properties: map[string]bool{},
+		TenantName: tenantName,
+		IsValid:    isValid,
+	}
+	msg, err := json.Marshal(integrationToUpdate)
+	if err != nil {
+		return err
+	}
+	return serv.sendInternalAccountMsgWithReply(serv.MemphisGlobalAccount(), INTEGRATIONS_UPDATES_SUBJ, _EMPTY_, nil, msg, true)
+}
+
+// testTieredStorageIntegration is used by the periodic broken integrations check
+func testTieredStorageIntegration(integration models.Integration) error {
+	backendType, ok := tieredStorageBackendTypes[integration.Name]
+	if !ok {
+		return errors.New("unsupported tiered storage integration")
+	}
+	keys := make(map[string]string, len(backendType.keys))
+	for _, key := range backendType.keys {
+		keys[key] = integrationKeyString(integration.Keys, key)
+	}
+	if keys["secret_key"] != _EMPTY_ {
+		decryptedValue, err := DecryptAES(getAESKey(), keys["secret_key"])
+		if err != nil {
+			return err
+		}
+		keys["secret_key"] = decryptedValue
+	}
+	backend, err := backendType.build(keys)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), tieredStorageBackendTimeout)
+	defer cancel()
+	return backend.Test(ctx)
+}
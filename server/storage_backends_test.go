@@ -0,0 +1,311 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/memphisdev/memphis/models"
+)
+
+func testTieredStorageBackendRoundTrip(t *testing.T, backend TieredStorageBackend) {
+	t.Helper()
+	ctx := context.Background()
+	if err := backend.Test(ctx); err != nil {
+		t.Fatalf("Test: %v", err)
+	}
+	objects := map[string]string{
+		"memphis/global/orders/a(1).json":   "first",
+		"memphis/global/orders/b(2).json":   "second",
+		"memphis/global/payments/c(1).json": "third",
+	}
+	for name, data := range objects {
+		if err := backend.UploadObject(ctx, name, []byte(data)); err != nil {
+			t.Fatalf("UploadObject %v: %v", name, err)
+		}
+	}
+	listed, err := backend.ListObjects(ctx, "memphis/global/orders/")
+	if err != nil {
+		t.Fatalf("ListObjects: %v", err)
+	}
+	sort.Strings(listed)
+	expected := []string{"memphis/global/orders/a(1).json", "memphis/global/orders/b(2).json"}
+	if !reflect.DeepEqual(listed, expected) {
+		t.Fatalf("expected %v, got %v", expected, listed)
+	}
+	data, err := backend.ReadObject(ctx, "memphis/global/payments/c(1).json")
+	if err != nil {
+		t.Fatalf("ReadObject: %v", err)
+	}
+	if string(data) != "third" {
+		t.Fatalf("unexpected object content %q", data)
+	}
+}
+
+// newTestFsBackend allows a temp directory in the broker configuration and opens a backend under it
+func newTestFsBackend(t *testing.T) TieredStorageBackend {
+	t.Helper()
+	dir := t.TempDir()
+	previous := configuration.TIERED_STORAGE_FS_ROOTS
+	configuration.TIERED_STORAGE_FS_ROOTS = dir
+	t.Cleanup(func() { configuration.TIERED_STORAGE_FS_ROOTS = previous })
+	backend, err := newFsBackend(map[string]string{"path": filepath.Join(dir, "tenant")})
+	if err != nil {
+		t.Fatalf("newFsBackend: %v", err)
+	}
+	return backend
+}
+
+func TestFsBackend(t *testing.T) {
+	backend := newTestFsBackend(t)
+	testTieredStorageBackendRoundTrip(t, backend)
+	if _, err := backend.ReadObject(context.Background(), "../outside"); err == nil {
+		t.Fatalf("expected object names escaping the root to be rejected")
+	}
+	if _, err := newFsBackend(map[string]string{"path": "relative/dir"}); err == nil {
+		t.Fatalf("expected a relative path to be rejected")
+	}
+	if _, err := newFsBackend(map[string]string{"path": "/etc"}); err == nil {
+		t.Fatalf("expected a path outside the allowed roots to be rejected")
+	}
+}
+
+func TestIsFsRootAllowed(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "tiered")
+	if err := os.Mkdir(allowed, 0755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if err := os.Symlink(dir, filepath.Join(allowed, "escape")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	cases := []struct {
+		root, allowedRoots string
+		allowed            bool
+	}{
+		{allowed, allowed, true},
+		{filepath.Join(allowed, "tenant", "a"), "/nowhere, " + allowed, true},
+		{filepath.Join(dir, "tiered-other"), allowed, false},
+		{dir, allowed, false},
+		{filepath.Join(allowed, "escape", "secrets"), allowed, false},
+		{allowed, _EMPTY_, false},
+		{allowed, "tiered", false},
+	}
+	for _, tc := range cases {
+		if got := isFsRootAllowed(tc.root, tc.allowedRoots); got != tc.allowed {
+			t.Errorf("root %v with allowed roots %q: expected %v, got %v", tc.root, tc.allowedRoots, tc.allowed, got)
+		}
+	}
+}
+
+// fakeObjectStore keeps objects in memory for the emulator stand-ins below
+type fakeObjectStore struct {
+	sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeObjectStore) list(prefix string) []string {
+	f.Lock()
+	defer f.Unlock()
+	var names []string
+	for name := range f.objects {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestGcsBackendAgainstEmulator(t *testing.T) {
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	// mimics the subset of the JSON API fake-gcs-server serves
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const objectsPath = "/storage/v1/b/bucket/o/"
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/bucket":
+			json.NewEncoder(w).Encode(map[string]string{"name": "bucket"})
+		case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/bucket/o":
+			data, _ := io.ReadAll(r.Body)
+			store.Lock()
+			store.objects[r.URL.Query().Get("name")] = data
+			store.Unlock()
+			json.NewEncoder(w).Encode(map[string]string{"name": r.URL.Query().Get("name")})
+		case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/bucket/o":
+			var items []map[string]string
+			for _, name := range store.list(r.URL.Query().Get("prefix")) {
+				items = append(items, map[string]string{"name": name})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+		case strings.HasPrefix(r.URL.Path, objectsPath):
+			name := strings.TrimPrefix(r.URL.Path, objectsPath)
+			store.Lock()
+			defer store.Unlock()
+			data, ok := store.objects[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Method == http.MethodDelete {
+				delete(store.objects, name)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.Write(data)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	backend, err := newGcsBackend(map[string]string{"bucket_name": "bucket", "url": srv.URL})
+	if err != nil {
+		t.Fatalf("newGcsBackend: %v", err)
+	}
+	testTieredStorageBackendRoundTrip(t, backend)
+	if _, err := newGcsBackend(map[string]string{"bucket_name": "bucket"}); err == nil {
+		t.Fatalf("expected a service account key to be required without a custom url")
+	}
+}
+
+func TestAzureBlobBackendAgainstEmulator(t *testing.T) {
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	accountKey := base64.StdEncoding.EncodeToString([]byte("azurite-account-key"))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey devstoreaccount1:") || r.Header.Get("x-ms-date") == _EMPTY_ {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		const containerPath = "/devstoreaccount1/container"
+		query := r.URL.Query()
+		switch {
+		case r.URL.Path == containerPath && query.Get("comp") == "list":
+			fmt.Fprint(w, "<EnumerationResults><Blobs>")
+			for _, name := range store.list(query.Get("prefix")) {
+				fmt.Fprintf(w, "<Blob><Name>%s</Name></Blob>", name)
+			}
+			fmt.Fprint(w, "</Blobs><NextMarker/></EnumerationResults>")
+		case r.URL.Path == containerPath:
+			w.WriteHeader(http.StatusOK)
+		case strings.HasPrefix(r.URL.Path, containerPath+"/"):
+			name := strings.TrimPrefix(r.URL.Path, containerPath+"/")
+			store.Lock()
+			defer store.Unlock()
+			switch r.Method {
+			case http.MethodPut:
+				if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				store.objects[name], _ = io.ReadAll(r.Body)
+				w.WriteHeader(http.StatusCreated)
+			case http.MethodDelete:
+				delete(store.objects, name)
+				w.WriteHeader(http.StatusAccepted)
+			default:
+				data, ok := store.objects[name]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					fmt.Fprint(w, "<Error><Code>BlobNotFound</Code></Error>")
+					return
+				}
+				w.Write(data)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	backend, err := newAzureBlobBackend(map[string]string{
+		"account_name":   "devstoreaccount1",
+		"secret_key":     accountKey,
+		"container_name": "container",
+		"url":            srv.URL + "/devstoreaccount1",
+	})
+	if err != nil {
+		t.Fatalf("newAzureBlobBackend: %v", err)
+	}
+	testTieredStorageBackendRoundTrip(t, backend)
+	if _, err := backend.ReadObject(context.Background(), "missing"); err == nil || !strings.Contains(err.Error(), "BlobNotFound") {
+		t.Fatalf("expected a BlobNotFound error, got %v", err)
+	}
+}
+
+func TestPendingTieredMsgs(t *testing.T) {
+	ts := time.Date(2026, 10, 17, 13, 59, 0, 0, time.UTC)
+	msgs := []StoredMsg{
+		{Sequence: 5, Time: ts},
+		{Sequence: 3, Time: ts},
+		{Sequence: 4, Time: ts},
+		{Sequence: 5, Time: ts, ReplySubject: "redelivery"},
+		{Sequence: 6, Time: ts.Add(2 * time.Minute)},
+		{Sequence: 2, Time: ts},
+	}
+	pending := pendingTieredMsgs(msgs, 2)
+	var seqs []uint64
+	for _, msg := range pending {
+		seqs = append(seqs, msg.Sequence)
+	}
+	if !reflect.DeepEqual(seqs, []uint64{3, 4, 5, 6}) {
+		t.Fatalf("unexpected pending sequences %v", seqs)
+	}
+	runs := splitTieredMsgsByHour(pending)
+	if len(runs) != 2 || len(runs[0]) != 3 || runs[1][0].Sequence != 6 {
+		t.Fatalf("unexpected runs %+v", runs)
+	}
+}
+
+func TestReconcileTieredStorage(t *testing.T) {
+	backend := newTestFsBackend(t)
+	hour := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	uploads := []struct {
+		partition         int
+		firstSeq, lastSeq uint64
+	}{
+		{1, 1, 10}, {1, 11, 20}, {1, 15, 25}, {1, 31, 40}, {2, 1, 5},
+	}
+	ctx := context.Background()
+	for _, u := range uploads {
+		name := tieredStorageObjectKey("tenant", "orders", hour, u.partition, u.firstSeq, u.lastSeq, tieredStorageFormatNdjsonGzip)
+		if err := backend.UploadObject(ctx, name, []byte("{}")); err != nil {
+			t.Fatalf("UploadObject: %v", err)
+		}
+	}
+	if err := backend.UploadObject(ctx, "memphis/tenant/orders/uid(3).json", []byte("[]")); err != nil {
+		t.Fatalf("UploadObject: %v", err)
+	}
+
+	report, err := reconcileTieredStorage(backend, "tenant", "orders")
+	if err != nil {
+		t.Fatalf("reconcileTieredStorage: %v", err)
+	}
+	if report.Objects != 6 || report.LegacyObjects != 1 {
+		t.Fatalf("unexpected object counts %+v", report)
+	}
+	expectedGaps := []models.TieredStorageSeqRange{{Partition: 1, From: 26, To: 30}}
+	expectedDuplicates := []models.TieredStorageSeqRange{{Partition: 1, From: 15, To: 20}}
+	if !reflect.DeepEqual(report.Gaps, expectedGaps) || !reflect.DeepEqual(report.Duplicates, expectedDuplicates) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	end, found, err := uploadedTieredRangeEnd(backend, tieredStorageObjectRangePrefix("tenant", "orders", hour, 1, 11))
+	if err != nil || !found || end != 20 {
+		t.Fatalf("unexpected uploaded range end %v %v %v", end, found, err)
+	}
+	if _, found, _ := uploadedTieredRangeEnd(backend, tieredStorageObjectRangePrefix("tenant", "orders", hour, 1, 12)); found {
+		t.Fatalf("expected no object to start at 12")
+	}
+}
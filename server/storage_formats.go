@@ -0,0 +1,381 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/memphisdev/memphis/db"
+	"github.com/memphisdev/memphis/models"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/hamba/avro/v2"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	tieredStorageFormatJson       = "json"
+	tieredStorageFormatNdjsonGzip = "ndjson_gzip"
+	tieredStorageFormatNdjsonZstd = "ndjson_zstd"
+	tieredStorageFormatParquet    = "parquet"
+)
+
+var tieredStorageFormatExtensions = map[string]string{
+	tieredStorageFormatJson:       ".json",
+	tieredStorageFormatNdjsonGzip: ".ndjson.gz",
+	tieredStorageFormatNdjsonZstd: ".ndjson.zst",
+	tieredStorageFormatParquet:    ".parquet",
+}
+
+func validateTieredStorageFormat(format string) error {
+	if _, ok := tieredStorageFormatExtensions[format]; !ok {
+		return errors.New("tiered storage format has to be one of json, ndjson_gzip, ndjson_zstd or parquet")
+	}
+	return nil
+}
+
+// tieredRecord is a single message as it is written to tier 2 storage
+type tieredRecord struct {
+	Station   string            `json:"station"`
+	Partition int               `json:"partition"`
+	Sequence  uint64            `json:"sequence"`
+	Timestamp time.Time         `json:"timestamp"`
+	Headers   map[string]string `json:"headers"`
+	Payload   []byte            `json:"payload"`
+	Decoded   json.RawMessage   `json:"decoded,omitempty"`
+}
+
+// tieredStorageObjectKey places objects under hive style dt/hour partitions so query engines can prune by time,
+// the name is derived from the partition and the sequence range so uploading the same batch twice overwrites it
+func tieredStorageObjectKey(tenantName, stationName string, hour time.Time, partition int, firstSeq, lastSeq uint64, format string) string {
+	return tieredStorageObjectRangePrefix(tenantName, stationName, hour, partition, firstSeq) + fmt.Sprintf("%020d%s", lastSeq, tieredStorageFormatExtensions[format])
+}
+
+// tieredStorageObjectRangePrefix is the key prefix shared by all the objects of an hour that start at firstSeq
+func tieredStorageObjectRangePrefix(tenantName, stationName string, hour time.Time, partition int, firstSeq uint64) string {
+	hour = hour.UTC()
+	return fmt.Sprintf("%sdt=%s/hour=%02d/%d-%020d-", tieredStorageObjectPrefix(tenantName, stationName), hour.Format("2006-01-02"), hour.Hour(), partition, firstSeq)
+}
+
+// parseTieredStorageObjectRange returns the partition and sequence range of an object, objects uploaded before
+// the names were derived from the sequences don't have them
+func parseTieredStorageObjectRange(objectName string) (int, uint64, uint64, bool) {
+	name := objectName[strings.LastIndex(objectName, "/")+1:]
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	parts := strings.Split(name, "-")
+	if len(parts) != 3 {
+		return 0, 0, 0, false
+	}
+	partition, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	firstSeq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	lastSeq, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil || lastSeq < firstSeq {
+		return 0, 0, 0, false
+	}
+	return partition, firstSeq, lastSeq, true
+}
+
+func tieredRecordsFromMsgs(stationName string, partition int, msgs []StoredMsg, decoder *schemaDecoder) []tieredRecord {
+	records := make([]tieredRecord, 0, len(msgs))
+	for _, msg := range msgs {
+		record := tieredRecord{
+			Station:   stationName,
+			Partition: partition,
+			Sequence:  msg.Sequence,
+			Timestamp: msg.Time.UTC(),
+			Headers:   map[string]string{},
+			Payload:   msg.Data,
+		}
+		if len(msg.Header) > 0 {
+			headers, err := DecodeHeader(msg.Header)
+			if err == nil {
+				record.Headers = headers
+			}
+		}
+		if decoder != nil {
+			// messages that don't match the schema are archived without the decoded fields
+			if decoded, err := decoder.decode(msg.Data); err == nil {
+				record.Decoded = decoded
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func encodeTieredRecords(format string, records []tieredRecord, fields []decodedField) ([]byte, error) {
+	switch format {
+	case tieredStorageFormatNdjsonGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		err := writeNdjson(zw, records)
+		if err != nil {
+			return nil, err
+		}
+		err = zw.Close()
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case tieredStorageFormatNdjsonZstd:
+		var buf bytes.Buffer
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		err = writeNdjson(zw, records)
+		if err != nil {
+			zw.Close()
+			return nil, err
+		}
+		err = zw.Close()
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case tieredStorageFormatParquet:
+		return encodeTieredRecordsParquet(records, fields, zstdCompress)
+	default:
+		return nil, fmt.Errorf("unsupported tiered storage format %v", format)
+	}
+}
+
+// encoding/json writes []byte fields as base64
+func writeNdjson(w interface{ Write([]byte) (int, error) }, records []tieredRecord) error {
+	enc := json.NewEncoder(w)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var zstdEncoder, _ = zstd.NewWriter(nil)
+
+func zstdCompress(data []byte) ([]byte, error) {
+	return zstdEncoder.EncodeAll(data, nil), nil
+}
+
+// kinds of the decoded fields columns
+const (
+	decodedFieldString = iota
+	decodedFieldInt64
+	decodedFieldDouble
+	decodedFieldBool
+	// nested, repeated and unknown fields are kept as json documents
+	decodedFieldJson
+)
+
+type decodedField struct {
+	name string
+	kind int
+}
+
+// schemaDecoder turns a payload into a json document of its fields according to the station's schema,
+// fields are the top level fields of the schema which parquet objects write as typed columns
+type schemaDecoder struct {
+	decode func(data []byte) (json.RawMessage, error)
+	fields []decodedField
+}
+
+func (d *schemaDecoder) decodedFields() []decodedField {
+	if d == nil {
+		return nil
+	}
+	return d.fields
+}
+
+var schemaDecodersCache = NewConcurrentMap[*schemaDecoder]()
+
+// decodes json documents, avro payloads are produced as json documents as well
+func decodeJsonPayload(data []byte) (json.RawMessage, error) {
+	if !json.Valid(data) {
+		return nil, errors.New("invalid json")
+	}
+	return json.RawMessage(data), nil
+}
+
+func compileSchemaDecoder(schemaType, schemaContent, messageStructName string, references map[string]string) (*schemaDecoder, error) {
+	switch schemaType {
+	case "protobuf":
+		md, err := parseProtoMessage(schemaContent, messageStructName, references)
+		if err != nil {
+			return nil, err
+		}
+		// zero values are written too, otherwise they could not be told apart from missing fields
+		marshaler := &jsonpb.Marshaler{EmitDefaults: true}
+		decode := func(data []byte) (json.RawMessage, error) {
+			msg := dynamic.NewMessage(md)
+			if err := msg.Unmarshal(data); err != nil {
+				return nil, err
+			}
+			return msg.MarshalJSONPB(marshaler)
+		}
+		return &schemaDecoder{decode: decode, fields: protoDecodedFields(md)}, nil
+	case "json":
+		return &schemaDecoder{decode: decodeJsonPayload, fields: jsonSchemaDecodedFields(schemaContent)}, nil
+	case "avro":
+		sch, err := avro.Parse(schemaContent)
+		if err != nil {
+			return nil, err
+		}
+		return &schemaDecoder{decode: decodeJsonPayload, fields: avroDecodedFields(sch)}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func protoDecodedFields(md *desc.MessageDescriptor) []decodedField {
+	fields := []decodedField{}
+	for _, f := range md.GetFields() {
+		kind := decodedFieldJson
+		if !f.IsRepeated() && !f.IsMap() {
+			switch f.GetType().String() {
+			case "TYPE_INT32", "TYPE_SINT32", "TYPE_SFIXED32", "TYPE_UINT32", "TYPE_FIXED32", "TYPE_INT64", "TYPE_SINT64", "TYPE_SFIXED64":
+				kind = decodedFieldInt64
+			case "TYPE_FLOAT", "TYPE_DOUBLE":
+				kind = decodedFieldDouble
+			case "TYPE_BOOL":
+				kind = decodedFieldBool
+			// uint64 values may not fit an int64 column
+			case "TYPE_STRING", "TYPE_BYTES", "TYPE_ENUM", "TYPE_UINT64", "TYPE_FIXED64":
+				kind = decodedFieldString
+			}
+		}
+		fields = append(fields, decodedField{name: f.GetJSONName(), kind: kind})
+	}
+	return fields
+}
+
+func avroDecodedFields(sch avro.Schema) []decodedField {
+	fields := []decodedField{}
+	record, ok := derefAvro(sch).(*avro.RecordSchema)
+	if !ok {
+		return fields
+	}
+	for _, f := range record.Fields() {
+		typ := derefAvro(f.Type())
+		// an optional field is a union of null and its type
+		if union, ok := typ.(*avro.UnionSchema); ok && union.Nullable() && len(union.Types()) == 2 {
+			for _, t := range union.Types() {
+				if t.Type() != avro.Null {
+					typ = derefAvro(t)
+				}
+			}
+		}
+		kind := decodedFieldJson
+		switch typ.Type() {
+		case avro.Int, avro.Long:
+			kind = decodedFieldInt64
+		case avro.Float, avro.Double:
+			kind = decodedFieldDouble
+		case avro.Boolean:
+			kind = decodedFieldBool
+		case avro.String, avro.Enum:
+			kind = decodedFieldString
+		}
+		fields = append(fields, decodedField{name: f.Name(), kind: kind})
+	}
+	return fields
+}
+
+// jsonSchemaDecodedFields maps the top level properties of a json schema, sorted by name
+func jsonSchemaDecodedFields(schemaContent string) []decodedField {
+	fields := []decodedField{}
+	var sch struct {
+		Properties map[string]struct {
+			Type interface{} `json:"type"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(schemaContent), &sch); err != nil {
+		return fields
+	}
+	for name, property := range sch.Properties {
+		types := []string{}
+		switch t := property.Type.(type) {
+		case string:
+			types = append(types, t)
+		case []interface{}:
+			for _, v := range t {
+				if typeName, ok := v.(string); ok && typeName != "null" {
+					types = append(types, typeName)
+				}
+			}
+		}
+		kind := decodedFieldJson
+		if len(types) == 1 {
+			switch types[0] {
+			case "integer":
+				kind = decodedFieldInt64
+			case "number":
+				kind = decodedFieldDouble
+			case "boolean":
+				kind = decodedFieldBool
+			case "string":
+				kind = decodedFieldString
+			}
+		}
+		fields = append(fields, decodedField{name: name, kind: kind})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].name < fields[j].name
+	})
+	return fields
+}
+
+// getStationSchemaDecoder returns nil when the station has no schema attached or its type can't be decoded
+func getStationSchemaDecoder(station models.Station) (*schemaDecoder, error) {
+	if station.SchemaName == _EMPTY_ {
+		return nil, nil
+	}
+	exist, schema, err := db.GetSchemaByName(station.SchemaName, station.TenantName)
+	if err != nil || !exist {
+		return nil, err
+	}
+	version, err := getActiveVersionBySchemaId(schema.ID)
+	if err != nil {
+		return nil, err
+	}
+	key := strconv.Itoa(version.ID)
+	if decoder, ok := schemaDecodersCache.Load(key); ok {
+		return decoder, nil
+	}
+	references, err := resolveSchemaReferences(version.References, station.TenantName)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := compileSchemaDecoder(schema.Type, version.SchemaContent, version.MessageStructName, references)
+	if err != nil {
+		return nil, err
+	}
+	schemaDecodersCache.Add(key, decoder)
+	return decoder, nil
+}
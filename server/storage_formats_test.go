@@ -0,0 +1,419 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func testTieredRecords() []tieredRecord {
+	ts := time.Date(2026, 10, 17, 13, 5, 0, 0, time.UTC)
+	return []tieredRecord{
+		{Station: "orders", Partition: 1, Sequence: 10, Timestamp: ts, Headers: map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "b": "2"}, Payload: []byte(`{"id":1}`), Decoded: json.RawMessage(`{"id":1}`)},
+		{Station: "orders", Partition: 1, Sequence: 11, Timestamp: ts.Add(time.Second), Headers: map[string]string{}, Payload: []byte{0xff, 0x00}},
+	}
+}
+
+func TestTieredStorageObjectKey(t *testing.T) {
+	hour := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	key := tieredStorageObjectKey("tenant", "orders", hour, 2, 10, 12, tieredStorageFormatParquet)
+	if key != "memphis/tenant/orders/dt=2026-10-17/hour=09/2-00000000000000000010-00000000000000000012.parquet" {
+		t.Fatalf("unexpected key %v", key)
+	}
+	partition, firstSeq, lastSeq, ok := parseTieredStorageObjectRange(key)
+	if !ok || partition != 2 || firstSeq != 10 || lastSeq != 12 {
+		t.Fatalf("unexpected range %v %v %v %v", partition, firstSeq, lastSeq, ok)
+	}
+	if _, _, _, ok := parseTieredStorageObjectRange("memphis/tenant/orders/dt=2026-10-17/hour=09/uid(3).parquet"); ok {
+		t.Fatalf("expected nuid keys to have no range")
+	}
+}
+
+func TestEncodeTieredRecordsNdjson(t *testing.T) {
+	records := testTieredRecords()
+	for _, format := range []string{tieredStorageFormatNdjsonGzip, tieredStorageFormatNdjsonZstd} {
+		data, err := encodeTieredRecords(format, records, nil)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		var plain []byte
+		if format == tieredStorageFormatNdjsonGzip {
+			zr, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("gzip: %v", err)
+			}
+			plain, _ = readAllBytes(zr)
+		} else {
+			zr, err := zstd.NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("zstd: %v", err)
+			}
+			plain, _ = readAllBytes(zr)
+			zr.Close()
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(plain))
+		i := 0
+		for scanner.Scan() {
+			var record tieredRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Fatalf("%v: line %v: %v", format, i, err)
+			}
+			if record.Sequence != records[i].Sequence || !bytes.Equal(record.Payload, records[i].Payload) {
+				t.Fatalf("%v: record %v mismatch: %+v", format, i, record)
+			}
+			i++
+		}
+		if i != len(records) {
+			t.Fatalf("%v: expected %v lines, got %v", format, len(records), i)
+		}
+	}
+}
+
+func readAllBytes(r interface{ Read([]byte) (int, error) }) ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(r)
+	return buf.Bytes(), err
+}
+
+// thriftCompactReader decodes the compact protocol generically, structs become map[field id]value
+type thriftCompactReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftCompactReader) varint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftCompactReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftCompactReader) value(t byte) interface{} {
+	switch t {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 5, 6:
+		return r.zigzag()
+	case 8:
+		n := int(r.varint())
+		v := string(r.data[r.pos : r.pos+n])
+		r.pos += n
+		return v
+	case 9:
+		h := r.data[r.pos]
+		r.pos++
+		size := int(h >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(h & 0x0f)
+		}
+		return list
+	case 12:
+		fields := map[int16]interface{}{}
+		lastId := int16(0)
+		for {
+			h := r.data[r.pos]
+			r.pos++
+			if h == 0 {
+				return fields
+			}
+			id := lastId + int16(h>>4)
+			if h>>4 == 0 {
+				id = int16(r.zigzag())
+			}
+			fields[id] = r.value(h & 0x0f)
+			lastId = id
+		}
+	}
+	panic("unexpected thrift type")
+}
+
+// parquetTestColumn is a column chunk read back from a file
+type parquetTestColumn struct {
+	physical  int64
+	repLevels []int
+	defLevels []int
+	values    []interface{}
+}
+
+// readParquetLevels decodes the RLE/bit-packing hybrid encoding, including bit packed runs the writer doesn't produce
+func readParquetLevels(t *testing.T, data []byte, pos *int, count, maxLevel int) []int {
+	bitWidth := 0
+	for maxLevel>>bitWidth > 0 {
+		bitWidth++
+	}
+	end := *pos + 4 + int(binary.LittleEndian.Uint32(data[*pos:]))
+	r := &thriftCompactReader{data: data[:end], pos: *pos + 4}
+	levels := []int{}
+	for len(levels) < count {
+		header := r.varint()
+		if header&1 == 1 {
+			groups := int(header >> 1)
+			for i := 0; i < groups*8*bitWidth; i += bitWidth {
+				v := 0
+				for b := 0; b < bitWidth; b++ {
+					bit := i + b
+					v |= int(data[r.pos+bit/8]>>(bit%8)&1) << b
+				}
+				levels = append(levels, v)
+			}
+			r.pos += groups * bitWidth
+			continue
+		}
+		v := 0
+		for b := 0; b < (bitWidth+7)/8; b++ {
+			v |= int(data[r.pos]) << (8 * b)
+			r.pos++
+		}
+		for i := 0; i < int(header>>1); i++ {
+			levels = append(levels, v)
+		}
+	}
+	if r.pos != end {
+		t.Fatalf("levels have %v trailing bytes", end-r.pos)
+	}
+	*pos = end
+	return levels[:count]
+}
+
+// readParquetFile decodes the footer and every column chunk of a single row group file, columns are keyed by their dotted path
+func readParquetFile(t *testing.T, data []byte) (map[int16]interface{}, map[string]*parquetTestColumn) {
+	if string(data[:4]) != parquetMagic || string(data[len(data)-4:]) != parquetMagic {
+		t.Fatalf("missing parquet magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := data[len(data)-8-footerLen : len(data)-8]
+	r := &thriftCompactReader{data: footer}
+	meta := r.value(12).(map[int16]interface{})
+	if r.pos != len(footer) {
+		t.Fatalf("footer has %v trailing bytes", len(footer)-r.pos)
+	}
+
+	// max levels of every leaf from the repetition of its ancestors
+	type level struct{ rep, def int }
+	maxLevels := map[string]level{}
+	schema := meta[2].([]interface{})
+	var walk func(i int, prefix string, parent level) int
+	walk = func(i int, prefix string, parent level) int {
+		e := schema[i].(map[int16]interface{})
+		current := parent
+		if repetition, ok := e[3].(int64); ok && repetition == parquetRepetitionOptional {
+			current.def++
+		} else if ok && repetition == parquetRepetitionRepeated {
+			current.def++
+			current.rep++
+		}
+		name := e[4].(string)
+		if i > 0 {
+			prefix += name
+		}
+		children, _ := e[5].(int64)
+		if children == 0 {
+			maxLevels[prefix] = current
+			return i + 1
+		}
+		if i > 0 {
+			prefix += "."
+		}
+		next := i + 1
+		for c := 0; c < int(children); c++ {
+			next = walk(next, prefix, current)
+		}
+		return next
+	}
+	if walk(0, "", level{}) != len(schema) {
+		t.Fatalf("schema elements are not a single tree")
+	}
+
+	dec, _ := zstd.NewReader(nil)
+	defer dec.Close()
+	columns := map[string]*parquetTestColumn{}
+	rowGroup := meta[4].([]interface{})[0].(map[int16]interface{})
+	for _, chunk := range rowGroup[1].([]interface{}) {
+		chunkMeta := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+		path := []string{}
+		for _, p := range chunkMeta[3].([]interface{}) {
+			path = append(path, p.(string))
+		}
+		name := strings.Join(path, ".")
+		levels, ok := maxLevels[name]
+		if !ok {
+			t.Fatalf("column chunk %v is not in the schema", name)
+		}
+		numValues := int(chunkMeta[5].(int64))
+		offset := int(chunkMeta[9].(int64))
+		pr := &thriftCompactReader{data: data[offset:]}
+		pageHeader := pr.value(12).(map[int16]interface{})
+		page := data[offset+pr.pos : offset+pr.pos+int(pageHeader[3].(int64))]
+		if chunkMeta[4].(int64) == parquetCodecZstd {
+			var err error
+			page, err = dec.DecodeAll(page, nil)
+			if err != nil {
+				t.Fatalf("%v: decompress page: %v", name, err)
+			}
+		}
+		if len(page) != int(pageHeader[2].(int64)) {
+			t.Fatalf("%v: expected %v uncompressed bytes, got %v", name, pageHeader[2], len(page))
+		}
+
+		column := &parquetTestColumn{physical: chunkMeta[1].(int64)}
+		pos := 0
+		if levels.rep > 0 {
+			column.repLevels = readParquetLevels(t, page, &pos, numValues, levels.rep)
+		}
+		present := numValues
+		if levels.def > 0 {
+			column.defLevels = readParquetLevels(t, page, &pos, numValues, levels.def)
+			present = 0
+			for _, d := range column.defLevels {
+				if d == levels.def {
+					present++
+				}
+			}
+		}
+		for i := 0; i < present; i++ {
+			switch column.physical {
+			case parquetTypeBoolean:
+				column.values = append(column.values, page[pos+i/8]>>(i%8)&1 == 1)
+			case parquetTypeInt32:
+				column.values = append(column.values, int32(binary.LittleEndian.Uint32(page[pos:])))
+				pos += 4
+			case parquetTypeInt64:
+				column.values = append(column.values, int64(binary.LittleEndian.Uint64(page[pos:])))
+				pos += 8
+			case parquetTypeDouble:
+				column.values = append(column.values, math.Float64frombits(binary.LittleEndian.Uint64(page[pos:])))
+				pos += 8
+			case parquetTypeByteArray:
+				n := int(binary.LittleEndian.Uint32(page[pos:]))
+				column.values = append(column.values, string(page[pos+4:pos+4+n]))
+				pos += 4 + n
+			}
+		}
+		if column.physical == parquetTypeBoolean {
+			pos += (present + 7) / 8
+		}
+		if pos != len(page) {
+			t.Fatalf("%v: page has %v trailing bytes", name, len(page)-pos)
+		}
+		columns[name] = column
+	}
+	if len(columns) != len(maxLevels) {
+		t.Fatalf("expected %v column chunks, got %v", len(maxLevels), len(columns))
+	}
+	return meta, columns
+}
+
+func TestEncodeTieredRecordsParquet(t *testing.T) {
+	records := testTieredRecords()
+	records = append(records, tieredRecord{Station: "orders", Partition: 1, Sequence: 12, Timestamp: records[1].Timestamp, Headers: map[string]string{"a": "1"}, Payload: []byte("{}"),
+		Decoded: json.RawMessage(`{"id":"9007199254740993","price":1.5,"paid":true,"note":null,"items":[1, 2]}`)})
+	fields := []decodedField{{name: "id", kind: decodedFieldInt64}, {name: "price", kind: decodedFieldDouble}, {name: "paid", kind: decodedFieldBool}, {name: "note", kind: decodedFieldString}, {name: "items", kind: decodedFieldJson}}
+	data, err := encodeTieredRecords(tieredStorageFormatParquet, records, fields)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	meta, columns := readParquetFile(t, data)
+	if meta[3].(int64) != int64(len(records)) {
+		t.Fatalf("expected %v rows, got %v", len(records), meta[3])
+	}
+
+	expected := map[string]parquetTestColumn{
+		"station":   {values: []interface{}{"orders", "orders", "orders"}},
+		"partition": {values: []interface{}{int32(1), int32(1), int32(1)}},
+		"sequence":  {values: []interface{}{int64(10), int64(11), int64(12)}},
+		"timestamp": {values: []interface{}{records[0].Timestamp.UnixMilli(), records[1].Timestamp.UnixMilli(), records[2].Timestamp.UnixMilli()}},
+		"headers.key_value.key": {repLevels: []int{0, 1, 0, 0}, defLevels: []int{2, 2, 1, 2},
+			values: []interface{}{"b", "traceparent", "a"}},
+		"headers.key_value.value": {repLevels: []int{0, 1, 0, 0}, defLevels: []int{3, 3, 1, 3},
+			values: []interface{}{"2", records[0].Headers["traceparent"], "1"}},
+		"payload": {values: []interface{}{`{"id":1}`, string([]byte{0xff, 0x00}), "{}"}},
+		// the first record is decoded without the other fields, the second is not decoded at all
+		"decoded.id":    {defLevels: []int{2, 0, 2}, values: []interface{}{int64(1), int64(9007199254740993)}},
+		"decoded.price": {defLevels: []int{1, 0, 2}, values: []interface{}{1.5}},
+		"decoded.paid":  {defLevels: []int{1, 0, 2}, values: []interface{}{true}},
+		"decoded.note":  {defLevels: []int{1, 0, 1}},
+		"decoded.items": {defLevels: []int{1, 0, 2}, values: []interface{}{"[1,2]"}},
+	}
+	if len(columns) != len(expected) {
+		t.Fatalf("expected %v columns, got %v", len(expected), len(columns))
+	}
+	for name, e := range expected {
+		c, ok := columns[name]
+		if !ok {
+			t.Fatalf("missing column %v", name)
+		}
+		if !reflect.DeepEqual(c.repLevels, e.repLevels) || !reflect.DeepEqual(c.defLevels, e.defLevels) || !reflect.DeepEqual(c.values, e.values) {
+			t.Fatalf("column %v: expected %+v, got %+v", name, e, *c)
+		}
+	}
+
+	// without schema fields there is no decoded group
+	data, err = encodeTieredRecords(tieredStorageFormatParquet, records, nil)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if _, columns = readParquetFile(t, data); len(columns) != 7 {
+		t.Fatalf("expected the decoded group to be left out, got %v columns", len(columns))
+	}
+}
+
+func TestSchemaDecodedFields(t *testing.T) {
+	decoder, err := compileSchemaDecoder("protobuf", `syntax = "proto3";
+message Order {
+	int64 id = 1;
+	double price = 2;
+	bool paid = 3;
+	string note = 4;
+	repeated int32 items = 5;
+	uint64 total = 6;
+}`, "Order", nil)
+	if err != nil {
+		t.Fatalf("protobuf: %v", err)
+	}
+	expected := []decodedField{{"id", decodedFieldInt64}, {"price", decodedFieldDouble}, {"paid", decodedFieldBool}, {"note", decodedFieldString}, {"items", decodedFieldJson}, {"total", decodedFieldString}}
+	if !reflect.DeepEqual(decoder.fields, expected) {
+		t.Fatalf("unexpected protobuf fields %+v", decoder.fields)
+	}
+	// zero values are decoded so they are not written as nulls
+	decoded, err := decoder.decode(nil)
+	if err != nil || !strings.Contains(string(decoded), `"paid":false`) {
+		t.Fatalf("expected zero values to be decoded, got %s %v", decoded, err)
+	}
+
+	decoder, err = compileSchemaDecoder("avro", `{"type":"record","name":"order","fields":[{"name":"id","type":"long"},{"name":"note","type":["null","string"]},{"name":"tags","type":{"type":"array","items":"string"}}]}`, _EMPTY_, nil)
+	if err != nil {
+		t.Fatalf("avro: %v", err)
+	}
+	expected = []decodedField{{"id", decodedFieldInt64}, {"note", decodedFieldString}, {"tags", decodedFieldJson}}
+	if !reflect.DeepEqual(decoder.fields, expected) {
+		t.Fatalf("unexpected avro fields %+v", decoder.fields)
+	}
+
+	decoder, err = compileSchemaDecoder("json", `{"type":"object","properties":{"price":{"type":"number"},"id":{"type":"integer"},"note":{"type":["string","null"]},"meta":{"type":"object"}}}`, _EMPTY_, nil)
+	if err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	expected = []decodedField{{"id", decodedFieldInt64}, {"meta", decodedFieldJson}, {"note", decodedFieldString}, {"price", decodedFieldDouble}}
+	if !reflect.DeepEqual(decoder.fields, expected) {
+		t.Fatalf("unexpected json fields %+v", decoder.fields)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fsBackend stores tiered objects as files under a local directory or a mounted NFS share
+type fsBackend struct {
+	root string
+}
+
+func newFsBackend(keys map[string]string) (TieredStorageBackend, error) {
+	root := filepath.Clean(keys["path"])
+	if !filepath.IsAbs(root) {
+		return nil, errors.New("path has to be an absolute path")
+	}
+	if !isFsRootAllowed(root, configuration.TIERED_STORAGE_FS_ROOTS) {
+		return nil, fmt.Errorf("path %v is not under a directory allowed for tiered storage by the broker configuration", root)
+	}
+	return &fsBackend{root: root}, nil
+}
+
+// isFsRootAllowed reports whether the root is one of the comma separated directories the broker allows
+// for the filesystem backend or a directory under one of them, nothing is allowed when none are set
+func isFsRootAllowed(root, allowedRoots string) bool {
+	root = resolveFsPath(root)
+	for _, allowed := range strings.Split(allowedRoots, ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == _EMPTY_ || !filepath.IsAbs(allowed) {
+			continue
+		}
+		allowed = resolveFsPath(filepath.Clean(allowed))
+		if root == allowed || strings.HasPrefix(root, strings.TrimSuffix(allowed, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveFsPath follows symlinks in the part of the path that already exists so a link can not lead
+// the root out of an allowed directory
+func resolveFsPath(p string) string {
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...)
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return filepath.Join(append([]string{p}, missing...)...)
+		}
+		missing = append([]string{filepath.Base(p)}, missing...)
+		p = parent
+	}
+}
+
+func (b *fsBackend) objectPath(objectName string) (string, error) {
+	p := filepath.Join(b.root, filepath.FromSlash(objectName))
+	if p != b.root && !strings.HasPrefix(p, b.root+string(filepath.Separator)) {
+		return _EMPTY_, fmt.Errorf("invalid object name %v", objectName)
+	}
+	return p, nil
+}
+
+func (b *fsBackend) UploadObject(ctx context.Context, objectName string, data []byte) error {
+	p, err := b.objectPath(objectName)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(p), 0755)
+	if err != nil {
+		return err
+	}
+	// written to a temp file and renamed so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	err = os.Rename(tmp.Name(), p)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (b *fsBackend) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	var objects []string
+	err := filepath.WalkDir(b.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(b.root, p)
+		if err != nil {
+			return err
+		}
+		objectName := filepath.ToSlash(rel)
+		if strings.HasPrefix(objectName, prefix) {
+			objects = append(objects, objectName)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return objects, nil
+}
+
+func (b *fsBackend) ReadObject(ctx context.Context, objectName string) ([]byte, error) {
+	p, err := b.objectPath(objectName)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p)
+}
+
+func (b *fsBackend) Test(ctx context.Context) error {
+	err := os.MkdirAll(b.root, 0755)
+	if err != nil {
+		return fmt.Errorf("could not create directory %v: %v", b.root, err.Error())
+	}
+	objectName := "memphis-test"
+	err = b.UploadObject(ctx, objectName, []byte("test"))
+	if err != nil {
+		return fmt.Errorf("could not write to directory %v: %v", b.root, err.Error())
+	}
+	p, _ := b.objectPath(objectName)
+	err = os.Remove(p)
+	if err != nil {
+		return fmt.Errorf("could not delete files from directory %v: %v", b.root, err.Error())
+	}
+	return nil
+}
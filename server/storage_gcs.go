@@ -0,0 +1,251 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	gcsDefaultEndpoint = "https://storage.googleapis.com"
+	gcsDefaultTokenUri = "https://oauth2.googleapis.com/token"
+	gcsScope           = "https://www.googleapis.com/auth/devstorage.read_write"
+)
+
+type gcsServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenUri    string `json:"token_uri"`
+}
+
+type gcsToken struct {
+	accessToken string
+	expiresAt   time.Time
+}
+
+// access tokens are shared between backends built for the same service account, a backend is built per upload
+var gcsTokens = struct {
+	sync.Mutex
+	m map[string]gcsToken
+}{m: map[string]gcsToken{}}
+
+// gcsBackend talks to the GCS JSON API, secret_key holds the service account key file.
+// A custom url without a secret_key is meant for emulators such as fake-gcs-server which don't check auth.
+type gcsBackend struct {
+	endpoint       string
+	bucketName     string
+	serviceAccount *gcsServiceAccount
+	client         *http.Client
+}
+
+func newGcsBackend(keys map[string]string) (TieredStorageBackend, error) {
+	b := &gcsBackend{
+		endpoint:   strings.TrimSuffix(keys["url"], "/"),
+		bucketName: keys["bucket_name"],
+		client:     &http.Client{Timeout: tieredStorageBackendTimeout},
+	}
+	if b.endpoint == _EMPTY_ {
+		b.endpoint = gcsDefaultEndpoint
+	}
+	if keys["secret_key"] == _EMPTY_ {
+		if keys["url"] == _EMPTY_ {
+			return nil, errors.New("service account key is required")
+		}
+		return b, nil
+	}
+	var sa gcsServiceAccount
+	err := json.Unmarshal([]byte(keys["secret_key"]), &sa)
+	if err != nil || sa.ClientEmail == _EMPTY_ || sa.PrivateKey == _EMPTY_ {
+		return nil, errors.New("invalid service account key")
+	}
+	if sa.TokenUri == _EMPTY_ {
+		sa.TokenUri = gcsDefaultTokenUri
+	}
+	b.serviceAccount = &sa
+	return b, nil
+}
+
+func (b *gcsBackend) accessToken(ctx context.Context) (string, error) {
+	sa := b.serviceAccount
+	gcsTokens.Lock()
+	defer gcsTokens.Unlock()
+	if token, ok := gcsTokens.m[sa.ClientEmail]; ok && time.Now().Add(time.Minute).Before(token.expiresAt) {
+		return token.accessToken, nil
+	}
+
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(sa.PrivateKey))
+	if err != nil {
+		return _EMPTY_, errors.New("invalid service account private key")
+	}
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   sa.ClientEmail,
+		"scope": gcsScope,
+		"aud":   sa.TokenUri,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(privateKey)
+	if err != nil {
+		return _EMPTY_, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sa.TokenUri, strings.NewReader(form.Encode()))
+	if err != nil {
+		return _EMPTY_, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return _EMPTY_, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return _EMPTY_, fmt.Errorf("failed getting an access token for %v: %v %s", sa.ClientEmail, resp.Status, body)
+	}
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&tokenResp)
+	if err != nil {
+		return _EMPTY_, err
+	}
+	gcsTokens.m[sa.ClientEmail] = gcsToken{accessToken: tokenResp.AccessToken, expiresAt: now.Add(time.Duration(tokenResp.ExpiresIn) * time.Second)}
+	return tokenResp.AccessToken, nil
+}
+
+func (b *gcsBackend) do(ctx context.Context, method, reqUrl string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	if b.serviceAccount != nil {
+		token, err := b.accessToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var errResp struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Error.Message != _EMPTY_ {
+			return nil, fmt.Errorf("%v: %v", resp.Status, errResp.Error.Message)
+		}
+		return nil, fmt.Errorf("%v", resp.Status)
+	}
+	return resp, nil
+}
+
+func (b *gcsBackend) objectUrl(objectName string) string {
+	return fmt.Sprintf("%s/storage/v1/b/%s/o/%s", b.endpoint, url.PathEscape(b.bucketName), url.PathEscape(objectName))
+}
+
+func (b *gcsBackend) UploadObject(ctx context.Context, objectName string, data []byte) error {
+	query := url.Values{}
+	query.Set("uploadType", "media")
+	query.Set("name", objectName)
+	resp, err := b.do(ctx, http.MethodPost, fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", b.endpoint, url.PathEscape(b.bucketName), query.Encode()), data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (b *gcsBackend) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	var objects []string
+	pageToken := _EMPTY_
+	for {
+		query := url.Values{}
+		query.Set("prefix", prefix)
+		if pageToken != _EMPTY_ {
+			query.Set("pageToken", pageToken)
+		}
+		resp, err := b.do(ctx, http.MethodGet, fmt.Sprintf("%s/storage/v1/b/%s/o?%s", b.endpoint, url.PathEscape(b.bucketName), query.Encode()), nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			objects = append(objects, item.Name)
+		}
+		if page.NextPageToken == _EMPTY_ {
+			return objects, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+func (b *gcsBackend) ReadObject(ctx context.Context, objectName string) ([]byte, error) {
+	resp, err := b.do(ctx, http.MethodGet, b.objectUrl(objectName)+"?alt=media", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (b *gcsBackend) Test(ctx context.Context) error {
+	resp, err := b.do(ctx, http.MethodGet, fmt.Sprintf("%s/storage/v1/b/%s", b.endpoint, url.PathEscape(b.bucketName)), nil)
+	if err != nil {
+		return fmt.Errorf("could not access bucket %v: %v", b.bucketName, err.Error())
+	}
+	resp.Body.Close()
+	err = b.UploadObject(ctx, "memphis", []byte("test"))
+	if err != nil {
+		return fmt.Errorf("could not upload objects - %v", err.Error())
+	}
+	resp, err = b.do(ctx, http.MethodDelete, b.objectUrl("memphis"), nil)
+	if err != nil {
+		return fmt.Errorf("could not delete objects - %v", err.Error())
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,502 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// A minimal Parquet writer for tier 2 objects: a single row group, one PLAIN encoded data page (v1) per column,
+// levels encoded as RLE runs and the file metadata serialized with the Thrift compact protocol.
+// See https://github.com/apache/parquet-format for the format and the Thrift definitions the field ids come from.
+
+const (
+	parquetMagic = "PAR1"
+
+	parquetTypeBoolean   = 0
+	parquetTypeInt32     = 1
+	parquetTypeInt64     = 2
+	parquetTypeDouble    = 5
+	parquetTypeByteArray = 6
+
+	parquetRepetitionRequired = 0
+	parquetRepetitionOptional = 1
+	parquetRepetitionRepeated = 2
+
+	parquetConvertedUtf8            = 0
+	parquetConvertedMap             = 1
+	parquetConvertedMapKeyValue     = 2
+	parquetConvertedTimestampMillis = 9
+	parquetConvertedJson            = 19
+
+	parquetEncodingPlain = 0
+	parquetEncodingRle   = 3
+
+	parquetCodecUncompressed = 0
+	parquetCodecZstd         = 6
+
+	parquetPageTypeData = 0
+)
+
+// thrift compact protocol types
+const (
+	thriftTypeI32    = 5
+	thriftTypeI64    = 6
+	thriftTypeBinary = 8
+	thriftTypeList   = 9
+	thriftTypeStruct = 12
+)
+
+type thriftWriter struct {
+	buf     bytes.Buffer
+	lastIds []int16
+	lastId  int16
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	w.buf.Write(b[:n])
+}
+
+func (w *thriftWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *thriftWriter) fieldHeader(id int16, fieldType byte) {
+	if delta := id - w.lastId; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		w.buf.WriteByte(fieldType)
+		w.zigzag(int64(id))
+	}
+	w.lastId = id
+}
+
+func (w *thriftWriter) i32Field(id int16, v int32) {
+	w.fieldHeader(id, thriftTypeI32)
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) i64Field(id int16, v int64) {
+	w.fieldHeader(id, thriftTypeI64)
+	w.zigzag(v)
+}
+
+func (w *thriftWriter) binary(v string) {
+	w.varint(uint64(len(v)))
+	w.buf.WriteString(v)
+}
+
+func (w *thriftWriter) stringField(id int16, v string) {
+	w.fieldHeader(id, thriftTypeBinary)
+	w.binary(v)
+}
+
+func (w *thriftWriter) listHeader(size int, elemType byte) {
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		w.buf.WriteByte(0xf0 | elemType)
+		w.varint(uint64(size))
+	}
+}
+
+func (w *thriftWriter) listField(id int16, size int, elemType byte) {
+	w.fieldHeader(id, thriftTypeList)
+	w.listHeader(size, elemType)
+}
+
+func (w *thriftWriter) structBegin() {
+	w.lastIds = append(w.lastIds, w.lastId)
+	w.lastId = 0
+}
+
+func (w *thriftWriter) structField(id int16) {
+	w.fieldHeader(id, thriftTypeStruct)
+	w.structBegin()
+}
+
+func (w *thriftWriter) structEnd() {
+	w.buf.WriteByte(0)
+	w.lastId = w.lastIds[len(w.lastIds)-1]
+	w.lastIds = w.lastIds[:len(w.lastIds)-1]
+}
+
+type parquetSchemaElement struct {
+	name          string
+	physicalType  int32 // -1 for groups
+	repetition    int32 // -1 for the root
+	numChildren   int32
+	convertedType int32 // -1 when not set
+}
+
+// parquetColumn holds the levels and PLAIN encoded values of a leaf column
+type parquetColumn struct {
+	path      []string
+	physical  int32
+	maxRep    int
+	maxDef    int
+	repLevels []int
+	defLevels []int
+	values    bytes.Buffer
+	numBools  int
+}
+
+func (c *parquetColumn) int32Value(v int32) {
+	binary.Write(&c.values, binary.LittleEndian, v)
+}
+
+func (c *parquetColumn) int64Value(v int64) {
+	binary.Write(&c.values, binary.LittleEndian, v)
+}
+
+func (c *parquetColumn) doubleValue(v float64) {
+	binary.Write(&c.values, binary.LittleEndian, math.Float64bits(v))
+}
+
+// booleans are bit packed, least significant bit first
+func (c *parquetColumn) boolValue(v bool) {
+	if c.numBools%8 == 0 {
+		c.values.WriteByte(0)
+	}
+	if v {
+		b := c.values.Bytes()
+		b[len(b)-1] |= 1 << (c.numBools % 8)
+	}
+	c.numBools++
+}
+
+func (c *parquetColumn) byteArrayValue(v []byte) {
+	binary.Write(&c.values, binary.LittleEndian, uint32(len(v)))
+	c.values.Write(v)
+}
+
+// encodeParquetLevels writes levels with the RLE/bit-packing hybrid encoding using RLE runs only
+func encodeParquetLevels(buf *bytes.Buffer, levels []int, maxLevel int) {
+	bitWidth := 0
+	for maxLevel>>bitWidth > 0 {
+		bitWidth++
+	}
+	byteWidth := (bitWidth + 7) / 8
+	var encoded bytes.Buffer
+	var tmp [binary.MaxVarintLen64]byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		n := binary.PutUvarint(tmp[:], uint64(j-i)<<1)
+		encoded.Write(tmp[:n])
+		for b := 0; b < byteWidth; b++ {
+			encoded.WriteByte(byte(levels[i] >> (8 * b)))
+		}
+		i = j
+	}
+	binary.Write(buf, binary.LittleEndian, uint32(encoded.Len()))
+	buf.Write(encoded.Bytes())
+}
+
+type parquetFile struct {
+	schema  []parquetSchemaElement
+	columns []*parquetColumn
+	numRows int64
+	// compress is applied to every page, nil leaves pages uncompressed
+	compress func([]byte) ([]byte, error)
+}
+
+func (f *parquetFile) codec() int32 {
+	if f.compress == nil {
+		return parquetCodecUncompressed
+	}
+	return parquetCodecZstd
+}
+
+func (f *parquetFile) encode() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString(parquetMagic)
+
+	type chunkMeta struct {
+		offset           int64
+		numValues        int64
+		uncompressedSize int64
+		compressedSize   int64
+	}
+	chunks := make([]chunkMeta, len(f.columns))
+	totalSize := int64(0)
+	for i, c := range f.columns {
+		var page bytes.Buffer
+		if c.maxRep > 0 {
+			encodeParquetLevels(&page, c.repLevels, c.maxRep)
+		}
+		if c.maxDef > 0 {
+			encodeParquetLevels(&page, c.defLevels, c.maxDef)
+		}
+		page.Write(c.values.Bytes())
+		numValues := int64(len(c.defLevels))
+		if c.maxDef == 0 {
+			numValues = f.numRows
+		}
+
+		data := page.Bytes()
+		if f.compress != nil {
+			compressed, err := f.compress(data)
+			if err != nil {
+				return nil, err
+			}
+			data = compressed
+		}
+		var header thriftWriter
+		header.structBegin()
+		header.i32Field(1, parquetPageTypeData)
+		header.i32Field(2, int32(page.Len()))
+		header.i32Field(3, int32(len(data)))
+		header.structField(5)
+		header.i32Field(1, int32(numValues))
+		header.i32Field(2, parquetEncodingPlain)
+		header.i32Field(3, parquetEncodingRle)
+		header.i32Field(4, parquetEncodingRle)
+		header.structEnd()
+		header.structEnd()
+
+		chunks[i] = chunkMeta{
+			offset:           int64(out.Len()),
+			numValues:        numValues,
+			uncompressedSize: int64(header.buf.Len() + page.Len()),
+			compressedSize:   int64(header.buf.Len() + len(data)),
+		}
+		totalSize += chunks[i].uncompressedSize
+		out.Write(header.buf.Bytes())
+		out.Write(data)
+	}
+
+	var meta thriftWriter
+	meta.structBegin()
+	meta.i32Field(1, 1)
+	meta.listField(2, len(f.schema), thriftTypeStruct)
+	for _, e := range f.schema {
+		meta.structBegin()
+		if e.physicalType >= 0 {
+			meta.i32Field(1, e.physicalType)
+		}
+		if e.repetition >= 0 {
+			meta.i32Field(3, e.repetition)
+		}
+		meta.stringField(4, e.name)
+		if e.numChildren > 0 {
+			meta.i32Field(5, e.numChildren)
+		}
+		if e.convertedType >= 0 {
+			meta.i32Field(6, e.convertedType)
+		}
+		meta.structEnd()
+	}
+	meta.i64Field(3, f.numRows)
+	meta.listField(4, 1, thriftTypeStruct)
+	meta.structBegin()
+	meta.listField(1, len(f.columns), thriftTypeStruct)
+	for i, c := range f.columns {
+		meta.structBegin()
+		meta.i64Field(2, chunks[i].offset)
+		meta.structField(3)
+		meta.i32Field(1, c.physical)
+		meta.listField(2, 2, thriftTypeI32)
+		meta.zigzag(parquetEncodingPlain)
+		meta.zigzag(parquetEncodingRle)
+		meta.listField(3, len(c.path), thriftTypeBinary)
+		for _, p := range c.path {
+			meta.binary(p)
+		}
+		meta.i32Field(4, f.codec())
+		meta.i64Field(5, chunks[i].numValues)
+		meta.i64Field(6, chunks[i].uncompressedSize)
+		meta.i64Field(7, chunks[i].compressedSize)
+		meta.i64Field(9, chunks[i].offset)
+		meta.structEnd()
+		meta.structEnd()
+	}
+	meta.i64Field(2, totalSize)
+	meta.i64Field(3, f.numRows)
+	meta.structEnd()
+	meta.stringField(6, "memphis")
+	meta.structEnd()
+
+	out.Write(meta.buf.Bytes())
+	binary.Write(&out, binary.LittleEndian, uint32(meta.buf.Len()))
+	out.WriteString(parquetMagic)
+	return out.Bytes(), nil
+}
+
+// encodeTieredRecordsParquet writes the records with the columns station, partition, sequence, timestamp,
+// headers (map<string,string>), payload and decoded, an optional group of the typed schema fields
+func encodeTieredRecordsParquet(records []tieredRecord, fields []decodedField, compress func([]byte) ([]byte, error)) ([]byte, error) {
+	numChildren := int32(6)
+	if len(fields) > 0 {
+		numChildren++
+	}
+	f := &parquetFile{
+		numRows:  int64(len(records)),
+		compress: compress,
+		schema: []parquetSchemaElement{
+			{name: "schema", physicalType: -1, repetition: -1, numChildren: numChildren, convertedType: -1},
+			{name: "station", physicalType: parquetTypeByteArray, repetition: parquetRepetitionRequired, convertedType: parquetConvertedUtf8},
+			{name: "partition", physicalType: parquetTypeInt32, repetition: parquetRepetitionRequired, convertedType: -1},
+			{name: "sequence", physicalType: parquetTypeInt64, repetition: parquetRepetitionRequired, convertedType: -1},
+			{name: "timestamp", physicalType: parquetTypeInt64, repetition: parquetRepetitionRequired, convertedType: parquetConvertedTimestampMillis},
+			{name: "headers", physicalType: -1, repetition: parquetRepetitionOptional, numChildren: 1, convertedType: parquetConvertedMap},
+			{name: "key_value", physicalType: -1, repetition: parquetRepetitionRepeated, numChildren: 2, convertedType: parquetConvertedMapKeyValue},
+			{name: "key", physicalType: parquetTypeByteArray, repetition: parquetRepetitionRequired, convertedType: parquetConvertedUtf8},
+			{name: "value", physicalType: parquetTypeByteArray, repetition: parquetRepetitionOptional, convertedType: parquetConvertedUtf8},
+			{name: "payload", physicalType: parquetTypeByteArray, repetition: parquetRepetitionRequired, convertedType: -1},
+		},
+	}
+	station := &parquetColumn{path: []string{"station"}, physical: parquetTypeByteArray}
+	partition := &parquetColumn{path: []string{"partition"}, physical: parquetTypeInt32}
+	sequence := &parquetColumn{path: []string{"sequence"}, physical: parquetTypeInt64}
+	timestamp := &parquetColumn{path: []string{"timestamp"}, physical: parquetTypeInt64}
+	headerKeys := &parquetColumn{path: []string{"headers", "key_value", "key"}, physical: parquetTypeByteArray, maxRep: 1, maxDef: 2}
+	headerValues := &parquetColumn{path: []string{"headers", "key_value", "value"}, physical: parquetTypeByteArray, maxRep: 1, maxDef: 3}
+	payload := &parquetColumn{path: []string{"payload"}, physical: parquetTypeByteArray}
+	f.columns = []*parquetColumn{station, partition, sequence, timestamp, headerKeys, headerValues, payload}
+
+	decoded := make([]*parquetColumn, len(fields))
+	if len(fields) > 0 {
+		f.schema = append(f.schema, parquetSchemaElement{name: "decoded", physicalType: -1, repetition: parquetRepetitionOptional, numChildren: int32(len(fields)), convertedType: -1})
+		for i, field := range fields {
+			physical, convertedType := parquetDecodedFieldType(field.kind)
+			f.schema = append(f.schema, parquetSchemaElement{name: field.name, physicalType: physical, repetition: parquetRepetitionOptional, convertedType: convertedType})
+			decoded[i] = &parquetColumn{path: []string{"decoded", field.name}, physical: physical, maxDef: 2}
+			f.columns = append(f.columns, decoded[i])
+		}
+	}
+
+	for _, r := range records {
+		station.byteArrayValue([]byte(r.Station))
+		partition.int32Value(int32(r.Partition))
+		sequence.int64Value(int64(r.Sequence))
+		timestamp.int64Value(r.Timestamp.UnixMilli())
+
+		if len(r.Headers) == 0 {
+			// an empty map: headers is defined while key_value is not
+			headerKeys.repLevels = append(headerKeys.repLevels, 0)
+			headerKeys.defLevels = append(headerKeys.defLevels, 1)
+			headerValues.repLevels = append(headerValues.repLevels, 0)
+			headerValues.defLevels = append(headerValues.defLevels, 1)
+		} else {
+			keys := make([]string, 0, len(r.Headers))
+			for k := range r.Headers {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for i, k := range keys {
+				rep := 0
+				if i > 0 {
+					rep = 1
+				}
+				headerKeys.repLevels = append(headerKeys.repLevels, rep)
+				headerKeys.defLevels = append(headerKeys.defLevels, 2)
+				headerKeys.byteArrayValue([]byte(k))
+				headerValues.repLevels = append(headerValues.repLevels, rep)
+				headerValues.defLevels = append(headerValues.defLevels, 3)
+				headerValues.byteArrayValue([]byte(r.Headers[k]))
+			}
+		}
+
+		payload.byteArrayValue(r.Payload)
+
+		if len(fields) == 0 {
+			continue
+		}
+		// messages that were not decoded leave the group undefined, fields missing from a message are null
+		var values map[string]json.RawMessage
+		if r.Decoded == nil || json.Unmarshal(r.Decoded, &values) != nil {
+			for _, c := range decoded {
+				c.defLevels = append(c.defLevels, 0)
+			}
+			continue
+		}
+		for i, field := range fields {
+			if decoded[i].decodedValue(field.kind, values[field.name]) {
+				decoded[i].defLevels = append(decoded[i].defLevels, 2)
+			} else {
+				decoded[i].defLevels = append(decoded[i].defLevels, 1)
+			}
+		}
+	}
+	return f.encode()
+}
+
+func parquetDecodedFieldType(kind int) (int32, int32) {
+	switch kind {
+	case decodedFieldInt64:
+		return parquetTypeInt64, -1
+	case decodedFieldDouble:
+		return parquetTypeDouble, -1
+	case decodedFieldBool:
+		return parquetTypeBoolean, -1
+	case decodedFieldString:
+		return parquetTypeByteArray, parquetConvertedUtf8
+	default:
+		return parquetTypeByteArray, parquetConvertedJson
+	}
+}
+
+// decodedValue writes a json value to a decoded field column, it returns false when the value is null
+// or can not be represented by the column type, quoted numbers are accepted since protobuf quotes 64 bit integers
+func (c *parquetColumn) decodedValue(kind int, raw json.RawMessage) bool {
+	if len(raw) == 0 || string(raw) == "null" {
+		return false
+	}
+	var str string
+	quoted := json.Unmarshal(raw, &str) == nil
+	if !quoted {
+		str = string(raw)
+	}
+	switch kind {
+	case decodedFieldInt64:
+		v, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			// integers written as 1.0 or 1e3
+			f, err := strconv.ParseFloat(str, 64)
+			if err != nil || f != math.Trunc(f) || f >= math.MaxInt64 || f < math.MinInt64 {
+				return false
+			}
+			v = int64(f)
+		}
+		c.int64Value(v)
+	case decodedFieldDouble:
+		v, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return false
+		}
+		c.doubleValue(v)
+	case decodedFieldBool:
+		if quoted || (str != "true" && str != "false") {
+			return false
+		}
+		c.boolValue(str == "true")
+	case decodedFieldString:
+		c.byteArrayValue([]byte(str))
+	default:
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, raw); err != nil {
+			return false
+		}
+		c.byteArrayValue(compacted.Bytes())
+	}
+	return true
+}
@@ -14,11 +14,10 @@ package server
 import (
 	"bytes"
 	"context"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-
+	"io"
 	"strconv"
 	"strings"
 
@@ -38,6 +37,8 @@ type TieredStorageMsg struct {
 	Buf         []byte `json:"buf"`
 	StationName string `json:"station_name"`
 	TenantName  string `json:"tenant_name"`
+	Sequence    uint64 `json:"seq,omitempty"`
+	Time        int64  `json:"time,omitempty"`
 }
 
 func cacheDetailsS3(keys map[string]interface{}, properties map[string]bool, tenantName string) {
@@ -81,7 +82,7 @@ func (it IntegrationsHandler) handleCreateS3Integration(tenantName string, keys
 		return models.Integration{}, statusCode, err
 	}
 
-	keysMap, properties := createIntegrationsKeysAndProperties("s3", _EMPTY_, _EMPTY_, false, false, false, keys["access_key"].(string), keys["secret_key"].(string), keys["bucket_name"].(string), keys["region"].(string), keys["url"].(string), keys["s3_path_style"].(string), map[string]interface{}{}, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_)
+	keysMap, properties := createIntegrationsKeysAndProperties("s3", _EMPTY_, _EMPTY_, false, false, false, false, keys["access_key"].(string), keys["secret_key"].(string), keys["bucket_name"].(string), keys["region"].(string), keys["url"].(string), keys["s3_path_style"].(string), map[string]interface{}{}, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_)
 	s3Integration, err := createS3Integration(tenantName, keysMap, properties)
 	if err != nil {
 		if strings.Contains(err.Error(), "already exists") {
@@ -99,7 +100,7 @@ func (it IntegrationsHandler) handleUpdateS3Integration(tenantName string, body
 		return models.Integration{}, statusCode, err
 	}
 	integrationType := strings.ToLower(body.Name)
-	keysMap, properties := createIntegrationsKeysAndProperties(integrationType, _EMPTY_, _EMPTY_, false, false, false, keys["access_key"].(string), keys["secret_key"].(string), keys["bucket_name"].(string), keys["region"].(string), keys["url"].(string), keys["s3_path_style"].(string), map[string]interface{}{}, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_)
+	keysMap, properties := createIntegrationsKeysAndProperties(integrationType, _EMPTY_, _EMPTY_, false, false, false, false, keys["access_key"].(string), keys["secret_key"].(string), keys["bucket_name"].(string), keys["region"].(string), keys["url"].(string), keys["s3_path_style"].(string), map[string]interface{}{}, _EMPTY_, _EMPTY_, _EMPTY_, _EMPTY_)
 	s3Integration, err := updateS3Integration(tenantName, keysMap, properties)
 	if err != nil {
 		return s3Integration, 500, err
@@ -366,95 +367,84 @@ type Msg struct {
 	Headers map[string]string `json:"headers"`
 }
 
-func (s *Server) uploadToS3Storage(tenantName string, tenant map[string][]StoredMsg) error {
-	for k, msgs := range tenant {
-		var credentialsMap models.Integration
-		if tenantIntegrations, ok := IntegrationsConcurrentCache.Load(tenantName); !ok {
-			continue
-		} else {
-			if credentialsMap, ok = tenantIntegrations["s3"].(models.Integration); !ok {
-				continue
-			}
-		}
-		provider := credentials.NewStaticCredentialsProvider(
-			credentialsMap.Keys["access_key"].(string),
-			credentialsMap.Keys["secret_key"].(string),
-			_EMPTY_,
-		)
+type s3Backend struct {
+	svc        *s3.Client
+	bucketName string
+	url        string
+}
 
-		region := credentialsMap.Keys["region"]
-		url := credentialsMap.Keys["url"]
-		pathStyle, _ := strconv.ParseBool(credentialsMap.Keys["s3_path_style"].(string))
+func newS3Backend(keys map[string]string) (TieredStorageBackend, error) {
+	provider := credentials.NewStaticCredentialsProvider(keys["access_key"], keys["secret_key"], _EMPTY_)
+	_, err := provider.Retrieve(context.Background())
+	if err != nil {
+		return nil, errors.New("invalid s3 credentials")
+	}
 
-		_, err := provider.Retrieve(context.Background())
-		if err != nil {
-			err = errors.New("uploadToS3Storage: Invalid credentials")
-			return err
-		}
-		cfg, err := awsconfig.LoadDefaultConfig(context.Background(),
-			awsconfig.WithCredentialsProvider(provider),
-			awsconfig.WithRegion(credentialsMap.Keys["region"].(string)),
-			awsconfig.WithEndpointResolverWithOptions(getS3EndpointResolver(region.(string), url.(string))),
-		)
-		svc := s3.NewFromConfig(cfg, func(o *s3.Options) {
-			o.UsePathStyle = pathStyle
-		})
-		if err != nil {
-			err = errors.New("uploadToS3Storage failure " + err.Error())
-			return err
-		}
-		uploader := manager.NewUploader(svc)
-		uid := serv.memphis.nuid.Next()
-		var objectName string
-
-		var messages []Msg
-		size := int64(0)
-		for _, msg := range msgs {
-			if tenantName == serv.MemphisGlobalAccountString() {
-				tenantName = "global"
-			}
-			objectName = "memphis/" + tenantName + "/" + k + "/" + uid + "(" + strconv.Itoa(len(msgs)) + ").json"
-			var headers string
-			hdrs := map[string]string{}
-			if len(msg.Header) > 0 {
-				headers = strings.ToLower(string(msg.Header))
-				headersSplit := strings.Split(headers, CR_LF)
-				for _, header := range headersSplit {
-					if header != _EMPTY_ && !strings.Contains(header, "nats") {
-						keyVal := strings.Split(header, ":")
-						key := strings.TrimSpace(keyVal[0])
-						value := strings.TrimSpace(keyVal[1])
-						hdrs[key] = value
-					}
-				}
-			} else {
-				headers = _EMPTY_
-			}
+	pathStyle, _ := strconv.ParseBool(keys["s3_path_style"])
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background(),
+		awsconfig.WithCredentialsProvider(provider),
+		awsconfig.WithRegion(keys["region"]),
+		awsconfig.WithEndpointResolverWithOptions(getS3EndpointResolver(keys["region"], keys["url"])),
+	)
+	if err != nil {
+		return nil, err
+	}
+	svc := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.UsePathStyle = pathStyle
+	})
+	return &s3Backend{svc: svc, bucketName: keys["bucket_name"], url: keys["url"]}, nil
+}
 
-			encodedMsg := hex.EncodeToString(msg.Data)
-			message := Msg{Payload: encodedMsg, Headers: hdrs}
-			messages = append(messages, message)
-			size += int64(len(msg.Data)) + int64(len(msg.Header))
-		}
-		// Upload the object to S3.
-		var buf bytes.Buffer
-		err = json.NewEncoder(&buf).Encode(messages)
+func (b *s3Backend) UploadObject(ctx context.Context, objectName string, data []byte) error {
+	_, err := manager.NewUploader(b.svc).Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(b.bucketName),
+		Key:    aws.String(objectName),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func (b *s3Backend) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	var objects []string
+	paginator := s3.NewListObjectsV2Paginator(b.svc, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.bucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		_, err = uploader.Upload(context.Background(), &s3.PutObjectInput{
-			Bucket: aws.String(credentialsMap.Keys["bucket_name"].(string)),
-			Key:    aws.String(objectName),
-			Body:   &buf,
-		})
-		if err != nil {
-			err = errors.New("uploadToS3Storage: failed to upload object to S3: " + err.Error())
-			return err
+		for _, object := range page.Contents {
+			objects = append(objects, aws.ToString(object.Key))
 		}
-		IncrementEventCounter(tenantName, "tiered", size, int64(len(messages)), _EMPTY_, []byte{}, []byte{})
-		serv.Noticef("new file has been uploaded to S3: %s", objectName)
 	}
+	return objects, nil
+}
 
-	return nil
+func (b *s3Backend) ReadObject(ctx context.Context, objectName string) ([]byte, error) {
+	res, err := b.svc.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucketName),
+		Key:    aws.String(objectName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return io.ReadAll(res.Body)
+}
 
+func (b *s3Backend) Test(ctx context.Context) error {
+	_, err := testS3Integration(b.svc, b.bucketName, b.url)
+	return err
+}
+
+// getTenantS3Uploader builds an uploader from the tenant's s3 integration and returns it with the integration bucket
+func getTenantS3Uploader(tenantName string) (*manager.Uploader, string, error) {
+	backend, err := getTenantTieredStorageBackend(tenantName, "s3")
+	if err != nil {
+		return nil, _EMPTY_, err
+	}
+	b := backend.(*s3Backend)
+	return manager.NewUploader(b.svc), b.bucketName, nil
 }
@@ -0,0 +1,474 @@
+// Copyright 2022-2023 The Memphis.dev Authors
+// Licensed under the Memphis Business Source License 1.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// Changed License: [Apache License, Version 2.0 (https://www.apache.org/licenses/LICENSE-2.0), as published by the Apache Foundation.
+//
+// https://github.com/memphisdev/memphis/blob/master/LICENSE
+//
+// Additional Use Grant: You may make use of the Licensed Work (i) only as part of your own product or service, provided it is not a message broker or a message queue product or service; and (ii) provided that you do not use, provide, distribute, or make available the Licensed Work as a Service.
+// A "Service" is a commercial offering, product, hosted, or managed service, that allows third parties (other than your own employees and contractors acting on your behalf) to access and/or use the Licensed Work or a substantial set of the features or functionality of the Licensed Work to third parties as a software-as-a-service, platform-as-a-service, infrastructure-as-a-service or other similar services that compete with Licensor products or services.
+package tracing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+
+	exportInterval  = 5 * time.Second
+	exportBatchSize = 512
+	queueSize       = 4096
+	exportTimeout   = 10 * time.Second
+)
+
+type SpanKind int
+
+// values follow the OTLP span kind enum
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+	SpanKindProducer SpanKind = 4
+	SpanKindConsumer SpanKind = 5
+)
+
+// SpanContext is the W3C trace context carried in the traceparent and tracestate headers
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Flags      byte
+	TraceState string
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&0x01 == 0x01
+}
+
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), sc.Flags)
+}
+
+// ParseTraceparent parses a version 00 traceparent header, unknown future versions are read
+// the same way as the spec asks for
+func ParseTraceparent(traceparent, tracestate string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.Flags = byte(flags)
+	sc.TraceState = strings.TrimSpace(tracestate)
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+// FromHeaders extracts the trace context out of message headers, header names are matched case insensitively
+func FromHeaders(headers map[string]string) (SpanContext, bool) {
+	var traceparent, tracestate string
+	for k, v := range headers {
+		switch strings.ToLower(k) {
+		case TraceparentHeader:
+			traceparent = v
+		case TracestateHeader:
+			tracestate = v
+		}
+	}
+	if traceparent == "" {
+		return SpanContext{}, false
+	}
+	return ParseTraceparent(traceparent, tracestate)
+}
+
+// Inject replaces the trace context of the headers with sc
+func (sc SpanContext) Inject(headers map[string]string) {
+	if !sc.IsValid() || headers == nil {
+		return
+	}
+	for k := range headers {
+		switch strings.ToLower(k) {
+		case TraceparentHeader, TracestateHeader:
+			delete(headers, k)
+		}
+	}
+	headers[TraceparentHeader] = sc.Traceparent()
+	if sc.TraceState != "" {
+		headers[TracestateHeader] = sc.TraceState
+	}
+}
+
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+type Span struct {
+	mu           sync.Mutex
+	name         string
+	kind         SpanKind
+	sc           SpanContext
+	parentSpanID [8]byte
+	start        time.Time
+	end          time.Time
+	attributes   []attribute
+	errMsg       string
+	recording    bool
+	ended        bool
+}
+
+// Start creates a child span of parent, or a new trace when parent is not valid.
+// When the exporter is disabled the returned span is not recorded and carries the parent context
+// unchanged, so propagating it never points consumers at a span that was never exported.
+func Start(name string, kind SpanKind, parent SpanContext) *Span {
+	if exp.Load() == nil {
+		return &Span{sc: parent}
+	}
+	sp := &Span{name: name, kind: kind, start: time.Now(), recording: true}
+	if parent.IsValid() {
+		sp.sc.TraceID = parent.TraceID
+		sp.sc.Flags = parent.Flags
+		sp.sc.TraceState = parent.TraceState
+		sp.parentSpanID = parent.SpanID
+		sp.recording = parent.IsSampled()
+	} else {
+		rand.Read(sp.sc.TraceID[:])
+		sp.sc.Flags = 0x01
+	}
+	rand.Read(sp.sc.SpanID[:])
+	return sp
+}
+
+func (sp *Span) Context() SpanContext {
+	return sp.sc
+}
+
+// SetAttribute supports string, bool and integer values, anything else is recorded as its string form
+func (sp *Span) SetAttribute(key string, value interface{}) {
+	if !sp.recording {
+		return
+	}
+	sp.mu.Lock()
+	sp.attributes = append(sp.attributes, attribute{key: key, value: value})
+	sp.mu.Unlock()
+}
+
+func (sp *Span) SetError(err error) {
+	if !sp.recording || err == nil {
+		return
+	}
+	sp.mu.Lock()
+	sp.errMsg = err.Error()
+	sp.mu.Unlock()
+}
+
+func (sp *Span) End() {
+	if !sp.recording {
+		return
+	}
+	sp.mu.Lock()
+	if sp.ended {
+		sp.mu.Unlock()
+		return
+	}
+	sp.ended = true
+	sp.end = time.Now()
+	sp.mu.Unlock()
+	if e := exp.Load(); e != nil {
+		e.enqueue(sp)
+	}
+}
+
+type Config struct {
+	// Endpoint is the OTLP/HTTP collector address, /v1/traces is appended when no path is given
+	Endpoint       string
+	Headers        map[string]string
+	ServiceName    string
+	ServiceVersion string
+	InstanceID     string
+}
+
+type exporter struct {
+	url      string
+	headers  map[string]string
+	resource []otlpKeyValue
+	client   *http.Client
+	queue    chan *Span
+	quit     chan struct{}
+	done     chan struct{}
+	onError  func(error)
+}
+
+var exp atomic.Pointer[exporter]
+
+func Enabled() bool {
+	return exp.Load() != nil
+}
+
+// Initialize starts the OTLP exporter, an empty endpoint keeps tracing disabled
+func Initialize(cfg Config, onError func(error)) error {
+	if cfg.Endpoint == "" {
+		return nil
+	}
+	if !strings.HasPrefix(cfg.Endpoint, "http://") && !strings.HasPrefix(cfg.Endpoint, "https://") {
+		return errors.New("otlp endpoint has to start with http:// or https://")
+	}
+	url := strings.TrimSuffix(cfg.Endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "memphis"
+	}
+	if onError == nil {
+		onError = func(error) {}
+	}
+	resource := []otlpKeyValue{newKeyValue("service.name", cfg.ServiceName)}
+	if cfg.ServiceVersion != "" {
+		resource = append(resource, newKeyValue("service.version", cfg.ServiceVersion))
+	}
+	if cfg.InstanceID != "" {
+		resource = append(resource, newKeyValue("service.instance.id", cfg.InstanceID))
+	}
+	e := &exporter{
+		url:      url,
+		headers:  cfg.Headers,
+		resource: resource,
+		client:   &http.Client{Timeout: exportTimeout},
+		queue:    make(chan *Span, queueSize),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+		onError:  onError,
+	}
+	if !exp.CompareAndSwap(nil, e) {
+		return errors.New("tracing is already initialized")
+	}
+	go e.run()
+	return nil
+}
+
+// Close flushes the spans waiting in the queue and stops the exporter
+func Close() {
+	e := exp.Swap(nil)
+	if e == nil {
+		return
+	}
+	close(e.quit)
+	<-e.done
+}
+
+// ParseHeaders reads the comma separated key=value list used by OTEL_EXPORTER_OTLP_HEADERS
+func ParseHeaders(value string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		k, v, found := strings.Cut(pair, "=")
+		if !found || strings.TrimSpace(k) == "" {
+			continue
+		}
+		headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return headers
+}
+
+func (e *exporter) enqueue(sp *Span) {
+	select {
+	case e.queue <- sp:
+	default: // the collector is not keeping up, dropping is better than blocking the hot path
+	}
+}
+
+func (e *exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, exportBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			e.onError(err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case sp := <-e.queue:
+			batch = append(batch, sp)
+			if len(batch) >= exportBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.quit:
+			for {
+				select {
+				case sp := <-e.queue:
+					batch = append(batch, sp)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *exporter) export(spans []*Span) error {
+	body, err := json.Marshal(e.buildRequest(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed exporting %v spans: %v", len(spans), err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed exporting %v spans: collector responded with %v", len(spans), resp.Status)
+	}
+	return nil
+}
+
+// OTLP/JSON request types, ids are hex encoded and timestamps are strings as the protocol requires
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func newKeyValue(key string, value interface{}) otlpKeyValue {
+	kv := otlpKeyValue{Key: key}
+	switch v := value.(type) {
+	case string:
+		kv.Value.StringValue = &v
+	case bool:
+		kv.Value.BoolValue = &v
+	case int:
+		s := strconv.FormatInt(int64(v), 10)
+		kv.Value.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		kv.Value.IntValue = &s
+	case uint64:
+		s := strconv.FormatUint(v, 10)
+		kv.Value.IntValue = &s
+	default:
+		s := fmt.Sprint(v)
+		kv.Value.StringValue = &s
+	}
+	return kv
+}
+
+func (e *exporter) buildRequest(spans []*Span) otlpTraceRequest {
+	scope := otlpScopeSpans{Spans: make([]otlpSpan, 0, len(spans))}
+	scope.Scope.Name = "memphis"
+	for _, sp := range spans {
+		sp.mu.Lock()
+		s := otlpSpan{
+			TraceID:           hex.EncodeToString(sp.sc.TraceID[:]),
+			SpanID:            hex.EncodeToString(sp.sc.SpanID[:]),
+			TraceState:        sp.sc.TraceState,
+			Name:              sp.name,
+			Kind:              int(sp.kind),
+			StartTimeUnixNano: strconv.FormatInt(sp.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(sp.end.UnixNano(), 10),
+			Status:            otlpStatus{Code: 1},
+		}
+		if sp.parentSpanID != [8]byte{} {
+			s.ParentSpanID = hex.EncodeToString(sp.parentSpanID[:])
+		}
+		for _, a := range sp.attributes {
+			s.Attributes = append(s.Attributes, newKeyValue(a.key, a.value))
+		}
+		if sp.errMsg != "" {
+			s.Status = otlpStatus{Code: 2, Message: sp.errMsg}
+		}
+		sp.mu.Unlock()
+		scope.Spans = append(scope.Spans, s)
+	}
+	rs := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scope}}
+	rs.Resource.Attributes = e.resource
+	return otlpTraceRequest{ResourceSpans: []otlpResourceSpans{rs}}
+}
@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "congo=t61rcWkgMzE")
+	if !ok {
+		t.Fatalf("expected a valid traceparent")
+	}
+	if !sc.IsSampled() || sc.TraceState != "congo=t61rcWkgMzE" {
+		t.Fatalf("unexpected span context %+v", sc)
+	}
+	if got := sc.Traceparent(); got != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Fatalf("traceparent round trip mismatch: %v", got)
+	}
+
+	for _, tp := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+	} {
+		if _, ok := ParseTraceparent(tp, ""); ok {
+			t.Fatalf("expected %q to be rejected", tp)
+		}
+	}
+}
+
+func TestInjectReplacesExistingContext(t *testing.T) {
+	headers := map[string]string{"Traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "key": "value"}
+	parent, ok := FromHeaders(headers)
+	if !ok {
+		t.Fatalf("expected trace context in headers")
+	}
+	sc := parent
+	sc.SpanID = [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
+	sc.Inject(headers)
+	if _, exists := headers["Traceparent"]; exists {
+		t.Fatalf("expected the old traceparent header to be replaced")
+	}
+	if headers[TraceparentHeader] != "00-4bf92f3577b34da6a3ce929d0e0e4736-0102030405060708-01" || headers["key"] != "value" {
+		t.Fatalf("unexpected headers %v", headers)
+	}
+}
+
+func TestDisabledSpanKeepsParent(t *testing.T) {
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "")
+	span := Start("produce", SpanKindProducer, parent)
+	span.SetAttribute("key", "value")
+	span.End()
+	if span.Context() != parent {
+		t.Fatalf("expected the parent context to pass through when tracing is disabled")
+	}
+}
+
+func TestExportSpans(t *testing.T) {
+	received := make(chan otlpTraceRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var req otlpTraceRequest
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		received <- req
+	}))
+	defer srv.Close()
+
+	if err := Initialize(Config{Endpoint: srv.URL, Headers: ParseHeaders("Authorization=Bearer token")}, func(err error) { t.Error(err) }); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "")
+	span := Start("produce", SpanKindProducer, parent)
+	span.SetAttribute("messaging.destination.name", "orders")
+	span.End()
+	if span.Context().TraceID != parent.TraceID || span.Context().SpanID == parent.SpanID {
+		t.Fatalf("expected a child span of the parent")
+	}
+	Close()
+
+	req := <-received
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 || spans[0].ParentSpanID != "00f067aa0ba902b7" || spans[0].TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || spans[0].Kind != int(SpanKindProducer) {
+		t.Fatalf("unexpected exported spans %+v", spans)
+	}
+}